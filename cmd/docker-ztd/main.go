@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/app"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
@@ -16,11 +19,11 @@ func main() {
 		return
 	}
 
+	logFormat := cli.LogFormatFromArgs(os.Args[1:])
 	cfg, err := cli.Parse(os.Args[1:])
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		fmt.Print(cli.Usage())
-		os.Exit(1)
+		cfg.LogFormat = logFormat
+		exitWithError(cfg, err, true)
 	}
 
 	if cfg.ShowHelp {
@@ -28,16 +31,74 @@ func main() {
 		return
 	}
 
-	if cfg.Service == "" && cfg.Action != cli.ActionAutoRun {
-		fmt.Fprintln(os.Stderr, "SERVICE is missing")
-		fmt.Print(cli.Usage())
-		os.Exit(1)
+	if cfg.PrintConfig {
+		if err := app.PrintConfig(os.Stdout, cfg); err != nil {
+			exitWithError(cfg, err, false)
+		}
+		return
+	}
+
+	if cfg.Service == "" && cfg.Action != cli.ActionAutoRun && !cfg.DownAll {
+		exitWithError(cfg, errors.New("SERVICE is missing"), true)
+	}
+
+	log := logging.NewLogger(loggingOptions(cfg))
+	if cfg.Output == cli.OutputJSON {
+		// stdout carries only the deployment result.
+		log.SetOutput(os.Stderr)
+	}
+	for _, warning := range cfg.Warnings {
+		log.Warnf("==> %s", warning)
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		// A second signal kills the process instead of waiting for the rollback.
+		signal.Reset(os.Interrupt, syscall.SIGTERM)
+		log.Warn("==> Received interrupt; rolling back in-flight deployment (send again to force quit)")
+		cancel()
+	}()
 
-	log := logging.NewLogger()
 	runner := app.NewRunner(log)
-	if err := runner.Run(context.Background(), cfg); err != nil {
+	err = runner.RunServices(ctx, cfg)
+	if err == nil {
+		return
+	}
+	log.Error(err.Error())
+	switch {
+	case errors.Is(err, app.ErrBreakerOpen):
+		os.Exit(app.ExitBreakerOpen)
+	case ctx.Err() != nil:
+		os.Exit(app.ExitInterrupted)
+	}
+	os.Exit(1)
+}
+
+// exitWithError reports an error raised before the deploy starts and exits with 1. With
+// --log-format=json it is logged as a JSON line on stderr; otherwise it is printed,
+// followed by the usage when withUsage is set.
+func exitWithError(cfg cli.Config, err error, withUsage bool) {
+	if cfg.LogFormat == cli.LogFormatJSON {
+		log := logging.NewLogger(loggingOptions(cfg))
+		log.SetOutput(os.Stderr)
 		log.Error(err.Error())
 		os.Exit(1)
 	}
+	fmt.Fprintln(os.Stderr, err.Error())
+	if withUsage {
+		fmt.Print(cli.Usage())
+	}
+	os.Exit(1)
+}
+
+func loggingOptions(cfg cli.Config) logging.Options {
+	return logging.Options{
+		TimestampFormat: cfg.TimestampFormat,
+		Color:           cfg.Color,
+		Format:          cfg.LogFormat,
+		Level:           cfg.LogLevel,
+	}
 }
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+)
+
+// ErrBreakerOpen is returned when too many recent rollbacks block new deploys.
+var ErrBreakerOpen = errors.New("deploy circuit-breaker is open")
+
+// ExitBreakerOpen is the exit code for ErrBreakerOpen, so deploy loops can tell a
+// refused deploy from a failed one.
+const ExitBreakerOpen = 3
+
+// checkBreaker refuses the deploy while the breaker of cfg.Service in its compose
+// project is tripped and clears it once the cooldown has passed.
+func (r *Runner) checkBreaker(cfg cli.Config, store *state.Store, now time.Time) error {
+	project := composeProjectName(cfg)
+	breaker, err := store.LoadBreaker(project, cfg.Service)
+	if err != nil {
+		return err
+	}
+	if until := breaker.OpenUntil(cfg.BreakerCooldown); !until.IsZero() {
+		if now.Before(until) {
+			return fmt.Errorf("%w for service %s: more than %d rollbacks within %s; retry after %s or run with --reset-breaker",
+				ErrBreakerOpen, cfg.Service, cfg.MaxRollbacks, cfg.RollbackWindow, until.Format(time.RFC3339))
+		}
+		r.log.Infof("==> Circuit-breaker for service '%s' cooled down; allowing deploys again", cfg.Service)
+		if cfg.DryRun {
+			return nil
+		}
+		return store.ResetBreaker(project, cfg.Service)
+	}
+	breaker.Prune(now, cfg.RollbackWindow)
+	if len(breaker.Rollbacks) > 0 {
+		r.log.Infof("==> Service '%s' rolled back %d time(s) in the last %s (breaker trips above %d)", cfg.Service, len(breaker.Rollbacks), cfg.RollbackWindow, cfg.MaxRollbacks)
+	}
+	return nil
+}
+
+// recordRollback adds a rollback of cfg.Service to its breaker history and trips
+// the breaker when the window holds more than cfg.MaxRollbacks of them.
+func (r *Runner) recordRollback(cfg cli.Config, store *state.Store, now time.Time) error {
+	project := composeProjectName(cfg)
+	breaker, err := store.LoadBreaker(project, cfg.Service)
+	if err != nil {
+		return err
+	}
+	breaker.Prune(now, cfg.RollbackWindow)
+	breaker.Rollbacks = append(breaker.Rollbacks, now)
+	if len(breaker.Rollbacks) > cfg.MaxRollbacks && breaker.TrippedAt == nil {
+		breaker.TrippedAt = &now
+		r.log.Warnf("==> Circuit-breaker tripped for service '%s': %d rollbacks within %s; deploys are refused for %s",
+			cfg.Service, len(breaker.Rollbacks), cfg.RollbackWindow, cfg.BreakerCooldown)
+	}
+	return store.SaveBreaker(project, cfg.Service, breaker)
+}
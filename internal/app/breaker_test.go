@@ -0,0 +1,90 @@
+package app
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+)
+
+func TestBreaker_TripsAfterMaxRollbacksAndCoolsDown(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	store := state.NewStore(t.TempDir())
+	cfg := cli.Config{ProjectName: "shop", Service: "api", MaxRollbacks: 2, RollbackWindow: time.Hour, BreakerCooldown: 30 * time.Minute}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if err := runner.recordRollback(cfg, store, now.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("record rollback: %v", err)
+		}
+	}
+	if err := runner.checkBreaker(cfg, store, now.Add(5*time.Minute)); err != nil {
+		t.Fatalf("expected breaker to stay closed at max rollbacks, got %v", err)
+	}
+
+	if err := runner.recordRollback(cfg, store, now.Add(10*time.Minute)); err != nil {
+		t.Fatalf("record rollback: %v", err)
+	}
+	err := runner.checkBreaker(cfg, store, now.Add(20*time.Minute))
+	if !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("expected ErrBreakerOpen, got %v", err)
+	}
+
+	if err := runner.checkBreaker(cfg, store, now.Add(41*time.Minute)); err != nil {
+		t.Fatalf("expected breaker to close after cooldown, got %v", err)
+	}
+	breaker, err := store.LoadBreaker("shop", "api")
+	if err != nil {
+		t.Fatalf("load breaker: %v", err)
+	}
+	if breaker.TrippedAt != nil || len(breaker.Rollbacks) != 0 {
+		t.Fatalf("expected breaker history to be cleared, got %+v", breaker)
+	}
+}
+
+func TestBreaker_IgnoresRollbacksOutsideWindow(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	store := state.NewStore(t.TempDir())
+	cfg := cli.Config{ProjectName: "shop", Service: "api", MaxRollbacks: 1, RollbackWindow: 10 * time.Minute, BreakerCooldown: time.Hour}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := runner.recordRollback(cfg, store, now); err != nil {
+		t.Fatalf("record rollback: %v", err)
+	}
+	if err := runner.recordRollback(cfg, store, now.Add(20*time.Minute)); err != nil {
+		t.Fatalf("record rollback: %v", err)
+	}
+	if err := runner.checkBreaker(cfg, store, now.Add(21*time.Minute)); err != nil {
+		t.Fatalf("expected old rollback to be pruned, got %v", err)
+	}
+}
+
+func TestBreaker_KeyedByProject(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	store := state.NewStore(t.TempDir())
+	shop := cli.Config{ProjectName: "shop", Service: "web", MaxRollbacks: 0, RollbackWindow: time.Hour, BreakerCooldown: time.Hour}
+	blog := shop
+	blog.ProjectName = "blog"
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := runner.recordRollback(shop, store, now); err != nil {
+		t.Fatalf("record rollback: %v", err)
+	}
+	if err := runner.checkBreaker(shop, store, now.Add(time.Minute)); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("expected the breaker of shop/web to be open, got %v", err)
+	}
+	if err := runner.checkBreaker(blog, store, now.Add(time.Minute)); err != nil {
+		t.Fatalf("expected blog/web to have its own breaker, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
+)
+
+type downOps interface {
+	Labels(ctx context.Context, containerID string) (map[string]string, error)
+	Stop(ctx context.Context, containerIDs []string) error
+	Remove(ctx context.Context, containerIDs []string) error
+}
+
+// runDown tears down the requested services, or every compose service with --all.
+func (r *Runner) runDown(ctx context.Context, cfg cli.Config) error {
+	cfg.TraefikConfigFile = resolveTraefikConfigPath(cfg.ProjectDirectory, cfg.TraefikConfigFile)
+	services := cfg.Services
+	if cfg.DownAll {
+		var err error
+		if services, err = collectComposeServices(cfg.ComposeFiles, cfg.EnvFiles); err != nil {
+			return fmt.Errorf("failed to read compose services: %w", err)
+		}
+	}
+	if len(cfg.ComposeFiles) > 0 {
+		for _, service := range services {
+			if err := compose.ValidateComposeFiles(cfg.ComposeFiles, service); err != nil {
+				return err
+			}
+		}
+	}
+
+	composeAdapter, err := selectComposeAdapter(cfg, r.log)
+	if err != nil {
+		return err
+	}
+	dockerClient := docker.NewClient(cfg.DockerArgs).WithMaxRetries(cfg.MaxRetries).WithStopConcurrency(cfg.StopConcurrency).WithStopTimeout(cfg.StopTimeout)
+	composeAdapter = compose.NewIgnoreFilter(composeAdapter, dockerClient).WithServiceLabel(cfg.ServiceLabel).WithProject(composeProjectName(cfg))
+	for _, service := range services {
+		if err := r.downService(ctx, cfg, composeAdapter, dockerClient, service); err != nil {
+			return fmt.Errorf("%s: %w", service, err)
+		}
+	}
+
+	store := state.NewStore(filepath.Join(cfg.ProjectDirectory, state.DefaultStateDir))
+	if _, err := store.DeleteByServiceNames(services); err != nil {
+		return fmt.Errorf("failed to clear service state: %w", err)
+	}
+	return nil
+}
+
+// downService removes the Traefik entries of service first, so no request is routed
+// to a container that is being stopped, then stops and removes its containers.
+func (r *Runner) downService(ctx context.Context, cfg cli.Config, adapter compose.Adapter, ops downOps, service string) error {
+	ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, service)
+	if err != nil {
+		return err
+	}
+	var labels map[string]string
+	if len(ids) > 0 {
+		if labels, err = ops.Labels(ctx, ids[0]); err != nil {
+			return err
+		}
+	}
+
+	removed := false
+	if !cfg.NoProxy {
+		if removed, err = traefik.RemoveService(cfg.TraefikConfigFile, cfg.ComposeFiles, cfg.EnvFiles, service, labels); err != nil {
+			return fmt.Errorf("failed to update traefik config: %w", err)
+		}
+	}
+	if removed {
+		r.log.Infof("==> Removed routers and services of '%s' from %s", service, cfg.TraefikConfigFile)
+		if err := proxyReloader(cfg).Reload(ctx, r.log, proxy.TypeTraefik); err != nil {
+			return err
+		}
+	}
+
+	if len(ids) == 0 {
+		r.log.Infof("==> Service '%s' has no running containers", service)
+		return nil
+	}
+	r.log.Infof("==> Stopping %d container(s) of service '%s'", len(ids), service)
+	if err := ops.Stop(ctx, ids); err != nil {
+		return fmt.Errorf("failed to stop containers: %w", err)
+	}
+	if err := ops.Remove(ctx, ids); err != nil {
+		return fmt.Errorf("failed to remove containers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+type downDockerMock struct {
+	calls []string
+}
+
+func (m *downDockerMock) Labels(context.Context, string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (m *downDockerMock) Stop(_ context.Context, ids []string) error {
+	m.calls = append(m.calls, "stop "+strings.Join(ids, ","))
+	return nil
+}
+
+func (m *downDockerMock) Remove(_ context.Context, ids []string) error {
+	m.calls = append(m.calls, "rm "+strings.Join(ids, ","))
+	return nil
+}
+
+func TestDownService_RemovesRoutesAndContainers(t *testing.T) {
+	dir := t.TempDir()
+	composeFile := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(composeFile, []byte("services:\n  api:\n    labels:\n      traefik.enable: \"true\"\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	traefikFile := filepath.Join(dir, "dynamic_conf.yml")
+	content := "http:\n  routers:\n    api:\n      service: api\n    web:\n      service: web\n  services:\n    api:\n      loadBalancer:\n        servers:\n          - url: http://c1:80\n    web:\n      loadBalancer:\n        servers:\n          - url: http://c3:80\n"
+	if err := os.WriteFile(traefikFile, []byte(content), 0o644); err != nil {
+		t.Fatalf("write traefik config: %v", err)
+	}
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	cfg := cli.Config{ComposeFiles: []string{composeFile}, TraefikConfigFile: traefikFile}
+	docker := &downDockerMock{}
+	if err := runner.downService(context.Background(), cfg, &versionComposeMock{ids: []string{"c1", "c2"}}, docker, "api"); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+
+	if strings.Join(docker.calls, "; ") != "stop c1,c2; rm c1,c2" {
+		t.Fatalf("unexpected docker calls: %v", docker.calls)
+	}
+	data, err := os.ReadFile(traefikFile)
+	if err != nil {
+		t.Fatalf("read traefik config: %v", err)
+	}
+	if strings.Contains(string(data), "c1:80") || !strings.Contains(string(data), "c3:80") {
+		t.Fatalf("expected only api entries to be removed:\n%s", data)
+	}
+}
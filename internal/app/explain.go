@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
+)
+
+// runExplain prints how the labels of cfg.Service map into the generated Traefik
+// config. It reads containers and compose files only and never writes state.
+func (r *Runner) runExplain(ctx context.Context, cfg cli.Config) error {
+	composeAdapter, err := selectComposeAdapter(cfg, r.log)
+	if err != nil {
+		return err
+	}
+	dockerClient := docker.NewClient(cfg.DockerArgs).WithMaxRetries(cfg.MaxRetries)
+	composeAdapter = compose.NewIgnoreFilter(composeAdapter, dockerClient).WithServiceLabel(cfg.ServiceLabel).WithProject(composeProjectName(cfg))
+	generator := traefik.NewGenerator(composeAdapter, dockerClient).WithDefaultProxy(cfg.ProxyType).WithServiceLabel(cfg.ServiceLabel)
+
+	entries, err := generator.Explain(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil {
+		return err
+	}
+	r.log.Infof("==> Label mapping for service '%s':", cfg.Service)
+	for _, entry := range entries {
+		label := entry.Label
+		if label == "" {
+			label = "(derived)"
+		}
+		switch {
+		case entry.Target == "":
+			r.log.Infof("    %s=%s -> ignored: %s", label, entry.Value, entry.Note)
+		case entry.Note != "":
+			r.log.Infof("    %s=%s -> %s (%s)", label, entry.Value, entry.Target, entry.Note)
+		default:
+			r.log.Infof("    %s=%s -> %s", label, entry.Value, entry.Target)
+		}
+	}
+	return nil
+}
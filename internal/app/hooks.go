@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/hooks"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
+)
+
+// loadHooks reads the x-ztd-hooks block of the service; --pre-deploy, --post-deploy
+// and --pre-stop override the matching entries. Fail-fast restores run no hooks.
+func loadHooks(cfg cli.Config) (hooks.Hooks, error) {
+	if cfg.RestoreImage != "" {
+		return hooks.Hooks{}, nil
+	}
+	serviceHooks, err := hooks.Load(cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil {
+		return hooks.Hooks{}, fmt.Errorf("failed to read x-ztd-hooks: %w", err)
+	}
+	if cfg.PreDeployHook != "" {
+		serviceHooks.PreDeploy = cfg.PreDeployHook
+	}
+	if cfg.PostDeployHook != "" {
+		serviceHooks.PostDeploy = cfg.PostDeployHook
+	}
+	if cfg.PreStopHook != "" {
+		serviceHooks.PreStop = cfg.PreStopHook
+	}
+	return serviceHooks, nil
+}
+
+// runHook runs the hook command registered for name, if any. The hook sees the
+// variables of the env files and the deploy context as ZTD_* environment variables;
+// deployErr is exposed to on-rollback and extraEnv is added as is.
+func (r *Runner) runHook(ctx context.Context, cfg cli.Config, serviceHooks hooks.Hooks, name string, deployID string, deployErr error, extraEnv ...string) error {
+	command := serviceHooks.Command(name)
+	if command == "" {
+		return nil
+	}
+	if cfg.DryRun {
+		r.log.Infof("==> [dry-run] Would run %s hook for service '%s': %s", name, cfg.Service, command)
+		return nil
+	}
+	fileEnv, err := compose.LoadEnv(cfg.ComposeFiles, cfg.EnvFiles)
+	if err != nil {
+		return fmt.Errorf("%s hook for service %s: %w", name, cfg.Service, err)
+	}
+	env := make([]string, 0, len(fileEnv)+8)
+	for key, value := range fileEnv {
+		env = append(env, key+"="+value)
+	}
+	env = append(env,
+		"ZTD_HOOK="+name,
+		"ZTD_SERVICE="+cfg.Service,
+		"ZTD_STRATEGY="+cfg.Strategy,
+		"ZTD_ACTION="+cfg.Action,
+		"ZTD_DEPLOY_ID="+deployID,
+		"ZTD_COMPOSE_FILES="+strings.Join(cfg.ComposeFiles, ","),
+	)
+	env = append(env, extraEnv...)
+	if deployErr != nil {
+		env = append(env, "ZTD_ERROR="+deployErr.Error(), "ZTD_FAILURE_REASON="+string(safeguard.ReasonOf(deployErr)))
+	}
+	r.log.Infof("==> Running %s hook for service '%s': %s", name, cfg.Service, command)
+	if err := hooks.Run(ctx, command, env, r.log.Out); err != nil {
+		return fmt.Errorf("%s hook failed for service %s: %w", name, cfg.Service, err)
+	}
+	return nil
+}
+
+// preStopHook returns the callback a rolling deploy runs against the old containers
+// right before stopping them, or nil without a pre-stop hook. Its failure is only
+// logged: the new containers already serve traffic at that point.
+func (r *Runner) preStopHook(cfg cli.Config, serviceHooks hooks.Hooks, deployID string) func(ctx context.Context, containerIDs []string) {
+	if serviceHooks.PreStop == "" {
+		return nil
+	}
+	return func(ctx context.Context, containerIDs []string) {
+		if err := r.runHook(ctx, cfg, serviceHooks, hooks.PreStop, deployID, nil, "ZTD_CONTAINERS="+strings.Join(containerIDs, " ")); err != nil {
+			r.log.WithError(err).Warn("==> pre-stop hook failed, stopping old containers anyway")
+		}
+	}
+}
+
+// finishHookTimeout bounds post-deploy and on-rollback, which run detached from the
+// deploy context so an interrupt or an expired --deploy-timeout does not kill them.
+const finishHookTimeout = 5 * time.Minute
+
+// runFinishHooks runs post-deploy after a successful deploy and on-rollback after a
+// failed deploy whose new containers were rolled back or an explicit canary
+// rollback/abort. Hook failures are only logged: the new containers already serve
+// traffic after a deploy.
+func (r *Runner) runFinishHooks(ctx context.Context, cfg cli.Config, serviceHooks hooks.Hooks, deployID string, deployErr error) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishHookTimeout)
+	defer cancel()
+	switch {
+	case deployErr == nil && cfg.Action == cli.ActionDeploy:
+		if err := r.runHook(ctx, cfg, serviceHooks, hooks.PostDeploy, deployID, nil); err != nil {
+			r.log.WithError(err).Warn("==> post-deploy hook failed, the deploy is kept")
+		}
+	case deployErr == nil && (cfg.Action == cli.ActionRollback || cfg.Action == cli.ActionAbort),
+		deployErr != nil && safeguard.RolledBack(deployErr):
+		if err := r.runHook(ctx, cfg, serviceHooks, hooks.OnRollback, deployID, deployErr); err != nil {
+			r.log.WithError(err).Warn("==> on-rollback hook failed")
+		}
+	}
+	return deployErr
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/hooks"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
+)
+
+func TestRunFinishHooks(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	out := filepath.Join(t.TempDir(), "hooks.log")
+	serviceHooks := hooks.Hooks{
+		PostDeploy: `echo "post $ZTD_SERVICE" >> ` + out,
+		OnRollback: `echo "rollback $ZTD_FAILURE_REASON" >> ` + out,
+	}
+	cfg := cli.Config{Service: "api", Strategy: cli.StrategyRolling}
+
+	if err := runner.runFinishHooks(context.Background(), cfg, serviceHooks, "id", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	deployErr := safeguard.WithRollback(safeguard.ReasonUnhealthy, errors.New("boom"))
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := runner.runFinishHooks(cancelled, cfg, serviceHooks, "id", deployErr); err != deployErr {
+		t.Fatalf("expected deploy error to be returned, got %v", err)
+	}
+	kept := safeguard.WithReason(safeguard.ReasonHealthcheckTimeout, errors.New("kept"))
+	if err := runner.runFinishHooks(context.Background(), cfg, serviceHooks, "id", kept); err != kept {
+		t.Fatalf("expected deploy error to be returned, got %v", err)
+	}
+	if err := runner.runFinishHooks(context.Background(), cfg, serviceHooks, "id", errors.New("no reason")); err == nil {
+		t.Fatal("expected deploy error to be returned")
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read hook output: %v", err)
+	}
+	want := "post api\nrollback " + string(safeguard.ReasonUnhealthy) + "\n"
+	if string(data) != want {
+		t.Fatalf("expected %q, got %q", want, string(data))
+	}
+
+	serviceHooks.PostDeploy = "exit 1"
+	var logs strings.Builder
+	log.SetOutput(&logs)
+	if err := runner.runFinishHooks(context.Background(), cfg, serviceHooks, "id", nil); err != nil {
+		t.Fatalf("expected a failing post-deploy hook to only warn, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "post-deploy hook failed") {
+		t.Fatalf("expected a post-deploy warning, got %q", logs.String())
+	}
+}
+
+func TestLoadHooks_FlagsOverrideComposeHooks(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "compose.yml")
+	content := "services:\n  api:\n    x-ztd-hooks:\n      pre-deploy: ./migrate.sh\n      post-deploy: ./notify.sh\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	cfg := cli.Config{Service: "api", ComposeFiles: []string{file}, PostDeployHook: "./warm-cache.sh", PreStopHook: "./drain.sh"}
+
+	got, err := loadHooks(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := hooks.Hooks{PreDeploy: "./migrate.sh", PostDeploy: "./warm-cache.sh", PreStop: "./drain.sh"}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestRunHook_InheritsEnvFilesAndPreStopContainers(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "app.env")
+	if err := os.WriteFile(envFile, []byte("DB_URL=postgres://db/app\n"), 0o644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	out := filepath.Join(dir, "hooks.log")
+	cfg := cli.Config{Service: "api", Strategy: cli.StrategyRolling, EnvFiles: []string{envFile}}
+	serviceHooks := hooks.Hooks{PreStop: `echo "$DB_URL $ZTD_HOOK $ZTD_CONTAINERS" >> ` + out}
+
+	runner.preStopHook(cfg, serviceHooks, "id")(context.Background(), []string{"old-1", "old-2"})
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read hook output: %v", err)
+	}
+	if want := "postgres://db/app pre-stop old-1 old-2\n"; string(data) != want {
+		t.Fatalf("expected %q, got %q", want, string(data))
+	}
+	if runner.preStopHook(cfg, hooks.Hooks{}, "id") != nil {
+		t.Fatal("expected no pre-stop callback without a hook")
+	}
+}
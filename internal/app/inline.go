@@ -0,0 +1,34 @@
+package app
+
+import (
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
+
+// prepareInlineCompose synthesizes the compose file for an --image deploy and
+// points cfg at it, so the rest of the flow runs unchanged. The returned func removes
+// the file of a dry run, which is written outside the project directory.
+func (r *Runner) prepareInlineCompose(cfg cli.Config) (cli.Config, func(), error) {
+	if cfg.ProjectDirectory == "" {
+		// Keep the project name derived from the current directory rather than
+		// from the directory holding the synthesized file.
+		cfg.ProjectDirectory = "."
+	}
+	dir, cleanup, err := scratchDir(cfg, cfg.ProjectDirectory)
+	if err != nil {
+		return cfg, func() {}, err
+	}
+	path, err := compose.WriteInlineComposeFile(dir, compose.InlineService{
+		Name:  cfg.Service,
+		Image: cfg.Image,
+		Port:  cfg.ImagePort,
+		Rule:  cfg.ImageRule,
+	})
+	if err != nil {
+		cleanup()
+		return cfg, func() {}, err
+	}
+	r.log.Infof("==> Deploying image %s as service '%s' from generated %s", cfg.Image, cfg.Service, path)
+	cfg.ComposeFiles = []string{path}
+	return cfg, cleanup, nil
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
+
+// Service labels that set deploy options per service. Options given on the command
+// line or in --config take precedence.
+const (
+	LabelTimeout          = "com.ztd.timeout"
+	LabelWait             = "com.ztd.wait"
+	LabelWaitAfterHealthy = "com.ztd.wait-after-healthy"
+	LabelNetwork          = "com.ztd.network"
+)
+
+// Short label names accepted for the same options; the com.ztd.* label wins when a
+// service sets both.
+const (
+	LabelTimeoutAlias          = "ztd.healthcheck.timeout"
+	LabelWaitAlias             = "ztd.wait"
+	LabelWaitAfterHealthyAlias = "ztd.wait-after-healthy"
+)
+
+type labelReader interface {
+	Labels(ctx context.Context, containerID string) (map[string]string, error)
+}
+
+// applyLabelOptions overrides the defaults of cfg with the com.ztd.* (or ztd.*) option
+// labels of the running containers of cfg.Service. Services that are not running keep cfg.
+func (r *Runner) applyLabelOptions(ctx context.Context, cfg cli.Config, adapter compose.Adapter, docker labelReader) (cli.Config, error) {
+	ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil || len(ids) == 0 {
+		return cfg, err
+	}
+	labels, err := docker.Labels(ctx, ids[0])
+	if err != nil {
+		return cfg, err
+	}
+
+	for _, opt := range []struct {
+		option string
+		labels []string
+		target *int
+	}{
+		{"timeout", []string{LabelTimeout, LabelTimeoutAlias}, &cfg.HealthcheckTimeout},
+		{"wait", []string{LabelWait, LabelWaitAlias}, &cfg.NoHealthcheckTimeout},
+		{"wait-after-healthy", []string{LabelWaitAfterHealthy, LabelWaitAfterHealthyAlias}, &cfg.WaitAfterHealthy},
+	} {
+		if cfg.ExplicitOptions[opt.option] {
+			continue
+		}
+		for _, label := range opt.labels {
+			value, ok := labels[label]
+			if !ok {
+				continue
+			}
+			n, err := cli.ParseSeconds(value)
+			if err != nil || n < 0 {
+				return cfg, fmt.Errorf("invalid %s label on service %s: %q is not a number of seconds or a duration", label, cfg.Service, value)
+			}
+			*opt.target = n
+			r.log.Infof("==> Using --%s=%d from label %s", opt.option, n, label)
+			break
+		}
+	}
+	if network := strings.TrimSpace(labels[LabelNetwork]); network != "" && cfg.Network == "" {
+		cfg.Network = network
+		r.log.Infof("==> Using --network=%s from label %s", network, LabelNetwork)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+func TestApplyLabelOptions_CommandLineWins(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	adapter := &versionComposeMock{ids: []string{"c1"}}
+	docker := &versionDockerMock{containerLabels: map[string]map[string]string{
+		"c1": {LabelTimeout: "2m", LabelWait: "30", LabelWaitAfterHealthy: "5"},
+	}}
+	cfg := cli.Config{
+		Service:              "api",
+		HealthcheckTimeout:   cli.DefaultHealthcheckTimeout,
+		NoHealthcheckTimeout: 15,
+		ExplicitOptions:      map[string]bool{"wait": true},
+	}
+
+	cfg, err := runner.applyLabelOptions(context.Background(), cfg, adapter, docker)
+	if err != nil {
+		t.Fatalf("apply labels: %v", err)
+	}
+	if cfg.HealthcheckTimeout != 120 || cfg.NoHealthcheckTimeout != 15 || cfg.WaitAfterHealthy != 5 {
+		t.Fatalf("unexpected options: timeout=%d wait=%d wait-after-healthy=%d", cfg.HealthcheckTimeout, cfg.NoHealthcheckTimeout, cfg.WaitAfterHealthy)
+	}
+
+	docker.containerLabels["c1"][LabelTimeout] = "soon"
+	if _, err := runner.applyLabelOptions(context.Background(), cli.Config{Service: "api"}, adapter, docker); err == nil {
+		t.Fatal("expected an invalid label value to fail")
+	}
+}
+
+func TestApplyLabelOptions_ShortLabelNames(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	adapter := &versionComposeMock{ids: []string{"c1"}}
+	docker := &versionDockerMock{containerLabels: map[string]map[string]string{
+		"c1": {LabelTimeoutAlias: "90", LabelWaitAlias: "20", LabelWaitAfterHealthy: "3", LabelWaitAfterHealthyAlias: "9"},
+	}}
+
+	cfg, err := NewRunner(log).applyLabelOptions(context.Background(), cli.Config{Service: "api"}, adapter, docker)
+	if err != nil {
+		t.Fatalf("apply labels: %v", err)
+	}
+	if cfg.HealthcheckTimeout != 90 || cfg.NoHealthcheckTimeout != 20 || cfg.WaitAfterHealthy != 3 {
+		t.Fatalf("unexpected options: timeout=%d wait=%d wait-after-healthy=%d", cfg.HealthcheckTimeout, cfg.NoHealthcheckTimeout, cfg.WaitAfterHealthy)
+	}
+}
+
+func TestApplyLabelOptions_Network(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	adapter := &versionComposeMock{ids: []string{"c1"}}
+	docker := &versionDockerMock{containerLabels: map[string]map[string]string{
+		"c1": {LabelNetwork: "backend"},
+	}}
+
+	cfg, err := NewRunner(log).applyLabelOptions(context.Background(), cli.Config{Service: "api"}, adapter, docker)
+	if err != nil {
+		t.Fatalf("apply labels: %v", err)
+	}
+	if cfg.Network != "backend" {
+		t.Fatalf("expected network from label, got %q", cfg.Network)
+	}
+	cfg, err = NewRunner(log).applyLabelOptions(context.Background(), cli.Config{Service: "api", Network: "frontend"}, adapter, docker)
+	if err != nil || cfg.Network != "frontend" {
+		t.Fatalf("expected --network to win, got %q %v", cfg.Network, err)
+	}
+}
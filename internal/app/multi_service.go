@@ -0,0 +1,240 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+)
+
+// RunServices runs every SERVICE of cfg.Services through Run with the same settings,
+// one after another or, with cfg.Parallel, concurrently. A failed service rolls back
+// its own new containers. With fail-fast the services after it are not started and
+// the services already deployed are rolled back to their previous image; with
+// continue-on-error the others are kept and the failures reported at the end.
+func (r *Runner) RunServices(ctx context.Context, cfg cli.Config) (err error) {
+	defer func() {
+		var jsonOut io.Writer
+		if cfg.Output == cli.OutputJSON {
+			jsonOut = r.out
+		}
+		if printErr := r.summary.print(r.log, jsonOut); printErr != nil && err == nil {
+			err = fmt.Errorf("failed to write deployment result: %w", printErr)
+		}
+	}()
+	if err := r.checkDockerEngine(ctx, cfg); err != nil {
+		r.reportNotStarted(cfg, err)
+		return err
+	}
+	// A compose file from stdin is read once here, so every service, --parallel runs and
+	// the fail-fast rollback all see the same file.
+	cfg, cleanupStdin, err := r.prepareStdinCompose(cfg)
+	if err != nil {
+		r.reportNotStarted(cfg, err)
+		return err
+	}
+	defer cleanupStdin()
+	switch cfg.Action {
+	case cli.ActionStatus:
+		return r.runStatus(ctx, cfg)
+	case cli.ActionDown:
+		return r.runDown(ctx, cfg)
+	}
+	if cfg.DeployTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, cfg.DeployTimeout, ErrDeployTimeout)
+		defer cancel()
+	}
+	return deployTimeoutError(ctx, cfg.DeployTimeout, r.runServices(ctx, cfg))
+}
+
+// reportNotStarted records every service of cfg as failed with err, for errors that
+// stop the run before any service deploy starts.
+func (r *Runner) reportNotStarted(cfg cli.Config, err error) {
+	services := cfg.Services
+	if len(services) <= 1 {
+		services = []string{cfg.Service}
+	}
+	for _, service := range services {
+		serviceCfg := cfg
+		serviceCfg.Service = service
+		if tracksResult(serviceCfg) {
+			r.summary.track(service, time.Now()).Emit(deployFinished(service, "", err))
+		}
+	}
+}
+
+// checkDockerEngine fails early when the engine chosen with --host or --context does
+// not answer, instead of on the first docker command of the deploy.
+func (r *Runner) checkDockerEngine(ctx context.Context, cfg cli.Config) error {
+	target := cfg.DockerHost
+	if target == "" {
+		target = cfg.DockerContext
+	}
+	if target == "" {
+		return nil
+	}
+	if err := docker.NewClient(cfg.DockerArgs).WithMaxRetries(cfg.MaxRetries).Ping(ctx); err != nil {
+		return fmt.Errorf("docker engine %s: %w", target, err)
+	}
+	r.log.Infof("==> Using Docker engine %s", target)
+	return nil
+}
+
+func (r *Runner) runServices(ctx context.Context, cfg cli.Config) error {
+	if len(cfg.Services) <= 1 {
+		return r.Run(ctx, cfg)
+	}
+
+	var mu sync.Mutex
+	previousImages := map[string]string{}
+	errs := r.runEach(cfg.Services, cfg.Parallel, cfg.ContinueOnError, func(service string) error {
+		if !cfg.ContinueOnError {
+			image := r.serviceImage(ctx, cfg, service)
+			mu.Lock()
+			previousImages[service] = image
+			mu.Unlock()
+		}
+		return r.runService(ctx, cfg, service)
+	})
+
+	var failed []string
+	var deployed []string
+	var failures []error
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, cfg.Services[i])
+			failures = append(failures, fmt.Errorf("%s: %w", cfg.Services[i], err))
+		} else if _, ran := previousImages[cfg.Services[i]]; ran {
+			deployed = append(deployed, cfg.Services[i])
+		}
+	}
+	if len(failures) == 0 {
+		r.log.Infof("==> Deployed services: %s", strings.Join(cfg.Services, ", "))
+		return nil
+	}
+	if !cfg.ContinueOnError {
+		failures = append(failures, r.rollbackServices(ctx, cfg, deployed, previousImages, r.Run, r.runDown)...)
+	}
+	return fmt.Errorf("%d of %d services failed (%s): %w", len(failed), len(cfg.Services), strings.Join(failed, ", "), errors.Join(failures...))
+}
+
+// serviceImage returns the image ID the running containers of service were created
+// from, or "" when it has none or it cannot be read.
+func (r *Runner) serviceImage(ctx context.Context, cfg cli.Config, service string) string {
+	adapter, err := selectComposeAdapter(cfg, r.log)
+	if err != nil {
+		return ""
+	}
+	ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, service)
+	if err != nil || len(ids) == 0 {
+		return ""
+	}
+	image, err := docker.NewClient(cfg.DockerArgs).WithMaxRetries(cfg.MaxRetries).ContainerImageID(ctx, ids[0])
+	if err != nil {
+		r.log.WithError(err).Warnf("==> Cannot read the current image of service '%s'; it cannot be rolled back", service)
+		return ""
+	}
+	return image
+}
+
+// serviceRollbackTimeout bounds the redeploy of one service during a fail-fast
+// rollback, which runs detached from the deploy context.
+const serviceRollbackTimeout = 10 * time.Minute
+
+// rollbackServices redeploys every service in deployed through run with the image it
+// ran before this invocation. Services that were not running before are taken down
+// through down instead. A failed service is often the result of an interrupt or an
+// expired --deploy-timeout, so each rollback gets a context of its own instead of the
+// cancelled one.
+func (r *Runner) rollbackServices(ctx context.Context, cfg cli.Config, deployed []string, previousImages map[string]string, run func(context.Context, cli.Config) error, down func(context.Context, cli.Config) error) []error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for _, service := range deployed {
+		image := previousImages[service]
+		if image == "" {
+			if cfg.DryRun {
+				r.log.Infof("==> [dry-run] Would stop and remove the containers of service '%s', which was not running before this deploy", service)
+				continue
+			}
+			r.log.Warnf("==> Service '%s' had no running containers before this deploy; stopping and removing them", service)
+			remove := cfg
+			remove.Action = cli.ActionDown
+			remove.Service = service
+			remove.Services = []string{service}
+			remove.DownAll = false
+			removeCtx, cancel := context.WithTimeout(ctx, serviceRollbackTimeout)
+			err := down(removeCtx, remove)
+			cancel()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rollback of %s: %w", service, err))
+			}
+			continue
+		}
+		r.log.Warnf("==> Rolling back service '%s' to image %s", service, image)
+		restore := cfg
+		restore.Service = service
+		restore.Services = nil
+		restore.RestoreImage = image
+		restore.DeployIfChanged = false
+		restore.SkipIfCurrent = false
+		restore.VerifySignature = false
+		restoreCtx, cancel := context.WithTimeout(ctx, serviceRollbackTimeout)
+		err := run(restoreCtx, restore)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rollback of %s: %w", service, err))
+		}
+	}
+	return errs
+}
+
+// deployTimeoutError reports err as a timeout when ctx ended because --deploy-timeout
+// expired; the underlying error is usually just a killed command.
+func deployTimeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil || !errors.Is(context.Cause(ctx), ErrDeployTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w after %s: %w", ErrDeployTimeout, timeout, err)
+}
+
+func (r *Runner) runService(ctx context.Context, cfg cli.Config, service string) error {
+	cfg.Service = service
+	cfg.Services = nil
+	r.log.Infof("==> Deploying service '%s'", service)
+	return r.Run(ctx, cfg)
+}
+
+// runEach calls deploy for every service and returns the errors by service index.
+// Sequential runs stop at the first error unless continueOnError is set.
+func (r *Runner) runEach(services []string, parallel bool, continueOnError bool, deploy func(service string) error) []error {
+	errs := make([]error, len(services))
+	if parallel {
+		var wg sync.WaitGroup
+		for i, service := range services {
+			wg.Add(1)
+			go func(i int, service string) {
+				defer wg.Done()
+				errs[i] = deploy(service)
+			}(i, service)
+		}
+		wg.Wait()
+		return errs
+	}
+	for i, service := range services {
+		errs[i] = deploy(service)
+		if errs[i] != nil && !continueOnError {
+			if remaining := services[i+1:]; len(remaining) > 0 {
+				r.log.Errorf("==> Service '%s' failed; not deploying %s (use --continue-on-error to keep going)", service, strings.Join(remaining, ", "))
+			}
+			break
+		}
+	}
+	return errs
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+func TestRunEach(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	services := []string{"api", "web", "worker"}
+
+	var mu sync.Mutex
+	var deployed []string
+	deploy := func(service string) error {
+		mu.Lock()
+		deployed = append(deployed, service)
+		mu.Unlock()
+		if service == "web" {
+			return errors.New("unhealthy")
+		}
+		return nil
+	}
+
+	errs := runner.runEach(services, false, false, deploy)
+	if len(deployed) != 2 || errs[1] == nil || errs[2] != nil {
+		t.Fatalf("expected fail-fast to stop after web, deployed %v errs %v", deployed, errs)
+	}
+
+	deployed = nil
+	errs = runner.runEach(services, false, true, deploy)
+	if len(deployed) != 3 || errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Fatalf("expected all services with --continue-on-error, deployed %v errs %v", deployed, errs)
+	}
+
+	deployed = nil
+	errs = runner.runEach(services, true, true, deploy)
+	if len(deployed) != 3 || errs[1] == nil {
+		t.Fatalf("expected all services in parallel, deployed %v errs %v", deployed, errs)
+	}
+}
+
+func TestRollbackServices(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	cfg := cli.Config{Services: []string{"api", "web", "worker"}, DeployIfChanged: true, VerifySignature: true}
+	previous := map[string]string{"api": "sha256:aaa", "web": "", "worker": "sha256:ccc"}
+
+	var restored []cli.Config
+	run := func(ctx context.Context, cfg cli.Config) error {
+		if ctx.Err() != nil {
+			t.Errorf("expected a live context for the rollback of %s, got %v", cfg.Service, ctx.Err())
+		}
+		restored = append(restored, cfg)
+		if cfg.Service == "worker" {
+			return errors.New("unhealthy")
+		}
+		return nil
+	}
+	var removed []cli.Config
+	down := func(ctx context.Context, cfg cli.Config) error {
+		if ctx.Err() != nil {
+			t.Errorf("expected a live context for the removal of %s, got %v", cfg.Service, ctx.Err())
+		}
+		removed = append(removed, cfg)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	errs := runner.rollbackServices(ctx, cfg, []string{"api", "web", "worker"}, previous, run, down)
+
+	if len(restored) != 2 || restored[0].Service != "api" || restored[1].Service != "worker" {
+		t.Fatalf("expected api and worker to be restored, got %+v", restored)
+	}
+	if len(removed) != 1 || removed[0].Action != cli.ActionDown || removed[0].Service != "web" || len(removed[0].Services) != 1 || removed[0].Services[0] != "web" {
+		t.Fatalf("expected web, which was not running before, to be taken down, got %+v", removed)
+	}
+	if got := restored[0]; got.RestoreImage != "sha256:aaa" || got.Services != nil || got.DeployIfChanged || got.VerifySignature {
+		t.Fatalf("unexpected restore config: %+v", got)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected the failed worker rollback to be reported, got %v", errs)
+	}
+}
+
+func TestDeployTimeoutError(t *testing.T) {
+	failure := errors.New("signal: interrupt")
+	if err := deployTimeoutError(context.Background(), time.Minute, failure); err != failure {
+		t.Fatalf("expected error to pass through without a deadline, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeoutCause(context.Background(), time.Nanosecond, ErrDeployTimeout)
+	defer cancel()
+	<-ctx.Done()
+	if err := deployTimeoutError(ctx, time.Minute, nil); err != nil {
+		t.Fatalf("expected nil to stay nil, got %v", err)
+	}
+	err := deployTimeoutError(ctx, time.Minute, failure)
+	if !errors.Is(err, ErrDeployTimeout) || !errors.Is(err, failure) {
+		t.Fatalf("expected timeout wrapping the failure, got %v", err)
+	}
+	if err.Error() != "deployment timed out after 1m0s: signal: interrupt" {
+		t.Fatalf("unexpected message: %q", err.Error())
+	}
+}
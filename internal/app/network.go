@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
+
+type networkReader interface {
+	NetworkIPs(ctx context.Context, containerID string) (map[string]string, error)
+}
+
+// warnAmbiguousNetwork warns when the running containers of cfg.Service are attached to
+// several networks, since --tcp-probe then connects to the first one in name order,
+// which the host may not be able to reach.
+func (r *Runner) warnAmbiguousNetwork(ctx context.Context, cfg cli.Config, adapter compose.Adapter, docker networkReader) error {
+	ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	ips, err := docker.NetworkIPs(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	var networks []string
+	for name, ip := range ips {
+		if ip != "" {
+			networks = append(networks, name)
+		}
+	}
+	if len(networks) < 2 {
+		return nil
+	}
+	sort.Strings(networks)
+	r.log.Warnf("==> Service '%s' is attached to networks %s; --tcp-probe uses %s, set --network or the %s label to pick another", cfg.Service, strings.Join(networks, ", "), networks[0], LabelNetwork)
+	return nil
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/healthdiag"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+)
+
+type currentDeployInspector interface {
+	ImageID(ctx context.Context, image string) (string, error)
+	ContainerImageID(ctx context.Context, containerID string) (string, error)
+	Labels(ctx context.Context, containerID string) (map[string]string, error)
+	State(ctx context.Context, containerID string) (docker.ContainerState, error)
+	HasHealthcheck(ctx context.Context, containerID string) (bool, error)
+	HealthStatus(ctx context.Context, containerID string) (string, error)
+}
+
+// deployIsCurrent reports whether the running containers of cfg.Service already match
+// the target: same image ID, same resolved compose config, the desired replica count
+// and healthy. When they do not, the returned reason says what differs.
+func deployIsCurrent(ctx context.Context, cfg cli.Config, adapter compose.Adapter, inspector currentDeployInspector) (bool, string, error) {
+	containerIDs, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil {
+		return false, "", err
+	}
+	if len(containerIDs) == 0 {
+		return false, "no running containers", nil
+	}
+	if cfg.Replicas > 0 && len(containerIDs) != cfg.Replicas {
+		return false, fmt.Sprintf("%d replicas running, %d requested", len(containerIDs), cfg.Replicas), nil
+	}
+
+	image, err := adapter.ServiceImage(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to resolve target image for service %s: %w", cfg.Service, err)
+	}
+	targetImageID, err := inspector.ImageID(ctx, image)
+	if err != nil {
+		return false, fmt.Sprintf("target image %s is not available locally", image), nil
+	}
+	targetHash, err := compose.ServiceConfigHash(ctx, adapter, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to hash config of service %s: %w", cfg.Service, err)
+	}
+	if targetHash == "" {
+		return false, "config hash is not available", nil
+	}
+
+	for _, id := range containerIDs {
+		imageID, err := inspector.ContainerImageID(ctx, id)
+		if err != nil {
+			return false, "", err
+		}
+		if imageID != targetImageID {
+			return false, fmt.Sprintf("container %s runs a different image", id), nil
+		}
+		labels, err := inspector.Labels(ctx, id)
+		if err != nil {
+			return false, "", err
+		}
+		if strings.TrimSpace(labels[compose.LabelConfigHash]) != targetHash {
+			return false, fmt.Sprintf("container %s was created from a different config", id), nil
+		}
+		st, err := inspector.State(ctx, id)
+		if err != nil {
+			return false, "", err
+		}
+		if !st.Running {
+			return false, fmt.Sprintf("container %s is %s", id, st.Status), nil
+		}
+		hasHealthcheck, err := inspector.HasHealthcheck(ctx, id)
+		if err != nil {
+			return false, "", err
+		}
+		if !hasHealthcheck {
+			continue
+		}
+		status, err := inspector.HealthStatus(ctx, id)
+		if err != nil {
+			return false, "", err
+		}
+		if !healthdiag.IsAcceptedStatus(status, cfg.HealthyStatuses) {
+			return false, fmt.Sprintf("container %s is %s", id, status), nil
+		}
+	}
+	return true, "", nil
+}
+
+func (r *Runner) skipCurrentDeploy(ctx context.Context, cfg cli.Config, adapter compose.Adapter, inspector currentDeployInspector, store *state.Store, deployID string) (bool, error) {
+	current, reason, err := deployIsCurrent(ctx, cfg, adapter, inspector)
+	if err != nil {
+		return false, err
+	}
+	if !current {
+		r.log.Infof("==> Service '%s' is not current (%s), deploying", cfg.Service, reason)
+		return false, nil
+	}
+
+	r.log.Infof("==> Service '%s' already runs the target image and config and is healthy, nothing to do", cfg.Service)
+	r.appendAudit(cfg, store, state.AuditEntry{
+		Service:  cfg.Service,
+		Strategy: cfg.Strategy,
+		Result:   state.AuditResultSkipped,
+		Reason:   "no change (already current)",
+		DeployID: deployID,
+	})
+	return true, nil
+}
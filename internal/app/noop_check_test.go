@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+)
+
+type currentComposeMock struct {
+	versionComposeMock
+	hash string
+}
+
+func (m *currentComposeMock) ConfigHash(context.Context, []string, []string, string) (string, error) {
+	return m.hash, nil
+}
+
+type currentDockerMock struct {
+	imageID string
+	images  map[string]string
+	labels  map[string]map[string]string
+	health  map[string]string
+	stopped map[string]bool
+}
+
+func (m *currentDockerMock) ImageID(context.Context, string) (string, error) {
+	if m.imageID == "" {
+		return "", fmt.Errorf("no such image")
+	}
+	return m.imageID, nil
+}
+
+func (m *currentDockerMock) ContainerImageID(_ context.Context, id string) (string, error) {
+	return m.images[id], nil
+}
+
+func (m *currentDockerMock) Labels(_ context.Context, id string) (map[string]string, error) {
+	return m.labels[id], nil
+}
+
+func (m *currentDockerMock) State(_ context.Context, id string) (docker.ContainerState, error) {
+	if m.stopped[id] {
+		return docker.ContainerState{Status: "exited"}, nil
+	}
+	return docker.ContainerState{Status: "running", Running: true}, nil
+}
+
+func (m *currentDockerMock) HasHealthcheck(_ context.Context, id string) (bool, error) {
+	_, ok := m.health[id]
+	return ok, nil
+}
+
+func (m *currentDockerMock) HealthStatus(_ context.Context, id string) (string, error) {
+	return m.health[id], nil
+}
+
+func TestDeployIsCurrent(t *testing.T) {
+	newMocks := func() (*currentComposeMock, *currentDockerMock) {
+		adapter := &currentComposeMock{
+			versionComposeMock: versionComposeMock{image: "api:latest", ids: []string{"c1", "c2"}},
+			hash:               "h1",
+		}
+		docker := &currentDockerMock{
+			imageID: "sha256:aaa",
+			images:  map[string]string{"c1": "sha256:aaa", "c2": "sha256:aaa"},
+			labels: map[string]map[string]string{
+				"c1": {compose.LabelConfigHash: "h1"},
+				"c2": {compose.LabelConfigHash: "h1"},
+			},
+			health: map[string]string{"c1": "healthy"},
+		}
+		return adapter, docker
+	}
+	cfg := cli.Config{Service: "api"}
+
+	adapter, docker := newMocks()
+	current, reason, err := deployIsCurrent(context.Background(), cfg, adapter, docker)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !current {
+		t.Fatalf("expected current deploy, got reason %q", reason)
+	}
+
+	cases := map[string]func(cfg *cli.Config, adapter *currentComposeMock, docker *currentDockerMock){
+		"no containers":      func(_ *cli.Config, a *currentComposeMock, _ *currentDockerMock) { a.ids = nil },
+		"replica mismatch":   func(c *cli.Config, _ *currentComposeMock, _ *currentDockerMock) { c.Replicas = 3 },
+		"image not pulled":   func(_ *cli.Config, _ *currentComposeMock, d *currentDockerMock) { d.imageID = "" },
+		"older image":        func(_ *cli.Config, _ *currentComposeMock, d *currentDockerMock) { d.images["c2"] = "sha256:old" },
+		"config changed":     func(_ *cli.Config, a *currentComposeMock, _ *currentDockerMock) { a.hash = "h2" },
+		"not deployed by us": func(_ *cli.Config, _ *currentComposeMock, d *currentDockerMock) { d.labels["c2"] = nil },
+		"stopped": func(_ *cli.Config, _ *currentComposeMock, d *currentDockerMock) {
+			d.stopped = map[string]bool{"c2": true}
+		},
+		"unhealthy": func(_ *cli.Config, _ *currentComposeMock, d *currentDockerMock) { d.health["c1"] = "unhealthy" },
+	}
+	for name, mutate := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := cli.Config{Service: "api"}
+			adapter, docker := newMocks()
+			mutate(&cfg, adapter, docker)
+			current, reason, err := deployIsCurrent(context.Background(), cfg, adapter, docker)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if current || reason == "" {
+				t.Fatalf("expected a reason to deploy, got current=%v reason=%q", current, reason)
+			}
+		})
+	}
+}
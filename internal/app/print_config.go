@@ -0,0 +1,27 @@
+package app
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+// PrintConfig writes the effective configuration after the same path and project
+// name resolution Run applies, without touching containers or state.
+func PrintConfig(w io.Writer, cfg cli.Config) error {
+	if cfg.ProjectDirectory != "" {
+		cfg.TraefikConfigFile = resolveTraefikConfigPath(cfg.ProjectDirectory, cfg.TraefikConfigFile)
+		cfg.NginxConfigFile = resolveTraefikConfigPath(cfg.ProjectDirectory, cfg.NginxConfigFile)
+		cfg.HAProxyConfigFile = resolveTraefikConfigPath(cfg.ProjectDirectory, cfg.HAProxyConfigFile)
+	}
+	if _, err := fmt.Fprint(w, cli.FormatConfig(cfg)); err != nil {
+		return err
+	}
+	projectName := composeProjectName(cfg)
+	if projectName == "" {
+		projectName = "- (derived by docker compose)"
+	}
+	_, err := fmt.Fprintf(w, "ComposeProjectName: %s\n", projectName)
+	return err
+}
@@ -0,0 +1,64 @@
+package app
+
+import (
+	"context"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+)
+
+// proxiesInUse returns the proxy types that route at least one running service, in a
+// fixed order: the com.ztd.proxy label of each service with traefik.enable=true, or
+// --proxy without it. With no routed service it returns --proxy alone, so that its
+// generator reports the empty configuration as before.
+func proxiesInUse(ctx context.Context, cfg cli.Config, adapter compose.Adapter, docker labelReader) ([]string, error) {
+	ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, "")
+	if err != nil {
+		return nil, err
+	}
+	serviceLabel := cfg.ServiceLabel
+	if serviceLabel == "" {
+		serviceLabel = compose.DefaultServiceLabel
+	}
+	used := map[string]bool{}
+	for _, id := range ids {
+		labels, err := docker.Labels(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if labels[serviceLabel] == "" || labels["traefik.enable"] != "true" {
+			continue
+		}
+		proxyType := proxy.Resolve(labels, cfg.ProxyType)
+		if err := proxy.ValidateKnown(proxyType); err != nil {
+			return nil, err
+		}
+		used[proxyType] = true
+	}
+	if len(used) == 0 {
+		return []string{cfg.ProxyType}, nil
+	}
+	var types []string
+	for _, proxyType := range []string{proxy.TypeTraefik, proxy.TypeNginxProxy, proxy.TypeHAProxy} {
+		if used[proxyType] {
+			types = append(types, proxyType)
+		}
+	}
+	return types, nil
+}
+
+// serviceProxy returns the proxy type that routes cfg.Service, from the com.ztd.proxy
+// label of its first running container, or --proxy when it has none.
+func serviceProxy(ctx context.Context, cfg cli.Config, adapter compose.Adapter, docker labelReader) (string, error) {
+	ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil || len(ids) == 0 {
+		return cfg.ProxyType, err
+	}
+	labels, err := docker.Labels(ctx, ids[0])
+	if err != nil {
+		return "", err
+	}
+	proxyType := proxy.Resolve(labels, cfg.ProxyType)
+	return proxyType, proxy.ValidateKnown(proxyType)
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+func TestProxiesInUse_GroupsServicesByProxyLabel(t *testing.T) {
+	ctx := context.Background()
+	cfg := cli.Config{ProxyType: cli.ProxyTraefik}
+	docker := &versionDockerMock{containerLabels: map[string]map[string]string{
+		"c1": {"com.docker.compose.service": "web", "traefik.enable": "true"},
+		"c2": {"com.docker.compose.service": "api", "traefik.enable": "true", "com.ztd.proxy": "haproxy"},
+		"c3": {"com.docker.compose.service": "worker", "com.ztd.proxy": "nginx-proxy"},
+	}}
+
+	got, err := proxiesInUse(ctx, cfg, &versionComposeMock{ids: []string{"c1", "c2", "c3"}}, docker)
+	if err != nil {
+		t.Fatalf("proxiesInUse: %v", err)
+	}
+	if want := []string{cli.ProxyTraefik, cli.ProxyHAProxy}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	got, err = proxiesInUse(ctx, cfg, &versionComposeMock{ids: []string{"c3"}}, docker)
+	if err != nil || !reflect.DeepEqual(got, []string{cli.ProxyTraefik}) {
+		t.Fatalf("expected --proxy without routed services, got %v %v", got, err)
+	}
+
+	docker.containerLabels["c1"]["com.ztd.proxy"] = "envoy"
+	if _, err := proxiesInUse(ctx, cfg, &versionComposeMock{ids: []string{"c1"}}, docker); err == nil {
+		t.Fatal("expected an unknown com.ztd.proxy value to fail")
+	}
+}
+
+func TestServiceProxy_ReadsServiceLabel(t *testing.T) {
+	ctx := context.Background()
+	cfg := cli.Config{Service: "api", ProxyType: cli.ProxyTraefik}
+	docker := &versionDockerMock{containerLabels: map[string]map[string]string{
+		"c1": {"com.ztd.proxy": "nginx-proxy"},
+	}}
+
+	proxyType, err := serviceProxy(ctx, cfg, &versionComposeMock{}, docker)
+	if err != nil || proxyType != cli.ProxyTraefik {
+		t.Fatalf("expected --proxy for a service without containers, got %q %v", proxyType, err)
+	}
+	proxyType, err = serviceProxy(ctx, cfg, &versionComposeMock{ids: []string{"c1"}}, docker)
+	if err != nil || proxyType != cli.ProxyNginxProxy {
+		t.Fatalf("expected the com.ztd.proxy label, got %q %v", proxyType, err)
+	}
+}
@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
+)
+
+// serviceIsProxied reports whether cfg.Service is routed by a proxy: its containers or
+// compose labels set traefik.enable=true, or it is handled by nginx-proxy or HAProxy. A service
+// without running containers counts as proxied, since there is nothing to swap yet.
+func serviceIsProxied(ctx context.Context, cfg cli.Config, adapter compose.Adapter, docker labelReader) (bool, error) {
+	ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil || len(ids) == 0 {
+		return true, err
+	}
+	labels, err := docker.Labels(ctx, ids[0])
+	if err != nil {
+		return false, err
+	}
+	if proxyType := proxy.Resolve(labels, cfg.ProxyType); proxyType == proxy.TypeNginxProxy || proxyType == proxy.TypeHAProxy || labels["traefik.enable"] == "true" {
+		return true, nil
+	}
+	if len(cfg.ComposeFiles) == 0 {
+		return false, nil
+	}
+	return traefik.RoutesService(cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+}
+
+// recreateService replaces the containers of a service that no proxy routes to in
+// place, keeping the current replica count unless --replicas is set.
+func (r *Runner) recreateService(ctx context.Context, cfg cli.Config, adapter compose.Adapter) error {
+	replicas := cfg.Replicas
+	if replicas == 0 {
+		ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+		if err != nil {
+			return err
+		}
+		replicas = len(ids)
+	}
+	r.log.Infof("==> Recreating %d container(s) of service '%s' in place", replicas, cfg.Service)
+	return compose.RecreateService(ctx, adapter, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service, replicas)
+}
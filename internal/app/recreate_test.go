@@ -0,0 +1,56 @@
+package app
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+type recreateComposeMock struct {
+	versionComposeMock
+	recreated string
+	replicas  int
+}
+
+func (m *recreateComposeMock) Recreate(_ context.Context, _ []string, _ []string, service string, replicas int) error {
+	m.recreated = service
+	m.replicas = replicas
+	return nil
+}
+
+func TestServiceIsProxied(t *testing.T) {
+	ctx := context.Background()
+	cfg := cli.Config{Service: "worker", ProxyType: cli.ProxyTraefik}
+	docker := &versionDockerMock{containerLabels: map[string]map[string]string{"c1": {}}}
+
+	proxied, err := serviceIsProxied(ctx, cfg, &versionComposeMock{}, docker)
+	if err != nil || !proxied {
+		t.Fatalf("expected a service without containers to count as proxied, got %v %v", proxied, err)
+	}
+	proxied, err = serviceIsProxied(ctx, cfg, &versionComposeMock{ids: []string{"c1"}}, docker)
+	if err != nil || proxied {
+		t.Fatalf("expected a service without traefik.enable not to be proxied, got %v %v", proxied, err)
+	}
+	docker.containerLabels["c1"]["traefik.enable"] = "true"
+	proxied, err = serviceIsProxied(ctx, cfg, &versionComposeMock{ids: []string{"c1"}}, docker)
+	if err != nil || !proxied {
+		t.Fatalf("expected traefik.enable=true to be proxied, got %v %v", proxied, err)
+	}
+}
+
+func TestRecreateService_KeepsReplicaCount(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	adapter := &recreateComposeMock{versionComposeMock: versionComposeMock{ids: []string{"c1", "c2"}}}
+
+	if err := NewRunner(log).recreateService(context.Background(), cli.Config{Service: "worker"}, adapter); err != nil {
+		t.Fatalf("recreate: %v", err)
+	}
+	if adapter.recreated != "worker" || adapter.replicas != 2 {
+		t.Fatalf("unexpected recreate: service=%q replicas=%d", adapter.recreated, adapter.replicas)
+	}
+}
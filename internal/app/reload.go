@@ -0,0 +1,20 @@
+package app
+
+import (
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+)
+
+// proxyReloader returns the reload step run after every proxy config write, for
+// proxies that do not watch the file: --nginx-reload or --haproxy-reload for those
+// proxies, then --reload-cmd and --reload-endpoint.
+func proxyReloader(cfg cli.Config) proxy.Reloader {
+	return proxy.Reloader{
+		Command:        cfg.ReloadCommand,
+		Endpoint:       cfg.ReloadEndpoint,
+		Required:       cfg.ReloadRequired,
+		NginxCommand:   cfg.NginxReloadCommand,
+		HAProxyCommand: cfg.HAProxyReloadCommand,
+		DryRun:         cfg.DryRun,
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+)
+
+// checkSurgeResources estimates whether the host can run one extra container per
+// running replica of cfg.Service, using the service's compose limits against the
+// host totals minus limits already reserved by running containers.
+func (r *Runner) checkSurgeResources(ctx context.Context, cfg cli.Config, adapter compose.Adapter, dockerClient *docker.Client) error {
+	limits, err := compose.ServiceResourceLimits(cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil {
+		return err
+	}
+	if limits.MemoryBytes == 0 && limits.NanoCPUs == 0 {
+		r.log.Infof("==> Service '%s' sets no memory/CPU limits; skipping resource check", cfg.Service)
+		return nil
+	}
+	ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil {
+		return err
+	}
+	host, err := dockerClient.HostResources(ctx)
+	if err != nil {
+		return r.resourceCheckFailed(cfg, fmt.Errorf("failed to read host resources: %w", err))
+	}
+	reservedMemory, reservedCPUs, err := dockerClient.ReservedResources(ctx)
+	if err != nil {
+		return r.resourceCheckFailed(cfg, fmt.Errorf("failed to read reserved resources: %w", err))
+	}
+
+	replicas := len(ids)
+	if replicas == 0 {
+		replicas = 1
+	}
+	problems := surgeShortfall(host, reservedMemory, reservedCPUs, limits, replicas)
+	if len(problems) == 0 {
+		return nil
+	}
+	return r.resourceCheckFailed(cfg, fmt.Errorf("host cannot fit %d surge containers of service %s: %s", replicas, cfg.Service, strings.Join(problems, "; ")))
+}
+
+func (r *Runner) resourceCheckFailed(cfg cli.Config, err error) error {
+	if cfg.ResourceCheck == cli.ResourceCheckStrict {
+		return err
+	}
+	r.log.Warnf("==> WARNING: %v", err)
+	return nil
+}
+
+func surgeShortfall(host docker.HostResources, reservedMemory int64, reservedCPUs int64, limits compose.ResourceLimits, replicas int) []string {
+	var problems []string
+	if limits.MemoryBytes > 0 && host.MemTotal > 0 {
+		need := limits.MemoryBytes * int64(replicas)
+		if free := host.MemTotal - reservedMemory; need > free {
+			problems = append(problems, fmt.Sprintf("memory needs %d MiB, %d MiB unreserved", need>>20, max(free, 0)>>20))
+		}
+	}
+	if limits.NanoCPUs > 0 && host.NCPU > 0 {
+		need := limits.NanoCPUs * int64(replicas)
+		if free := int64(host.NCPU)*1e9 - reservedCPUs; need > free {
+			problems = append(problems, fmt.Sprintf("cpus needs %.2f, %.2f unreserved", float64(need)/1e9, float64(max(free, 0))/1e9))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+)
+
+func TestSurgeShortfall(t *testing.T) {
+	host := docker.HostResources{NCPU: 4, MemTotal: 8 << 30}
+	limits := compose.ResourceLimits{MemoryBytes: 1 << 30, NanoCPUs: 500000000}
+
+	if problems := surgeShortfall(host, 2<<30, 1e9, limits, 3); len(problems) != 0 {
+		t.Fatalf("expected surge to fit, got %v", problems)
+	}
+
+	problems := surgeShortfall(host, 7<<30, 1e9, limits, 2)
+	if len(problems) != 1 {
+		t.Fatalf("expected memory shortfall only, got %v", problems)
+	}
+
+	problems = surgeShortfall(host, 0, 3800000000, limits, 2)
+	if len(problems) != 1 {
+		t.Fatalf("expected cpu shortfall only, got %v", problems)
+	}
+
+	if problems := surgeShortfall(docker.HostResources{}, 0, 0, limits, 2); len(problems) != 0 {
+		t.Fatalf("expected unknown host totals to be skipped, got %v", problems)
+	}
+}
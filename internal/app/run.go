@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -16,64 +18,209 @@ import (
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/canary"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
-	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/haproxy"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/hooks"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/metricsgate"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/nginx"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/registry"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/rollout"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/tracing"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
 )
 
 type Runner struct {
-	log *logrus.Logger
+	log     *logrus.Logger
+	in      io.Reader
+	out     io.Writer
+	summary *deploySummary
 }
 
 const autoCleanupLockFileName = ".auto-cleanup.lock"
 const envRegistryStrict = "ZTD_REGISTRY_STRICT"
 
+// ErrDeployTimeout is returned when a deploy is still running after --deploy-timeout.
+var ErrDeployTimeout = errors.New("deployment timed out")
+
+// ExitInterrupted is the exit code for a deploy stopped by SIGINT or SIGTERM.
+const ExitInterrupted = 130
+
 func NewRunner(log *logrus.Logger) *Runner {
-	return &Runner{log: log}
+	return &Runner{log: log, in: os.Stdin, out: os.Stdout, summary: &deploySummary{}}
 }
 
-func (r *Runner) Run(ctx context.Context, cfg cli.Config) error {
-	store := state.NewStore(state.DefaultStateDir)
+func (r *Runner) Run(ctx context.Context, cfg cli.Config) (err error) {
 	regStore := registry.NewStore("")
 	if cfg.Action == cli.ActionAutoRun {
 		return r.runAutoCleanup(ctx, cfg, regStore)
 	}
+	deployID := tracing.NewTraceID()
+	// The summary entry is finished here rather than with the other sinks, so a
+	// deploy that fails before the event sinks are open still reports its result.
+	var result events.Sink = events.Nop{}
+	if tracksResult(cfg) {
+		result = r.summary.track(cfg.Service, time.Now())
+		defer func() {
+			result.Emit(deployFinished(cfg.Service, deployID, err))
+		}()
+	}
+	cfg, cleanupStdin, err := r.prepareStdinCompose(cfg)
+	if err != nil {
+		return err
+	}
+	defer cleanupStdin()
+	if cfg.Action == cli.ActionExplain {
+		return r.runExplain(ctx, cfg)
+	}
+	if cfg.Image != "" {
+		var cleanupInline func()
+		if cfg, cleanupInline, err = r.prepareInlineCompose(cfg); err != nil {
+			return err
+		}
+		defer cleanupInline()
+	}
+
+	if cfg.ProjectDirectory != "" {
+		cfg.TraefikConfigFile = resolveTraefikConfigPath(cfg.ProjectDirectory, cfg.TraefikConfigFile)
+		cfg.NginxConfigFile = resolveTraefikConfigPath(cfg.ProjectDirectory, cfg.NginxConfigFile)
+		cfg.HAProxyConfigFile = resolveTraefikConfigPath(cfg.ProjectDirectory, cfg.HAProxyConfigFile)
+	}
+	if len(cfg.ComposeFiles) > 0 {
+		service := cfg.Service
+		if service == "up" || cfg.ResetBreaker {
+			service = ""
+		}
+		if err := compose.ValidateComposeFiles(cfg.ComposeFiles, service); err != nil {
+			return err
+		}
+	}
+	store := state.NewStore(filepath.Join(cfg.ProjectDirectory, state.DefaultStateDir))
 
-	if err := registerCurrentWorkingDir(regStore); err != nil {
+	if cfg.DryRun {
+		r.log.Info("==> [dry-run] Nothing is scaled, stopped or written; the steps below are what this run would do")
+	} else if err := registerWorkingDir(regStore, cfg.ProjectDirectory); err != nil {
 		if registryStrictMode() {
 			return fmt.Errorf("failed to register working directory: %w", err)
 		}
 		r.log.WithError(err).Warn("==> Registry update skipped for current working directory")
 	}
 
-	composeAdapter, err := selectComposeAdapter(cfg)
+	if cfg.ResetBreaker {
+		if err := store.ResetBreaker(composeProjectName(cfg), cfg.Service); err != nil {
+			return fmt.Errorf("failed to reset circuit-breaker: %w", err)
+		}
+		r.log.Infof("==> Circuit-breaker reset for service '%s'", cfg.Service)
+		return nil
+	}
+
+	eventSink, closeEvents, err := openEventSink(cfg)
 	if err != nil {
 		return err
 	}
+	var tracer *tracing.Tracer
+	if cfg.OtelEndpoint != "" && !cfg.DryRun {
+		tracer = tracing.New(cfg.OtelEndpoint, deployID, cfg.Service)
+		eventSink = events.Multi(eventSink, tracer)
+	}
+	finishSink := eventSink
+	eventSink = events.Multi(eventSink, result)
+	defer func() {
+		finished := deployFinished(cfg.Service, deployID, err)
+		finishSink.Emit(finished)
+		if finished.Reason != "" {
+			r.appendAudit(cfg, store, state.AuditEntry{
+				Service:  cfg.Service,
+				Strategy: cfg.Strategy,
+				Result:   state.AuditResultFailed,
+				Reason:   finished.Reason,
+				DeployID: deployID,
+			})
+			if cfg.MaxRollbacks > 0 && !cfg.DryRun && cfg.Action == cli.ActionDeploy && safeguard.RolledBack(err) {
+				if breakerErr := r.recordRollback(cfg, store, time.Now().UTC()); breakerErr != nil {
+					r.log.WithError(breakerErr).Warn("==> Failed to record rollback for the circuit-breaker")
+				}
+			}
+		}
+		closeEvents()
+		if tracer != nil {
+			if flushErr := tracer.Flush(context.Background()); flushErr != nil {
+				r.log.WithError(flushErr).Warn("==> Failed to export deploy traces")
+			}
+		}
+	}()
 
-	dockerClient := docker.NewClient(cfg.DockerArgs)
-	generator := traefik.NewGenerator(composeAdapter, dockerClient)
-	bgDeployer := bluegreen.NewDeployer(r.log, composeAdapter, dockerClient, store)
-	canaryDeployer := canary.NewDeployer(r.log, composeAdapter, dockerClient, store)
-	cleanupWorker := newCleanupWorker(store, cfg.TraefikConfigFile, bgDeployer, canaryDeployer)
-	if err := cleanupWorker.ProcessOverdue(ctx); err != nil {
-		r.log.WithError(err).Warn("==> Failed to process overdue scheduled cleanups")
+	if cfg.MaxRollbacks > 0 && cfg.Action == cli.ActionDeploy && cfg.Service != "up" {
+		if err := r.checkBreaker(cfg, store, time.Now().UTC()); err != nil {
+			return err
+		}
 	}
 
-	if cfg.Service == "up" {
-		if err := ensureTraefikConfigDir(cfg.TraefikConfigFile); err != nil {
+	if cfg.Service != "up" && !cfg.DryRun {
+		release, err := r.acquireServiceLock(ctx, cfg, deployID)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := release(); err != nil {
+				r.log.WithError(err).Warnf("==> Failed to release the lock of service '%s'", cfg.Service)
+			}
+		}()
+	}
+
+	releaseDeploySlot := func() {}
+	if cfg.Action == cli.ActionDeploy && !cfg.DryRun {
+		release, err := r.acquireDeploySlot(ctx, cfg)
+		if err != nil {
 			return err
 		}
+		var once sync.Once
+		releaseDeploySlot = func() {
+			once.Do(func() {
+				if err := release(); err != nil {
+					r.log.WithError(err).Warn("==> Failed to release host-wide deploy slot")
+				}
+			})
+		}
+		defer releaseDeploySlot()
+	}
+
+	composeAdapter, err := selectComposeAdapter(cfg, r.log)
+	if err != nil {
+		return err
+	}
+
+	dockerClient := docker.NewClient(cfg.DockerArgs).WithMaxRetries(cfg.MaxRetries).WithStopConcurrency(cfg.StopConcurrency).WithStopTimeout(cfg.StopTimeout).WithDryRun(r.dryRunLog(cfg))
+	composeAdapter = compose.NewIgnoreFilter(composeAdapter, dockerClient).WithServiceLabel(cfg.ServiceLabel).WithProject(composeProjectName(cfg))
+	generator := traefik.NewGenerator(composeAdapter, dockerClient).WithDefaultProxy(cfg.ProxyType).WithServiceLabel(cfg.ServiceLabel).WithDNSNames(cfg.DNSNames).WithLogger(r.log).WithDryRun(r.dryRunOut(cfg))
+	nginxGenerator := nginx.NewGenerator(composeAdapter, dockerClient).WithDefaultProxy(cfg.ProxyType).WithServiceLabel(cfg.ServiceLabel).WithLogger(r.log).WithDryRun(r.dryRunOut(cfg))
+	haproxyGenerator := haproxy.NewGenerator(composeAdapter, dockerClient).WithDefaultProxy(cfg.ProxyType).WithServiceLabel(cfg.ServiceLabel).WithLogger(r.log).WithDryRun(r.dryRunOut(cfg))
+	reloader := proxyReloader(cfg)
+	bgDeployer := bluegreen.NewDeployer(r.log, composeAdapter, dockerClient, store).WithEvents(eventSink).WithReloader(reloader)
+	canaryDeployer := canary.NewDeployer(r.log, composeAdapter, dockerClient, store).WithEvents(eventSink).WithReloader(reloader)
+	if !cfg.DryRun {
+		cleanupWorker := newCleanupWorker(store, cfg.TraefikConfigFile, bgDeployer, canaryDeployer)
+		if err := cleanupWorker.ProcessOverdue(ctx); err != nil {
+			r.log.WithError(err).Warn("==> Failed to process overdue scheduled cleanups")
+		}
+	}
+
+	if cfg.Service == "up" {
+		if cfg.ProxyType == cli.ProxyTraefik && !cfg.NoProxy && !cfg.DryRun {
+			if err := ensureTraefikConfigDir(cfg.TraefikConfigFile); err != nil {
+				return err
+			}
+		}
 
-		composeServices, err := collectComposeServices(cfg.ComposeFiles)
+		composeServices, err := collectComposeServices(cfg.ComposeFiles, cfg.EnvFiles)
 		if err != nil {
 			return fmt.Errorf("failed to read compose services: %w", err)
 		}
-		if deleted, err := store.DeleteByServiceNames(composeServices); err != nil {
+		if cfg.DryRun {
+			r.log.Infof("==> [dry-run] Would remove the state files of services %v", composeServices)
+		} else if deleted, err := store.DeleteByServiceNames(composeServices); err != nil {
 			return fmt.Errorf("failed to clean service states before up: %w", err)
 		} else if deleted > 0 {
 			r.log.WithField("statesDeleted", deleted).Info("==> Removed stale service state files before up.")
@@ -84,23 +231,154 @@ func (r *Runner) Run(ctx context.Context, cfg cli.Config) error {
 			return err
 		}
 
-		time.Sleep(5 * time.Second)
-		if err := generator.Generate(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.TraefikConfigFile); err != nil {
-			return err
+		if !cfg.DryRun {
+			time.Sleep(5 * time.Second)
+		}
+		if !cfg.NoProxy {
+			proxyTypes, err := proxiesInUse(ctx, cfg, composeAdapter, dockerClient)
+			if err != nil {
+				return err
+			}
+			for _, proxyType := range proxyTypes {
+				switch proxyType {
+				case cli.ProxyNginxProxy:
+					err = nginxGenerator.Generate(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.NginxConfigFile)
+				case cli.ProxyHAProxy:
+					err = haproxyGenerator.Generate(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.HAProxyConfigFile)
+				default:
+					err = generator.Generate(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.TraefikConfigFile)
+				}
+				if err != nil {
+					return err
+				}
+				if err := reloader.Reload(ctx, r.log, proxyType); err != nil {
+					return err
+				}
+			}
 		}
 		if !cfg.UpDetached {
+			releaseDeploySlot()
 			return composeAdapter.LogsFollowTail(ctx, cfg.ComposeFiles, "", 1)
 		}
 		return nil
 	}
 
+	if cfg.OnlyConfig {
+		proxyType, err := serviceProxy(ctx, cfg, composeAdapter, dockerClient)
+		if err != nil {
+			return err
+		}
+		switch proxyType {
+		case cli.ProxyNginxProxy:
+			r.log.Infof("==> Refreshing nginx config for service '%s' from current labels", cfg.Service)
+			err = nginxGenerator.Generate(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.NginxConfigFile)
+		case cli.ProxyHAProxy:
+			r.log.Infof("==> Refreshing HAProxy config for service '%s' from current labels", cfg.Service)
+			err = haproxyGenerator.Generate(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.HAProxyConfigFile)
+		default:
+			r.log.Infof("==> Refreshing Traefik config for service '%s' from current labels", cfg.Service)
+			err = generator.RefreshService(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.TraefikConfigFile, cfg.Service)
+		}
+		if err != nil {
+			return err
+		}
+		return reloader.Reload(ctx, r.log, proxyType)
+	}
+
 	if err := ensureNoConflictingActiveDeployment(cfg, store); err != nil {
 		return err
 	}
 
+	proxied := true
+	if cfg.Action == cli.ActionDeploy {
+		others, err := compose.CheckScalable(cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+		if err != nil {
+			return err
+		}
+		if len(others) > 0 {
+			r.log.Warnf("==> Services %v set container_name and cannot be scaled for zero-downtime deploys", others)
+		}
+		if cfg, err = r.applyLabelOptions(ctx, cfg, composeAdapter, dockerClient); err != nil {
+			return err
+		}
+		dockerClient.WithNetwork(cfg.Network)
+		if cfg.Network == "" && cfg.TCPProbePort > 0 {
+			if err := r.warnAmbiguousNetwork(ctx, cfg, composeAdapter, dockerClient); err != nil {
+				return err
+			}
+		}
+		if !cfg.NoProxy {
+			if proxied, err = serviceIsProxied(ctx, cfg, composeAdapter, dockerClient); err != nil {
+				return err
+			}
+		}
+		if !proxied && !cfg.Recreate {
+			r.log.Warnf("==> WARNING: service '%s' is not routed by a proxy (no traefik.enable=true), so scaling up and swapping cannot hide the gap when old containers stop; use --recreate to recreate it in place instead", cfg.Service)
+		}
+	}
+
+	if cfg.SkipIfCurrent {
+		skip, err := r.skipCurrentDeploy(ctx, cfg, composeAdapter, dockerClient, store, deployID)
+		if err != nil {
+			return err
+		}
+		if skip {
+			return nil
+		}
+	}
+
+	if cfg.ResourceCheck != cli.ResourceCheckOff {
+		if err := r.checkSurgeResources(ctx, cfg, composeAdapter, dockerClient); err != nil {
+			return err
+		}
+	}
+
+	if cfg.VerifySignature {
+		if err := r.verifyImageSignature(ctx, cfg, composeAdapter, store, deployID); err != nil {
+			return err
+		}
+	}
+
+	if cfg.DeployIfChanged {
+		skip, err := r.skipUnchangedDeploy(ctx, cfg, composeAdapter, dockerClient, store, deployID)
+		if err != nil {
+			return err
+		}
+		if skip {
+			return nil
+		}
+	}
+
+	serviceHooks, err := loadHooks(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Action == cli.ActionDeploy {
+		if err := r.runHook(ctx, cfg, serviceHooks, hooks.PreDeploy, deployID, nil); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		err = r.runFinishHooks(ctx, cfg, serviceHooks, deployID, err)
+	}()
+
+	if !proxied && cfg.Recreate {
+		return r.recreateService(ctx, cfg, composeAdapter)
+	}
+
+	surgeOverrides, cleanupOverrides, err := buildSurgeOverrides(ctx, cfg, composeAdapter)
+	if err != nil {
+		return err
+	}
+	defer cleanupOverrides()
+
 	switch cfg.Strategy {
 	case cli.StrategyRolling:
-		updater := rollout.NewUpdater(r.log, composeAdapter, dockerClient, generator)
+		surgeAdd, surgeTarget, err := cli.ParseScaleStep(cfg.ScaleStep)
+		if err != nil {
+			return err
+		}
+		updater := rollout.NewUpdater(r.log, composeAdapter, dockerClient, generator).WithNginxGenerator(nginxGenerator).WithHAProxyGenerator(haproxyGenerator).WithReloader(reloader).WithEvents(eventSink)
 		return updater.Run(ctx, rollout.Options{
 			Service:              cfg.Service,
 			ComposeFiles:         cfg.ComposeFiles,
@@ -110,6 +388,29 @@ func (r *Runner) Run(ctx context.Context, cfg cli.Config) error {
 			WaitAfterHealthy:     cfg.WaitAfterHealthy,
 			ProxyType:            cfg.ProxyType,
 			TraefikConfigFile:    cfg.TraefikConfigFile,
+			DNSNames:             cfg.DNSNames,
+			ServiceLabel:         cfg.ServiceLabel,
+			NginxConfigFile:      cfg.NginxConfigFile,
+			HAProxyConfigFile:    cfg.HAProxyConfigFile,
+			TimeoutAction:        cfg.TimeoutAction,
+			PollInterval:         cfg.PollInterval,
+			StartPeriod:          cfg.StartPeriod,
+			HealthyStatuses:      cfg.HealthyStatuses,
+			SurgeOverrides:       surgeOverrides,
+			CriticalCount:        cfg.CriticalCount,
+			FailOnUnmatched:      cfg.FailOnUnmatched,
+			ReconcileCount:       cfg.ReconcileCount,
+			MinOldUptime:         cfg.MinOldUptime,
+			Drain:                cfg.Drain,
+			ForceRegenerate:      cfg.ForceRegenerate,
+			NoProxy:              cfg.NoProxy,
+			TraefikAPI:           cfg.TraefikAPI,
+			TCPProbePort:         cfg.TCPProbePort,
+			Replicas:             cfg.Replicas,
+			SurgeAdd:             surgeAdd,
+			SurgeTarget:          surgeTarget,
+			PreStop:              r.preStopHook(cfg, serviceHooks, deployID),
+			DryRun:               cfg.DryRun,
 		})
 	case cli.StrategyBlueGreen:
 		return bgDeployer.Run(ctx, bluegreen.Options{
@@ -127,6 +428,15 @@ func (r *Runner) Run(ctx context.Context, cfg cli.Config) error {
 			HealthTimeout:     cfg.HealthcheckTimeout,
 			NoHealthTimeout:   cfg.NoHealthcheckTimeout,
 			WaitAfterHealthy:  cfg.WaitAfterHealthy,
+			TimeoutAction:     cfg.TimeoutAction,
+			PollInterval:      cfg.PollInterval,
+			StartPeriod:       cfg.StartPeriod,
+			HealthyStatuses:   cfg.HealthyStatuses,
+			SurgeOverrides:    surgeOverrides,
+			ProjectName:       composeProjectName(cfg),
+			CriticalCount:     cfg.CriticalCount,
+			TCPProbePort:      cfg.TCPProbePort,
+			Replicas:          cfg.Replicas,
 			Metrics: metricsgate.Config{
 				Enabled:          cfg.Analyze,
 				URL:              cfg.MetricsURL,
@@ -137,6 +447,7 @@ func (r *Runner) Run(ctx context.Context, cfg cli.Config) error {
 				Max4xxRatio:      cfg.AnalyzeMax4xxRatio,
 				MaxMeanLatencyMS: cfg.AnalyzeMaxLatencyMS,
 			},
+			DryRun: cfg.DryRun,
 		})
 	case cli.StrategyCanary:
 		return canaryDeployer.Run(ctx, canary.Options{
@@ -150,6 +461,19 @@ func (r *Runner) Run(ctx context.Context, cfg cli.Config) error {
 			HealthTimeout:     cfg.HealthcheckTimeout,
 			NoHealthTimeout:   cfg.NoHealthcheckTimeout,
 			WaitAfterHealthy:  cfg.WaitAfterHealthy,
+			TimeoutAction:     cfg.TimeoutAction,
+			PollInterval:      cfg.PollInterval,
+			StartPeriod:       cfg.StartPeriod,
+			HealthyStatuses:   cfg.HealthyStatuses,
+			SurgeOverrides:    surgeOverrides,
+			ProjectName:       composeProjectName(cfg),
+			CriticalCount:     cfg.CriticalCount,
+			TCPProbePort:      cfg.TCPProbePort,
+			Replicas:          cfg.Replicas,
+			CanaryRule:        cfg.CanaryRule,
+			RampDuration:      cfg.RampDuration,
+			RampSteps:         cfg.RampSteps,
+			CanaryDuration:    cfg.CanaryDuration,
 			Metrics: metricsgate.Config{
 				Enabled:          cfg.Analyze,
 				URL:              cfg.MetricsURL,
@@ -160,6 +484,7 @@ func (r *Runner) Run(ctx context.Context, cfg cli.Config) error {
 				Max4xxRatio:      cfg.AnalyzeMax4xxRatio,
 				MaxMeanLatencyMS: cfg.AnalyzeMaxLatencyMS,
 			},
+			DryRun: cfg.DryRun,
 		})
 	default:
 		return fmt.Errorf("unsupported strategy: %s", cfg.Strategy)
@@ -194,11 +519,12 @@ func (r *Runner) runAutoCleanup(ctx context.Context, cfg cli.Config, regStore *r
 		return nil
 	}
 
-	composeAdapter, err := selectComposeAdapter(cfg)
+	composeAdapter, err := selectComposeAdapter(cfg, r.log)
 	if err != nil {
 		return err
 	}
-	dockerClient := docker.NewClient(cfg.DockerArgs)
+	dockerClient := docker.NewClient(cfg.DockerArgs).WithMaxRetries(cfg.MaxRetries).WithStopConcurrency(cfg.StopConcurrency).WithStopTimeout(cfg.StopTimeout)
+	composeAdapter = compose.NewIgnoreFilter(composeAdapter, dockerClient).WithServiceLabel(cfg.ServiceLabel).WithProject(composeProjectName(cfg))
 	r.log.Infof("==> Running scheduled overdue cleanup across %d registered projects", len(entries))
 
 	var totalScheduledCount int
@@ -211,8 +537,8 @@ func (r *Runner) runAutoCleanup(ctx context.Context, cfg cli.Config, regStore *r
 		}
 		projectDir := entry.WorkingDir
 		store := state.NewStore(filepath.Join(projectDir, state.DefaultStateDir))
-		bgDeployer := bluegreen.NewDeployer(r.log, composeAdapter, dockerClient, store)
-		canaryDeployer := canary.NewDeployer(r.log, composeAdapter, dockerClient, store)
+		bgDeployer := bluegreen.NewDeployer(r.log, composeAdapter, dockerClient, store).WithReloader(proxyReloader(cfg))
+		canaryDeployer := canary.NewDeployer(r.log, composeAdapter, dockerClient, store).WithReloader(proxyReloader(cfg))
 
 		lockPath := filepath.Join(projectDir, state.DefaultStateDir, autoCleanupLockFileName)
 		unlock, acquired, err := state.TryExclusiveFileLock(lockPath)
@@ -277,15 +603,180 @@ func (r *Runner) runAutoCleanup(ctx context.Context, cfg cli.Config, regStore *r
 	return errors.Join(runErrs...)
 }
 
+func (r *Runner) acquireDeploySlot(ctx context.Context, cfg cli.Config) (func() error, error) {
+	if cfg.MaxConcurrentDeploys <= 0 {
+		return func() error { return nil }, nil
+	}
+	dir := state.ResolveDeploySlotsDir("")
+	return state.AcquireDeploySlot(ctx, dir, cfg.MaxConcurrentDeploys, cfg.DeploySlotTimeout, func() {
+		r.log.Infof("==> Host-wide deploy limit reached (%d concurrent deploys). Waiting up to %s for a free slot (%s)",
+			cfg.MaxConcurrentDeploys,
+			cfg.DeploySlotTimeout,
+			dir,
+		)
+	})
+}
+
+// acquireServiceLock takes the lock of the project's service so a second run against
+// it, e.g. a retriggered CI job, waits up to --lock-timeout instead of racing on the
+// containers and the proxy config.
+func (r *Runner) acquireServiceLock(ctx context.Context, cfg cli.Config, deployID string) (func() error, error) {
+	key, err := state.ServiceStateKey(composeProjectName(cfg), cfg.Service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve lock for service %s: %w", cfg.Service, err)
+	}
+	dir := filepath.Join(cfg.ProjectDirectory, state.DefaultStateDir, state.ServiceLocksDir)
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("pid %d on %s, %s of %s since %s, deploy %s", os.Getpid(), host, cfg.Action, cfg.Service, time.Now().UTC().Format(time.RFC3339), deployID)
+	return state.AcquireServiceLock(ctx, dir, key, holder, cfg.LockTimeout, func(current string) {
+		r.log.Infof("==> Service '%s' is locked by another ztd run (%s). Waiting up to %s for it to finish", cfg.Service, current, cfg.LockTimeout)
+	})
+}
+
+// buildSurgeOverrides returns the extra compose files used only for scale-up: the user's
+// --surge-override and, for deploys, an override stamping deploy metadata labels.
+func buildSurgeOverrides(ctx context.Context, cfg cli.Config, adapter compose.Adapter) ([]string, func(), error) {
+	var overrides []string
+	if cfg.SurgeOverride != "" {
+		overrides = append(overrides, cfg.SurgeOverride)
+	}
+	if cfg.Action != cli.ActionDeploy {
+		return overrides, func() {}, nil
+	}
+
+	restoreCleanup := func() {}
+	if cfg.RestoreImage != "" {
+		path, cleanup, err := compose.WriteImageOverride(cfg.Service, cfg.RestoreImage)
+		if err != nil {
+			return nil, nil, err
+		}
+		overrides = append(overrides, path)
+		restoreCleanup = cleanup
+	}
+
+	deployedBy := cfg.DeployedBy
+	if deployedBy == "" {
+		deployedBy = compose.DefaultDeployedBy()
+	}
+	// The hash of the base files is stamped so --skip-if-current can compare it later;
+	// compose's own config-hash label also covers this override and changes every deploy.
+	// Without a hash the new containers simply never count as current.
+	configHash, _ := compose.ServiceConfigHash(ctx, adapter, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	path, cleanup, err := compose.WriteDeployMetadataOverride(cfg.Service, compose.DeployMetadata{
+		DeployedAt: time.Now(),
+		Reason:     cfg.DeployReason,
+		DeployedBy: deployedBy,
+		ConfigHash: configHash,
+	})
+	if err != nil {
+		restoreCleanup()
+		return nil, nil, err
+	}
+	return append(overrides, path), func() { cleanup(); restoreCleanup() }, nil
+}
+
+// appendAudit adds entry to the audit log in store, only warning when that fails. A dry
+// run writes nothing.
+func (r *Runner) appendAudit(cfg cli.Config, store *state.Store, entry state.AuditEntry) {
+	if cfg.DryRun {
+		return
+	}
+	if err := store.AppendAudit(entry); err != nil {
+		r.log.WithError(err).Warn("==> Failed to write audit log entry")
+	}
+}
+
+// dryRunOut returns where the generators print the config they would write in a dry
+// run, or nil when they write it.
+func (r *Runner) dryRunOut(cfg cli.Config) io.Writer {
+	if !cfg.DryRun {
+		return nil
+	}
+	return r.out
+}
+
+// dryRunLog returns the logger the docker client reports skipped commands to in a dry
+// run, or nil when it runs them.
+func (r *Runner) dryRunLog(cfg cli.Config) *logrus.Logger {
+	if !cfg.DryRun {
+		return nil
+	}
+	return r.log
+}
+
+// tracksResult reports whether a run of cfg is a SERVICE deploy that gets a summary
+// line and, with --output json, a deployment result.
+func tracksResult(cfg cli.Config) bool {
+	return cfg.Action == cli.ActionDeploy && cfg.Service != "up" && cfg.RestoreImage == "" && !cfg.DryRun && !cfg.ResetBreaker
+}
+
+// deployFinished returns the deploy-finished event for a deploy of service that ended
+// with err.
+func deployFinished(service string, deployID string, err error) events.Event {
+	finished := events.Event{Type: events.TypeDeployFinished, Service: service, Status: "success", DeployID: deployID}
+	if err != nil {
+		finished.Status = "failed"
+		finished.Message = err.Error()
+		finished.Reason = string(safeguard.ReasonOf(err))
+	}
+	return finished
+}
+
+// openEventSink connects to --events-socket. A dry run emits no events, since nothing
+// it would report happens.
+func openEventSink(cfg cli.Config) (events.Sink, func(), error) {
+	if strings.TrimSpace(cfg.EventsSocket) == "" || cfg.DryRun {
+		return events.Nop{}, func() {}, nil
+	}
+	sink, err := events.DialSocket(cfg.EventsSocket)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sink, func() { _ = sink.Close() }, nil
+}
+
 func registerCurrentWorkingDir(store *registry.Store) error {
-	wd, err := os.Getwd()
+	return registerWorkingDir(store, "")
+}
+
+// registerWorkingDir registers projectDir, or the current directory when it is empty.
+func registerWorkingDir(store *registry.Store, projectDir string) error {
+	dir := projectDir
+	if dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		dir = wd
+	}
+	abs, err := filepath.Abs(dir)
 	if err != nil {
 		return err
 	}
-	_, err = store.Register(wd)
+	_, err = store.Register(abs)
 	return err
 }
 
+// composeProjectName returns the compose project name of cfg, derived like docker
+// compose does; see compose.ProjectName. It is also the fallback used when containers
+// carry no project label.
+func composeProjectName(cfg cli.Config) string {
+	return compose.ProjectName(cfg.ComposeFiles, cfg.EnvFiles, cfg.ProjectDirectory, explicitProjectName(cfg))
+}
+
+// explicitProjectName returns the compose project set with --project or
+// COMPOSE_PROJECT_NAME, from the environment or the env files (--env-file or .env) as
+// docker compose reads it, or an empty string when compose derives it.
+func explicitProjectName(cfg cli.Config) string {
+	if cfg.ProjectName != "" {
+		return cfg.ProjectName
+	}
+	if env, err := compose.LoadEnv(cfg.ComposeFiles, cfg.EnvFiles); err == nil {
+		return strings.TrimSpace(env["COMPOSE_PROJECT_NAME"])
+	}
+	return strings.TrimSpace(os.Getenv("COMPOSE_PROJECT_NAME"))
+}
+
 func registryStrictMode() bool {
 	raw := strings.TrimSpace(strings.ToLower(os.Getenv(envRegistryStrict)))
 	switch raw {
@@ -337,7 +828,11 @@ type composeServicesFile struct {
 	Services map[string]any `yaml:"services"`
 }
 
-func collectComposeServices(files []string) ([]string, error) {
+func collectComposeServices(files []string, envFiles []string) ([]string, error) {
+	env, err := compose.LoadEnv(files, envFiles)
+	if err != nil {
+		return nil, err
+	}
 	services := map[string]struct{}{}
 	for _, file := range files {
 		data, err := os.ReadFile(file)
@@ -346,7 +841,7 @@ func collectComposeServices(files []string) ([]string, error) {
 		}
 
 		var cfg composeServicesFile
-		if err := configio.UnmarshalYAML(data, &cfg); err != nil {
+		if err := compose.UnmarshalInterpolated(data, env, &cfg); err != nil {
 			return nil, err
 		}
 
@@ -363,8 +858,11 @@ func collectComposeServices(files []string) ([]string, error) {
 	return out, nil
 }
 
-func selectComposeAdapter(cfg cli.Config) (compose.Adapter, error) {
+func selectComposeAdapter(cfg cli.Config, log *logrus.Logger) (compose.Adapter, error) {
 	if os.Getenv("ZTD_COMPOSE_ADAPTER") == "api" {
+		if cfg.DryRun {
+			return nil, fmt.Errorf("--dry-run is not supported with ZTD_COMPOSE_ADAPTER=api")
+		}
 		return compose.NewAPIAdapter(), nil
 	}
 
@@ -372,7 +870,7 @@ func selectComposeAdapter(cfg cli.Config) (compose.Adapter, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize compose adapter: %w", err)
 	}
-	return adapter, nil
+	return adapter.WithProjectDirectory(cfg.ProjectDirectory).WithProjectName(cfg.ProjectName).WithMaxRetries(cfg.MaxRetries).WithRemoveOrphans(cfg.RemoveOrphans).WithDryRun(cfg.DryRun).WithLogger(log), nil
 }
 
 func ensureNoConflictingActiveDeployment(cfg cli.Config, store *state.Store) error {
@@ -434,7 +932,7 @@ func strategyFinishHint(strategy string) string {
 	case state.StrategyBlueGreen:
 		return "--strategy=blue-green switch/cleanup"
 	case state.StrategyCanary:
-		return "--strategy=canary rollback/cleanup"
+		return "--strategy=canary promote/abort/cleanup"
 	default:
 		return "cleanup for the active deployment strategy"
 	}
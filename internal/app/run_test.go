@@ -186,3 +186,59 @@ func TestEnsureNoConflictingActiveDeployment_IgnoresNonDeployAction(t *testing.T
 		t.Fatalf("expected no error for cleanup action, got: %v", err)
 	}
 }
+
+func TestCollectComposeServices_AnchoredServices(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "compose.yml")
+	data := `x-app: &app
+  image: app:latest
+services:
+  api:
+    <<: *app
+  worker: *app
+`
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+
+	services, err := collectComposeServices([]string{file}, nil)
+	if err != nil {
+		t.Fatalf("collect services: %v", err)
+	}
+	if strings.Join(services, ",") != "api,worker" {
+		t.Fatalf("unexpected services: %v", services)
+	}
+}
+
+func TestComposeProjectName_FromEnvFile(t *testing.T) {
+	t.Setenv("COMPOSE_PROJECT_NAME", "")
+	os.Unsetenv("COMPOSE_PROJECT_NAME")
+	dir := filepath.Join(t.TempDir(), "checkout")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(composePath, []byte("services:\n  api:\n    image: api:1\n"), 0o644); err != nil {
+		t.Fatalf("write compose: %v", err)
+	}
+	if got := composeProjectName(cli.Config{ComposeFiles: []string{composePath}}); got != "checkout" {
+		t.Fatalf("expected the directory name without .env, got %q", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("COMPOSE_PROJECT_NAME=Shop\n"), 0o644); err != nil {
+		t.Fatalf("write .env: %v", err)
+	}
+	if got := composeProjectName(cli.Config{ComposeFiles: []string{composePath}}); got != "shop" {
+		t.Fatalf("expected the name from .env, got %q", got)
+	}
+
+	envFile := filepath.Join(dir, "prod.env")
+	if err := os.WriteFile(envFile, []byte("COMPOSE_PROJECT_NAME=shop-prod\n"), 0o644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	if got := composeProjectName(cli.Config{ComposeFiles: []string{composePath}, EnvFiles: []string{envFile}}); got != "shop-prod" {
+		t.Fatalf("expected the name from --env-file, got %q", got)
+	}
+	if got := composeProjectName(cli.Config{ComposeFiles: []string{composePath}, EnvFiles: []string{envFile}, ProjectName: "other"}); got != "other" {
+		t.Fatalf("expected --project to win, got %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/signature"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+)
+
+// verifyImageSignature checks the service's target image before any container is created
+// and records the outcome in the audit log.
+func (r *Runner) verifyImageSignature(ctx context.Context, cfg cli.Config, adapter compose.Adapter, store *state.Store, deployID string) error {
+	image, err := adapter.ServiceImage(ctx, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service)
+	if err != nil {
+		return fmt.Errorf("failed to resolve target image for service %s: %w", cfg.Service, err)
+	}
+
+	r.log.Infof("==> Verifying signature of image %s", image)
+	_, verifyErr := signature.Verify(ctx, cfg.VerifyCommand, image)
+	entry := state.AuditEntry{
+		Service:  cfg.Service,
+		Strategy: cfg.Strategy,
+		Result:   state.AuditResultSignatureVerified,
+		Reason:   image,
+		DeployID: deployID,
+	}
+	if verifyErr != nil {
+		entry.Result = state.AuditResultSignatureRejected
+		entry.Reason = verifyErr.Error()
+	}
+	r.appendAudit(cfg, store, entry)
+	if verifyErr != nil {
+		return verifyErr
+	}
+	r.log.Infof("==> Signature of image %s verified", image)
+	return nil
+}
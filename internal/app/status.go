@@ -0,0 +1,177 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/healthdiag"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
+)
+
+const composeContainerNumberLabel = "com.docker.compose.container-number"
+
+// ErrNotReady is returned by the status action when a service is missing containers,
+// has an unhealthy one, or is not routed by Traefik.
+var ErrNotReady = errors.New("service is not ready")
+
+type statusInspector interface {
+	Labels(ctx context.Context, containerID string) (map[string]string, error)
+	State(ctx context.Context, containerID string) (docker.ContainerState, error)
+	HasHealthcheck(ctx context.Context, containerID string) (bool, error)
+	HealthStatus(ctx context.Context, containerID string) (string, error)
+}
+
+type containerStatus struct {
+	ID     string
+	Number string
+	Health string
+	// Routed is empty when the service is not expected in the Traefik config.
+	Routed string
+	Ready  bool
+}
+
+type serviceStatus struct {
+	Service    string
+	Replicas   int
+	Containers []containerStatus
+}
+
+// problems lists why the service is not ready; none means it is.
+func (s serviceStatus) problems() []string {
+	var problems []string
+	if len(s.Containers) == 0 {
+		problems = append(problems, "no running containers")
+	} else if s.Replicas > 0 && len(s.Containers) != s.Replicas {
+		problems = append(problems, fmt.Sprintf("%d replicas running, %d requested", len(s.Containers), s.Replicas))
+	}
+	for _, c := range s.Containers {
+		if !c.Ready {
+			problems = append(problems, fmt.Sprintf("container %s is %s", c.ID, c.Health))
+		} else if c.Routed == "no" {
+			problems = append(problems, fmt.Sprintf("container %s is not in the Traefik config", c.ID))
+		}
+	}
+	return problems
+}
+
+// runStatus prints the containers of every requested service and fails with
+// ErrNotReady unless all of them are healthy and routed. It never writes state.
+func (r *Runner) runStatus(ctx context.Context, cfg cli.Config) error {
+	cfg.TraefikConfigFile = resolveTraefikConfigPath(cfg.ProjectDirectory, cfg.TraefikConfigFile)
+	composeAdapter, err := selectComposeAdapter(cfg, r.log)
+	if err != nil {
+		return err
+	}
+	dockerClient := docker.NewClient(cfg.DockerArgs).WithMaxRetries(cfg.MaxRetries)
+	composeAdapter = compose.NewIgnoreFilter(composeAdapter, dockerClient).WithServiceLabel(cfg.ServiceLabel).WithProject(composeProjectName(cfg))
+	hosts, err := traefik.ReferencedHosts(cfg.TraefikConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read traefik config: %w", err)
+	}
+
+	services := cfg.Services
+	if len(services) == 0 {
+		services = []string{cfg.Service}
+	}
+	w := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVICE\tCONTAINER\tNUMBER\tHEALTH\tROUTED")
+	var notReady []string
+	for _, service := range services {
+		status, err := collectServiceStatus(ctx, cfg, composeAdapter, dockerClient, hosts, service)
+		if err != nil {
+			return err
+		}
+		for _, c := range status.Containers {
+			routed := c.Routed
+			if routed == "" {
+				routed = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", service, c.ID, c.Number, c.Health, routed)
+		}
+		if problems := status.problems(); len(problems) > 0 {
+			notReady = append(notReady, fmt.Sprintf("%s: %s", service, strings.Join(problems, "; ")))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(notReady) > 0 {
+		return fmt.Errorf("%w: %s", ErrNotReady, strings.Join(notReady, ", "))
+	}
+	return nil
+}
+
+func collectServiceStatus(ctx context.Context, cfg cli.Config, adapter compose.Adapter, inspector statusInspector, hosts map[string]bool, service string) (serviceStatus, error) {
+	status := serviceStatus{Service: service, Replicas: cfg.Replicas}
+	ids, err := adapter.PsQuiet(ctx, cfg.ComposeFiles, cfg.EnvFiles, service)
+	if err != nil {
+		return status, err
+	}
+	routes := false
+	if len(ids) > 0 && len(cfg.ComposeFiles) > 0 {
+		if routes, err = traefik.RoutesService(cfg.ComposeFiles, cfg.EnvFiles, service); err != nil {
+			return status, err
+		}
+	}
+
+	for _, id := range ids {
+		labels, err := inspector.Labels(ctx, id)
+		if err != nil {
+			return status, err
+		}
+		c := containerStatus{ID: shortContainerID(id), Number: labels[composeContainerNumberLabel]}
+		if c.Number == "" {
+			c.Number = "-"
+		}
+		if c.Health, c.Ready, err = containerHealth(ctx, inspector, id, cfg.HealthyStatuses); err != nil {
+			return status, err
+		}
+		if routes && proxy.Resolve(labels, cfg.ProxyType) == proxy.TypeTraefik {
+			c.Routed = "no"
+			if traefik.IsReferenced(hosts, id, labels, cfg.ServiceLabel) {
+				c.Routed = "yes"
+			}
+		}
+		status.Containers = append(status.Containers, c)
+	}
+	return status, nil
+}
+
+// containerHealth returns the health status of a container, or its state when it has
+// no healthcheck, and whether that counts as ready.
+func containerHealth(ctx context.Context, inspector statusInspector, id string, accepted []string) (string, bool, error) {
+	st, err := inspector.State(ctx, id)
+	if err != nil {
+		return "", false, err
+	}
+	if !st.Running {
+		return st.Status, false, nil
+	}
+	hasHealthcheck, err := inspector.HasHealthcheck(ctx, id)
+	if err != nil {
+		return "", false, err
+	}
+	if !hasHealthcheck {
+		return st.Status, true, nil
+	}
+	health, err := inspector.HealthStatus(ctx, id)
+	if err != nil {
+		return "", false, err
+	}
+	return health, healthdiag.IsAcceptedStatus(health, accepted), nil
+}
+
+func shortContainerID(id string) string {
+	id = strings.TrimSpace(id)
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
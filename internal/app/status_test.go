@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+func TestCollectServiceStatus(t *testing.T) {
+	composeFile := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(composeFile, []byte("services:\n  api:\n    labels:\n      traefik.enable: \"true\"\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	cfg := cli.Config{ComposeFiles: []string{composeFile}, ProxyType: cli.ProxyTraefik}
+	adapter := &versionComposeMock{ids: []string{"aaaaaaaaaaaa1111", "bbbbbbbbbbbb2222"}}
+	inspector := &currentDockerMock{
+		labels: map[string]map[string]string{
+			"aaaaaaaaaaaa1111": {composeContainerNumberLabel: "1"},
+			"bbbbbbbbbbbb2222": {composeContainerNumberLabel: "2"},
+		},
+		health: map[string]string{"aaaaaaaaaaaa1111": "healthy", "bbbbbbbbbbbb2222": "healthy"},
+	}
+	hosts := map[string]bool{"aaaaaaaaaaaa": true, "bbbbbbbbbbbb": true}
+
+	status, err := collectServiceStatus(context.Background(), cfg, adapter, inspector, hosts, "api")
+	if err != nil {
+		t.Fatalf("collect status: %v", err)
+	}
+	if len(status.Containers) != 2 || status.Containers[1].Number != "2" || status.Containers[1].Routed != "yes" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if problems := status.problems(); len(problems) != 0 {
+		t.Fatalf("expected ready service, got %v", problems)
+	}
+
+	inspector.health["bbbbbbbbbbbb2222"] = "unhealthy"
+	delete(hosts, "aaaaaaaaaaaa")
+	cfg.Replicas = 3
+	status, err = collectServiceStatus(context.Background(), cfg, adapter, inspector, hosts, "api")
+	if err != nil {
+		t.Fatalf("collect status: %v", err)
+	}
+	problems := strings.Join(status.problems(), "; ")
+	for _, want := range []string{"2 replicas running, 3 requested", "container aaaaaaaaaaaa is not in the Traefik config", "container bbbbbbbbbbbb is unhealthy"} {
+		if !strings.Contains(problems, want) {
+			t.Fatalf("expected %q in problems, got %q", want, problems)
+		}
+	}
+}
+
+func TestCollectServiceStatus_SkipsRoutingWithoutTraefik(t *testing.T) {
+	composeFile := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if err := os.WriteFile(composeFile, []byte("services:\n  worker:\n    image: worker\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	cfg := cli.Config{ComposeFiles: []string{composeFile}, ProxyType: cli.ProxyTraefik}
+	adapter := &versionComposeMock{ids: []string{"c1"}}
+	inspector := &currentDockerMock{}
+
+	status, err := collectServiceStatus(context.Background(), cfg, adapter, inspector, nil, "worker")
+	if err != nil {
+		t.Fatalf("collect status: %v", err)
+	}
+	if len(status.Containers) != 1 || status.Containers[0].Routed != "" || status.Containers[0].Health != "running" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if problems := status.problems(); len(problems) != 0 {
+		t.Fatalf("expected ready service, got %v", problems)
+	}
+
+	adapter.ids = nil
+	status, err = collectServiceStatus(context.Background(), cfg, adapter, inspector, nil, "worker")
+	if err != nil {
+		t.Fatalf("collect status: %v", err)
+	}
+	if problems := status.problems(); len(problems) != 1 || problems[0] != "no running containers" {
+		t.Fatalf("expected missing containers, got %v", problems)
+	}
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"os"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
+
+// prepareStdinCompose buffers a compose file passed as -f - to a temporary file and
+// points cfg at it. The returned func removes the file once the run is over. A dry run
+// buffers it outside the project directory.
+func (r *Runner) prepareStdinCompose(cfg cli.Config) (cli.Config, func(), error) {
+	idx := -1
+	for i, file := range cfg.ComposeFiles {
+		if file == cli.StdinComposeFile {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return cfg, func() {}, nil
+	}
+	if cfg.ProjectDirectory == "" {
+		// docker compose treats the working directory as the project directory of a
+		// compose file read from stdin.
+		cfg.ProjectDirectory = "."
+	}
+	dir, cleanupDir, err := scratchDir(cfg, cfg.ProjectDirectory)
+	if err != nil {
+		return cfg, func() {}, err
+	}
+	path, err := compose.BufferStdin(r.in, dir)
+	if err != nil {
+		cleanupDir()
+		return cfg, func() {}, err
+	}
+	r.log.Debugf("==> Buffered compose file from stdin to %s", path)
+	cfg.ComposeFiles = append([]string{}, cfg.ComposeFiles...)
+	cfg.ComposeFiles[idx] = path
+	return cfg, func() { _ = os.Remove(path); cleanupDir() }, nil
+}
+
+// scratchDir returns dir, or in a dry run a temporary directory, so a dry run writes
+// nothing under the project. The returned func removes the temporary directory.
+func scratchDir(cfg cli.Config, dir string) (string, func(), error) {
+	if !cfg.DryRun {
+		return dir, func() {}, nil
+	}
+	tmp, err := os.MkdirTemp("", "ztd-dry-run-*")
+	if err != nil {
+		return "", nil, err
+	}
+	return tmp, func() { _ = os.RemoveAll(tmp) }, nil
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+func TestPrepareStdinCompose_BuffersAndCleansUp(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	runner.in = strings.NewReader("services:\n  api:\n    image: nginx:1.27\n")
+	dir := t.TempDir()
+	files := []string{"compose.yml", cli.StdinComposeFile}
+
+	cfg, cleanup, err := runner.prepareStdinCompose(cli.Config{ComposeFiles: files, ProjectDirectory: dir})
+	if err != nil {
+		t.Fatalf("prepare stdin compose: %v", err)
+	}
+	if files[1] != cli.StdinComposeFile {
+		t.Fatalf("expected the caller's file list to be left alone, got %v", files)
+	}
+	if len(cfg.ComposeFiles) != 2 || cfg.ComposeFiles[0] != "compose.yml" || filepath.Dir(cfg.ComposeFiles[1]) != dir {
+		t.Fatalf("expected stdin replaced by a file in the project directory, got %v", cfg.ComposeFiles)
+	}
+	if _, err := os.Stat(cfg.ComposeFiles[1]); err != nil {
+		t.Fatalf("expected buffered compose file: %v", err)
+	}
+	cleanup()
+	if _, err := os.Stat(cfg.ComposeFiles[1]); !os.IsNotExist(err) {
+		t.Fatalf("expected buffered compose file removed, got %v", err)
+	}
+}
+
+func TestPrepareStdinCompose_NoStdin(t *testing.T) {
+	runner := NewRunner(logrus.New())
+	cfg, cleanup, err := runner.prepareStdinCompose(cli.Config{ComposeFiles: []string{"compose.yml"}})
+	if err != nil {
+		t.Fatalf("prepare stdin compose: %v", err)
+	}
+	cleanup()
+	if len(cfg.ComposeFiles) != 1 || cfg.ComposeFiles[0] != "compose.yml" || cfg.ProjectDirectory != "" {
+		t.Fatalf("expected config untouched, got %#v", cfg)
+	}
+}
+
+func TestPrepareStdinCompose_DryRunWritesOutsideProject(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	runner.in = strings.NewReader("services:\n  api:\n    image: nginx:1.27\n")
+	dir := t.TempDir()
+
+	cfg, cleanup, err := runner.prepareStdinCompose(cli.Config{ComposeFiles: []string{cli.StdinComposeFile}, ProjectDirectory: dir, DryRun: true})
+	if err != nil {
+		t.Fatalf("prepare stdin compose: %v", err)
+	}
+	if filepath.Dir(cfg.ComposeFiles[0]) == dir {
+		t.Fatalf("expected a dry run to buffer outside the project directory, got %v", cfg.ComposeFiles)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected nothing written to the project directory, got %v", entries)
+	}
+	cleanup()
+	if _, err := os.Stat(filepath.Dir(cfg.ComposeFiles[0])); !os.IsNotExist(err) {
+		t.Fatalf("expected the temporary directory removed, got %v", err)
+	}
+}
+
+func TestPrepareInlineCompose_DryRunWritesOutsideProject(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	dir := t.TempDir()
+
+	cfg, cleanup, err := runner.prepareInlineCompose(cli.Config{Service: "api", Image: "nginx:1.27", ProjectDirectory: dir, DryRun: true})
+	if err != nil {
+		t.Fatalf("prepare inline compose: %v", err)
+	}
+	if _, err := os.Stat(cfg.ComposeFiles[0]); err != nil {
+		t.Fatalf("expected generated compose file: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected nothing written to the project directory, got %v", entries)
+	}
+	cleanup()
+	if _, err := os.Stat(cfg.ComposeFiles[0]); !os.IsNotExist(err) {
+		t.Fatalf("expected generated compose file removed, got %v", err)
+	}
+}
+
+func TestRunServices_ReadsStdinComposeOnce(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	runner.in = strings.NewReader("services:\n  other:\n    image: nginx:1.27\n")
+	runner.out = io.Discard
+	dir := t.TempDir()
+	cfg := cli.Config{
+		Action:           cli.ActionDeploy,
+		Services:         []string{"api", "web"},
+		ComposeFiles:     []string{cli.StdinComposeFile},
+		ProjectDirectory: dir,
+		Parallel:         true,
+		ContinueOnError:  true,
+	}
+
+	err := runner.RunServices(context.Background(), cfg)
+	if err == nil {
+		t.Fatal("expected the undeclared services to fail")
+	}
+	for _, service := range cfg.Services {
+		if !strings.Contains(err.Error(), "service "+service+" is not declared") {
+			t.Fatalf("expected %s to be checked against the buffered compose file, got %v", service, err)
+		}
+	}
+	if strings.Contains(err.Error(), "input is empty") {
+		t.Fatalf("expected stdin to be read only once, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected the buffered compose file removed, got %v", entries)
+	}
+}
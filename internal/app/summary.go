@@ -0,0 +1,148 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+)
+
+// deploySummary collects the outcome of every service deployed in one run from the
+// progress events, so it can be printed once all of them have finished.
+type deploySummary struct {
+	mu       sync.Mutex
+	services []*serviceSummary
+}
+
+type serviceSummary struct {
+	service   string
+	start     time.Time
+	elapsed   time.Duration
+	status    string
+	promoted  int
+	removed   int
+	readiness string
+	oldIDs    []string
+	newIDs    []string
+	health    map[string]string
+	swapped   bool
+	err       string
+	reason    string
+}
+
+// DeploymentResult is the outcome of one service deploy printed by --output json.
+type DeploymentResult struct {
+	Service         string            `json:"service"`
+	Status          string            `json:"status"`
+	DurationSeconds int               `json:"durationSeconds"`
+	OldContainers   []string          `json:"oldContainers,omitempty"`
+	NewContainers   []string          `json:"newContainers,omitempty"`
+	Readiness       string            `json:"readiness,omitempty"`
+	ContainerHealth map[string]string `json:"containerHealth,omitempty"`
+	ConfigUpdated   bool              `json:"configUpdated"`
+	Error           string            `json:"error,omitempty"`
+	Reason          string            `json:"reason,omitempty"`
+}
+
+// track starts the summary of service and returns the sink that fills it in.
+func (s *deploySummary) track(service string, start time.Time) events.Sink {
+	entry := &serviceSummary{service: service, start: start}
+	s.mu.Lock()
+	s.services = append(s.services, entry)
+	s.mu.Unlock()
+	return summarySink{summary: s, entry: entry}
+}
+
+type summarySink struct {
+	summary *deploySummary
+	entry   *serviceSummary
+}
+
+func (s summarySink) Emit(event events.Event) {
+	s.summary.mu.Lock()
+	defer s.summary.mu.Unlock()
+	switch {
+	case event.Type == events.TypeSwapComplete:
+		s.entry.promoted += event.Count
+		s.entry.newIDs = appendMissing(s.entry.newIDs, event.Containers)
+		s.entry.oldIDs = appendMissing(s.entry.oldIDs, event.Replaced)
+		s.entry.swapped = true
+	case event.Type == events.TypeContainersReady:
+		s.entry.newIDs = appendMissing(s.entry.newIDs, event.Containers)
+		s.entry.oldIDs = appendMissing(s.entry.oldIDs, event.Replaced)
+	case event.Type == events.TypePhase && event.Phase == events.PhaseRemove:
+		s.entry.removed += event.Count
+		s.entry.oldIDs = appendMissing(s.entry.oldIDs, event.Containers)
+	case event.Type == events.TypeContainerHealth:
+		if s.entry.health == nil {
+			s.entry.health = map[string]string{}
+		}
+		s.entry.health[event.Container] = event.Status
+	case event.Type == events.TypePhase && event.Phase == events.PhaseWaitHealthy && event.Message != "":
+		s.entry.readiness = event.Message
+	case event.Type == events.TypeDeployFinished:
+		s.entry.status = event.Status
+		s.entry.elapsed = time.Since(s.entry.start)
+		s.entry.err = event.Message
+		s.entry.reason = event.Reason
+	}
+}
+
+// appendMissing appends the ids not yet in list, since blue-green and canary report
+// the same containers on several events.
+func appendMissing(list []string, ids []string) []string {
+	for _, id := range ids {
+		if !slices.Contains(list, id) {
+			list = append(list, id)
+		}
+	}
+	return list
+}
+
+// print logs one line per tracked service and, with jsonOut set, writes the results to
+// it as one JSON object per line. The tracked services are forgotten afterwards.
+func (s *deploySummary) print(log *logrus.Logger, jsonOut io.Writer) error {
+	s.mu.Lock()
+	services := s.services
+	s.services = nil
+	s.mu.Unlock()
+
+	for _, entry := range services {
+		readiness := entry.readiness
+		if readiness == "" {
+			readiness = "none"
+		}
+		log.Infof("==> Summary: %s: %s in %s, %d new containers promoted, %d old containers removed, readiness: %s",
+			entry.service, entry.status, entry.elapsed.Round(time.Second), entry.promoted, entry.removed, readiness)
+	}
+	if jsonOut == nil {
+		return nil
+	}
+	enc := json.NewEncoder(jsonOut)
+	for _, entry := range services {
+		if err := enc.Encode(entry.result()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *serviceSummary) result() DeploymentResult {
+	return DeploymentResult{
+		Service:         e.service,
+		Status:          e.status,
+		DurationSeconds: int(e.elapsed.Round(time.Second) / time.Second),
+		OldContainers:   e.oldIDs,
+		NewContainers:   e.newIDs,
+		Readiness:       e.readiness,
+		ContainerHealth: e.health,
+		ConfigUpdated:   e.swapped,
+		Error:           e.err,
+		Reason:          e.reason,
+	}
+}
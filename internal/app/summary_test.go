@@ -0,0 +1,143 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+)
+
+func TestDeploySummary_CollectsEventsPerService(t *testing.T) {
+	summary := &deploySummary{}
+	api := summary.track("api", time.Now().Add(-42*time.Second))
+	api.Emit(events.Event{Type: events.TypePhase, Phase: events.PhaseWaitHealthy, Message: events.ReadinessHealthcheck})
+	api.Emit(events.Event{Type: events.TypeSwapComplete, Count: 3})
+	api.Emit(events.Event{Type: events.TypePhase, Phase: events.PhaseRemove, Count: 3})
+	api.Emit(events.Event{Type: events.TypeDeployFinished, Status: "success"})
+	worker := summary.track("worker", time.Now())
+	worker.Emit(events.Event{Type: events.TypeDeployFinished, Status: "failed"})
+
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableQuote: true})
+	summary.print(log, nil)
+
+	out := buf.String()
+	if !strings.Contains(out, "==> Summary: api: success in 42s, 3 new containers promoted, 3 old containers removed, readiness: healthcheck") {
+		t.Fatalf("unexpected api summary:\n%s", out)
+	}
+	if !strings.Contains(out, "==> Summary: worker: failed in 0s, 0 new containers promoted, 0 old containers removed, readiness: none") {
+		t.Fatalf("unexpected worker summary:\n%s", out)
+	}
+
+	buf.Reset()
+	summary.print(log, nil)
+	if buf.Len() != 0 {
+		t.Fatalf("expected the summary to be printed once, got:\n%s", buf.String())
+	}
+}
+
+func TestDeploySummary_WritesJSONResults(t *testing.T) {
+	summary := &deploySummary{}
+	api := summary.track("api", time.Now())
+	api.Emit(events.Event{Type: events.TypeContainerHealth, Container: "new-1", Status: "healthy"})
+	api.Emit(events.Event{Type: events.TypeSwapComplete, Count: 1, Containers: []string{"new-1"}})
+	api.Emit(events.Event{Type: events.TypePhase, Phase: events.PhaseRemove, Count: 1, Containers: []string{"old-1"}})
+	api.Emit(events.Event{Type: events.TypeDeployFinished, Status: "success"})
+	worker := summary.track("worker", time.Now())
+	worker.Emit(events.Event{Type: events.TypeDeployFinished, Status: "failed", Message: "boom", Reason: "unhealthy"})
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	var out bytes.Buffer
+	if err := summary.print(log, &out); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one result per service, got:\n%s", out.String())
+	}
+	var got DeploymentResult
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Service != "api" || got.Status != "success" || !got.ConfigUpdated ||
+		strings.Join(got.NewContainers, ",") != "new-1" || strings.Join(got.OldContainers, ",") != "old-1" ||
+		got.ContainerHealth["new-1"] != "healthy" {
+		t.Fatalf("unexpected api result: %#v", got)
+	}
+	got = DeploymentResult{}
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Status != "failed" || got.Error != "boom" || got.Reason != "unhealthy" || got.ConfigUpdated {
+		t.Fatalf("unexpected worker result: %#v", got)
+	}
+}
+
+func TestDeploySummary_CanaryReportsOldAndNewOnce(t *testing.T) {
+	summary := &deploySummary{}
+	canary := summary.track("api", time.Now())
+	canary.Emit(events.Event{Type: events.TypeSwapComplete, Count: 1, Containers: []string{"new-1"}, Replaced: []string{"old-1"}})
+	canary.Emit(events.Event{Type: events.TypePhase, Phase: events.PhaseRemove, Count: 1, Containers: []string{"old-1"}})
+	canary.Emit(events.Event{Type: events.TypeDeployFinished, Status: "success"})
+	blueGreen := summary.track("web", time.Now())
+	blueGreen.Emit(events.Event{Type: events.TypeContainersReady, Count: 1, Containers: []string{"green-1"}, Replaced: []string{"blue-1"}})
+	blueGreen.Emit(events.Event{Type: events.TypeDeployFinished, Status: "success"})
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	var out bytes.Buffer
+	if err := summary.print(log, &out); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var api, web DeploymentResult
+	if err := json.Unmarshal([]byte(lines[0]), &api); err != nil {
+		t.Fatalf("decode api result: %v", err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &web); err != nil {
+		t.Fatalf("decode web result: %v", err)
+	}
+	if strings.Join(api.OldContainers, ",") != "old-1" || strings.Join(api.NewContainers, ",") != "new-1" || !api.ConfigUpdated {
+		t.Fatalf("unexpected canary result: %+v", api)
+	}
+	if strings.Join(web.OldContainers, ",") != "blue-1" || strings.Join(web.NewContainers, ",") != "green-1" || web.ConfigUpdated {
+		t.Fatalf("unexpected blue-green result: %+v", web)
+	}
+}
+
+func TestRunServices_ReportsEarlyFailure(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	runner := NewRunner(log)
+	var out bytes.Buffer
+	runner.out = &out
+
+	err := runner.RunServices(context.Background(), cli.Config{
+		Service:      "api",
+		ComposeFiles: []string{filepath.Join(t.TempDir(), "missing.yml")},
+		Output:       cli.OutputJSON,
+	})
+	if err == nil {
+		t.Fatal("expected a missing compose file to fail the run")
+	}
+	var result DeploymentResult
+	if decodeErr := json.Unmarshal(out.Bytes(), &result); decodeErr != nil {
+		t.Fatalf("expected a JSON result, got %q: %v", out.String(), decodeErr)
+	}
+	if result.Service != "api" || result.Status != "failed" || result.Error == "" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+)
+
+type versionLabelReader interface {
+	Labels(ctx context.Context, containerID string) (map[string]string, error)
+	ImageLabels(ctx context.Context, image string) (map[string]string, error)
+}
+
+// versionUnchanged reports whether every running container of the service already
+// carries the target image's value for label. The returned version is the target value.
+func versionUnchanged(ctx context.Context, adapter compose.Adapter, docker versionLabelReader, files []string, envFiles []string, service string, label string) (bool, string, error) {
+	image, err := adapter.ServiceImage(ctx, files, envFiles, service)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to resolve target image for service %s: %w", service, err)
+	}
+	imageLabels, err := docker.ImageLabels(ctx, image)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to inspect target image %s: %w", image, err)
+	}
+	target := strings.TrimSpace(imageLabels[label])
+	if target == "" {
+		return false, "", nil
+	}
+
+	containerIDs, err := adapter.PsQuiet(ctx, files, envFiles, service)
+	if err != nil {
+		return false, target, err
+	}
+	if len(containerIDs) == 0 {
+		return false, target, nil
+	}
+	for _, id := range containerIDs {
+		labels, err := docker.Labels(ctx, id)
+		if err != nil {
+			return false, target, err
+		}
+		if strings.TrimSpace(labels[label]) != target {
+			return false, target, nil
+		}
+	}
+	return true, target, nil
+}
+
+func (r *Runner) skipUnchangedDeploy(ctx context.Context, cfg cli.Config, adapter compose.Adapter, docker versionLabelReader, store *state.Store, deployID string) (bool, error) {
+	unchanged, version, err := versionUnchanged(ctx, adapter, docker, cfg.ComposeFiles, cfg.EnvFiles, cfg.Service, cfg.VersionLabel)
+	if err != nil {
+		return false, err
+	}
+	if version == "" {
+		r.log.Warnf("==> Target image for service '%s' has no '%s' label, deploying anyway", cfg.Service, cfg.VersionLabel)
+		return false, nil
+	}
+	if !unchanged {
+		r.log.Infof("==> Service '%s' version changed to %s, deploying", cfg.Service, version)
+		return false, nil
+	}
+
+	r.log.Infof("==> Service '%s' already runs %s=%s, skipping deploy", cfg.Service, cfg.VersionLabel, version)
+	r.appendAudit(cfg, store, state.AuditEntry{
+		Service:  cfg.Service,
+		Strategy: cfg.Strategy,
+		Result:   state.AuditResultSkipped,
+		Reason:   fmt.Sprintf("no change (%s=%s)", cfg.VersionLabel, version),
+		DeployID: deployID,
+	})
+	return true, nil
+}
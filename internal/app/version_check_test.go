@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
+
+type versionComposeMock struct {
+	compose.Adapter
+	image string
+	ids   []string
+}
+
+func (m *versionComposeMock) ServiceImage(context.Context, []string, []string, string) (string, error) {
+	return m.image, nil
+}
+
+func (m *versionComposeMock) PsQuiet(context.Context, []string, []string, string) ([]string, error) {
+	return m.ids, nil
+}
+
+type versionDockerMock struct {
+	imageLabels     map[string]string
+	containerLabels map[string]map[string]string
+}
+
+func (m *versionDockerMock) ImageLabels(context.Context, string) (map[string]string, error) {
+	return m.imageLabels, nil
+}
+
+func (m *versionDockerMock) Labels(_ context.Context, id string) (map[string]string, error) {
+	return m.containerLabels[id], nil
+}
+
+func TestVersionUnchanged(t *testing.T) {
+	const label = "org.opencontainers.image.revision"
+	adapter := &versionComposeMock{image: "api:latest", ids: []string{"c1", "c2"}}
+	docker := &versionDockerMock{
+		imageLabels: map[string]string{label: "abc123"},
+		containerLabels: map[string]map[string]string{
+			"c1": {label: "abc123"},
+			"c2": {label: "abc123"},
+		},
+	}
+
+	unchanged, version, err := versionUnchanged(context.Background(), adapter, docker, nil, nil, "api", label)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !unchanged || version != "abc123" {
+		t.Fatalf("expected unchanged abc123, got %v %q", unchanged, version)
+	}
+
+	docker.containerLabels["c2"] = map[string]string{label: "old"}
+	unchanged, _, err = versionUnchanged(context.Background(), adapter, docker, nil, nil, "api", label)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if unchanged {
+		t.Fatal("expected change when one container runs an older version")
+	}
+
+	docker.imageLabels = map[string]string{}
+	unchanged, version, err = versionUnchanged(context.Background(), adapter, docker, nil, nil, "api", label)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if unchanged || version != "" {
+		t.Fatalf("expected deploy when target image has no version label, got %v %q", unchanged, version)
+	}
+}
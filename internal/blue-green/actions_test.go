@@ -10,6 +10,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/metricsgate"
 	"github.com/sirupsen/logrus"
 
@@ -36,10 +38,16 @@ func (m *composeMock) PsQuiet(_ context.Context, _ []string, _ []string, service
 	return append([]string{}, m.idsByService[service]...), nil
 }
 func (m *composeMock) LogsFollowTail(context.Context, []string, string, int) error { return nil }
+func (m *composeMock) ServiceImage(context.Context, []string, []string, string) (string, error) {
+	return "", nil
+}
 
-func (m *dockerMock) HasHealthcheck(context.Context, string) (bool, error) { return true, nil }
-func (m *dockerMock) HealthStatus(context.Context, string) (string, error) { return "healthy", nil }
+func (m *dockerMock) HasHealthcheck(context.Context, string) (bool, error)  { return true, nil }
+func (m *dockerMock) HealthStatus(context.Context, string) (string, error)  { return "healthy", nil }
 func (m *dockerMock) LogsTail(context.Context, string, int) (string, error) { return "", nil }
+func (m *dockerMock) State(context.Context, string) (docker.ContainerState, error) {
+	return docker.ContainerState{Status: "running", Running: true}, nil
+}
 func (m *dockerMock) Stop(_ context.Context, ids []string) error {
 	m.stop = append(m.stop, ids...)
 	return nil
@@ -49,6 +57,15 @@ func (m *dockerMock) Remove(_ context.Context, ids []string) error {
 	return nil
 }
 func (m *dockerMock) Labels(context.Context, string) (map[string]string, error) { return m.labels, nil }
+func (m *dockerMock) IPAddress(context.Context, string) (string, error)         { return "127.0.0.1", nil }
+
+type recordingSink struct {
+	events []events.Event
+}
+
+func (s *recordingSink) Emit(event events.Event) {
+	s.events = append(s.events, event)
+}
 
 func TestSwitchTrafficUpdatesState(t *testing.T) {
 	t.Parallel()
@@ -82,6 +99,8 @@ func TestSwitchTrafficUpdatesState(t *testing.T) {
 			"traefik.http.services.api.loadbalancer.healthCheck.path": "/health",
 		},
 	}, store)
+	sink := &recordingSink{}
+	deployer.WithEvents(sink)
 
 	err := deployer.switchTraffic(context.Background(), Options{
 		Service:           "api",
@@ -92,6 +111,10 @@ func TestSwitchTrafficUpdatesState(t *testing.T) {
 	if err != nil {
 		t.Fatalf("switch traffic failed: %v", err)
 	}
+	last := sink.events[len(sink.events)-1]
+	if last.Type != events.TypeSwapComplete || last.Count != 1 || len(last.Containers) != 1 || last.Containers[0] != "green-id" {
+		t.Fatalf("expected swap-complete for the green container, got %#v", last)
+	}
 
 	got, err := store.Load("project")
 	if err != nil {
@@ -128,12 +151,17 @@ func TestCleanupInactiveRemovesState(t *testing.T) {
 		},
 	}
 	deployer := NewDeployer(logrus.New(), nil, dock, store)
+	sink := &recordingSink{}
+	deployer.WithEvents(sink)
 	if err := deployer.cleanupInactive(context.Background(), Options{
 		Service:           "api",
 		TraefikConfigFile: t.TempDir() + "/dynamic.yml",
 	}); err != nil {
 		t.Fatalf("cleanup failed: %v", err)
 	}
+	if len(sink.events) != 1 || sink.events[0].Phase != events.PhaseRemove || sink.events[0].Count != 1 || sink.events[0].Containers[0] != "blue-id" {
+		t.Fatalf("expected a remove event for blue-id, got %#v", sink.events)
+	}
 
 	if len(dock.stop) != 1 || dock.stop[0] != "blue-id" {
 		t.Fatalf("expected stop blue-id, got %#v", dock.stop)
@@ -422,3 +450,47 @@ func TestSwitchTrafficWritesTCPDynamicConfig(t *testing.T) {
 		t.Fatalf("expected tcp QA routers in config, got:\n%s", content)
 	}
 }
+
+func TestSwitchTrafficDerivesHealthCheckFromPathLabel(t *testing.T) {
+	t.Parallel()
+
+	store := state.NewStore(t.TempDir())
+	if err := store.Save("project", state.DeploymentState{
+		Service:   "api",
+		Strategy:  state.StrategyBlueGreen,
+		Blue:      []string{"blue-id"},
+		Green:     []string{"green-id"},
+		Active:    state.ColorBlue,
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	dynamicPath := t.TempDir() + "/dynamic.yml"
+	deployer := NewDeployer(logrus.New(), nil, &dockerMock{
+		labels: map[string]string{
+			"traefik.http.routers.api.rule":                      "Host(`example.com`)",
+			"traefik.http.services.api.loadbalancer.server.port": "8080",
+			"com.ztd.healthcheck.path":                           "/healthz",
+		},
+	}, store)
+
+	if err := deployer.switchTraffic(context.Background(), Options{
+		Service:           "api",
+		SwitchTo:          state.ColorGreen,
+		TraefikConfigFile: dynamicPath,
+	}); err != nil {
+		t.Fatalf("switch traffic failed: %v", err)
+	}
+
+	data, err := os.ReadFile(dynamicPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{"path: /healthz", "interval: 10s", "port: \"8080\""} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected %q in config, got:\n%s", want, content)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
 )
@@ -36,6 +38,7 @@ func (d *Deployer) CleanupProjectState(ctx context.Context, project string, st s
 		if err := d.docker.Remove(ctx, inactive); err != nil {
 			return err
 		}
+		d.events.Emit(events.Event{Type: events.TypePhase, Service: st.Service, Phase: events.PhaseRemove, Count: len(inactive), Containers: inactive})
 	} else {
 		d.log.Infof("==> No inactive %s containers found for service '%s'", inactiveColor, st.Service)
 	}
@@ -44,10 +47,6 @@ func (d *Deployer) CleanupProjectState(ctx context.Context, project string, st s
 	if err != nil {
 		return err
 	}
-	productionRule, port := productionRuleAndPort(labels, st.Service)
-	tcpRoutes := traefik.ExtractTCPRoutes(labels)
-	hc := extractHealthCheck(labels, st.Service)
-
 	activeBlue := st.Blue
 	activeGreen := st.Green
 	if st.Active == state.ColorBlue {
@@ -55,12 +54,16 @@ func (d *Deployer) CleanupProjectState(ctx context.Context, project string, st s
 	} else {
 		activeBlue = nil
 	}
+	productionRule, port := d.productionRuleAndPort(ctx, labels, st.Service, append(activeBlue, activeGreen...))
+	tcpRoutes := traefik.ExtractTCPRoutes(labels)
+	hc := traefik.HealthCheck(labels, st.Service, port)
 
 	if err := traefik.ApplyBlueGreenConfig(traefikConfigFile, traefik.BlueGreenConfigInput{
 		Service:        st.Service,
 		Active:         st.Active,
 		ProductionRule: productionRule,
 		Port:           port,
+		Scheme:         traefik.ServerScheme(labels, st.Service),
 		BlueIDs:        activeBlue,
 		GreenIDs:       activeGreen,
 		TCPRouters:     tcpRoutes,
@@ -69,6 +72,9 @@ func (d *Deployer) CleanupProjectState(ctx context.Context, project string, st s
 	}); err != nil {
 		return fmt.Errorf("failed to update traefik config after cleanup: %w", err)
 	}
+	if err := d.reloader.Reload(ctx, d.log, proxy.TypeTraefik); err != nil {
+		return err
+	}
 
 	if err := d.store.Delete(project); err != nil {
 		return err
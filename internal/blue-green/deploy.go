@@ -5,19 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/healthdiag"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/metricsgate"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/probe"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
-	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
 )
 
 type Options struct {
@@ -35,23 +37,39 @@ type Options struct {
 	HealthTimeout     int
 	NoHealthTimeout   int
 	WaitAfterHealthy  int
+	TimeoutAction     string
+	PollInterval      time.Duration
+	StartPeriod       time.Duration
+	HealthyStatuses   []string
+	SurgeOverrides    []string
+	ProjectName       string
+	CriticalCount     int
+	TCPProbePort      int
+	Replicas          int
 	Metrics           metricsgate.Config
+	// DryRun marks a run whose compose adapter and docker client only log what they
+	// would do; the deploy stops after the scale-up and the other actions only log.
+	DryRun bool
 }
 
 type dockerOps interface {
 	HasHealthcheck(ctx context.Context, containerID string) (bool, error)
 	HealthStatus(ctx context.Context, containerID string) (string, error)
 	LogsTail(ctx context.Context, containerID string, tail int) (string, error)
+	State(ctx context.Context, containerID string) (docker.ContainerState, error)
 	Stop(ctx context.Context, containerIDs []string) error
 	Remove(ctx context.Context, containerIDs []string) error
 	Labels(ctx context.Context, containerID string) (map[string]string, error)
+	IPAddress(ctx context.Context, containerID string) (string, error)
 }
 
 type Deployer struct {
-	log     *logrus.Logger
-	compose compose.Adapter
-	docker  dockerOps
-	store   *state.Store
+	log      *logrus.Logger
+	compose  compose.Adapter
+	docker   dockerOps
+	store    *state.Store
+	events   events.Sink
+	reloader proxy.Reloader
 }
 
 func NewDeployer(log *logrus.Logger, composeAdapter compose.Adapter, dockerClient dockerOps, store *state.Store) *Deployer {
@@ -60,10 +78,28 @@ func NewDeployer(log *logrus.Logger, composeAdapter compose.Adapter, dockerClien
 		compose: composeAdapter,
 		docker:  dockerClient,
 		store:   store,
+		events:  events.Nop{},
 	}
 }
 
+func (d *Deployer) WithEvents(sink events.Sink) *Deployer {
+	if sink != nil {
+		d.events = sink
+	}
+	return d
+}
+
+// WithReloader sets how the proxy is told to apply a rewritten Traefik config.
+func (d *Deployer) WithReloader(reloader proxy.Reloader) *Deployer {
+	d.reloader = reloader
+	return d
+}
+
 func (d *Deployer) Run(ctx context.Context, opt Options) (err error) {
+	if opt.DryRun && opt.Action != "" {
+		d.log.Infof("==> [dry-run] Would run blue-green %s for service '%s' against %s", opt.Action, opt.Service, opt.TraefikConfigFile)
+		return nil
+	}
 	switch opt.Action {
 	case "":
 		return d.deploy(ctx, opt)
@@ -102,9 +138,12 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 	}
 	if len(oldIDs) == 0 {
 		d.log.Infof("==> Service '%s' is not running. Starting the service.", opt.Service)
-		if err := d.compose.Up(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service, true, true); err != nil {
+		if err := compose.StartService(ctx, d.compose, opt.ComposeFiles, opt.EnvFiles, opt.Service, opt.Replicas); err != nil {
 			return err
 		}
+		if opt.DryRun {
+			return nil
+		}
 		oldIDs, err = d.compose.PsQuiet(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service)
 		if err != nil {
 			return err
@@ -116,9 +155,14 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 
 	target := len(oldIDs) * 2
 	d.log.Infof("==> Blue-green deploy: scaling '%s' to %d instances", opt.Service, target)
-	if err := d.compose.Scale(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service, target); err != nil {
+	events.Phase(d.events, opt.Service, events.PhaseScale)
+	if err := d.compose.Scale(ctx, compose.SurgeFiles(opt.ComposeFiles, opt.SurgeOverrides), opt.EnvFiles, opt.Service, target); err != nil {
 		return err
 	}
+	if opt.DryRun {
+		d.log.Infof("==> [dry-run] Would wait up to %d seconds for the green containers of '%s', then add them as green in %s with blue active", opt.HealthTimeout, opt.Service, opt.TraefikConfigFile)
+		return nil
+	}
 
 	var newIDs []string
 	guard := safeguard.NewRollbackGuard(d.log, "blue-green post-scale rollback", func(ctx context.Context) error {
@@ -144,28 +188,78 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 	if err != nil {
 		return err
 	}
+	newLabels, err := d.docker.Labels(ctx, newIDs[0])
+	if err != nil {
+		return err
+	}
+	httpCheck, hasHTTPCheck, err := probe.HTTPCheckFromLabels(newLabels)
+	if err != nil {
+		return err
+	}
 	if hasHC {
 		d.log.Infof("==> Waiting for green containers to be healthy (timeout: %d seconds)", opt.HealthTimeout)
-		ok, err := d.waitHealthy(ctx, newIDs, len(oldIDs), opt.HealthTimeout)
+		events.WaitPhase(d.events, opt.Service, events.ReadinessHealthcheck)
+		gated, gatedExpected, rest := healthdiag.CriticalSubset(newIDs, len(oldIDs), opt.CriticalCount)
+		ok, err := d.waitHealthy(ctx, gated, gatedExpected, opt.HealthTimeout, opt.PollInterval, opt.StartPeriod, opt.HealthyStatuses, events.NewHealthTracker(d.events, opt.Service))
+		if err != nil {
+			return err
+		}
+		if ok && len(rest) > 0 {
+			healthdiag.LogNonCriticalStatus(ctx, d.log, d.docker, rest)
+		}
+		if !ok {
+			healthdiag.LogUnhealthyContainerLogs(ctx, d.log, d.docker, newIDs, opt.HealthyStatuses, 20)
+			switch opt.TimeoutAction {
+			case safeguard.TimeoutActionKeep:
+				d.log.Errorf("==> Green containers are not healthy. Keeping them without routing traffic: %v", newIDs)
+				guard.Disarm()
+				return safeguard.WithReason(healthdiag.HealthFailureReason(ctx, d.docker, newIDs), fmt.Errorf("green containers are not healthy; kept without routing traffic"))
+			case safeguard.TimeoutActionForce:
+				d.log.Warn("==> Green containers are not healthy. Continuing anyway (--timeout-action=force).")
+			default:
+				events.Phase(d.events, opt.Service, events.PhaseRollback)
+				return safeguard.WithRollback(healthdiag.HealthFailureReason(ctx, d.docker, newIDs), fmt.Errorf("green containers are not healthy"))
+			}
+		} else if opt.WaitAfterHealthy > 0 {
+			if err := safeguard.Sleep(ctx, time.Duration(opt.WaitAfterHealthy)*time.Second); err != nil {
+				return err
+			}
+		}
+	} else if opt.TCPProbePort > 0 {
+		d.log.Infof("==> Waiting for green containers to accept TCP connections on port %d (timeout: %d seconds)", opt.TCPProbePort, opt.HealthTimeout)
+		events.WaitPhase(d.events, opt.Service, events.ReadinessTCPProbe)
+		ok, err := probe.WaitTCP(ctx, d.docker, newIDs, opt.TCPProbePort, time.Duration(opt.HealthTimeout)*time.Second)
 		if err != nil {
 			return err
 		}
 		if !ok {
-			healthdiag.LogUnhealthyContainerLogs(ctx, d.log, d.docker, newIDs, 20)
-			return fmt.Errorf("green containers are not healthy")
+			events.Phase(d.events, opt.Service, events.PhaseRollback)
+			return safeguard.WithRollback(safeguard.ReasonTCPProbeTimeout, fmt.Errorf("green containers did not accept TCP connections on port %d", opt.TCPProbePort))
+		}
+	} else if opt.NoHealthTimeout > 0 && !hasHTTPCheck {
+		events.WaitPhase(d.events, opt.Service, events.ReadinessFixedWait)
+		if err := safeguard.Sleep(ctx, time.Duration(opt.NoHealthTimeout)*time.Second); err != nil {
+			return err
+		}
+	}
+	if hasHTTPCheck {
+		d.log.Infof("==> Waiting for green containers to answer GET %s on port %d (timeout: %d seconds)", httpCheck.Path, httpCheck.Port, opt.HealthTimeout)
+		events.WaitPhase(d.events, opt.Service, events.ReadinessHTTPProbe)
+		ok, err := probe.WaitHTTP(ctx, d.docker, newIDs, httpCheck, time.Duration(opt.HealthTimeout)*time.Second)
+		if err != nil {
+			return err
 		}
-		if opt.WaitAfterHealthy > 0 {
-			time.Sleep(time.Duration(opt.WaitAfterHealthy) * time.Second)
+		if !ok {
+			events.Phase(d.events, opt.Service, events.PhaseRollback)
+			return safeguard.WithRollback(safeguard.ReasonHTTPProbeTimeout, fmt.Errorf("green containers did not answer GET %s on port %d with 2xx", httpCheck.Path, httpCheck.Port))
 		}
-	} else if opt.NoHealthTimeout > 0 {
-		time.Sleep(time.Duration(opt.NoHealthTimeout) * time.Second)
 	}
 
 	labels, err := d.docker.Labels(ctx, oldIDs[0])
 	if err != nil {
 		return err
 	}
-	project, err = state.ResolveProjectName(labels, os.Getenv("COMPOSE_PROJECT_NAME"))
+	project, err = state.ResolveProjectName(labels, opt.ProjectName)
 	if err != nil {
 		return err
 	}
@@ -173,7 +267,7 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 	if err != nil {
 		return err
 	}
-	productionRule, port := productionRuleAndPort(labels, opt.Service)
+	productionRule, port := d.productionRuleAndPort(ctx, labels, opt.Service, newIDs)
 	tcpRoutes := traefik.ExtractTCPRoutes(labels)
 	d.warnTCPIncompatibleQAModes(tcpRoutes, &state.QAModes{
 		Host:    opt.HostMode,
@@ -181,7 +275,7 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 		Cookies: opt.CookiesMode,
 		IP:      opt.IPMode,
 	})
-	hc := extractHealthCheck(labels, opt.Service)
+	hc := traefik.HealthCheck(labels, opt.Service, port)
 
 	currentState := state.DeploymentState{
 		Service:   opt.Service,
@@ -201,18 +295,27 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 		return err
 	}
 
+	events.Phase(d.events, opt.Service, events.PhaseUpdateConfig)
 	if err := traefik.ApplyBlueGreenConfig(opt.TraefikConfigFile, traefik.BlueGreenConfigInput{
 		Service:        opt.Service,
 		Active:         state.ColorBlue,
 		ProductionRule: productionRule,
 		Port:           port,
+		Scheme:         traefik.ServerScheme(labels, opt.Service),
 		BlueIDs:        oldIDs,
 		GreenIDs:       newIDs,
 		TCPRouters:     tcpRoutes,
 		QA:             currentState.QA,
 		HealthCheck:    hc,
 	}); err != nil {
-		return err
+		d.log.Errorf("==> Failed to write Traefik config: %v. Keeping blue containers serving.", err)
+		if delErr := d.store.Delete(stateKey); delErr != nil {
+			d.log.Warnf("==> Unable to remove blue-green state after config failure: %v", delErr)
+		}
+		return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("update Traefik config: %w", err))
+	}
+	if err := d.reloader.Reload(ctx, d.log, proxy.TypeTraefik); err != nil {
+		return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, err)
 	}
 	if baseline, baselineErr := d.captureServiceSnapshot(ctx, opt, blueGreenMetricServiceName(opt.Service, state.ColorGreen)); baselineErr != nil {
 		d.log.Warnf("==> Unable to capture green baseline metrics: %v", baselineErr)
@@ -233,72 +336,27 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 
 	guard.Disarm()
 	d.log.Infof("==> Blue-green deploy ready. Blue active, green waiting for switch.")
+	d.events.Emit(events.Event{Type: events.TypeContainersReady, Service: opt.Service, Message: "active=" + state.ColorBlue, Count: len(newIDs), Containers: newIDs, Replaced: oldIDs})
 	return nil
 }
 
-func productionRuleAndPort(labels map[string]string, service string) (string, string) {
+// productionRuleAndPort returns the router rule of service and its backend port; without
+// a port label the port exposed by the first of containerIDs is used, see
+// traefik.BackendPort.
+func (d *Deployer) productionRuleAndPort(ctx context.Context, labels map[string]string, service string, containerIDs []string) (string, string) {
 	rule := labels["traefik.http.routers."+service+".rule"]
 	if strings.TrimSpace(rule) == "" {
 		rule = fmt.Sprintf("Host(`%s.local`)", service)
 	}
-	port := labels["traefik.http.services."+service+".loadbalancer.server.port"]
-	if strings.TrimSpace(port) == "" {
-		port = "80"
-	}
-	return rule, port
-}
-
-func extractHealthCheck(labels map[string]string, serviceName string) *types.HealthChecks {
-	prefix := "traefik.http.services." + serviceName + ".loadbalancer.healthCheck."
-	hc := &types.HealthChecks{
-		Path:            labels[prefix+"path"],
-		Interval:        labels[prefix+"interval"],
-		Timeout:         labels[prefix+"timeout"],
-		Scheme:          labels[prefix+"scheme"],
-		Mode:            labels[prefix+"mode"],
-		Hostname:        labels[prefix+"hostname"],
-		Port:            labels[prefix+"port"],
-		FollowRedirects: labels[prefix+"followRedirects"],
-		Method:          labels[prefix+"method"],
-		Status:          labels[prefix+"status"],
-	}
-
-	headers := map[string]string{}
-	for k, v := range labels {
-		if strings.HasPrefix(k, prefix+"headers.") {
-			headers[strings.TrimPrefix(k, prefix+"headers.")] = v
-		}
-	}
-	if len(headers) > 0 {
-		hc.Headers = headers
-	}
-
-	if hc.Path == "" && hc.Interval == "" && hc.Timeout == "" && hc.Scheme == "" && hc.Mode == "" &&
-		hc.Hostname == "" && hc.Port == "" && hc.FollowRedirects == "" && hc.Method == "" && hc.Status == "" && len(hc.Headers) == 0 {
-		return nil
+	containerID := ""
+	if len(containerIDs) > 0 {
+		containerID = containerIDs[0]
 	}
-	return hc
+	return rule, traefik.BackendPort(ctx, d.log, d.docker, labels, service, containerID)
 }
 
-func (d *Deployer) waitHealthy(ctx context.Context, containerIDs []string, expected int, timeoutSec int) (bool, error) {
-	deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
-	for time.Now().Before(deadline) {
-		okCount := 0
-		for _, id := range containerIDs {
-			status, err := d.docker.HealthStatus(ctx, id)
-			if err != nil {
-				return false, err
-			}
-			if status == "healthy" {
-				okCount++
-			}
-		}
-		if okCount == expected {
-			return true, nil
-		}
-		time.Sleep(time.Second)
-	}
-	return false, nil
+func (d *Deployer) waitHealthy(ctx context.Context, containerIDs []string, expected int, timeoutSec int, pollInterval time.Duration, startPeriod time.Duration, accepted []string, tracker *events.HealthTracker) (bool, error) {
+	return healthdiag.WaitHealthy(ctx, d.log, d.docker, containerIDs, expected, time.Duration(timeoutSec)*time.Second, pollInterval, startPeriod, accepted, tracker)
 }
 
 func diffIDs(oldIDs []string, allIDs []string) []string {
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
 )
@@ -40,16 +42,21 @@ func (d *Deployer) switchTraffic(ctx context.Context, opt Options) error {
 	if err != nil {
 		return err
 	}
-	productionRule, port := productionRuleAndPort(labels, currentState.Service)
+	targetIDs := currentState.Blue
+	if targetColor == state.ColorGreen {
+		targetIDs = currentState.Green
+	}
+	productionRule, port := d.productionRuleAndPort(ctx, labels, currentState.Service, targetIDs)
 	tcpRoutes := traefik.ExtractTCPRoutes(labels)
 	d.warnTCPIncompatibleQAModes(tcpRoutes, currentState.QA)
-	hc := extractHealthCheck(labels, currentState.Service)
+	hc := traefik.HealthCheck(labels, currentState.Service, port)
 
 	if err := traefik.ApplyBlueGreenConfig(opt.TraefikConfigFile, traefik.BlueGreenConfigInput{
 		Service:        currentState.Service,
 		Active:         targetColor,
 		ProductionRule: productionRule,
 		Port:           port,
+		Scheme:         traefik.ServerScheme(labels, currentState.Service),
 		BlueIDs:        currentState.Blue,
 		GreenIDs:       currentState.Green,
 		TCPRouters:     tcpRoutes,
@@ -58,6 +65,9 @@ func (d *Deployer) switchTraffic(ctx context.Context, opt Options) error {
 	}); err != nil {
 		return err
 	}
+	if err := d.reloader.Reload(ctx, d.log, proxy.TypeTraefik); err != nil {
+		return err
+	}
 
 	now := time.Now().UTC()
 	currentState.Active = targetColor
@@ -81,7 +91,12 @@ func (d *Deployer) switchTraffic(ctx context.Context, opt Options) error {
 	}
 	d.runMetricsGate(ctx, opt, blueGreenMetricServiceName(currentState.Service, targetColor), "switch")
 
+	activeIDs, replacedIDs := currentState.Green, currentState.Blue
+	if targetColor == state.ColorBlue {
+		activeIDs, replacedIDs = currentState.Blue, currentState.Green
+	}
 	d.log.Infof("==> Switched service '%s' traffic to %s", currentState.Service, targetColor)
+	d.events.Emit(events.Event{Type: events.TypeSwapComplete, Service: currentState.Service, Message: "active=" + targetColor, Count: len(activeIDs), Containers: activeIDs, Replaced: replacedIDs})
 	return nil
 }
 
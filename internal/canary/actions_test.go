@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/metricsgate"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
 	"github.com/sirupsen/logrus"
@@ -35,10 +36,16 @@ func (m *composeMock) PsQuiet(_ context.Context, _ []string, _ []string, service
 	return append([]string{}, m.idsByService[service]...), nil
 }
 func (m *composeMock) LogsFollowTail(context.Context, []string, string, int) error { return nil }
+func (m *composeMock) ServiceImage(context.Context, []string, []string, string) (string, error) {
+	return "", nil
+}
 
-func (m *dockerMock) HasHealthcheck(context.Context, string) (bool, error) { return true, nil }
-func (m *dockerMock) HealthStatus(context.Context, string) (string, error) { return "healthy", nil }
+func (m *dockerMock) HasHealthcheck(context.Context, string) (bool, error)  { return true, nil }
+func (m *dockerMock) HealthStatus(context.Context, string) (string, error)  { return "healthy", nil }
 func (m *dockerMock) LogsTail(context.Context, string, int) (string, error) { return "", nil }
+func (m *dockerMock) State(context.Context, string) (docker.ContainerState, error) {
+	return docker.ContainerState{Status: "running", Running: true}, nil
+}
 func (m *dockerMock) Stop(_ context.Context, ids []string) error {
 	m.stop = append(m.stop, ids...)
 	return nil
@@ -48,6 +55,7 @@ func (m *dockerMock) Remove(_ context.Context, ids []string) error {
 	return nil
 }
 func (m *dockerMock) Labels(context.Context, string) (map[string]string, error) { return m.labels, nil }
+func (m *dockerMock) IPAddress(context.Context, string) (string, error)         { return "127.0.0.1", nil }
 
 func TestCleanupRejectsNonTerminalWeight(t *testing.T) {
 	t.Parallel()
@@ -5,17 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/healthdiag"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/metricsgate"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/probe"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
-	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
 	"github.com/sirupsen/logrus"
 )
 
@@ -32,23 +34,43 @@ type Options struct {
 	HealthTimeout     int
 	NoHealthTimeout   int
 	WaitAfterHealthy  int
+	TimeoutAction     string
+	PollInterval      time.Duration
+	StartPeriod       time.Duration
+	HealthyStatuses   []string
+	SurgeOverrides    []string
+	ProjectName       string
+	CriticalCount     int
+	TCPProbePort      int
+	Replicas          int
+	CanaryRule        string
+	RampDuration      time.Duration
+	RampSteps         int
+	CanaryDuration    time.Duration
 	Metrics           metricsgate.Config
+	// DryRun marks a run whose compose adapter and docker client only log what they
+	// would do; the deploy stops after the scale-up and the other actions only log.
+	DryRun bool
 }
 
 type dockerOps interface {
 	HasHealthcheck(ctx context.Context, containerID string) (bool, error)
 	HealthStatus(ctx context.Context, containerID string) (string, error)
 	LogsTail(ctx context.Context, containerID string, tail int) (string, error)
+	State(ctx context.Context, containerID string) (docker.ContainerState, error)
 	Stop(ctx context.Context, containerIDs []string) error
 	Remove(ctx context.Context, containerIDs []string) error
 	Labels(ctx context.Context, containerID string) (map[string]string, error)
+	IPAddress(ctx context.Context, containerID string) (string, error)
 }
 
 type Deployer struct {
-	log     *logrus.Logger
-	compose compose.Adapter
-	docker  dockerOps
-	store   *state.Store
+	log      *logrus.Logger
+	compose  compose.Adapter
+	docker   dockerOps
+	store    *state.Store
+	events   events.Sink
+	reloader proxy.Reloader
 }
 
 func NewDeployer(log *logrus.Logger, composeAdapter compose.Adapter, dockerClient dockerOps, store *state.Store) *Deployer {
@@ -57,15 +79,40 @@ func NewDeployer(log *logrus.Logger, composeAdapter compose.Adapter, dockerClien
 		compose: composeAdapter,
 		docker:  dockerClient,
 		store:   store,
+		events:  events.Nop{},
 	}
 }
 
+func (d *Deployer) WithEvents(sink events.Sink) *Deployer {
+	if sink != nil {
+		d.events = sink
+	}
+	return d
+}
+
+// WithReloader sets how the proxy is told to apply a rewritten Traefik config.
+func (d *Deployer) WithReloader(reloader proxy.Reloader) *Deployer {
+	d.reloader = reloader
+	return d
+}
+
 func (d *Deployer) Run(ctx context.Context, opt Options) error {
+	if opt.DryRun && opt.Action != "" {
+		d.log.Infof("==> [dry-run] Would run canary %s for service '%s' against %s", opt.Action, opt.Service, opt.TraefikConfigFile)
+		return nil
+	}
 	switch opt.Action {
 	case "":
 		return d.deploy(ctx, opt)
-	case "rollback":
+	case "rollback", "abort":
 		return d.rollback(ctx, opt)
+	case "promote":
+		st, err := d.promote(ctx, opt)
+		if err != nil {
+			return err
+		}
+		d.emitSwapComplete(st.Service, 100, st.New, st.Old)
+		return nil
 	case "cleanup":
 		return d.cleanup(ctx, opt)
 	default:
@@ -75,6 +122,10 @@ func (d *Deployer) Run(ctx context.Context, opt Options) error {
 
 func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 	project, existingState, err := d.findStateByService(opt.Service)
+	if err == nil && opt.DryRun {
+		d.log.Infof("==> [dry-run] Would move the active canary of service '%s' to %d%% in %s", opt.Service, opt.Weight, opt.TraefikConfigFile)
+		return nil
+	}
 	if err == nil {
 		return d.deployFromExistingState(ctx, opt, project, existingState)
 	}
@@ -88,9 +139,12 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 	}
 	if len(oldIDs) == 0 {
 		d.log.Infof("==> Service '%s' is not running. Starting the service.", opt.Service)
-		if err := d.compose.Up(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service, true, true); err != nil {
+		if err := compose.StartService(ctx, d.compose, opt.ComposeFiles, opt.EnvFiles, opt.Service, opt.Replicas); err != nil {
 			return err
 		}
+		if opt.DryRun {
+			return nil
+		}
 		oldIDs, err = d.compose.PsQuiet(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service)
 		if err != nil {
 			return err
@@ -102,9 +156,14 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 
 	target := len(oldIDs) * 2
 	d.log.Infof("==> Canary deploy: scaling '%s' to %d instances", opt.Service, target)
-	if err := d.compose.Scale(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service, target); err != nil {
+	events.Phase(d.events, opt.Service, events.PhaseScale)
+	if err := d.compose.Scale(ctx, compose.SurgeFiles(opt.ComposeFiles, opt.SurgeOverrides), opt.EnvFiles, opt.Service, target); err != nil {
 		return err
 	}
+	if opt.DryRun {
+		d.log.Infof("==> [dry-run] Would wait up to %d seconds for the canary containers of '%s', then route %d%% of traffic to them in %s", opt.HealthTimeout, opt.Service, opt.Weight, opt.TraefikConfigFile)
+		return nil
+	}
 
 	var newIDs []string
 	guard := safeguard.NewRollbackGuard(d.log, "canary post-scale rollback", func(ctx context.Context) error {
@@ -130,28 +189,78 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 	if err != nil {
 		return err
 	}
+	newLabels, err := d.docker.Labels(ctx, newIDs[0])
+	if err != nil {
+		return err
+	}
+	httpCheck, hasHTTPCheck, err := probe.HTTPCheckFromLabels(newLabels)
+	if err != nil {
+		return err
+	}
 	if hasHC {
 		d.log.Infof("==> Waiting for canary containers to be healthy (timeout: %d seconds)", opt.HealthTimeout)
-		ok, err := d.waitHealthy(ctx, newIDs, len(oldIDs), opt.HealthTimeout)
+		events.WaitPhase(d.events, opt.Service, events.ReadinessHealthcheck)
+		gated, gatedExpected, rest := healthdiag.CriticalSubset(newIDs, len(oldIDs), opt.CriticalCount)
+		ok, err := d.waitHealthy(ctx, gated, gatedExpected, opt.HealthTimeout, opt.PollInterval, opt.StartPeriod, opt.HealthyStatuses, events.NewHealthTracker(d.events, opt.Service))
 		if err != nil {
 			return err
 		}
+		if ok && len(rest) > 0 {
+			healthdiag.LogNonCriticalStatus(ctx, d.log, d.docker, rest)
+		}
 		if !ok {
-			healthdiag.LogUnhealthyContainerLogs(ctx, d.log, d.docker, newIDs, 20)
-			return fmt.Errorf("canary containers are not healthy")
+			healthdiag.LogUnhealthyContainerLogs(ctx, d.log, d.docker, newIDs, opt.HealthyStatuses, 20)
+			switch opt.TimeoutAction {
+			case safeguard.TimeoutActionKeep:
+				d.log.Errorf("==> Canary containers are not healthy. Keeping them without routing traffic: %v", newIDs)
+				guard.Disarm()
+				return safeguard.WithReason(healthdiag.HealthFailureReason(ctx, d.docker, newIDs), fmt.Errorf("canary containers are not healthy; kept without routing traffic"))
+			case safeguard.TimeoutActionForce:
+				d.log.Warn("==> Canary containers are not healthy. Continuing anyway (--timeout-action=force).")
+			default:
+				events.Phase(d.events, opt.Service, events.PhaseRollback)
+				return safeguard.WithRollback(healthdiag.HealthFailureReason(ctx, d.docker, newIDs), fmt.Errorf("canary containers are not healthy"))
+			}
+		} else if opt.WaitAfterHealthy > 0 {
+			if err := safeguard.Sleep(ctx, time.Duration(opt.WaitAfterHealthy)*time.Second); err != nil {
+				return err
+			}
 		}
-		if opt.WaitAfterHealthy > 0 {
-			time.Sleep(time.Duration(opt.WaitAfterHealthy) * time.Second)
+	} else if opt.TCPProbePort > 0 {
+		d.log.Infof("==> Waiting for canary containers to accept TCP connections on port %d (timeout: %d seconds)", opt.TCPProbePort, opt.HealthTimeout)
+		events.WaitPhase(d.events, opt.Service, events.ReadinessTCPProbe)
+		ok, err := probe.WaitTCP(ctx, d.docker, newIDs, opt.TCPProbePort, time.Duration(opt.HealthTimeout)*time.Second)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			events.Phase(d.events, opt.Service, events.PhaseRollback)
+			return safeguard.WithRollback(safeguard.ReasonTCPProbeTimeout, fmt.Errorf("canary containers did not accept TCP connections on port %d", opt.TCPProbePort))
+		}
+	} else if opt.NoHealthTimeout > 0 && !hasHTTPCheck {
+		events.WaitPhase(d.events, opt.Service, events.ReadinessFixedWait)
+		if err := safeguard.Sleep(ctx, time.Duration(opt.NoHealthTimeout)*time.Second); err != nil {
+			return err
+		}
+	}
+	if hasHTTPCheck {
+		d.log.Infof("==> Waiting for canary containers to answer GET %s on port %d (timeout: %d seconds)", httpCheck.Path, httpCheck.Port, opt.HealthTimeout)
+		events.WaitPhase(d.events, opt.Service, events.ReadinessHTTPProbe)
+		ok, err := probe.WaitHTTP(ctx, d.docker, newIDs, httpCheck, time.Duration(opt.HealthTimeout)*time.Second)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			events.Phase(d.events, opt.Service, events.PhaseRollback)
+			return safeguard.WithRollback(safeguard.ReasonHTTPProbeTimeout, fmt.Errorf("canary containers did not answer GET %s on port %d with 2xx", httpCheck.Path, httpCheck.Port))
 		}
-	} else if opt.NoHealthTimeout > 0 {
-		time.Sleep(time.Duration(opt.NoHealthTimeout) * time.Second)
 	}
 
 	labels, err := d.docker.Labels(ctx, oldIDs[0])
 	if err != nil {
 		return err
 	}
-	project, err = state.ResolveProjectName(labels, os.Getenv("COMPOSE_PROJECT_NAME"))
+	project, err = state.ResolveProjectName(labels, opt.ProjectName)
 	if err != nil {
 		return err
 	}
@@ -159,32 +268,43 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 	if err != nil {
 		return err
 	}
-	productionRule, port := productionRuleAndPort(labels, opt.Service)
+	productionRule, port := d.productionRuleAndPort(ctx, labels, opt.Service, newIDs)
 	tcpRoutes := traefik.ExtractTCPRoutes(labels)
-	hc := extractHealthCheck(labels, opt.Service)
+	hc := traefik.HealthCheck(labels, opt.Service, port)
 
 	currentState := state.DeploymentState{
-		Service:   opt.Service,
-		Strategy:  state.StrategyCanary,
-		Old:       oldIDs,
-		New:       newIDs,
-		Weight:    opt.Weight,
-		CreatedAt: time.Now().UTC(),
+		Service:    opt.Service,
+		Strategy:   state.StrategyCanary,
+		Old:        oldIDs,
+		New:        newIDs,
+		Weight:     opt.Weight,
+		CreatedAt:  time.Now().UTC(),
+		CanaryRule: opt.CanaryRule,
 	}
 	if err := d.store.Save(stateKey, currentState); err != nil {
 		return err
 	}
+	events.Phase(d.events, opt.Service, events.PhaseUpdateConfig)
 	if err := traefik.ApplyCanaryConfig(opt.TraefikConfigFile, traefik.CanaryConfigInput{
 		Service:        opt.Service,
 		ProductionRule: productionRule,
 		Port:           port,
+		Scheme:         traefik.ServerScheme(labels, opt.Service),
 		OldIDs:         oldIDs,
 		NewIDs:         newIDs,
 		NewWeight:      opt.Weight,
 		TCPRouters:     tcpRoutes,
 		HealthCheck:    hc,
+		CanaryRule:     opt.CanaryRule,
 	}); err != nil {
-		return err
+		d.log.Errorf("==> Failed to write Traefik config: %v. Keeping old containers serving.", err)
+		if delErr := d.store.Delete(stateKey); delErr != nil {
+			d.log.Warnf("==> Unable to remove canary state after config failure: %v", delErr)
+		}
+		return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("update Traefik config: %w", err))
+	}
+	if err := d.reloader.Reload(ctx, d.log, proxy.TypeTraefik); err != nil {
+		return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, err)
 	}
 	if baseline, baselineErr := d.captureServiceSnapshot(ctx, opt, canaryMetricServiceName(opt.Service, "new")); baselineErr != nil {
 		d.log.Warnf("==> Unable to capture canary baseline metrics: %v", baselineErr)
@@ -208,7 +328,14 @@ func (d *Deployer) deploy(ctx context.Context, opt Options) (err error) {
 	}
 
 	guard.Disarm()
+	d.emitSwapComplete(opt.Service, opt.Weight, newIDs, oldIDs)
+	if opt.RampDuration > 0 {
+		return d.ramp(ctx, opt, stateKey, currentState)
+	}
 	d.log.Infof("==> Canary deploy ready. old=%d%% new=%d%%", 100-opt.Weight, opt.Weight)
+	if opt.CanaryDuration > 0 {
+		return d.hold(ctx, opt)
+	}
 	return nil
 }
 
@@ -221,22 +348,30 @@ func (d *Deployer) deployFromExistingState(ctx context.Context, opt Options, pro
 	if err != nil {
 		return err
 	}
-	productionRule, port := productionRuleAndPort(labels, st.Service)
+	productionRule, port := d.productionRuleAndPort(ctx, labels, st.Service, st.New)
 	tcpRoutes := traefik.ExtractTCPRoutes(labels)
-	hc := extractHealthCheck(labels, st.Service)
+	hc := traefik.HealthCheck(labels, st.Service, port)
+	if opt.CanaryRule != "" {
+		st.CanaryRule = opt.CanaryRule
+	}
 
 	if err := traefik.ApplyCanaryConfig(opt.TraefikConfigFile, traefik.CanaryConfigInput{
 		Service:        st.Service,
 		ProductionRule: productionRule,
 		Port:           port,
+		Scheme:         traefik.ServerScheme(labels, st.Service),
 		OldIDs:         st.Old,
 		NewIDs:         st.New,
 		NewWeight:      opt.Weight,
 		TCPRouters:     tcpRoutes,
 		HealthCheck:    hc,
+		CanaryRule:     st.CanaryRule,
 	}); err != nil {
 		return err
 	}
+	if err := d.reloader.Reload(ctx, d.log, proxy.TypeTraefik); err != nil {
+		return err
+	}
 	st = d.ensureCanaryBaseline(ctx, opt, project, st)
 	if err := d.runMetricsGateWithRollback(ctx, opt, "deploy", st); err != nil {
 		return err
@@ -249,51 +384,87 @@ func (d *Deployer) deployFromExistingState(ctx context.Context, opt Options, pro
 	if err := d.store.Save(project, st); err != nil {
 		return err
 	}
+	d.emitSwapComplete(st.Service, opt.Weight, st.New, st.Old)
+	if opt.RampDuration > 0 {
+		return d.ramp(ctx, opt, project, st)
+	}
 
 	d.log.Infof("==> Canary deploy reused existing pool without scaling. old=%d%% new=%d%%", 100-opt.Weight, opt.Weight)
+	if opt.CanaryDuration > 0 {
+		return d.hold(ctx, opt)
+	}
 	return nil
 }
 
 func (d *Deployer) rollback(ctx context.Context, opt Options) error {
+	if _, err := d.setTerminalWeight(ctx, opt, 0); err != nil {
+		return err
+	}
+	d.log.Infof("==> Canary rollback completed. new=0%%")
+	events.Phase(d.events, opt.Service, events.PhaseRollback)
+	return nil
+}
+
+// promote routes all traffic to the new containers and returns the promoted state. It
+// emits no swap-complete event: a deploy that ramps or holds already reported the swap
+// when the new containers first took traffic.
+func (d *Deployer) promote(ctx context.Context, opt Options) (state.DeploymentState, error) {
+	st, err := d.setTerminalWeight(ctx, opt, 100)
+	if err != nil {
+		return st, err
+	}
+	d.log.Infof("==> Canary promote completed. new=100%%")
+	return st, nil
+}
+
+// emitSwapComplete reports that weight percent of the traffic of service now reaches
+// newIDs instead of oldIDs.
+func (d *Deployer) emitSwapComplete(service string, weight int, newIDs []string, oldIDs []string) {
+	d.events.Emit(events.Event{Type: events.TypeSwapComplete, Service: service, Message: fmt.Sprintf("new=%d%%", weight), Count: len(newIDs), Containers: newIDs, Replaced: oldIDs})
+}
+
+// setTerminalWeight routes all traffic to one side and drops the canary router.
+func (d *Deployer) setTerminalWeight(ctx context.Context, opt Options, weight int) (state.DeploymentState, error) {
 	project, st, err := d.findStateByService(opt.Service)
 	if err != nil {
-		return err
+		return st, err
 	}
 	labels, err := d.labelsFromState(ctx, st)
 	if err != nil {
-		return err
+		return st, err
 	}
-	productionRule, port := productionRuleAndPort(labels, st.Service)
+	productionRule, port := d.productionRuleAndPort(ctx, labels, st.Service, st.New)
 	tcpRoutes := traefik.ExtractTCPRoutes(labels)
-	hc := extractHealthCheck(labels, st.Service)
+	hc := traefik.HealthCheck(labels, st.Service, port)
 
 	if err := traefik.ApplyCanaryConfig(opt.TraefikConfigFile, traefik.CanaryConfigInput{
 		Service:        st.Service,
 		ProductionRule: productionRule,
 		Port:           port,
+		Scheme:         traefik.ServerScheme(labels, st.Service),
 		OldIDs:         st.Old,
 		NewIDs:         st.New,
-		NewWeight:      0,
+		NewWeight:      weight,
 		TCPRouters:     tcpRoutes,
 		HealthCheck:    hc,
 	}); err != nil {
-		return err
+		return st, err
+	}
+	if err := d.reloader.Reload(ctx, d.log, proxy.TypeTraefik); err != nil {
+		return st, err
 	}
 
 	now := time.Now().UTC()
-	st.Weight = 0
+	st.Weight = weight
 	st.SwitchedAt = &now
+	st.CanaryRule = ""
 	if opt.AutoCleanup > 0 {
 		cleanupAt := now.Add(opt.AutoCleanup)
 		st.CleanupAt = &cleanupAt
 	} else {
 		st.CleanupAt = nil
 	}
-	if err := d.store.Save(project, st); err != nil {
-		return err
-	}
-	d.log.Infof("==> Canary rollback completed. new=0%%")
-	return nil
+	return st, d.store.Save(project, st)
 }
 
 func (d *Deployer) cleanup(ctx context.Context, opt Options) error {
@@ -332,6 +503,7 @@ func (d *Deployer) CleanupProjectState(ctx context.Context, project string, st s
 		if err := d.docker.Remove(ctx, inactive); err != nil {
 			return err
 		}
+		d.events.Emit(events.Event{Type: events.TypePhase, Service: st.Service, Phase: events.PhaseRemove, Count: len(inactive), Containers: inactive})
 	} else {
 		d.log.Infof("==> No inactive %s containers found for service '%s'", inactiveSide, st.Service)
 	}
@@ -340,9 +512,9 @@ func (d *Deployer) CleanupProjectState(ctx context.Context, project string, st s
 	if err != nil {
 		return err
 	}
-	productionRule, port := productionRuleAndPort(labels, st.Service)
+	productionRule, port := d.productionRuleAndPort(ctx, labels, st.Service, active)
 	tcpRoutes := traefik.ExtractTCPRoutes(labels)
-	hc := extractHealthCheck(labels, st.Service)
+	hc := traefik.HealthCheck(labels, st.Service, port)
 
 	var oldIDs []string
 	var newIDs []string
@@ -356,6 +528,7 @@ func (d *Deployer) CleanupProjectState(ctx context.Context, project string, st s
 		Service:        st.Service,
 		ProductionRule: productionRule,
 		Port:           port,
+		Scheme:         traefik.ServerScheme(labels, st.Service),
 		OldIDs:         oldIDs,
 		NewIDs:         newIDs,
 		NewWeight:      weight,
@@ -364,6 +537,9 @@ func (d *Deployer) CleanupProjectState(ctx context.Context, project string, st s
 	}); err != nil {
 		return fmt.Errorf("failed to update traefik config after cleanup: %w", err)
 	}
+	if err := d.reloader.Reload(ctx, d.log, proxy.TypeTraefik); err != nil {
+		return err
+	}
 
 	if err := d.store.Delete(project); err != nil {
 		return err
@@ -433,69 +609,23 @@ func (d *Deployer) labelsFromCandidates(ctx context.Context, first []string, fal
 	return nil, fmt.Errorf("unable to read labels from canary containers")
 }
 
-func productionRuleAndPort(labels map[string]string, service string) (string, string) {
+// productionRuleAndPort returns the router rule of service and its backend port; without
+// a port label the port exposed by the first of containerIDs is used, see
+// traefik.BackendPort.
+func (d *Deployer) productionRuleAndPort(ctx context.Context, labels map[string]string, service string, containerIDs []string) (string, string) {
 	rule := labels["traefik.http.routers."+service+".rule"]
 	if strings.TrimSpace(rule) == "" {
 		rule = fmt.Sprintf("Host(`%s.local`)", service)
 	}
-	port := labels["traefik.http.services."+service+".loadbalancer.server.port"]
-	if strings.TrimSpace(port) == "" {
-		port = "80"
+	containerID := ""
+	if len(containerIDs) > 0 {
+		containerID = containerIDs[0]
 	}
-	return rule, port
+	return rule, traefik.BackendPort(ctx, d.log, d.docker, labels, service, containerID)
 }
 
-func extractHealthCheck(labels map[string]string, serviceName string) *types.HealthChecks {
-	prefix := "traefik.http.services." + serviceName + ".loadbalancer.healthCheck."
-	hc := &types.HealthChecks{
-		Path:            labels[prefix+"path"],
-		Interval:        labels[prefix+"interval"],
-		Timeout:         labels[prefix+"timeout"],
-		Scheme:          labels[prefix+"scheme"],
-		Mode:            labels[prefix+"mode"],
-		Hostname:        labels[prefix+"hostname"],
-		Port:            labels[prefix+"port"],
-		FollowRedirects: labels[prefix+"followRedirects"],
-		Method:          labels[prefix+"method"],
-		Status:          labels[prefix+"status"],
-	}
-
-	headers := map[string]string{}
-	for k, v := range labels {
-		if strings.HasPrefix(k, prefix+"headers.") {
-			headers[strings.TrimPrefix(k, prefix+"headers.")] = v
-		}
-	}
-	if len(headers) > 0 {
-		hc.Headers = headers
-	}
-
-	if hc.Path == "" && hc.Interval == "" && hc.Timeout == "" && hc.Scheme == "" && hc.Mode == "" &&
-		hc.Hostname == "" && hc.Port == "" && hc.FollowRedirects == "" && hc.Method == "" && hc.Status == "" && len(hc.Headers) == 0 {
-		return nil
-	}
-	return hc
-}
-
-func (d *Deployer) waitHealthy(ctx context.Context, containerIDs []string, expected int, timeoutSec int) (bool, error) {
-	deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
-	for time.Now().Before(deadline) {
-		okCount := 0
-		for _, id := range containerIDs {
-			status, err := d.docker.HealthStatus(ctx, id)
-			if err != nil {
-				return false, err
-			}
-			if status == "healthy" {
-				okCount++
-			}
-		}
-		if okCount == expected {
-			return true, nil
-		}
-		time.Sleep(time.Second)
-	}
-	return false, nil
+func (d *Deployer) waitHealthy(ctx context.Context, containerIDs []string, expected int, timeoutSec int, pollInterval time.Duration, startPeriod time.Duration, accepted []string, tracker *events.HealthTracker) (bool, error) {
+	return healthdiag.WaitHealthy(ctx, d.log, d.docker, containerIDs, expected, time.Duration(timeoutSec)*time.Second, pollInterval, startPeriod, accepted, tracker)
 }
 
 func diffIDs(oldIDs []string, allIDs []string) []string {
@@ -550,9 +680,9 @@ func (d *Deployer) runMetricsGateWithRollback(ctx context.Context, opt Options,
 			AutoCleanup:       opt.AutoCleanup,
 		})
 		if rbErr != nil {
-			return fmt.Errorf("metrics analysis failed during canary %s (%v); rollback also failed: %w", stage, err, rbErr)
+			return safeguard.WithReason(safeguard.ReasonMetricsGate, fmt.Errorf("metrics analysis failed during canary %s (%v); rollback also failed: %w", stage, err, rbErr))
 		}
-		return fmt.Errorf("metrics analysis failed during canary %s: %w; rollback applied", stage, err)
+		return safeguard.WithRollback(safeguard.ReasonMetricsGate, fmt.Errorf("metrics analysis failed during canary %s: %w; rollback applied", stage, err))
 	}
 
 	lifetimeResult, lifetimeSince := d.buildCanaryLifetimeSummary(ctx, opt, st, targetService)
@@ -572,9 +702,9 @@ func (d *Deployer) runMetricsGateWithRollback(ctx context.Context, opt Options,
 		})
 		d.log.Warnf("%s", formatCanaryResultReport(opt.Weight, result, "ROLLBACK -> new=0%%, old=100%%", lifetimeResult, lifetimeSince))
 		if rbErr != nil {
-			return fmt.Errorf("canary metrics gate failed after %s (%s); rollback also failed: %w", stage, strings.Join(result.Reasons, "; "), rbErr)
+			return safeguard.WithReason(safeguard.ReasonMetricsGate, fmt.Errorf("canary metrics gate failed after %s (%s); rollback also failed: %w", stage, strings.Join(result.Reasons, "; "), rbErr))
 		}
-		return fmt.Errorf("canary metrics gate failed after %s (%s); rollback applied", stage, strings.Join(result.Reasons, "; "))
+		return safeguard.WithRollback(safeguard.ReasonMetricsGate, fmt.Errorf("canary metrics gate failed after %s (%s); rollback applied", stage, strings.Join(result.Reasons, "; ")))
 	}
 }
 
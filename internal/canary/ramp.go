@@ -0,0 +1,109 @@
+package canary
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
+)
+
+// ramp shifts traffic from opt.Weight to 100% in opt.RampSteps steps spread over
+// opt.RampDuration. Each step is held for the metrics gate window (plus any time
+// left of its share of the ramp); a failing gate rolls back to new=0%.
+func (d *Deployer) ramp(ctx context.Context, opt Options, project string, st state.DeploymentState) error {
+	weights := rampWeights(opt.Weight, opt.RampSteps)
+	if len(weights) == 0 {
+		return nil
+	}
+	hold := opt.RampDuration/time.Duration(len(weights)) - opt.Metrics.Window
+	d.log.Infof("==> Ramping canary '%s' to 100%% over %s: %v", opt.Service, opt.RampDuration, weights)
+
+	for _, weight := range weights {
+		var err error
+		st, err = d.applyWeight(ctx, opt, project, st, weight)
+		if err != nil {
+			return err
+		}
+		d.log.Infof("==> Canary ramp: old=%d%% new=%d%%", 100-weight, weight)
+		d.events.Emit(events.Event{Type: events.TypePhase, Service: opt.Service, Phase: events.PhaseRamp, Message: fmt.Sprintf("new=%d%%", weight)})
+
+		stepOpt := opt
+		stepOpt.Weight = weight
+		if err := d.runMetricsGateWithRollback(ctx, stepOpt, fmt.Sprintf("ramp to %d%%", weight), st); err != nil {
+			return err
+		}
+		if hold > 0 {
+			if err := safeguard.Sleep(ctx, hold); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := d.promote(ctx, opt)
+	return err
+}
+
+// hold keeps the canary at opt.Weight for opt.CanaryDuration, then promotes it and
+// removes the old containers. Interrupting the wait leaves the split in place, so
+// promote, rollback or abort can still be run by hand.
+func (d *Deployer) hold(ctx context.Context, opt Options) error {
+	d.log.Infof("==> Holding canary '%s' at new=%d%% for %s before promoting", opt.Service, opt.Weight, opt.CanaryDuration)
+	if err := safeguard.Sleep(ctx, opt.CanaryDuration); err != nil {
+		return err
+	}
+	if _, err := d.promote(ctx, opt); err != nil {
+		return err
+	}
+	return d.cleanup(ctx, opt)
+}
+
+// applyWeight routes weight percent of traffic to the new containers of st and
+// persists the new weight.
+func (d *Deployer) applyWeight(ctx context.Context, opt Options, project string, st state.DeploymentState, weight int) (state.DeploymentState, error) {
+	labels, err := d.labelsFromState(ctx, st)
+	if err != nil {
+		return st, err
+	}
+	productionRule, port := d.productionRuleAndPort(ctx, labels, st.Service, st.New)
+	if err := traefik.ApplyCanaryConfig(opt.TraefikConfigFile, traefik.CanaryConfigInput{
+		Service:        st.Service,
+		ProductionRule: productionRule,
+		Port:           port,
+		Scheme:         traefik.ServerScheme(labels, st.Service),
+		OldIDs:         st.Old,
+		NewIDs:         st.New,
+		NewWeight:      weight,
+		TCPRouters:     traefik.ExtractTCPRoutes(labels),
+		HealthCheck:    traefik.HealthCheck(labels, st.Service, port),
+		CanaryRule:     st.CanaryRule,
+	}); err != nil {
+		return st, err
+	}
+	if err := d.reloader.Reload(ctx, d.log, proxy.TypeTraefik); err != nil {
+		return st, err
+	}
+	now := time.Now().UTC()
+	st.Weight = weight
+	st.SwitchedAt = &now
+	return st, d.store.Save(project, st)
+}
+
+// rampWeights returns steps evenly spaced weights above start, ending at 100.
+func rampWeights(start int, steps int) []int {
+	if steps < 1 || start >= 100 {
+		return nil
+	}
+	weights := make([]int, 0, steps)
+	for i := 1; i <= steps; i++ {
+		weight := start + (100-start)*i/steps
+		if weight <= start || (len(weights) > 0 && weights[len(weights)-1] == weight) {
+			continue
+		}
+		weights = append(weights, weight)
+	}
+	return weights
+}
@@ -0,0 +1,171 @@
+package canary
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/metricsgate"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRampWeights(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		start int
+		steps int
+		want  []int
+	}{
+		{start: 10, steps: 3, want: []int{40, 70, 100}},
+		{start: 5, steps: 1, want: []int{100}},
+		{start: 98, steps: 5, want: []int{99, 100}},
+		{start: 100, steps: 5, want: nil},
+	}
+	for _, tc := range cases {
+		if got := rampWeights(tc.start, tc.steps); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("rampWeights(%d, %d) = %v, want %v", tc.start, tc.steps, got, tc.want)
+		}
+	}
+}
+
+func TestDeployRampPromotesWhenEveryStepPasses(t *testing.T) {
+	t.Parallel()
+
+	store, deployer := newRampFixture(t)
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, "traefik_service_requests_total{code=\"200\",service=\"api_new@file\"} %d\n", 100+calls.Add(10))
+	}))
+	defer server.Close()
+
+	if err := deployer.deploy(context.Background(), rampOptions(t, server.URL)); err != nil {
+		t.Fatalf("deploy failed: %v", err)
+	}
+	got, err := store.Load("project")
+	if err != nil {
+		t.Fatalf("load state: %v", err)
+	}
+	if got.Weight != 100 {
+		t.Fatalf("expected ramp to promote to 100, got %d", got.Weight)
+	}
+}
+
+func TestDeployRampRollsBackOnFailingStep(t *testing.T) {
+	t.Parallel()
+
+	store, deployer := newRampFixture(t)
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		call := calls.Add(1)
+		fmt.Fprintf(w, "traefik_service_requests_total{code=\"200\",service=\"api_new@file\"} %d\n", 100+call)
+		fmt.Fprintf(w, "traefik_service_requests_total{code=\"500\",service=\"api_new@file\"} %d\n", 1+call*5)
+	}))
+	defer server.Close()
+
+	if err := deployer.deploy(context.Background(), rampOptions(t, server.URL)); err == nil {
+		t.Fatal("expected ramp to fail when the metrics gate fails")
+	}
+	got, err := store.Load("project")
+	if err != nil {
+		t.Fatalf("load state: %v", err)
+	}
+	if got.Weight != 0 {
+		t.Fatalf("expected rollback to set weight 0, got %d", got.Weight)
+	}
+}
+
+func TestDeployCanaryDurationPromotesAndRemovesOld(t *testing.T) {
+	t.Parallel()
+
+	store, deployer := newRampFixture(t)
+	sink := &recordingSink{}
+	deployer.WithEvents(sink)
+	if err := deployer.deploy(context.Background(), Options{
+		Service:           "api",
+		Weight:            10,
+		TraefikConfigFile: t.TempDir() + "/dynamic.yml",
+		CanaryDuration:    10 * time.Millisecond,
+	}); err != nil {
+		t.Fatalf("deploy failed: %v", err)
+	}
+	if removed := deployer.docker.(*dockerMock).remove; len(removed) != 1 || removed[0] != "old-id" {
+		t.Fatalf("expected old containers to be removed, got %v", removed)
+	}
+	var swaps, removes []events.Event
+	for _, event := range sink.events {
+		switch {
+		case event.Type == events.TypeSwapComplete:
+			swaps = append(swaps, event)
+		case event.Type == events.TypePhase && event.Phase == events.PhaseRemove:
+			removes = append(removes, event)
+		}
+	}
+	if len(swaps) != 1 || swaps[0].Count != 1 || !reflect.DeepEqual(swaps[0].Containers, []string{"new-id"}) {
+		t.Fatalf("expected one swap-complete event for the new container, got %#v", swaps)
+	}
+	if len(removes) != 1 || removes[0].Count != 1 || !reflect.DeepEqual(removes[0].Containers, []string{"old-id"}) {
+		t.Fatalf("expected one remove event for the old container, got %#v", removes)
+	}
+	if _, err := store.Load("project"); err == nil {
+		t.Fatal("expected canary state to be cleared after promotion")
+	}
+}
+
+type recordingSink struct {
+	events []events.Event
+}
+
+func (s *recordingSink) Emit(event events.Event) {
+	s.events = append(s.events, event)
+}
+
+func newRampFixture(t *testing.T) (*state.Store, *Deployer) {
+	t.Helper()
+	store := state.NewStore(t.TempDir())
+	if err := store.Save("project", state.DeploymentState{
+		Service:   "api",
+		Strategy:  state.StrategyCanary,
+		Old:       []string{"old-id"},
+		New:       []string{"new-id"},
+		Weight:    10,
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+	compose := &composeMock{idsByService: map[string][]string{"api": {"old-id", "new-id"}}}
+	deployer := NewDeployer(logrus.New(), compose, &dockerMock{
+		labels: map[string]string{
+			"traefik.http.routers.api.rule":                      "Host(`example.com`)",
+			"traefik.http.services.api.loadbalancer.server.port": "8080",
+		},
+	}, store)
+	return store, deployer
+}
+
+func rampOptions(t *testing.T, metricsURL string) Options {
+	return Options{
+		Service:           "api",
+		Weight:            10,
+		TraefikConfigFile: t.TempDir() + "/dynamic.yml",
+		RampDuration:      100 * time.Millisecond,
+		RampSteps:         2,
+		Metrics: metricsgate.Config{
+			Enabled:          true,
+			URL:              metricsURL,
+			Window:           40 * time.Millisecond,
+			Interval:         10 * time.Millisecond,
+			MinRequests:      1,
+			Max5xxRatio:      0.05,
+			Max4xxRatio:      -1,
+			MaxMeanLatencyMS: -1,
+		},
+	}
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StdinComposeFile is the --file value that reads the compose file from stdin.
+const StdinComposeFile = "-"
+
+// composeFilesFromEnv returns the compose files listed in COMPOSE_FILE, like docker
+// compose does when no -f is given. Entries are separated by COMPOSE_PATH_SEPARATOR
+// when set, else by ':' or ';'.
+func composeFilesFromEnv() []string {
+	raw := strings.TrimSpace(os.Getenv("COMPOSE_FILE"))
+	if raw == "" {
+		return nil
+	}
+	var parts []string
+	if sep := os.Getenv("COMPOSE_PATH_SEPARATOR"); sep != "" {
+		parts = strings.Split(raw, sep)
+	} else {
+		parts = strings.FieldsFunc(raw, func(r rune) bool { return r == ':' || r == ';' })
+	}
+	var files []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			files = append(files, part)
+		}
+	}
+	return files
+}
+
+// defaultComposeFileNames are looked up in order when neither -f nor COMPOSE_FILE is
+// given, each with the override file docker compose merges into it.
+var defaultComposeFileNames = [][2]string{
+	{"compose.yaml", "compose.override.yaml"},
+	{"compose.yml", "compose.override.yml"},
+	{"docker-compose.yml", "docker-compose.override.yml"},
+	{"docker-compose.yaml", "docker-compose.override.yaml"},
+}
+
+// discoverComposeFiles returns the first default compose file found in dir, followed
+// by its override file when present, like docker compose does without -f.
+func discoverComposeFiles(dir string) []string {
+	for _, names := range defaultComposeFileNames {
+		file := filepath.Join(dir, names[0])
+		if !isFile(file) {
+			continue
+		}
+		files := []string{file}
+		if override := filepath.Join(dir, names[1]); isFile(override) {
+			files = append(files, override)
+		}
+		return files
+	}
+	return nil
+}
+
+// discoverEnvFile returns the .env file of the project, in dir or else next to the
+// first compose file, or "" when there is none.
+func discoverEnvFile(dir string, files []string) string {
+	if dir == "" {
+		if len(files) == 0 || files[0] == StdinComposeFile {
+			return ""
+		}
+		dir = filepath.Dir(files[0])
+	}
+	if file := filepath.Join(dir, ".env"); isFile(file) {
+		return file
+	}
+	return ""
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
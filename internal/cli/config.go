@@ -1,13 +1,22 @@
 package cli
 
-import "time"
+import (
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/retry"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/signature"
+)
 
 const (
 	DefaultHealthcheckTimeout   = 60
 	DefaultNoHealthcheckTimeout = 10
 	DefaultWaitAfterHealthy     = 0
+	DefaultPollInterval         = time.Second
 	DefaultTraefikConfig        = "traefik/dynamic_conf.yml"
-	DefaultProxyType            = "traefik"
+	DefaultNginxConfig          = "nginx/ztd.conf"
+	DefaultHAProxyConfig        = "haproxy/ztd.cfg"
+	DefaultProxyType            = ProxyTraefik
 	DefaultStrategy             = StrategyRolling
 	DefaultCanaryWeight         = 10
 	DefaultMetricsURL           = "http://localhost:8080/metrics"
@@ -17,6 +26,25 @@ const (
 	DefaultAnalyzeMax5xxRatio   = 0.05
 	DefaultAnalyzeMax4xxRatio   = -1.0
 	DefaultAnalyzeMaxLatencyMS  = -1.0
+	DefaultMaxConcurrentDeploys = 0
+	DefaultDeploySlotTimeout    = 10 * time.Minute
+	DefaultTimeoutAction        = TimeoutActionRollback
+	DefaultVersionLabel         = "org.opencontainers.image.revision"
+	DefaultVerifyCommand        = signature.DefaultCommand
+	DefaultResourceCheck        = ResourceCheckOff
+	DefaultRollbackWindow       = time.Hour
+	DefaultBreakerCooldown      = 30 * time.Minute
+	DefaultRampSteps            = 5
+	DefaultScaleStep            = ScaleStepDouble
+	DefaultStopConcurrency      = 4
+	DefaultMaxRetries           = retry.DefaultMaxRetries
+	DefaultLogFormat            = LogFormatText
+	DefaultLogLevel             = "info"
+	LogFormatText               = "text"
+	LogFormatJSON               = "json"
+	OutputText                  = "text"
+	OutputJSON                  = "json"
+	ScaleStepDouble             = "double"
 )
 
 const (
@@ -25,22 +53,57 @@ const (
 	StrategyCanary    = "canary"
 )
 
+const (
+	ProxyTraefik    = "traefik"
+	ProxyNginxProxy = "nginx-proxy"
+	ProxyHAProxy    = "haproxy"
+)
+
+const (
+	TimeoutActionRollback = safeguard.TimeoutActionRollback
+	TimeoutActionKeep     = safeguard.TimeoutActionKeep
+	TimeoutActionForce    = safeguard.TimeoutActionForce
+)
+
+const (
+	ResourceCheckStrict = "strict"
+	ResourceCheckWarn   = "warn"
+	ResourceCheckOff    = "off"
+)
+
 const (
 	ActionDeploy   = ""
 	ActionSwitch   = "switch"
 	ActionCleanup  = "cleanup"
 	ActionRollback = "rollback"
+	ActionPromote  = "promote"
+	ActionAbort    = "abort"
+	ActionExplain  = "explain"
+	ActionStatus   = "status"
+	ActionDown     = "down"
 	ActionAutoRun  = "auto-cleanup-run"
 )
 
 type Config struct {
 	DockerArgs           []string
+	DockerHost           string
+	DockerContext        string
 	ComposeFiles         []string
 	EnvFiles             []string
 	HealthcheckTimeout   int
 	NoHealthcheckTimeout int
 	WaitAfterHealthy     int
 	TraefikConfigFile    string
+	DNSNames             bool
+	NginxConfigFile      string
+	NginxReloadCommand   string
+	HAProxyConfigFile    string
+	HAProxyReloadCommand string
+	ReloadCommand        string
+	ReloadEndpoint       string
+	ReloadRequired       bool
+	TraefikAPI           string
+	NoProxy              bool
 	ProxyType            string
 	Strategy             string
 	HostMode             string
@@ -52,7 +115,9 @@ type Config struct {
 	SwitchTo             string
 	AutoCleanup          time.Duration
 	Service              string
+	Services             []string
 	UpDetached           bool
+	RemoveOrphans        bool
 	ShowHelp             bool
 	Analyze              bool
 	MetricsURL           string
@@ -62,4 +127,83 @@ type Config struct {
 	AnalyzeMax5xxRatio   float64
 	AnalyzeMax4xxRatio   float64
 	AnalyzeMaxLatencyMS  float64
+	MaxConcurrentDeploys int
+	DeploySlotTimeout    time.Duration
+	LockTimeout          time.Duration
+	PreDeployHook        string
+	PostDeployHook       string
+	PreStopHook          string
+	DeployTimeout        time.Duration
+	PollInterval         time.Duration
+	StartPeriod          time.Duration
+	TimeoutAction        string
+	OnlyConfig           bool
+	TimestampFormat      string
+	HealthyStatuses      []string
+	DeployIfChanged      bool
+	DryRun               bool
+	SkipIfCurrent        bool
+	Recreate             bool
+	ForceRegenerate      bool
+	VersionLabel         string
+	EventsSocket         string
+	SurgeOverride        string
+	ProjectDirectory     string
+	ProjectName          string
+	CriticalCount        int
+	FailOnUnmatched      bool
+	DeployReason         string
+	DeployedBy           string
+	ContinueOnError      bool
+	Parallel             bool
+	TCPProbePort         int
+	Network              string
+	OtelEndpoint         string
+	Color                string
+	LogFormat            string
+	Output               string
+	LogLevel             string
+	VerifySignature      bool
+	VerifyCommand        string
+	CanaryRule           string
+	ReconcileCount       bool
+	ResourceCheck        string
+	Replicas             int
+	ScaleStep            string
+	MinOldUptime         time.Duration
+	Drain                time.Duration
+	StopConcurrency      int
+	StopTimeout          int
+	MaxRetries           int
+	Image                string
+	ImagePort            int
+	ImageRule            string
+	MaxRollbacks         int
+	RollbackWindow       time.Duration
+	BreakerCooldown      time.Duration
+	ResetBreaker         bool
+	DownAll              bool
+	ServiceLabel         string
+	PrintConfig          bool
+	RampDuration         time.Duration
+	RampSteps            int
+	CanaryDuration       time.Duration
+	ConfigFile           string
+	// RestoreImage pins the service to this image ID for the deploy. It is not a flag:
+	// fail-fast sets it to roll already deployed services back to their previous image.
+	RestoreImage string
+	// ExplicitOptions holds the long names of the options given on the command line or
+	// in --config that service labels may otherwise override (timeout, wait,
+	// wait-after-healthy).
+	ExplicitOptions map[string]bool
+	// Warnings are non-fatal problems found while parsing, such as unknown keys in
+	// the --config file.
+	Warnings []string
+}
+
+func (c *Config) markExplicit(option string) {
+	if c.ExplicitOptions == nil {
+		c.ExplicitOptions = map[string]bool{}
+	}
+	c.ExplicitOptions[option] = true
 }
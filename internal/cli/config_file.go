@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the YAML read from --config. Keys are the long flag names.
+type fileConfig struct {
+	Timeout          *int     `yaml:"timeout"`
+	Wait             *int     `yaml:"wait"`
+	WaitAfterHealthy *int     `yaml:"wait-after-healthy"`
+	TraefikConf      string   `yaml:"traefik-conf"`
+	Proxy            string   `yaml:"proxy"`
+	Files            []string `yaml:"file"`
+	EnvFiles         []string `yaml:"env-file"`
+	Service          string   `yaml:"service"`
+}
+
+var fileConfigKeys = map[string]struct{}{
+	"timeout":            {},
+	"wait":               {},
+	"wait-after-healthy": {},
+	"traefik-conf":       {},
+	"proxy":              {},
+	"file":               {},
+	"env-file":           {},
+	"service":            {},
+}
+
+// extractConfigFlag removes --config FILE from args and returns the file path, or ""
+// when it is not set.
+func extractConfigFlag(args []string) ([]string, string, error) {
+	rest := make([]string, 0, len(args))
+	path := ""
+	for len(args) > 0 {
+		token := args[0]
+		if token != "--config" && !strings.HasPrefix(token, "--config=") {
+			rest = append(rest, token)
+			args = args[1:]
+			continue
+		}
+		value, consumed, err := parseStringFlag(args, "--config")
+		if err != nil {
+			return nil, "", err
+		}
+		path = value
+		args = args[consumed:]
+	}
+	return rest, path, nil
+}
+
+// loadConfigFile reads path and returns its settings with a warning per unknown key.
+func loadConfigFile(path string) (fileConfig, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileConfig{}, nil, fmt.Errorf("read --config file: %w", err)
+	}
+	var keys map[string]any
+	if err := yaml.Unmarshal(data, &keys); err != nil {
+		return fileConfig{}, nil, fmt.Errorf("parse --config file %s: %w", path, err)
+	}
+	var warnings []string
+	for key := range keys {
+		if _, ok := fileConfigKeys[key]; !ok {
+			warnings = append(warnings, fmt.Sprintf("unknown key %q in config file %s is ignored", key, path))
+		}
+	}
+	sort.Strings(warnings)
+
+	var fc fileConfig
+	if err := yaml.Unmarshal(data, &fc); err != nil {
+		return fileConfig{}, nil, fmt.Errorf("parse --config file %s: %w", path, err)
+	}
+	return fc, warnings, nil
+}
+
+// args renders the file settings as flags to parse ahead of cliArgs, so flags given
+// on the command line win. Compose and env files from the file are dropped when
+// cliArgs set their own.
+func (fc fileConfig) args(cliArgs []string) []string {
+	var args []string
+	if fc.Timeout != nil {
+		args = append(args, "--timeout", strconv.Itoa(*fc.Timeout))
+	}
+	if fc.Wait != nil {
+		args = append(args, "--wait", strconv.Itoa(*fc.Wait))
+	}
+	if fc.WaitAfterHealthy != nil {
+		args = append(args, "--wait-after-healthy", strconv.Itoa(*fc.WaitAfterHealthy))
+	}
+	if fc.TraefikConf != "" {
+		args = append(args, "--traefik-conf", fc.TraefikConf)
+	}
+	if fc.Proxy != "" {
+		args = append(args, "--proxy", fc.Proxy)
+	}
+	if indexOf(cliArgs, "-f") < 0 && indexOf(cliArgs, "--file") < 0 {
+		for _, file := range fc.Files {
+			args = append(args, "--file", file)
+		}
+	}
+	if indexOf(cliArgs, "--env-file") < 0 {
+		for _, file := range fc.EnvFiles {
+			args = append(args, "--env-file", file)
+		}
+	}
+	return args
+}
+
+// applyService sets the file's service when the command line named none. A lone
+// action on the command line, such as "switch", then applies to that service.
+func (fc fileConfig) applyService(cfg *Config) {
+	if fc.Service == "" || cfg.Action == ActionAutoRun || cfg.DownAll {
+		return
+	}
+	switch {
+	case cfg.Service == "":
+		cfg.Service = fc.Service
+		cfg.Services = []string{fc.Service}
+	case cfg.Action == ActionDeploy && len(cfg.Services) == 1 && isActionToken(cfg.Service):
+		cfg.Action = cfg.Service
+		cfg.Service = fc.Service
+		cfg.Services = []string{fc.Service}
+	}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ztd.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+const testConfigFile = `file: [docker-compose.yml, docker-compose.prod.yml]
+env-file: [.env.prod]
+service: api
+proxy: traefik
+traefik-conf: traefik/prod.yml
+timeout: 120
+wait: 15
+wait-after-healthy: 5
+`
+
+func TestParse_ConfigFile(t *testing.T) {
+	path := writeConfigFile(t, testConfigFile)
+
+	cfg, err := Parse([]string{"--config", path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Service != "api" || !reflect.DeepEqual(cfg.Services, []string{"api"}) {
+		t.Fatalf("expected service from file, got %q %v", cfg.Service, cfg.Services)
+	}
+	if !reflect.DeepEqual(cfg.ComposeFiles, []string{"docker-compose.yml", "docker-compose.prod.yml"}) {
+		t.Fatalf("unexpected compose files: %v", cfg.ComposeFiles)
+	}
+	if !reflect.DeepEqual(cfg.EnvFiles, []string{".env.prod"}) {
+		t.Fatalf("unexpected env files: %v", cfg.EnvFiles)
+	}
+	if cfg.HealthcheckTimeout != 120 || cfg.NoHealthcheckTimeout != 15 || cfg.WaitAfterHealthy != 5 {
+		t.Fatalf("unexpected timeouts: %+v", cfg)
+	}
+	if cfg.TraefikConfigFile != "traefik/prod.yml" || cfg.ProxyType != ProxyTraefik {
+		t.Fatalf("unexpected proxy settings: %+v", cfg)
+	}
+	if cfg.ConfigFile != path || len(cfg.Warnings) != 0 {
+		t.Fatalf("unexpected config file %q or warnings %v", cfg.ConfigFile, cfg.Warnings)
+	}
+}
+
+func TestParse_ConfigFileFlagsOverride(t *testing.T) {
+	path := writeConfigFile(t, testConfigFile)
+
+	cfg, err := Parse([]string{"-t", "30", "--config=" + path, "-f", "other.yml", "web"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.HealthcheckTimeout != 30 {
+		t.Fatalf("expected command-line timeout to win, got %d", cfg.HealthcheckTimeout)
+	}
+	if !reflect.DeepEqual(cfg.ComposeFiles, []string{"other.yml"}) {
+		t.Fatalf("expected command-line compose files to replace the file's, got %v", cfg.ComposeFiles)
+	}
+	if !reflect.DeepEqual(cfg.EnvFiles, []string{".env.prod"}) {
+		t.Fatalf("expected env files from file, got %v", cfg.EnvFiles)
+	}
+	if cfg.Service != "web" || !reflect.DeepEqual(cfg.Services, []string{"web"}) {
+		t.Fatalf("expected command-line service to win, got %q %v", cfg.Service, cfg.Services)
+	}
+
+	cfg, err = Parse([]string{"--config", path, "--strategy", "blue-green", "switch"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Service != "api" || cfg.Action != ActionSwitch {
+		t.Fatalf("expected switch of the file's service, got service=%q action=%q", cfg.Service, cfg.Action)
+	}
+}
+
+func TestParse_ConfigFileUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "service: api\nstrategy: canary\nretries: 3\n")
+
+	cfg, err := Parse([]string{"--config", path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Warnings) != 2 || !strings.Contains(cfg.Warnings[0], `"retries"`) || !strings.Contains(cfg.Warnings[1], `"strategy"`) {
+		t.Fatalf("expected warnings for unknown keys, got %v", cfg.Warnings)
+	}
+}
+
+func TestParse_ConfigFileErrors(t *testing.T) {
+	if _, err := Parse([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Fatal("expected error for missing config file")
+	}
+	if _, err := Parse([]string{"--config"}); err == nil {
+		t.Fatal("expected error for --config without a value")
+	}
+	path := writeConfigFile(t, "timeout: soon\n")
+	if _, err := Parse([]string{"--config", path}); err == nil {
+		t.Fatal("expected error for invalid timeout in config file")
+	}
+}
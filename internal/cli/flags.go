@@ -2,9 +2,12 @@ package cli
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
 )
 
 func Parse(rawArgs []string) (Config, error) {
@@ -13,6 +16,8 @@ func Parse(rawArgs []string) (Config, error) {
 		NoHealthcheckTimeout: DefaultNoHealthcheckTimeout,
 		WaitAfterHealthy:     DefaultWaitAfterHealthy,
 		TraefikConfigFile:    DefaultTraefikConfig,
+		NginxConfigFile:      DefaultNginxConfig,
+		HAProxyConfigFile:    DefaultHAProxyConfig,
 		ProxyType:            DefaultProxyType,
 		Strategy:             DefaultStrategy,
 		Weight:               DefaultCanaryWeight,
@@ -23,8 +28,25 @@ func Parse(rawArgs []string) (Config, error) {
 		AnalyzeMax5xxRatio:   DefaultAnalyzeMax5xxRatio,
 		AnalyzeMax4xxRatio:   DefaultAnalyzeMax4xxRatio,
 		AnalyzeMaxLatencyMS:  DefaultAnalyzeMaxLatencyMS,
+		MaxConcurrentDeploys: DefaultMaxConcurrentDeploys,
+		DeploySlotTimeout:    DefaultDeploySlotTimeout,
+		PollInterval:         DefaultPollInterval,
+		TimeoutAction:        DefaultTimeoutAction,
+		VersionLabel:         DefaultVersionLabel,
+		VerifyCommand:        DefaultVerifyCommand,
+		ResourceCheck:        DefaultResourceCheck,
+		RollbackWindow:       DefaultRollbackWindow,
+		BreakerCooldown:      DefaultBreakerCooldown,
+		ServiceLabel:         compose.DefaultServiceLabel,
+		RampSteps:            DefaultRampSteps,
+		ScaleStep:            DefaultScaleStep,
+		StopConcurrency:      DefaultStopConcurrency,
+		MaxRetries:           DefaultMaxRetries,
+		LogFormat:            DefaultLogFormat,
+		LogLevel:             DefaultLogLevel,
 	}
 	weightExplicitlySet := false
+	canaryShorthand := false
 	strategyExplicitlySet := false
 
 	args := rawArgs
@@ -33,8 +55,39 @@ func Parse(rawArgs []string) (Config, error) {
 		args = args[ztdIdx+1:]
 	}
 
+	args, configPath, err := extractConfigFlag(args)
+	if err != nil {
+		return cfg, err
+	}
+	var file fileConfig
+	if configPath != "" {
+		var warnings []string
+		if file, warnings, err = loadConfigFile(configPath); err != nil {
+			return cfg, err
+		}
+		cfg.ConfigFile = configPath
+		cfg.Warnings = warnings
+		args = append(file.args(args), args...)
+	}
+
 	for len(args) > 0 {
 		switch token := args[0]; {
+		case token == "-H" || token == "--host" || strings.HasPrefix(token, "--host="):
+			value, consumed, err := parseStringFlag(args, "--host")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.DockerHost = value
+			cfg.DockerArgs = append(cfg.DockerArgs, "--host", value)
+			args = args[consumed:]
+		case token == "--context" || strings.HasPrefix(token, "--context="):
+			value, consumed, err := parseStringFlag(args, "--context")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.DockerContext = value
+			cfg.DockerArgs = append(cfg.DockerArgs, "--context", value)
+			args = args[consumed:]
 		case token == "--proxy":
 			if len(args) < 2 {
 				return cfg, fmt.Errorf("missing value for --proxy")
@@ -47,6 +100,73 @@ func Parse(rawArgs []string) (Config, error) {
 			}
 			cfg.TraefikConfigFile = args[1]
 			args = args[2:]
+		case token == "--dns-names":
+			cfg.DNSNames = true
+			args = args[1:]
+		case token == "--nginx-conf" || strings.HasPrefix(token, "--nginx-conf="):
+			value, consumed, err := parseStringFlag(args, "--nginx-conf")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.NginxConfigFile = value
+			args = args[consumed:]
+		case token == "--nginx-reload" || strings.HasPrefix(token, "--nginx-reload="):
+			value, consumed, err := parseStringFlag(args, "--nginx-reload")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.NginxReloadCommand = value
+			args = args[consumed:]
+		case token == "--haproxy-conf" || strings.HasPrefix(token, "--haproxy-conf="):
+			value, consumed, err := parseStringFlag(args, "--haproxy-conf")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.HAProxyConfigFile = value
+			args = args[consumed:]
+		case token == "--haproxy-reload" || strings.HasPrefix(token, "--haproxy-reload="):
+			value, consumed, err := parseStringFlag(args, "--haproxy-reload")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.HAProxyReloadCommand = value
+			args = args[consumed:]
+		case token == "--reload-cmd" || strings.HasPrefix(token, "--reload-cmd="):
+			value, consumed, err := parseStringFlag(args, "--reload-cmd")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.ReloadCommand = value
+			args = args[consumed:]
+		case token == "--reload-endpoint" || strings.HasPrefix(token, "--reload-endpoint="):
+			value, consumed, err := parseStringFlag(args, "--reload-endpoint")
+			if err != nil {
+				return cfg, err
+			}
+			if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+				return cfg, fmt.Errorf("--reload-endpoint must be an http:// or https:// URL")
+			}
+			cfg.ReloadEndpoint = value
+			args = args[consumed:]
+		case token == "--traefik-api" || strings.HasPrefix(token, "--traefik-api="):
+			value, consumed, err := parseStringFlag(args, "--traefik-api")
+			if err != nil {
+				return cfg, err
+			}
+			if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+				return cfg, fmt.Errorf("--traefik-api must be an http:// or https:// URL")
+			}
+			cfg.TraefikAPI = value
+			args = args[consumed:]
+		case token == "--reload-required":
+			cfg.ReloadRequired = true
+			args = args[1:]
+		case token == "--no-proxy" || token == "--no-traefik":
+			cfg.NoProxy = true
+			args = args[1:]
+		case token == "-n" || token == "--dry-run":
+			cfg.DryRun = true
+			args = args[1:]
 		case token == "-h" || token == "--help":
 			cfg.ShowHelp = true
 			args = args[1:]
@@ -62,36 +182,40 @@ func Parse(rawArgs []string) (Config, error) {
 			}
 			cfg.EnvFiles = append(cfg.EnvFiles, args[1])
 			args = args[2:]
-		case token == "-t" || token == "--timeout":
-			if len(args) < 2 {
-				return cfg, fmt.Errorf("missing value for --timeout")
-			}
-			n, err := strconv.Atoi(args[1])
+		case token == "-t" || token == "--timeout" || strings.HasPrefix(token, "--timeout="):
+			n, consumed, err := parseSecondsFlag(args, "--timeout")
 			if err != nil {
-				return cfg, fmt.Errorf("invalid --timeout: %w", err)
+				return cfg, err
 			}
 			cfg.HealthcheckTimeout = n
-			args = args[2:]
-		case token == "-w" || token == "--wait":
-			if len(args) < 2 {
-				return cfg, fmt.Errorf("missing value for --wait")
-			}
-			n, err := strconv.Atoi(args[1])
+			cfg.markExplicit("timeout")
+			args = args[consumed:]
+		case token == "-w" || token == "--wait" || strings.HasPrefix(token, "--wait="):
+			n, consumed, err := parseSecondsFlag(args, "--wait")
 			if err != nil {
-				return cfg, fmt.Errorf("invalid --wait: %w", err)
+				return cfg, err
 			}
 			cfg.NoHealthcheckTimeout = n
-			args = args[2:]
-		case token == "--wait-after-healthy":
-			if len(args) < 2 {
-				return cfg, fmt.Errorf("missing value for --wait-after-healthy")
-			}
-			n, err := strconv.Atoi(args[1])
+			cfg.markExplicit("wait")
+			args = args[consumed:]
+		case token == "--wait-after-healthy" || strings.HasPrefix(token, "--wait-after-healthy="):
+			n, consumed, err := parseSecondsFlag(args, "--wait-after-healthy")
 			if err != nil {
-				return cfg, fmt.Errorf("invalid --wait-after-healthy: %w", err)
+				return cfg, err
 			}
 			cfg.WaitAfterHealthy = n
-			args = args[2:]
+			cfg.markExplicit("wait-after-healthy")
+			args = args[consumed:]
+		case token == "--poll-interval" || strings.HasPrefix(token, "--poll-interval="):
+			d, consumed, err := parseDurationFlag(args, "--poll-interval")
+			if err != nil {
+				return cfg, err
+			}
+			if d <= 0 {
+				return cfg, fmt.Errorf("--poll-interval must be greater than 0")
+			}
+			cfg.PollInterval = d
+			args = args[consumed:]
 		case token == "-d":
 			if cfg.Service == "up" {
 				cfg.UpDetached = true
@@ -99,6 +223,41 @@ func Parse(rawArgs []string) (Config, error) {
 				continue
 			}
 			return cfg, fmt.Errorf("unknown option: -d")
+		case token == "--healthy-status" || strings.HasPrefix(token, "--healthy-status="):
+			value, consumed, err := parseStringFlag(args, "--healthy-status")
+			if err != nil {
+				return cfg, err
+			}
+			statuses, err := parseHealthyStatuses(value)
+			if err != nil {
+				return cfg, err
+			}
+			cfg.HealthyStatuses = statuses
+			args = args[consumed:]
+		case token == "--timeout-action" || strings.HasPrefix(token, "--timeout-action="):
+			value, consumed, err := parseStringFlag(args, "--timeout-action")
+			if err != nil {
+				return cfg, err
+			}
+			switch value {
+			case TimeoutActionRollback, TimeoutActionKeep, TimeoutActionForce:
+			default:
+				return cfg, fmt.Errorf("invalid --timeout-action: %s (options: %s, %s, %s)", value, TimeoutActionRollback, TimeoutActionKeep, TimeoutActionForce)
+			}
+			cfg.TimeoutAction = value
+			args = args[consumed:]
+		case token == "--resource-check" || strings.HasPrefix(token, "--resource-check="):
+			value, consumed, err := parseStringFlag(args, "--resource-check")
+			if err != nil {
+				return cfg, err
+			}
+			switch value {
+			case ResourceCheckStrict, ResourceCheckWarn, ResourceCheckOff:
+			default:
+				return cfg, fmt.Errorf("invalid --resource-check: %s (options: %s, %s, %s)", value, ResourceCheckStrict, ResourceCheckWarn, ResourceCheckOff)
+			}
+			cfg.ResourceCheck = value
+			args = args[consumed:]
 		case token == "--strategy" || strings.HasPrefix(token, "--strategy="):
 			value, consumed, err := parseStringFlag(args, "--strategy")
 			if err != nil {
@@ -149,6 +308,26 @@ func Parse(rawArgs []string) (Config, error) {
 			cfg.Weight = value
 			weightExplicitlySet = true
 			args = args[consumed:]
+		case token == "--canary" || strings.HasPrefix(token, "--canary="):
+			value, consumed, err := parseIntFlag(args, "--canary")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Strategy = StrategyCanary
+			cfg.Weight = value
+			weightExplicitlySet = true
+			canaryShorthand = true
+			args = args[consumed:]
+		case token == "--canary-duration" || strings.HasPrefix(token, "--canary-duration="):
+			d, consumed, err := parseDurationFlag(args, "--canary-duration")
+			if err != nil {
+				return cfg, err
+			}
+			if d <= 0 {
+				return cfg, fmt.Errorf("--canary-duration must be greater than 0")
+			}
+			cfg.CanaryDuration = d
+			args = args[consumed:]
 		case token == "--to" || strings.HasPrefix(token, "--to="):
 			value, consumed, err := parseStringFlag(args, "--to")
 			if err != nil {
@@ -156,6 +335,237 @@ func Parse(rawArgs []string) (Config, error) {
 			}
 			cfg.SwitchTo = value
 			args = args[consumed:]
+		case token == "--no-color":
+			cfg.Color = "never"
+			args = args[1:]
+		case token == "--color":
+			cfg.Color = "always"
+			args = args[1:]
+		case token == "--timestamp-format" || strings.HasPrefix(token, "--timestamp-format="):
+			value, consumed, err := parseStringFlag(args, "--timestamp-format")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.TimestampFormat = value
+			args = args[consumed:]
+		case token == "--log-format" || strings.HasPrefix(token, "--log-format="):
+			value, consumed, err := parseStringFlag(args, "--log-format")
+			if err != nil {
+				return cfg, err
+			}
+			if value != LogFormatText && value != LogFormatJSON {
+				return cfg, fmt.Errorf("--log-format must be either %s or %s", LogFormatText, LogFormatJSON)
+			}
+			cfg.LogFormat = value
+			args = args[consumed:]
+		case token == "--output" || strings.HasPrefix(token, "--output="):
+			value, consumed, err := parseStringFlag(args, "--output")
+			if err != nil {
+				return cfg, err
+			}
+			if value != OutputText && value != OutputJSON {
+				return cfg, fmt.Errorf("--output must be either %s or %s", OutputText, OutputJSON)
+			}
+			cfg.Output = value
+			args = args[consumed:]
+		case token == "--log-level" || strings.HasPrefix(token, "--log-level="):
+			value, consumed, err := parseStringFlag(args, "--log-level")
+			if err != nil {
+				return cfg, err
+			}
+			level := strings.ToLower(value)
+			switch level {
+			case "debug", "info", "warn", "error":
+			default:
+				return cfg, fmt.Errorf("--log-level must be one of debug, info, warn, error")
+			}
+			cfg.LogLevel = level
+			args = args[consumed:]
+		case token == "-v" || token == "--verbose":
+			cfg.LogLevel = "debug"
+			args = args[1:]
+		case token == "--deploy-if-changed":
+			cfg.DeployIfChanged = true
+			args = args[1:]
+		case token == "--remove-orphans":
+			cfg.RemoveOrphans = true
+			args = args[1:]
+		case token == "--skip-if-current":
+			cfg.SkipIfCurrent = true
+			args = args[1:]
+		case token == "--recreate":
+			cfg.Recreate = true
+			args = args[1:]
+		case token == "--force-regenerate":
+			cfg.ForceRegenerate = true
+			args = args[1:]
+		case token == "--service-label" || strings.HasPrefix(token, "--service-label="):
+			value, consumed, err := parseStringFlag(args, "--service-label")
+			if err != nil {
+				return cfg, err
+			}
+			if strings.TrimSpace(value) == "" {
+				return cfg, fmt.Errorf("--service-label must not be empty")
+			}
+			cfg.ServiceLabel = strings.TrimSpace(value)
+			args = args[consumed:]
+		case token == "--version-label" || strings.HasPrefix(token, "--version-label="):
+			value, consumed, err := parseStringFlag(args, "--version-label")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.VersionLabel = strings.TrimSpace(value)
+			args = args[consumed:]
+		case token == "--events-socket" || strings.HasPrefix(token, "--events-socket="):
+			value, consumed, err := parseStringFlag(args, "--events-socket")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.EventsSocket = value
+			args = args[consumed:]
+		case token == "--surge-override" || strings.HasPrefix(token, "--surge-override="):
+			value, consumed, err := parseStringFlag(args, "--surge-override")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.SurgeOverride = value
+			args = args[consumed:]
+		case token == "--project-directory" || strings.HasPrefix(token, "--project-directory="):
+			value, consumed, err := parseStringFlag(args, "--project-directory")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.ProjectDirectory = value
+			args = args[consumed:]
+		case token == "--project" || strings.HasPrefix(token, "--project="):
+			value, consumed, err := parseStringFlag(args, "--project")
+			if err != nil {
+				return cfg, err
+			}
+			if !validProjectName(value) {
+				return cfg, fmt.Errorf("invalid --project %q: use lowercase letters, digits, '-' and '_', starting with a letter or digit", value)
+			}
+			cfg.ProjectName = value
+			args = args[consumed:]
+		case token == "--fail-on-unmatched-config":
+			cfg.FailOnUnmatched = true
+			args = args[1:]
+		case token == "--reconcile-count":
+			cfg.ReconcileCount = true
+			args = args[1:]
+		case token == "--deploy-reason" || strings.HasPrefix(token, "--deploy-reason="):
+			value, consumed, err := parseStringFlag(args, "--deploy-reason")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.DeployReason = value
+			args = args[consumed:]
+		case token == "--deployed-by" || strings.HasPrefix(token, "--deployed-by="):
+			value, consumed, err := parseStringFlag(args, "--deployed-by")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.DeployedBy = value
+			args = args[consumed:]
+		case token == "--fail-fast":
+			cfg.ContinueOnError = false
+			args = args[1:]
+		case token == "--continue-on-error":
+			cfg.ContinueOnError = true
+			args = args[1:]
+		case token == "--scale-step" || strings.HasPrefix(token, "--scale-step="):
+			value, consumed, err := parseStringFlag(args, "--scale-step")
+			if err != nil {
+				return cfg, err
+			}
+			if _, _, err := ParseScaleStep(value); err != nil {
+				return cfg, err
+			}
+			cfg.ScaleStep = value
+			args = args[consumed:]
+		case token == "--batch-size" || strings.HasPrefix(token, "--batch-size="):
+			value, consumed, err := parseIntFlag(args, "--batch-size")
+			if err != nil {
+				return cfg, err
+			}
+			if value <= 0 {
+				return cfg, fmt.Errorf("--batch-size must be greater than 0")
+			}
+			cfg.ScaleStep = "+" + strconv.Itoa(value)
+			args = args[consumed:]
+		case token == "--parallel":
+			cfg.Parallel = true
+			args = args[1:]
+		case token == "--otel-endpoint" || strings.HasPrefix(token, "--otel-endpoint="):
+			value, consumed, err := parseStringFlag(args, "--otel-endpoint")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.OtelEndpoint = value
+			args = args[consumed:]
+		case token == "--verify-signature":
+			cfg.VerifySignature = true
+			args = args[1:]
+		case token == "--verify-command" || strings.HasPrefix(token, "--verify-command="):
+			value, consumed, err := parseStringFlag(args, "--verify-command")
+			if err != nil {
+				return cfg, err
+			}
+			if strings.TrimSpace(value) == "" {
+				return cfg, fmt.Errorf("--verify-command must not be empty")
+			}
+			cfg.VerifyCommand = value
+			args = args[consumed:]
+		case token == "--canary-rule" || strings.HasPrefix(token, "--canary-rule="):
+			value, consumed, err := parseStringFlag(args, "--canary-rule")
+			if err != nil {
+				return cfg, err
+			}
+			if strings.TrimSpace(value) == "" {
+				return cfg, fmt.Errorf("--canary-rule must not be empty")
+			}
+			cfg.CanaryRule = value
+			args = args[consumed:]
+		case token == "--image" || strings.HasPrefix(token, "--image="):
+			value, consumed, err := parseStringFlag(args, "--image")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Image = value
+			args = args[consumed:]
+		case token == "--name" || strings.HasPrefix(token, "--name="):
+			value, consumed, err := parseStringFlag(args, "--name")
+			if err != nil {
+				return cfg, err
+			}
+			if cfg.Service != "" {
+				return cfg, fmt.Errorf("--name cannot be combined with SERVICE")
+			}
+			cfg.Service = value
+			args = args[consumed:]
+		case token == "--port" || strings.HasPrefix(token, "--port="):
+			value, consumed, err := parseIntFlag(args, "--port")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 1 || value > 65535 {
+				return cfg, fmt.Errorf("--port must be between 1 and 65535")
+			}
+			cfg.ImagePort = value
+			args = args[consumed:]
+		case token == "--rule" || strings.HasPrefix(token, "--rule="):
+			value, consumed, err := parseStringFlag(args, "--rule")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.ImageRule = value
+			args = args[consumed:]
+		case token == "--print-config":
+			cfg.PrintConfig = true
+			args = args[1:]
+		case token == "--only-config":
+			cfg.OnlyConfig = true
+			args = args[1:]
 		case token == "--analyze":
 			cfg.Analyze = true
 			args = args[1:]
@@ -167,25 +577,17 @@ func Parse(rawArgs []string) (Config, error) {
 			cfg.MetricsURL = value
 			args = args[consumed:]
 		case token == "--analyze-window" || strings.HasPrefix(token, "--analyze-window="):
-			value, consumed, err := parseStringFlag(args, "--analyze-window")
+			d, consumed, err := parseDurationFlag(args, "--analyze-window")
 			if err != nil {
 				return cfg, err
 			}
-			d, err := time.ParseDuration(value)
-			if err != nil {
-				return cfg, fmt.Errorf("invalid --analyze-window: %w", err)
-			}
 			cfg.AnalyzeWindow = d
 			args = args[consumed:]
 		case token == "--analyze-interval" || strings.HasPrefix(token, "--analyze-interval="):
-			value, consumed, err := parseStringFlag(args, "--analyze-interval")
+			d, consumed, err := parseDurationFlag(args, "--analyze-interval")
 			if err != nil {
 				return cfg, err
 			}
-			d, err := time.ParseDuration(value)
-			if err != nil {
-				return cfg, fmt.Errorf("invalid --analyze-interval: %w", err)
-			}
 			cfg.AnalyzeInterval = d
 			args = args[consumed:]
 		case token == "--min-requests" || strings.HasPrefix(token, "--min-requests="):
@@ -217,19 +619,229 @@ func Parse(rawArgs []string) (Config, error) {
 			cfg.AnalyzeMaxLatencyMS = value
 			args = args[consumed:]
 		case token == "--auto-cleanup" || strings.HasPrefix(token, "--auto-cleanup="):
-			value, consumed, err := parseStringFlag(args, "--auto-cleanup")
+			d, consumed, err := parseDurationFlag(args, "--auto-cleanup")
 			if err != nil {
 				return cfg, err
 			}
-			d, err := time.ParseDuration(value)
-			if err != nil {
-				return cfg, fmt.Errorf("invalid --auto-cleanup: %w", err)
-			}
 			if d <= 0 {
 				return cfg, fmt.Errorf("--auto-cleanup must be greater than 0")
 			}
 			cfg.AutoCleanup = d
 			args = args[consumed:]
+		case token == "--ramp" || strings.HasPrefix(token, "--ramp="):
+			d, consumed, err := parseDurationFlag(args, "--ramp")
+			if err != nil {
+				return cfg, err
+			}
+			if d <= 0 {
+				return cfg, fmt.Errorf("--ramp must be greater than 0")
+			}
+			cfg.RampDuration = d
+			args = args[consumed:]
+		case token == "--ramp-steps" || strings.HasPrefix(token, "--ramp-steps="):
+			value, consumed, err := parseIntFlag(args, "--ramp-steps")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 1 {
+				return cfg, fmt.Errorf("--ramp-steps must be at least 1")
+			}
+			cfg.RampSteps = value
+			args = args[consumed:]
+		case token == "--tcp-probe" || strings.HasPrefix(token, "--tcp-probe="):
+			value, consumed, err := parseIntFlag(args, "--tcp-probe")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 1 || value > 65535 {
+				return cfg, fmt.Errorf("--tcp-probe must be a port between 1 and 65535")
+			}
+			cfg.TCPProbePort = value
+			args = args[consumed:]
+		case token == "--network" || strings.HasPrefix(token, "--network="):
+			value, consumed, err := parseStringFlag(args, "--network")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Network = value
+			args = args[consumed:]
+		case token == "--replicas" || strings.HasPrefix(token, "--replicas="):
+			value, consumed, err := parseIntFlag(args, "--replicas")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 1 {
+				return cfg, fmt.Errorf("--replicas must be at least 1")
+			}
+			cfg.Replicas = value
+			args = args[consumed:]
+		case token == "--critical-count" || strings.HasPrefix(token, "--critical-count="):
+			value, consumed, err := parseIntFlag(args, "--critical-count")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 0 {
+				return cfg, fmt.Errorf("--critical-count must be greater than or equal to 0")
+			}
+			cfg.CriticalCount = value
+			args = args[consumed:]
+		case token == "--max-retries" || strings.HasPrefix(token, "--max-retries="):
+			value, consumed, err := parseIntFlag(args, "--max-retries")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 0 {
+				return cfg, fmt.Errorf("--max-retries must be greater than or equal to 0")
+			}
+			cfg.MaxRetries = value
+			args = args[consumed:]
+		case token == "--max-concurrent-deploys" || strings.HasPrefix(token, "--max-concurrent-deploys="):
+			value, consumed, err := parseIntFlag(args, "--max-concurrent-deploys")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 0 {
+				return cfg, fmt.Errorf("--max-concurrent-deploys must be greater than or equal to 0")
+			}
+			cfg.MaxConcurrentDeploys = value
+			args = args[consumed:]
+		case token == "--stop-concurrency" || strings.HasPrefix(token, "--stop-concurrency="):
+			value, consumed, err := parseIntFlag(args, "--stop-concurrency")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 1 {
+				return cfg, fmt.Errorf("--stop-concurrency must be greater than 0")
+			}
+			cfg.StopConcurrency = value
+			args = args[consumed:]
+		case token == "--stop-timeout" || strings.HasPrefix(token, "--stop-timeout="):
+			value, consumed, err := parseSecondsFlag(args, "--stop-timeout")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 0 {
+				return cfg, fmt.Errorf("--stop-timeout must be greater than or equal to 0")
+			}
+			cfg.StopTimeout = value
+			args = args[consumed:]
+		case token == "--min-old-uptime" || strings.HasPrefix(token, "--min-old-uptime="):
+			d, consumed, err := parseDurationFlag(args, "--min-old-uptime")
+			if err != nil {
+				return cfg, err
+			}
+			if d < 0 {
+				return cfg, fmt.Errorf("--min-old-uptime must not be negative")
+			}
+			cfg.MinOldUptime = d
+			args = args[consumed:]
+		case token == "--drain" || strings.HasPrefix(token, "--drain="):
+			d, consumed, err := parseDurationFlag(args, "--drain")
+			if err != nil {
+				return cfg, err
+			}
+			if d < 0 {
+				return cfg, fmt.Errorf("--drain must not be negative")
+			}
+			cfg.Drain = d
+			args = args[consumed:]
+		case token == "--max-rollbacks" || strings.HasPrefix(token, "--max-rollbacks="):
+			value, consumed, err := parseIntFlag(args, "--max-rollbacks")
+			if err != nil {
+				return cfg, err
+			}
+			if value < 0 {
+				return cfg, fmt.Errorf("--max-rollbacks must be greater than or equal to 0")
+			}
+			cfg.MaxRollbacks = value
+			args = args[consumed:]
+		case token == "--rollback-window" || strings.HasPrefix(token, "--rollback-window="):
+			d, consumed, err := parseDurationFlag(args, "--rollback-window")
+			if err != nil {
+				return cfg, err
+			}
+			if d <= 0 {
+				return cfg, fmt.Errorf("--rollback-window must be greater than 0")
+			}
+			cfg.RollbackWindow = d
+			args = args[consumed:]
+		case token == "--breaker-cooldown" || strings.HasPrefix(token, "--breaker-cooldown="):
+			d, consumed, err := parseDurationFlag(args, "--breaker-cooldown")
+			if err != nil {
+				return cfg, err
+			}
+			if d <= 0 {
+				return cfg, fmt.Errorf("--breaker-cooldown must be greater than 0")
+			}
+			cfg.BreakerCooldown = d
+			args = args[consumed:]
+		case token == "--reset-breaker":
+			cfg.ResetBreaker = true
+			args = args[1:]
+		case token == "--all":
+			cfg.DownAll = true
+			args = args[1:]
+		case token == "--deploy-slot-timeout" || strings.HasPrefix(token, "--deploy-slot-timeout="):
+			d, consumed, err := parseDurationFlag(args, "--deploy-slot-timeout")
+			if err != nil {
+				return cfg, err
+			}
+			if d <= 0 {
+				return cfg, fmt.Errorf("--deploy-slot-timeout must be greater than 0")
+			}
+			cfg.DeploySlotTimeout = d
+			args = args[consumed:]
+		case token == "--start-period" || strings.HasPrefix(token, "--start-period="):
+			d, consumed, err := parseDurationFlag(args, "--start-period")
+			if err != nil {
+				return cfg, err
+			}
+			if d < 0 {
+				return cfg, fmt.Errorf("--start-period must not be negative")
+			}
+			cfg.StartPeriod = d
+			args = args[consumed:]
+		case token == "--lock-timeout" || strings.HasPrefix(token, "--lock-timeout="):
+			d, consumed, err := parseDurationFlag(args, "--lock-timeout")
+			if err != nil {
+				return cfg, err
+			}
+			if d < 0 {
+				return cfg, fmt.Errorf("--lock-timeout must not be negative")
+			}
+			cfg.LockTimeout = d
+			args = args[consumed:]
+		case token == "--pre-deploy" || strings.HasPrefix(token, "--pre-deploy="):
+			value, consumed, err := parseStringFlag(args, "--pre-deploy")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.PreDeployHook = value
+			args = args[consumed:]
+		case token == "--post-deploy" || strings.HasPrefix(token, "--post-deploy="):
+			value, consumed, err := parseStringFlag(args, "--post-deploy")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.PostDeployHook = value
+			args = args[consumed:]
+		case token == "--pre-stop" || strings.HasPrefix(token, "--pre-stop="):
+			value, consumed, err := parseStringFlag(args, "--pre-stop")
+			if err != nil {
+				return cfg, err
+			}
+			cfg.PreStopHook = value
+			args = args[consumed:]
+		case token == "--deploy-timeout" || strings.HasPrefix(token, "--deploy-timeout="):
+			d, consumed, err := parseDurationFlag(args, "--deploy-timeout")
+			if err != nil {
+				return cfg, err
+			}
+			if d < 0 {
+				return cfg, fmt.Errorf("--deploy-timeout must not be negative")
+			}
+			cfg.DeployTimeout = d
+			args = args[consumed:]
 		default:
 			if len(token) > 0 && token[0] == '-' {
 				return cfg, fmt.Errorf("unknown option: %s", token)
@@ -243,9 +855,15 @@ func Parse(rawArgs []string) (Config, error) {
 			if cfg.Action == ActionAutoRun {
 				return cfg, fmt.Errorf("unexpected token: %s", token)
 			}
+			if cfg.Service == "" && cfg.Action == ActionDeploy && (token == ActionExplain || token == ActionStatus || token == ActionDown) {
+				cfg.Action = token
+				args = args[1:]
+				continue
+			}
 
 			if cfg.Service == "" {
 				cfg.Service = token
+				cfg.Services = append(cfg.Services, token)
 				args = args[1:]
 				continue
 			}
@@ -256,10 +874,32 @@ func Parse(rawArgs []string) (Config, error) {
 				continue
 			}
 
+			if (cfg.Action == ActionDeploy || cfg.Action == ActionStatus || cfg.Action == ActionDown) && len(cfg.Services) > 0 {
+				cfg.Services = append(cfg.Services, token)
+				args = args[1:]
+				continue
+			}
+
 			return cfg, fmt.Errorf("unexpected token: %s", token)
 		}
 	}
 
+	file.applyService(&cfg)
+	if len(cfg.ComposeFiles) == 0 && cfg.Image == "" {
+		cfg.ComposeFiles = composeFilesFromEnv()
+		if len(cfg.ComposeFiles) == 0 {
+			cfg.ComposeFiles = discoverComposeFiles(cfg.ProjectDirectory)
+		}
+	}
+	if len(cfg.EnvFiles) == 0 && len(cfg.ComposeFiles) > 0 {
+		if envFile := discoverEnvFile(cfg.ProjectDirectory, cfg.ComposeFiles); envFile != "" {
+			cfg.EnvFiles = []string{envFile}
+		}
+	}
+
+	if canaryShorthand && cfg.Strategy != StrategyCanary {
+		return cfg, fmt.Errorf("--canary cannot be combined with --strategy=%s", cfg.Strategy)
+	}
 	if err := validateStrategy(&cfg, weightExplicitlySet, strategyExplicitlySet); err != nil {
 		return cfg, err
 	}
@@ -267,9 +907,26 @@ func Parse(rawArgs []string) (Config, error) {
 	return cfg, nil
 }
 
+// ParseScaleStep parses a --scale-step value: "double", "+N" to add N containers per
+// batch, or "N" to surge to N containers in total.
+func ParseScaleStep(value string) (add int, target int, err error) {
+	if value == ScaleStepDouble {
+		return 0, 0, nil
+	}
+	raw := strings.TrimPrefix(value, "+")
+	n, convErr := strconv.Atoi(raw)
+	if convErr != nil || n <= 0 {
+		return 0, 0, fmt.Errorf("invalid --scale-step: %s (use double, +N or N)", value)
+	}
+	if raw != value {
+		return n, 0, nil
+	}
+	return 0, n, nil
+}
+
 func isActionToken(token string) bool {
 	switch token {
-	case ActionSwitch, ActionCleanup, ActionRollback, ActionAutoRun:
+	case ActionSwitch, ActionCleanup, ActionRollback, ActionPromote, ActionAbort, ActionAutoRun:
 		return true
 	default:
 		return false
@@ -285,6 +942,27 @@ func indexOf(items []string, target string) int {
 	return -1
 }
 
+// LogFormatFromArgs returns the --log-format given in args, or DefaultLogFormat, without
+// parsing the other flags, so errors raised while parsing can be logged in that format.
+func LogFormatFromArgs(args []string) string {
+	format := DefaultLogFormat
+	for len(args) > 0 {
+		if args[0] != "--log-format" && !strings.HasPrefix(args[0], "--log-format=") {
+			args = args[1:]
+			continue
+		}
+		value, consumed, err := parseStringFlag(args, "--log-format")
+		if err != nil {
+			break
+		}
+		if value == LogFormatText || value == LogFormatJSON {
+			format = value
+		}
+		args = args[consumed:]
+	}
+	return format
+}
+
 func parseStringFlag(args []string, flag string) (string, int, error) {
 	if inline, ok := parseInlineValue(args[0], flag); ok {
 		if inline == "" {
@@ -310,6 +988,57 @@ func parseIntFlag(args []string, flag string) (int, int, error) {
 	return n, consumed, nil
 }
 
+// parseDurationFlag reads a Go duration such as 90s, 2m or 1m30s. A bare number is
+// taken as seconds.
+func parseDurationFlag(args []string, flag string) (time.Duration, int, error) {
+	raw, consumed, err := parseStringFlag(args, flag)
+	if err != nil {
+		return 0, 0, err
+	}
+	d, err := parseDuration(raw)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s: %w", flag, err)
+	}
+	return d, consumed, nil
+}
+
+// parseSecondsFlag reads flag as a number of seconds; see ParseSeconds.
+func parseSecondsFlag(args []string, flag string) (int, int, error) {
+	raw, consumed, err := parseStringFlag(args, flag)
+	if err != nil {
+		return 0, 0, err
+	}
+	seconds, err := ParseSeconds(raw)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s: %w", flag, err)
+	}
+	return seconds, consumed, nil
+}
+
+// ParseSeconds reads a whole number of seconds, given either as a bare number or as a
+// Go duration (2m, 1m30s). Fractions of a second round up.
+func ParseSeconds(raw string) (int, error) {
+	d, err := parseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		return 0, err
+	}
+	seconds := d / time.Second
+	if d%time.Second > 0 {
+		seconds++
+	}
+	return int(seconds), nil
+}
+
+func parseDuration(raw string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		if math.IsNaN(seconds) || math.Abs(seconds) > math.MaxInt64/float64(time.Second) {
+			return 0, fmt.Errorf("invalid duration %q", raw)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(raw)
+}
+
 func parseFloatFlag(args []string, flag string) (float64, int, error) {
 	raw, consumed, err := parseStringFlag(args, flag)
 	if err != nil {
@@ -339,12 +1068,178 @@ func validateStrategy(cfg *Config, weightExplicitlySet bool, strategyExplicitlyS
 		return fmt.Errorf("invalid --strategy: %s", cfg.Strategy)
 	}
 
+	switch cfg.ProxyType {
+	case ProxyTraefik:
+	case ProxyNginxProxy, ProxyHAProxy:
+		if cfg.Strategy != StrategyRolling {
+			return fmt.Errorf("--proxy %s supports only --strategy=%s", cfg.ProxyType, StrategyRolling)
+		}
+	default:
+		return fmt.Errorf("invalid --proxy: %s (options: %s, %s, %s)", cfg.ProxyType, ProxyTraefik, ProxyNginxProxy, ProxyHAProxy)
+	}
+
+	if cfg.DNSNames && cfg.Strategy != StrategyRolling {
+		return fmt.Errorf("--dns-names supports only --strategy=%s", StrategyRolling)
+	}
+
 	if cfg.Strategy != StrategyBlueGreen {
 		if cfg.HostMode != "" || cfg.HeadersMode != "" || cfg.CookiesMode != "" || cfg.IPMode != "" {
 			return fmt.Errorf("--host-mode, --headers-mode, --cookies-mode and --ip-mode require --strategy=%s", StrategyBlueGreen)
 		}
 	}
 
+	if cfg.OnlyConfig && (cfg.Action != ActionDeploy || cfg.Service == "up") {
+		return fmt.Errorf("--only-config requires a SERVICE deploy without action")
+	}
+
+	if cfg.SurgeOverride != "" && (cfg.Action != ActionDeploy || cfg.Service == "up") {
+		return fmt.Errorf("--surge-override requires a SERVICE deploy without action")
+	}
+
+	if cfg.VerifySignature && (cfg.Action != ActionDeploy || cfg.Service == "up") {
+		return fmt.Errorf("--verify-signature requires a SERVICE deploy without action")
+	}
+
+	if cfg.Image == "" && (cfg.ImagePort > 0 || cfg.ImageRule != "") {
+		return fmt.Errorf("--port and --rule require --image")
+	}
+
+	stdinFiles := 0
+	for _, file := range cfg.ComposeFiles {
+		if file == StdinComposeFile {
+			stdinFiles++
+		}
+	}
+	if stdinFiles > 1 {
+		return fmt.Errorf("--file %s can be given only once", StdinComposeFile)
+	}
+
+	if cfg.Image != "" {
+		if cfg.Service == "" || cfg.Service == "up" {
+			return fmt.Errorf("--image requires --name")
+		}
+		if !compose.ValidServiceName(cfg.Service) {
+			return fmt.Errorf("invalid --name %q: must be a compose service name (letters, digits, '.', '_' and '-')", cfg.Service)
+		}
+		if len(cfg.ComposeFiles) > 0 {
+			return fmt.Errorf("--image cannot be combined with --file")
+		}
+	}
+
+	if cfg.ResetBreaker && (cfg.Action != ActionDeploy || cfg.Service == "up") {
+		return fmt.Errorf("--reset-breaker requires a SERVICE without action")
+	}
+
+	if cfg.Replicas > 0 && (cfg.Action != ActionDeploy || cfg.Service == "up") {
+		return fmt.Errorf("--replicas requires a SERVICE deploy without action")
+	}
+
+	if cfg.ResourceCheck != ResourceCheckOff && (cfg.Action != ActionDeploy || cfg.Service == "up") {
+		return fmt.Errorf("--resource-check requires a SERVICE deploy without action")
+	}
+
+	if cfg.DeployIfChanged {
+		if cfg.Action != ActionDeploy || cfg.Service == "up" {
+			return fmt.Errorf("--deploy-if-changed requires a SERVICE deploy without action")
+		}
+		if cfg.VersionLabel == "" {
+			return fmt.Errorf("--version-label must not be empty")
+		}
+	}
+
+	if cfg.DockerHost != "" && cfg.DockerContext != "" {
+		return fmt.Errorf("--host and --context cannot be combined")
+	}
+	if cfg.RemoveOrphans && cfg.Service != "up" {
+		return fmt.Errorf("--remove-orphans is only supported with up")
+	}
+	if cfg.SkipIfCurrent && (cfg.Action != ActionDeploy || cfg.Service == "up") {
+		return fmt.Errorf("--skip-if-current requires a SERVICE deploy without action")
+	}
+	if (cfg.PreDeployHook != "" || cfg.PostDeployHook != "" || cfg.PreStopHook != "") && (cfg.Action != ActionDeploy || cfg.Service == "up") {
+		return fmt.Errorf("--pre-deploy, --post-deploy and --pre-stop require a SERVICE deploy without action")
+	}
+	if cfg.Drain > 0 && cfg.Strategy != StrategyRolling {
+		return fmt.Errorf("--drain supports only --strategy=%s", StrategyRolling)
+	}
+	if cfg.PreStopHook != "" && cfg.Strategy != StrategyRolling {
+		return fmt.Errorf("--pre-stop supports only --strategy=%s", StrategyRolling)
+	}
+	if cfg.MinOldUptime > 0 && cfg.Strategy != StrategyRolling {
+		return fmt.Errorf("--min-old-uptime supports only --strategy=%s", StrategyRolling)
+	}
+	if cfg.ReconcileCount && cfg.Strategy != StrategyRolling {
+		return fmt.Errorf("--reconcile-count supports only --strategy=%s", StrategyRolling)
+	}
+	if cfg.FailOnUnmatched && cfg.Strategy != StrategyRolling {
+		return fmt.Errorf("--fail-on-unmatched-config supports only --strategy=%s", StrategyRolling)
+	}
+	if cfg.Recreate && (cfg.Action != ActionDeploy || cfg.Service == "up" || cfg.Strategy != StrategyRolling) {
+		return fmt.Errorf("--recreate requires a SERVICE deploy with the rolling strategy")
+	}
+	if cfg.ForceRegenerate && (cfg.Action != ActionDeploy || cfg.Service == "up" || cfg.Strategy != StrategyRolling || cfg.ProxyType != ProxyTraefik) {
+		return fmt.Errorf("--force-regenerate requires a SERVICE deploy with the rolling strategy behind Traefik")
+	}
+	if cfg.NoProxy {
+		if cfg.Strategy != StrategyRolling {
+			return fmt.Errorf("--no-proxy supports only --strategy=%s", StrategyRolling)
+		}
+		if cfg.OnlyConfig || cfg.ForceRegenerate {
+			return fmt.Errorf("--no-proxy cannot be combined with --only-config or --force-regenerate")
+		}
+		if cfg.ReloadCommand != "" || cfg.ReloadEndpoint != "" {
+			return fmt.Errorf("--no-proxy cannot be combined with --reload-cmd or --reload-endpoint")
+		}
+		if cfg.TraefikAPI != "" {
+			return fmt.Errorf("--no-proxy cannot be combined with --traefik-api")
+		}
+	}
+	if cfg.ReloadRequired && cfg.ReloadCommand == "" && cfg.ReloadEndpoint == "" {
+		return fmt.Errorf("--reload-required requires --reload-cmd or --reload-endpoint")
+	}
+	if cfg.Output == OutputJSON && (cfg.Action != ActionDeploy || cfg.Service == "up" || cfg.DryRun) {
+		return fmt.Errorf("--output %s requires a SERVICE deploy", OutputJSON)
+	}
+
+	if len(cfg.Services) > 1 {
+		if cfg.Action != ActionDeploy && cfg.Action != ActionStatus && cfg.Action != ActionDown {
+			return fmt.Errorf("%s accepts a single SERVICE", cfg.Action)
+		}
+		seen := map[string]bool{}
+		for _, service := range cfg.Services {
+			if service == "up" {
+				return fmt.Errorf("up cannot be combined with other services")
+			}
+			if seen[service] {
+				return fmt.Errorf("service %s is listed more than once", service)
+			}
+			seen[service] = true
+		}
+	}
+
+	if cfg.ScaleStep != ScaleStepDouble && (cfg.Strategy != StrategyRolling || cfg.Action != ActionDeploy || cfg.Service == "up") {
+		return fmt.Errorf("--scale-step and --batch-size require a SERVICE deploy with --strategy=%s", StrategyRolling)
+	}
+
+	if cfg.Parallel {
+		if len(cfg.Services) < 2 {
+			return fmt.Errorf("--parallel requires more than one SERVICE")
+		}
+		if !cfg.ContinueOnError {
+			return fmt.Errorf("--parallel requires --continue-on-error; deploys in flight cannot be aborted safely")
+		}
+		if cfg.Strategy != StrategyRolling {
+			return fmt.Errorf("--parallel supports only --strategy=%s", StrategyRolling)
+		}
+	}
+
+	if cfg.DryRun && (cfg.Action == ActionAutoRun || cfg.Action == ActionExplain || cfg.Action == ActionStatus || cfg.Action == ActionDown) {
+		return fmt.Errorf("--dry-run cannot be combined with %s", cfg.Action)
+	}
+	if cfg.DryRun && cfg.ResetBreaker {
+		return fmt.Errorf("--dry-run cannot be combined with --reset-breaker")
+	}
+
 	if cfg.Action == ActionAutoRun {
 		if cfg.Service != "" {
 			return fmt.Errorf("%s does not accept SERVICE", ActionAutoRun)
@@ -352,6 +1247,33 @@ func validateStrategy(cfg *Config, weightExplicitlySet bool, strategyExplicitlyS
 		return nil
 	}
 
+	if cfg.DownAll && cfg.Action != ActionDown {
+		return fmt.Errorf("--all requires action %s", ActionDown)
+	}
+
+	if cfg.Action == ActionDown {
+		if cfg.DownAll {
+			if cfg.Service != "" {
+				return fmt.Errorf("%s --all does not accept SERVICE", ActionDown)
+			}
+			if len(cfg.ComposeFiles) == 0 {
+				return fmt.Errorf("%s --all requires -f", ActionDown)
+			}
+			return nil
+		}
+		if cfg.Service == "" || cfg.Service == "up" {
+			return fmt.Errorf("%s requires SERVICE or --all", ActionDown)
+		}
+		return nil
+	}
+
+	if cfg.Action == ActionExplain || cfg.Action == ActionStatus {
+		if cfg.Service == "" || cfg.Service == "up" {
+			return fmt.Errorf("%s requires SERVICE", cfg.Action)
+		}
+		return nil
+	}
+
 	if cfg.Action != ActionDeploy {
 		if !strategyExplicitlySet {
 			cfg.Strategy = defaultStrategyForAction(cfg.Action)
@@ -381,8 +1303,28 @@ func validateStrategy(cfg *Config, weightExplicitlySet bool, strategyExplicitlyS
 		}
 	}
 
-	if cfg.AutoCleanup > 0 && cfg.Action != ActionSwitch && cfg.Action != ActionRollback {
-		return fmt.Errorf("--auto-cleanup requires action %s or %s", ActionSwitch, ActionRollback)
+	if cfg.AutoCleanup > 0 && cfg.Action != ActionSwitch && cfg.Action != ActionRollback && cfg.Action != ActionPromote && cfg.Action != ActionAbort {
+		return fmt.Errorf("--auto-cleanup requires action %s, %s, %s or %s", ActionSwitch, ActionRollback, ActionPromote, ActionAbort)
+	}
+	if cfg.CanaryRule != "" && (cfg.Strategy != StrategyCanary || cfg.Action != ActionDeploy) {
+		return fmt.Errorf("--canary-rule requires a --strategy=%s deploy", StrategyCanary)
+	}
+
+	if cfg.RampDuration > 0 {
+		if cfg.Strategy != StrategyCanary || cfg.Action != ActionDeploy {
+			return fmt.Errorf("--ramp requires a --strategy=%s deploy", StrategyCanary)
+		}
+		if !cfg.Analyze {
+			return fmt.Errorf("--ramp requires --analyze to gate each step")
+		}
+	}
+	if cfg.CanaryDuration > 0 {
+		if cfg.Strategy != StrategyCanary || cfg.Action != ActionDeploy {
+			return fmt.Errorf("--canary-duration requires a --strategy=%s deploy", StrategyCanary)
+		}
+		if cfg.RampDuration > 0 {
+			return fmt.Errorf("--canary-duration cannot be combined with --ramp")
+		}
 	}
 
 	if cfg.Analyze && cfg.Strategy != StrategyBlueGreen && cfg.Strategy != StrategyCanary {
@@ -414,7 +1356,7 @@ func requiredStrategyForAction(action string) (string, bool) {
 	switch action {
 	case ActionSwitch:
 		return StrategyBlueGreen, true
-	case ActionRollback:
+	case ActionRollback, ActionPromote, ActionAbort:
 		return StrategyCanary, true
 	case ActionCleanup:
 		return "", true
@@ -427,7 +1369,7 @@ func requiredStrategyForAction(action string) (string, bool) {
 
 func defaultStrategyForAction(action string) string {
 	switch action {
-	case ActionRollback:
+	case ActionRollback, ActionPromote, ActionAbort:
 		return StrategyCanary
 	case ActionSwitch, ActionCleanup:
 		return StrategyBlueGreen
@@ -436,6 +1378,24 @@ func defaultStrategyForAction(action string) string {
 	}
 }
 
+func parseHealthyStatuses(value string) ([]string, error) {
+	var statuses []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if part == "unhealthy" {
+			return nil, fmt.Errorf("--healthy-status must not accept unhealthy")
+		}
+		statuses = append(statuses, part)
+	}
+	if len(statuses) == 0 {
+		return nil, fmt.Errorf("missing value for --healthy-status")
+	}
+	return statuses, nil
+}
+
 func validateHeaderMode(value string) error {
 	value = strings.TrimSpace(value)
 	if value == "" {
@@ -475,3 +1435,16 @@ func validateCookieMode(value string) error {
 	}
 	return nil
 }
+
+// validProjectName reports whether name is accepted by docker compose as a project name.
+func validProjectName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case (r == '-' || r == '_') && i > 0:
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
@@ -1,6 +1,10 @@
 package cli
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -229,13 +233,34 @@ func TestParse_CanaryRollbackDefaultsStrategyToCanary(t *testing.T) {
 	}
 }
 
-func TestParse_PromoteActionIsNotSupported(t *testing.T) {
-	_, err := Parse([]string{
-		"api",
-		"promote",
-	})
-	if err == nil {
-		t.Fatal("expected parse error")
+func TestParse_PromoteAndAbortDefaultToCanary(t *testing.T) {
+	for _, action := range []string{ActionPromote, ActionAbort} {
+		cfg, err := Parse([]string{"api", action})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", action, err)
+		}
+		if cfg.Strategy != StrategyCanary || cfg.Action != action {
+			t.Fatalf("%s: expected canary %s, got %s %s", action, action, cfg.Strategy, cfg.Action)
+		}
+	}
+	if _, err := Parse([]string{"--strategy=blue-green", "api", ActionPromote}); err == nil {
+		t.Fatal("expected promote to require canary strategy")
+	}
+}
+
+func TestParse_CanaryRule(t *testing.T) {
+	cfg, err := Parse([]string{"--strategy=canary", "--canary-rule", "Host(`canary.example.com`)", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CanaryRule != "Host(`canary.example.com`)" {
+		t.Fatalf("unexpected canary rule: %q", cfg.CanaryRule)
+	}
+	if _, err := Parse([]string{"--canary-rule=Host(`c`)", "api"}); err == nil {
+		t.Fatal("expected --canary-rule to require canary strategy")
+	}
+	if _, err := Parse([]string{"--canary-rule=Host(`c`)", "api", ActionPromote}); err == nil {
+		t.Fatal("expected --canary-rule to be rejected for promote")
 	}
 }
 
@@ -306,3 +331,1120 @@ func TestParse_AllFlagIsUnknown(t *testing.T) {
 		t.Fatal("expected parse error")
 	}
 }
+
+func TestParse_MaxConcurrentDeploys(t *testing.T) {
+	cfg, err := Parse([]string{"--max-concurrent-deploys=2", "--deploy-slot-timeout", "90s", "example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxConcurrentDeploys != 2 || cfg.DeploySlotTimeout != 90*time.Second {
+		t.Fatalf("unexpected deploy slot config: %+v", cfg)
+	}
+	if cfg.DeployTimeout != 0 {
+		t.Fatalf("expected deploy timeout to be disabled by default, got %s", cfg.DeployTimeout)
+	}
+
+	if _, err := Parse([]string{"--max-concurrent-deploys=-1", "example"}); err == nil {
+		t.Fatal("expected negative --max-concurrent-deploys to fail")
+	}
+}
+
+func TestParse_PollInterval(t *testing.T) {
+	cfg, err := Parse([]string{"example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PollInterval != DefaultPollInterval {
+		t.Fatalf("expected default poll interval, got %s", cfg.PollInterval)
+	}
+	cfg, err = Parse([]string{"--poll-interval", "250ms", "example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PollInterval != 250*time.Millisecond {
+		t.Fatalf("expected 250ms, got %s", cfg.PollInterval)
+	}
+	if _, err := Parse([]string{"--poll-interval=0s", "example"}); err == nil {
+		t.Fatal("expected error for zero --poll-interval")
+	}
+}
+
+func TestParse_DeployTimeout(t *testing.T) {
+	cfg, err := Parse([]string{"--deploy-timeout=15m", "example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DeployTimeout != 15*time.Minute {
+		t.Fatalf("expected 15m deploy timeout, got %s", cfg.DeployTimeout)
+	}
+	if _, err := Parse([]string{"--deploy-timeout", "-1s", "example"}); err == nil {
+		t.Fatal("expected error for negative --deploy-timeout")
+	}
+}
+
+func TestParse_TimeoutAction(t *testing.T) {
+	cfg, err := Parse([]string{"example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TimeoutAction != TimeoutActionRollback {
+		t.Fatalf("expected default timeout action %s, got %s", TimeoutActionRollback, cfg.TimeoutAction)
+	}
+
+	cfg, err = Parse([]string{"--timeout-action=keep", "example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TimeoutAction != TimeoutActionKeep {
+		t.Fatalf("expected timeout action %s, got %s", TimeoutActionKeep, cfg.TimeoutAction)
+	}
+
+	if _, err := Parse([]string{"--timeout-action=ignore", "example"}); err == nil {
+		t.Fatal("expected invalid --timeout-action to fail")
+	}
+}
+
+func TestParse_OnlyConfig(t *testing.T) {
+	cfg, err := Parse([]string{"--only-config", "example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.OnlyConfig {
+		t.Fatal("expected --only-config to be set")
+	}
+
+	if _, err := Parse([]string{"--only-config", "--strategy=blue-green", "example", "switch"}); err == nil {
+		t.Fatal("expected --only-config with action to fail")
+	}
+}
+
+func TestParse_TimestampFormat(t *testing.T) {
+	cfg, err := Parse([]string{"--timestamp-format=RFC3339", "example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TimestampFormat != "RFC3339" {
+		t.Fatalf("unexpected timestamp format: %q", cfg.TimestampFormat)
+	}
+}
+
+func TestParse_HealthyStatus(t *testing.T) {
+	cfg, err := Parse([]string{"--healthy-status=starting, ready", "example"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.HealthyStatuses) != 2 || cfg.HealthyStatuses[0] != "starting" || cfg.HealthyStatuses[1] != "ready" {
+		t.Fatalf("unexpected healthy statuses: %#v", cfg.HealthyStatuses)
+	}
+
+	if _, err := Parse([]string{"--healthy-status=unhealthy", "example"}); err == nil {
+		t.Fatal("expected unhealthy to be rejected")
+	}
+}
+
+func TestParse_DeployIfChanged(t *testing.T) {
+	cfg, err := Parse([]string{"--deploy-if-changed", "--version-label=git.sha", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.DeployIfChanged || cfg.VersionLabel != "git.sha" {
+		t.Fatalf("unexpected config: %#v", cfg)
+	}
+
+	if _, err := Parse([]string{"--deploy-if-changed", "up"}); err == nil {
+		t.Fatal("expected error for --deploy-if-changed with up")
+	}
+}
+
+func TestParse_SkipIfCurrent(t *testing.T) {
+	cfg, err := Parse([]string{"--skip-if-current", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.SkipIfCurrent {
+		t.Fatalf("expected SkipIfCurrent, got %#v", cfg)
+	}
+
+	if _, err := Parse([]string{"--skip-if-current", "api", "rollback"}); err == nil {
+		t.Fatal("expected error for --skip-if-current with an action")
+	}
+}
+
+func TestParse_RemoveOrphans(t *testing.T) {
+	cfg, err := Parse([]string{"--remove-orphans", "up", "-d"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.RemoveOrphans || !cfg.UpDetached {
+		t.Fatalf("expected RemoveOrphans with a detached up, got %#v", cfg)
+	}
+	if _, err := Parse([]string{"--remove-orphans", "api"}); err == nil {
+		t.Fatal("expected error for --remove-orphans with a service deploy")
+	}
+}
+
+func TestParse_TraefikAPI(t *testing.T) {
+	cfg, err := Parse([]string{"--traefik-api=http://localhost:8080", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TraefikAPI != "http://localhost:8080" {
+		t.Fatalf("unexpected Traefik API URL: %q", cfg.TraefikAPI)
+	}
+	if _, err := Parse([]string{"--traefik-api", "localhost:8080", "api"}); err == nil {
+		t.Fatal("expected error for a Traefik API URL without scheme")
+	}
+	if _, err := Parse([]string{"--traefik-api", "http://localhost:8080", "--no-proxy", "api"}); err == nil {
+		t.Fatal("expected error for --traefik-api with --no-proxy")
+	}
+}
+
+func TestParse_StartPeriod(t *testing.T) {
+	cfg, err := Parse([]string{"--start-period", "45", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StartPeriod != 45*time.Second {
+		t.Fatalf("unexpected start period: %s", cfg.StartPeriod)
+	}
+	if _, err := Parse([]string{"--start-period=-5s", "api"}); err == nil {
+		t.Fatal("expected error for a negative start period")
+	}
+}
+
+func TestParse_LockTimeout(t *testing.T) {
+	cfg, err := Parse([]string{"--lock-timeout=2m", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.LockTimeout != 2*time.Minute {
+		t.Fatalf("unexpected lock timeout: %s", cfg.LockTimeout)
+	}
+	if _, err := Parse([]string{"--lock-timeout", "-1s", "api"}); err == nil {
+		t.Fatal("expected error for a negative lock timeout")
+	}
+}
+
+func TestParse_Verbose(t *testing.T) {
+	for _, flag := range []string{"-v", "--verbose"} {
+		cfg, err := Parse([]string{flag, "api"})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", flag, err)
+		}
+		if cfg.LogLevel != "debug" {
+			t.Fatalf("%s: expected debug log level, got %q", flag, cfg.LogLevel)
+		}
+	}
+}
+
+func TestParse_Drain(t *testing.T) {
+	cfg, err := Parse([]string{"--drain", "15", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Drain != 15*time.Second {
+		t.Fatalf("unexpected drain: %s", cfg.Drain)
+	}
+	if _, err := Parse([]string{"--drain=-1s", "api"}); err == nil {
+		t.Fatal("expected error for a negative drain")
+	}
+	if _, err := Parse([]string{"--drain", "10s", "--strategy", "blue-green", "api"}); err == nil {
+		t.Fatal("expected error for --drain with blue-green")
+	}
+}
+
+func TestParse_DeployHooks(t *testing.T) {
+	cfg, err := Parse([]string{"--pre-deploy", "./migrate.sh", "--post-deploy=./warm.sh", "--pre-stop", "./drain.sh", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PreDeployHook != "./migrate.sh" || cfg.PostDeployHook != "./warm.sh" || cfg.PreStopHook != "./drain.sh" {
+		t.Fatalf("unexpected hooks: %q %q %q", cfg.PreDeployHook, cfg.PostDeployHook, cfg.PreStopHook)
+	}
+	if _, err := Parse([]string{"--pre-deploy", "./migrate.sh", "up"}); err == nil {
+		t.Fatal("expected error for hooks with up")
+	}
+	if _, err := Parse([]string{"--pre-stop", "./drain.sh", "--strategy", "canary", "api"}); err == nil {
+		t.Fatal("expected error for --pre-stop with canary")
+	}
+}
+
+func TestParse_Project(t *testing.T) {
+	cfg, err := Parse([]string{"--project", "shop-2", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ProjectName != "shop-2" {
+		t.Fatalf("expected project shop-2, got %q", cfg.ProjectName)
+	}
+	for _, name := range []string{"Shop", "-shop", "shop.prod", ""} {
+		if _, err := Parse([]string{"--project=" + name, "api"}); err == nil {
+			t.Fatalf("expected invalid project %q to fail", name)
+		}
+	}
+}
+
+func TestParse_Recreate(t *testing.T) {
+	cfg, err := Parse([]string{"--recreate", "worker"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Recreate {
+		t.Fatalf("expected Recreate, got %#v", cfg)
+	}
+
+	if _, err := Parse([]string{"--recreate", "--strategy", "blue-green", "worker"}); err == nil {
+		t.Fatal("expected error for --recreate with blue-green")
+	}
+}
+
+func TestParse_Output(t *testing.T) {
+	cfg, err := Parse([]string{"--output", "json", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Output != OutputJSON {
+		t.Fatalf("expected json output, got %q", cfg.Output)
+	}
+
+	for _, args := range [][]string{
+		{"--output", "yaml", "api"},
+		{"--output=json", "up"},
+		{"--output=json", "--dry-run", "api"},
+		{"--output=json", "status", "api"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParse_ForceRegenerate(t *testing.T) {
+	cfg, err := Parse([]string{"--force-regenerate", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.ForceRegenerate {
+		t.Fatalf("expected ForceRegenerate, got %#v", cfg)
+	}
+
+	for _, args := range [][]string{
+		{"--force-regenerate", "--strategy", "canary", "api"},
+		{"--force-regenerate", "--proxy", "nginx-proxy", "api"},
+		{"--force-regenerate", "up"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParse_NginxProxy(t *testing.T) {
+	cfg, err := Parse([]string{"--proxy", "nginx-proxy", "--nginx-conf=proxy/api.conf", "--nginx-reload", "docker exec proxy nginx -s reload", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ProxyType != ProxyNginxProxy || cfg.NginxConfigFile != "proxy/api.conf" || cfg.NginxReloadCommand != "docker exec proxy nginx -s reload" {
+		t.Fatalf("unexpected config: %#v", cfg)
+	}
+
+	cfg, err = Parse([]string{"--proxy", "nginx-proxy", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.NginxConfigFile != DefaultNginxConfig {
+		t.Fatalf("unexpected default nginx config: %q", cfg.NginxConfigFile)
+	}
+
+	if _, err := Parse([]string{"--proxy", "nginx-proxy", "--strategy", "canary", "api"}); err == nil {
+		t.Fatal("expected error for nginx-proxy with canary strategy")
+	}
+}
+
+func TestParse_HAProxy(t *testing.T) {
+	cfg, err := Parse([]string{"--proxy", "haproxy", "--haproxy-conf=proxy/ztd.cfg", "--haproxy-reload", "docker kill -s HUP haproxy", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ProxyType != ProxyHAProxy || cfg.HAProxyConfigFile != "proxy/ztd.cfg" || cfg.HAProxyReloadCommand != "docker kill -s HUP haproxy" {
+		t.Fatalf("unexpected config: %#v", cfg)
+	}
+
+	cfg, err = Parse([]string{"--proxy", "haproxy", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.HAProxyConfigFile != DefaultHAProxyConfig {
+		t.Fatalf("unexpected default HAProxy config: %q", cfg.HAProxyConfigFile)
+	}
+
+	if _, err := Parse([]string{"--proxy", "haproxy", "--strategy", "blue-green", "api"}); err == nil {
+		t.Fatal("expected error for haproxy with blue-green strategy")
+	}
+	if _, err := Parse([]string{"--proxy", "envoy", "api"}); err == nil {
+		t.Fatal("expected error for unknown proxy type")
+	}
+}
+
+func TestParse_Reload(t *testing.T) {
+	cfg, err := Parse([]string{"--reload-cmd", "docker kill -s HUP traefik", "--reload-endpoint=http://localhost:9000/reload", "--reload-required", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ReloadCommand != "docker kill -s HUP traefik" || cfg.ReloadEndpoint != "http://localhost:9000/reload" || !cfg.ReloadRequired {
+		t.Fatalf("unexpected config: %#v", cfg)
+	}
+
+	if _, err := Parse([]string{"--reload-required", "api"}); err == nil {
+		t.Fatal("expected error for --reload-required without a reload step")
+	}
+	if _, err := Parse([]string{"--reload-endpoint", "localhost:9000", "api"}); err == nil {
+		t.Fatal("expected error for an endpoint without scheme")
+	}
+}
+
+func TestParse_DNSNames(t *testing.T) {
+	cfg, err := Parse([]string{"--dns-names", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.DNSNames {
+		t.Fatalf("expected DNSNames, got %#v", cfg)
+	}
+	if _, err := Parse([]string{"--dns-names", "--strategy=blue-green", "api"}); err == nil {
+		t.Fatal("expected error for --dns-names with blue-green")
+	}
+}
+
+func TestParse_DryRun(t *testing.T) {
+	for _, flag := range []string{"-n", "--dry-run"} {
+		cfg, err := Parse([]string{flag, "api"})
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", flag, err)
+		}
+		if !cfg.DryRun {
+			t.Fatalf("expected DryRun for %s", flag)
+		}
+	}
+	if _, err := Parse([]string{"--dry-run", "auto-cleanup-run"}); err == nil {
+		t.Fatal("expected error for --dry-run with auto-cleanup-run")
+	}
+	if _, err := Parse([]string{"--dry-run", "--reset-breaker", "api"}); err == nil {
+		t.Fatal("expected error for --dry-run with --reset-breaker")
+	}
+}
+
+func TestParse_MultipleServices(t *testing.T) {
+	cfg, err := Parse([]string{"api", "web", "worker"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Service != "api" || len(cfg.Services) != 3 || cfg.Services[2] != "worker" {
+		t.Fatalf("unexpected services: %q %q", cfg.Service, cfg.Services)
+	}
+
+	cfg, err = Parse([]string{"--parallel", "--continue-on-error", "api", "web"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Parallel {
+		t.Fatal("expected Parallel")
+	}
+
+	for _, args := range [][]string{
+		{"api", "web", "rollback"},
+		{"up", "web"},
+		{"api", "api"},
+		{"--parallel", "api", "web"},
+		{"--parallel", "--continue-on-error", "api"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("expected error for %q", args)
+		}
+	}
+}
+
+func TestParse_ScaleStep(t *testing.T) {
+	cases := map[string][2]int{
+		"double": {0, 0},
+		"+2":     {2, 0},
+		"12":     {0, 12},
+	}
+	for value, want := range cases {
+		cfg, err := Parse([]string{"--scale-step", value, "api"})
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", value, err)
+		}
+		add, target, err := ParseScaleStep(cfg.ScaleStep)
+		if err != nil || add != want[0] || target != want[1] {
+			t.Fatalf("ParseScaleStep(%s) = %d, %d, %v", value, add, target, err)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--scale-step", "+0", "api"},
+		{"--scale-step", "half", "api"},
+		{"--scale-step", "+2", "--strategy", "canary", "api"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("expected error for %q", args)
+		}
+	}
+}
+
+func TestParse_DockerHostAndContext(t *testing.T) {
+	cfg, err := Parse([]string{"--tls", "ztd", "-H", "ssh://deploy@web1", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DockerHost != "ssh://deploy@web1" {
+		t.Fatalf("unexpected docker host: %q", cfg.DockerHost)
+	}
+	if want := []string{"--tls", "--host", "ssh://deploy@web1"}; !reflect.DeepEqual(cfg.DockerArgs, want) {
+		t.Fatalf("DockerArgs = %v, want %v", cfg.DockerArgs, want)
+	}
+
+	cfg, err = Parse([]string{"--context=staging", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DockerContext != "staging" || !reflect.DeepEqual(cfg.DockerArgs, []string{"--context", "staging"}) {
+		t.Fatalf("unexpected context config: %q %v", cfg.DockerContext, cfg.DockerArgs)
+	}
+
+	if _, err := Parse([]string{"--host", "tcp://web1:2376", "--context", "staging", "api"}); err == nil {
+		t.Fatal("expected error for --host with --context")
+	}
+}
+
+func TestParse_BatchSize(t *testing.T) {
+	cfg, err := Parse([]string{"--batch-size", "2", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ScaleStep != "+2" {
+		t.Fatalf("expected --batch-size 2 to mean --scale-step +2, got %q", cfg.ScaleStep)
+	}
+	for _, args := range [][]string{
+		{"--batch-size", "0", "api"},
+		{"--batch-size=2", "--strategy", "blue-green", "api"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("expected error for %q", args)
+		}
+	}
+}
+
+func TestParse_EventsSocket(t *testing.T) {
+	cfg, err := Parse([]string{"--events-socket", "/run/ztd.sock", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.EventsSocket != "/run/ztd.sock" {
+		t.Fatalf("unexpected events socket: %q", cfg.EventsSocket)
+	}
+}
+
+func TestParse_SurgeOverride(t *testing.T) {
+	cfg, err := Parse([]string{"--surge-override=surge.yml", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.SurgeOverride != "surge.yml" {
+		t.Fatalf("unexpected surge override: %q", cfg.SurgeOverride)
+	}
+	if _, err := Parse([]string{"--surge-override", "surge.yml", "up"}); err == nil {
+		t.Fatal("expected error for --surge-override with up")
+	}
+}
+
+func TestParse_ProjectDirectory(t *testing.T) {
+	cfg, err := Parse([]string{"--project-directory", "/srv/app", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ProjectDirectory != "/srv/app" {
+		t.Fatalf("unexpected project directory: %q", cfg.ProjectDirectory)
+	}
+}
+
+func TestParse_CriticalCount(t *testing.T) {
+	cfg, err := Parse([]string{"--critical-count=2", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CriticalCount != 2 {
+		t.Fatalf("unexpected critical count: %d", cfg.CriticalCount)
+	}
+	if _, err := Parse([]string{"--critical-count=-1", "api"}); err == nil {
+		t.Fatal("expected error for negative critical count")
+	}
+}
+
+func TestParse_DeployMetadata(t *testing.T) {
+	cfg, err := Parse([]string{"--deploy-reason", "hotfix", "--deployed-by=ci", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DeployReason != "hotfix" || cfg.DeployedBy != "ci" {
+		t.Fatalf("unexpected deploy metadata: %q %q", cfg.DeployReason, cfg.DeployedBy)
+	}
+}
+
+func TestParse_FailFastToggle(t *testing.T) {
+	cfg, err := Parse([]string{"api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ContinueOnError {
+		t.Fatal("expected fail-fast by default")
+	}
+
+	cfg, err = Parse([]string{"--continue-on-error", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.ContinueOnError {
+		t.Fatal("expected --continue-on-error to disable fail-fast")
+	}
+}
+
+func TestParse_TCPProbe(t *testing.T) {
+	cfg, err := Parse([]string{"--tcp-probe", "5432", "db"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TCPProbePort != 5432 {
+		t.Fatalf("unexpected tcp probe port: %d", cfg.TCPProbePort)
+	}
+	if _, err := Parse([]string{"--tcp-probe=70000", "db"}); err == nil {
+		t.Fatal("expected error for out-of-range port")
+	}
+}
+
+func TestParse_ColorFlags(t *testing.T) {
+	cfg, err := Parse([]string{"--no-color", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Color != "never" {
+		t.Fatalf("unexpected color mode: %q", cfg.Color)
+	}
+	cfg, err = Parse([]string{"--color", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Color != "always" {
+		t.Fatalf("unexpected color mode: %q", cfg.Color)
+	}
+}
+
+func TestParse_VerifySignature(t *testing.T) {
+	cfg, err := Parse([]string{"--verify-signature", "--verify-command", "cosign verify --key cosign.pub", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.VerifySignature || cfg.VerifyCommand != "cosign verify --key cosign.pub" {
+		t.Fatalf("unexpected signature config: %#v", cfg)
+	}
+	if _, err := Parse([]string{"--verify-signature", "api", "switch", "--strategy", "blue-green"}); err == nil {
+		t.Fatal("expected error for --verify-signature with an action")
+	}
+}
+
+func TestParse_ReconcileCount(t *testing.T) {
+	cfg, err := Parse([]string{"--reconcile-count", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.ReconcileCount {
+		t.Fatal("expected ReconcileCount to be set")
+	}
+	if _, err := Parse([]string{"--reconcile-count", "--strategy", "blue-green", "api"}); err == nil {
+		t.Fatal("expected --reconcile-count to be rejected for blue-green")
+	}
+}
+
+func TestParse_FailOnUnmatchedConfig(t *testing.T) {
+	cfg, err := Parse([]string{"--fail-on-unmatched-config", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.FailOnUnmatched {
+		t.Fatal("expected FailOnUnmatched to be set")
+	}
+	if _, err := Parse([]string{"--fail-on-unmatched-config", "--strategy", "canary", "api"}); err == nil {
+		t.Fatal("expected --fail-on-unmatched-config to be rejected for canary")
+	}
+}
+
+func TestParse_ExplainAction(t *testing.T) {
+	cfg, err := Parse([]string{"-f", "docker-compose.yml", "explain", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Action != ActionExplain || cfg.Service != "api" {
+		t.Fatalf("expected explain action for api, got action=%q service=%q", cfg.Action, cfg.Service)
+	}
+	if _, err := Parse([]string{"explain"}); err == nil {
+		t.Fatal("expected explain without SERVICE to fail")
+	}
+}
+
+func TestParse_StatusAction(t *testing.T) {
+	cfg, err := Parse([]string{"status", "api", "worker"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Action != ActionStatus || len(cfg.Services) != 2 || cfg.Services[1] != "worker" {
+		t.Fatalf("expected status action for api and worker, got action=%q services=%v", cfg.Action, cfg.Services)
+	}
+	if _, err := Parse([]string{"status"}); err == nil {
+		t.Fatal("expected status without SERVICE to fail")
+	}
+	if _, err := Parse([]string{"--dry-run", "status", "api"}); err == nil {
+		t.Fatal("expected status with --dry-run to fail")
+	}
+}
+
+func TestParse_ResourceCheck(t *testing.T) {
+	cfg, err := Parse([]string{"api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ResourceCheck != ResourceCheckOff {
+		t.Fatalf("expected default %s, got %s", ResourceCheckOff, cfg.ResourceCheck)
+	}
+	cfg, err = Parse([]string{"--resource-check=strict", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ResourceCheck != ResourceCheckStrict {
+		t.Fatalf("expected strict, got %s", cfg.ResourceCheck)
+	}
+	if _, err := Parse([]string{"--resource-check=loose", "api"}); err == nil {
+		t.Fatal("expected invalid mode to fail")
+	}
+	if _, err := Parse([]string{"--resource-check=warn", "up"}); err == nil {
+		t.Fatal("expected --resource-check to be rejected for up")
+	}
+}
+
+func TestParse_Replicas(t *testing.T) {
+	cfg, err := Parse([]string{"--replicas", "3", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Replicas != 3 {
+		t.Fatalf("expected 3 replicas, got %d", cfg.Replicas)
+	}
+	if _, err := Parse([]string{"--replicas=0", "api"}); err == nil {
+		t.Fatal("expected --replicas=0 to fail")
+	}
+	if _, err := Parse([]string{"--replicas=2", "up"}); err == nil {
+		t.Fatal("expected --replicas to be rejected for up")
+	}
+}
+
+func TestParse_MinOldUptime(t *testing.T) {
+	cfg, err := Parse([]string{"--min-old-uptime", "2m", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MinOldUptime != 2*time.Minute {
+		t.Fatalf("expected 2m, got %s", cfg.MinOldUptime)
+	}
+	if _, err := Parse([]string{"--min-old-uptime=-1s", "api"}); err == nil {
+		t.Fatal("expected negative duration to fail")
+	}
+	if _, err := Parse([]string{"--min-old-uptime", "2m", "--strategy", "canary", "api"}); err == nil {
+		t.Fatal("expected --min-old-uptime to be rejected for canary")
+	}
+}
+
+func TestParse_ImageMode(t *testing.T) {
+	cfg, err := Parse([]string{"--image", "nginx:1.25", "--name", "web", "--port", "80", "--rule", "Host(`x`)"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Image != "nginx:1.25" || cfg.Service != "web" || cfg.ImagePort != 80 || cfg.ImageRule != "Host(`x`)" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	cfg, err = Parse([]string{"--image", "nginx:1.25", "--name", "web", "--strategy=canary", "rollback"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Action != ActionRollback {
+		t.Fatalf("expected rollback action, got %q", cfg.Action)
+	}
+}
+
+func TestParse_ImageModeValidation(t *testing.T) {
+	cases := [][]string{
+		{"--image", "nginx:1.25"},
+		{"--image", "nginx:1.25", "--name", "web", "-f", "docker-compose.yml"},
+		{"--port", "80", "web"},
+		{"--image", "nginx:1.25", "--name", "web", "--port", "0"},
+		{"api", "--name", "web"},
+		{"--image", "nginx:1.25", "--name", "../x"},
+		{"--image", "nginx:1.25", "--name", "a/b"},
+	}
+	for _, args := range cases {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParse_BreakerFlags(t *testing.T) {
+	cfg, err := Parse([]string{"--max-rollbacks", "3", "--rollback-window=15m", "--breaker-cooldown", "1h", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxRollbacks != 3 || cfg.RollbackWindow != 15*time.Minute || cfg.BreakerCooldown != time.Hour {
+		t.Fatalf("unexpected breaker config: %+v", cfg)
+	}
+
+	cfg, err = Parse([]string{"api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RollbackWindow != DefaultRollbackWindow || cfg.BreakerCooldown != DefaultBreakerCooldown {
+		t.Fatalf("unexpected breaker defaults: %+v", cfg)
+	}
+
+	if _, err := Parse([]string{"--reset-breaker", "--strategy=canary", "api", "rollback"}); err == nil {
+		t.Fatal("expected --reset-breaker with an action to fail")
+	}
+}
+
+func TestParse_RampRequiresCanaryAnalyze(t *testing.T) {
+	cfg, err := Parse([]string{"--strategy=canary", "--analyze", "--ramp=30m", "--ramp-steps=6", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RampDuration != 30*time.Minute || cfg.RampSteps != 6 {
+		t.Fatalf("unexpected ramp config: %+v", cfg)
+	}
+	if _, err := Parse([]string{"--strategy=canary", "--ramp=30m", "api"}); err == nil {
+		t.Fatal("expected --ramp without --analyze to fail")
+	}
+	if _, err := Parse([]string{"--analyze", "--strategy=blue-green", "--ramp=30m", "api"}); err == nil {
+		t.Fatal("expected --ramp with blue-green to fail")
+	}
+}
+
+func TestParse_CanaryShorthand(t *testing.T) {
+	cfg, err := Parse([]string{"--canary", "10", "--canary-duration=15m", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Strategy != StrategyCanary || cfg.Weight != 10 || cfg.CanaryDuration != 15*time.Minute {
+		t.Fatalf("unexpected canary config: %+v", cfg)
+	}
+	for _, args := range [][]string{
+		{"--canary", "10", "--strategy=rolling", "api"},
+		{"--canary-duration=15m", "api"},
+		{"--canary", "10", "--canary-duration=15m", "--analyze", "--ramp=30m", "api"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParse_DownAction(t *testing.T) {
+	cfg, err := Parse([]string{"-f", "docker-compose.yml", "down", "api", "worker"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Action != ActionDown || len(cfg.Services) != 2 {
+		t.Fatalf("expected down for api and worker, got action=%q services=%v", cfg.Action, cfg.Services)
+	}
+	cfg, err = Parse([]string{"-f", "docker-compose.yml", "down", "--all"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Action != ActionDown || !cfg.DownAll || cfg.Service != "" {
+		t.Fatalf("expected down --all, got %+v", cfg)
+	}
+
+	for _, args := range [][]string{
+		{"down"},
+		{"-f", "docker-compose.yml", "down", "--all", "api"},
+		{"down", "--all"},
+		{"--all", "api"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("expected %v to fail", args)
+		}
+	}
+}
+
+func TestParse_StopConcurrency(t *testing.T) {
+	cfg, err := Parse([]string{"api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StopConcurrency != DefaultStopConcurrency {
+		t.Fatalf("expected default %d, got %d", DefaultStopConcurrency, cfg.StopConcurrency)
+	}
+	cfg, err = Parse([]string{"--stop-concurrency=8", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StopConcurrency != 8 {
+		t.Fatalf("expected 8, got %d", cfg.StopConcurrency)
+	}
+	if _, err := Parse([]string{"--stop-concurrency", "0", "api"}); err == nil {
+		t.Fatal("expected --stop-concurrency 0 to fail")
+	}
+}
+
+func TestParse_StopTimeout(t *testing.T) {
+	cfg, err := Parse([]string{"--stop-timeout", "45", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StopTimeout != 45 {
+		t.Fatalf("expected 45, got %d", cfg.StopTimeout)
+	}
+	if _, err := Parse([]string{"--stop-timeout=-1", "api"}); err == nil {
+		t.Fatal("expected negative --stop-timeout to fail")
+	}
+}
+
+func TestParse_MaxRetries(t *testing.T) {
+	cfg, err := Parse([]string{"api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxRetries != DefaultMaxRetries {
+		t.Fatalf("expected default %d, got %d", DefaultMaxRetries, cfg.MaxRetries)
+	}
+	cfg, err = Parse([]string{"--max-retries=0", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxRetries != 0 {
+		t.Fatalf("expected retries to be disabled, got %d", cfg.MaxRetries)
+	}
+	if _, err := Parse([]string{"--max-retries", "-1", "api"}); err == nil {
+		t.Fatal("expected negative --max-retries to fail")
+	}
+}
+
+func TestParse_LogFormatAndLevel(t *testing.T) {
+	cfg, err := Parse([]string{"--log-format=json", "--log-level", "WARN", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.LogFormat != LogFormatJSON || cfg.LogLevel != "warn" {
+		t.Fatalf("expected json/warn, got %s/%s", cfg.LogFormat, cfg.LogLevel)
+	}
+	if _, err := Parse([]string{"--log-format", "xml", "api"}); err == nil {
+		t.Fatal("expected unknown --log-format to fail")
+	}
+	if _, err := Parse([]string{"--log-level", "verbose", "api"}); err == nil {
+		t.Fatal("expected unknown --log-level to fail")
+	}
+}
+
+func TestLogFormatFromArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"--bogus", "--log-format", "json", "api"}, want: LogFormatJSON},
+		{args: []string{"--log-format=json", "--log-format=text", "api"}, want: LogFormatText},
+		{args: []string{"--log-format", "xml", "api"}, want: DefaultLogFormat},
+		{args: []string{"--log-format"}, want: DefaultLogFormat},
+	}
+	for _, tt := range tests {
+		if got := LogFormatFromArgs(tt.args); got != tt.want {
+			t.Fatalf("LogFormatFromArgs(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParse_RecordsExplicitTimingOptions(t *testing.T) {
+	cfg, err := Parse([]string{"-t", "90", "--wait-after-healthy", "3", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.ExplicitOptions["timeout"] || !cfg.ExplicitOptions["wait-after-healthy"] || cfg.ExplicitOptions["wait"] {
+		t.Fatalf("unexpected explicit options: %v", cfg.ExplicitOptions)
+	}
+}
+
+func TestParse_ComposeFileEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       string
+		separator string
+		args      []string
+		want      []string
+	}{
+		{name: "colon separated", env: "compose.yml:compose.prod.yml", args: []string{"api"}, want: []string{"compose.yml", "compose.prod.yml"}},
+		{name: "semicolon separated", env: "compose.yml; compose.prod.yml", args: []string{"api"}, want: []string{"compose.yml", "compose.prod.yml"}},
+		{name: "custom separator", env: "compose.yml,compose.prod.yml", separator: ",", args: []string{"api"}, want: []string{"compose.yml", "compose.prod.yml"}},
+		{name: "-f wins", env: "compose.yml", args: []string{"-f", "other.yml", "api"}, want: []string{"other.yml"}},
+		{name: "stdin", env: "compose.yml", args: []string{"-f", "-", "api"}, want: []string{"-"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COMPOSE_FILE", tt.env)
+			t.Setenv("COMPOSE_PATH_SEPARATOR", tt.separator)
+			cfg, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(cfg.ComposeFiles, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("ComposeFiles = %v, want %v", cfg.ComposeFiles, tt.want)
+			}
+		})
+	}
+
+	t.Run("ignored with --image", func(t *testing.T) {
+		t.Setenv("COMPOSE_FILE", "compose.yml")
+		cfg, err := Parse([]string{"--image", "nginx:1.27", "--name", "web"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(cfg.ComposeFiles) != 0 {
+			t.Fatalf("expected no compose files, got %v", cfg.ComposeFiles)
+		}
+	})
+}
+
+func TestParse_DiscoversDefaultComposeAndEnvFiles(t *testing.T) {
+	t.Setenv("COMPOSE_FILE", "")
+	dir := t.TempDir()
+	for _, name := range []string{"docker-compose.yml", "docker-compose.override.yml", "compose.override.yaml", ".env"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("services: {}\n"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	cfg, err := Parse([]string{"--project-directory", dir, "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantFiles := []string{filepath.Join(dir, "docker-compose.yml"), filepath.Join(dir, "docker-compose.override.yml")}
+	if !reflect.DeepEqual(cfg.ComposeFiles, wantFiles) {
+		t.Fatalf("ComposeFiles = %v, want %v", cfg.ComposeFiles, wantFiles)
+	}
+	if want := []string{filepath.Join(dir, ".env")}; !reflect.DeepEqual(cfg.EnvFiles, want) {
+		t.Fatalf("EnvFiles = %v, want %v", cfg.EnvFiles, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "compose.yaml"), []byte("services: {}\n"), 0o644); err != nil {
+		t.Fatalf("write compose.yaml: %v", err)
+	}
+	cfg, err = Parse([]string{"--project-directory", dir, "-f", "other.yml", "--env-file", "prod.env", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.ComposeFiles, []string{"other.yml"}) || !reflect.DeepEqual(cfg.EnvFiles, []string{"prod.env"}) {
+		t.Fatalf("expected explicit files to win, got %v and %v", cfg.ComposeFiles, cfg.EnvFiles)
+	}
+	cfg, err = Parse([]string{"--project-directory", dir, "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantFiles = []string{filepath.Join(dir, "compose.yaml"), filepath.Join(dir, "compose.override.yaml")}
+	if !reflect.DeepEqual(cfg.ComposeFiles, wantFiles) {
+		t.Fatalf("ComposeFiles = %v, want %v", cfg.ComposeFiles, wantFiles)
+	}
+}
+
+func TestParse_StdinComposeFileOnlyOnce(t *testing.T) {
+	if _, err := Parse([]string{"-f", "-", "-f", "-", "api"}); err == nil {
+		t.Fatal("expected error for reading stdin twice")
+	}
+}
+
+func TestParse_NoProxy(t *testing.T) {
+	for _, flag := range []string{"--no-proxy", "--no-traefik"} {
+		cfg, err := Parse([]string{flag, "api"})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", flag, err)
+		}
+		if !cfg.NoProxy {
+			t.Fatalf("%s: expected NoProxy, got %#v", flag, cfg)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--no-proxy", "--strategy=blue-green", "api"},
+		{"--no-proxy", "--only-config", "api"},
+		{"--no-proxy", "--force-regenerate", "api"},
+		{"--no-proxy", "--reload-cmd", "true", "api"},
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestParse_SecondsFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{args: []string{"--timeout", "90", "api"}, want: 90},
+		{args: []string{"--timeout", "90s", "api"}, want: 90},
+		{args: []string{"-t", "2m", "api"}, want: 120},
+		{args: []string{"--timeout=1m30s", "api"}, want: 90},
+		{args: []string{"--timeout", "1.5", "api"}, want: 2},
+		{args: []string{"--timeout", "500ms", "api"}, want: 1},
+	}
+	for _, tt := range tests {
+		cfg, err := Parse(tt.args)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.args, err)
+		}
+		if cfg.HealthcheckTimeout != tt.want {
+			t.Fatalf("%v: HealthcheckTimeout = %d, want %d", tt.args, cfg.HealthcheckTimeout, tt.want)
+		}
+	}
+
+	cfg, err := Parse([]string{"-w", "1m", "--wait-after-healthy=30s", "--stop-timeout", "45", "api"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.NoHealthcheckTimeout != 60 || cfg.WaitAfterHealthy != 30 || cfg.StopTimeout != 45 {
+		t.Fatalf("unexpected config: %#v", cfg)
+	}
+
+	for _, value := range []string{"90ss", "2 minutes", "NaN", "1e300"} {
+		if _, err := Parse([]string{"--timeout", value, "api"}); err == nil {
+			t.Fatalf("expected error for --timeout %q", value)
+		}
+	}
+}
+
+func TestParse_DurationFlagsAcceptBareSeconds(t *testing.T) {
+	tests := []struct {
+		args []string
+		want time.Duration
+	}{
+		{args: []string{"--poll-interval", "2", "api"}, want: 2 * time.Second},
+		{args: []string{"--poll-interval", "0.5", "api"}, want: 500 * time.Millisecond},
+		{args: []string{"--poll-interval=1m30s", "api"}, want: 90 * time.Second},
+		{args: []string{"--poll-interval", "250ms", "api"}, want: 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		cfg, err := Parse(tt.args)
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.args, err)
+		}
+		if cfg.PollInterval != tt.want {
+			t.Fatalf("%v: PollInterval = %v, want %v", tt.args, cfg.PollInterval, tt.want)
+		}
+	}
+	if _, err := Parse([]string{"--poll-interval", "2x", "api"}); err == nil {
+		t.Fatal("expected error for an unknown unit")
+	}
+}
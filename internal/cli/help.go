@@ -1,6 +1,10 @@
 package cli
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
 
 func PluginMetadata() string {
 	return `{
@@ -14,31 +18,160 @@ func PluginMetadata() string {
 
 func Usage() string {
 	return fmt.Sprintf(`
-Usage: docker ztd [OPTIONS] SERVICE
+Usage: docker ztd [OPTIONS] SERVICE [SERVICE...]
        docker ztd [OPTIONS] SERVICE ACTION
        docker ztd [OPTIONS] auto-cleanup-run
+       docker ztd [OPTIONS] explain SERVICE
+       docker ztd [OPTIONS] status SERVICE [SERVICE...]
+       docker ztd [OPTIONS] down SERVICE [SERVICE...] | --all
+       docker ztd [OPTIONS] --image IMAGE --name SERVICE [ACTION]
 
 Rolling new Compose service version.
 
 Actions:
   switch                    blue-green only: switch active traffic between blue and green
   rollback                  canary only: route 100%% traffic to old containers
+  promote                   canary only: route 100%% traffic to new containers and drop the canary router
+  abort                     canary only: same as rollback
   cleanup                   blue-green/canary: cleanup inactive side and clear state
   auto-cleanup-run          process overdue cleanup deadlines from state files
+  explain                   print how each label of SERVICE maps into the generated Traefik config
+  status                    print health and Traefik routing of each container; exits non-zero
+                            unless all are healthy and routed
+  down                      stop and remove the containers of SERVICE (or every service with --all)
+                            and drop its routers and services from the Traefik config
 
 Options:
   General:
     -h, --help                  Print usage
-    -f, --file FILE             Compose configuration files
+        --config FILE           Read options from a YAML file; command-line flags override it
+        --print-config          Print the effective configuration (flags and defaults) and exit
+        --all                   down only: tear down every service in the compose files
+        --remove-orphans        up only: remove containers of services no longer in the compose files
+    -n, --dry-run               Run every check and gate, log the compose, docker and reload
+                                commands instead of running them and print the proxy config
+                                instead of writing it
+    -f, --file FILE             Compose configuration files; "-" reads one from stdin
+                                (default: COMPOSE_FILE)
+    -H, --host HOST             Docker engine to deploy to (example: ssh://deploy@web1)
+        --context NAME          Docker context to deploy to
         --env-file FILE         Specify an alternate environment file
+        --project-directory DIR Compose project directory (default: current directory)
+        --project NAME          Compose project name; containers of other projects are never
+                                touched (default: COMPOSE_PROJECT_NAME or derived by compose)
+        --image IMAGE           Deploy IMAGE without a compose file; the compose file is generated
+                                under .ztd/inline (requires --name)
+        --name SERVICE          Service name for --image
+        --port PORT             Backend port routed by Traefik for --image
+        --rule RULE             Traefik router rule for --image (example: Host(`+"`example.com`"+`))
     -t, --timeout N             Healthcheck timeout (default: %d seconds)
     -w, --wait N                When no healthcheck is defined, wait for N seconds
                                 before stopping old container (default: %d seconds)
         --wait-after-healthy N  When healthcheck is defined and succeeds, wait for additional N seconds
                                 before stopping the old container (default: 0 seconds)
+        --poll-interval DUR     How often container health is checked while waiting (default: %s)
+        --start-period DUR      Tolerate unhealthy new containers for DUR before rolling back
+                                (default: the start_period of the container healthcheck)
+        --tcp-probe PORT        When no healthcheck is defined, wait until new containers accept
+                                TCP connections on PORT (bounded by --timeout)
+        --network NAME          Network whose container IP the TCP and HTTP readiness probes
+                                connect to, for containers on several networks
+        --healthy-status LIST   Extra health statuses accepted as ready, comma-separated
+                                (example: starting; healthy is always accepted)
+        --replicas N            Start N containers when SERVICE is not running yet and wait until
+                                all of them are healthy (rolling)
+        --critical-count N      Only wait for the first N new containers to be healthy, 0 waits for all
+        --timeout-action TYPE   What to do when new containers miss the healthcheck timeout
+                                (default: %s, options: rollback, keep, force)
+        --surge-override FILE   Extra compose file applied only when scaling up surge containers
+        --resource-check MODE   Check host memory/CPU against the surge containers' compose limits
+                                before scaling (default: %s, options: strict, warn, off)
+        --deploy-reason TEXT    Recorded on new containers as com.ztd.deploy-reason
+        --deployed-by NAME      Recorded on new containers as com.ztd.deployed-by (default: $USER@host)
         --strategy TYPE         Deployment strategy (default: %s, options: rolling, blue-green, canary)
-        --proxy TYPE            Set proxy type (default: traefik, options: traefik, nginx-proxy)
+        --fail-on-unmatched-config
+                                Roll back when no Traefik server matches the old containers
+        --drain DUR             After the proxy stops routing to old containers, wait DUR for in-flight
+                                requests before stopping them, instead of the --wait pause (rolling only)
+        --min-old-uptime DUR    Wait until old containers have run for DUR before removing them
+                                (rolling, example: 2m)
+        --stop-concurrency N    Containers stopped and removed at the same time (default: %d)
+        --stop-timeout N        Seconds to wait for a container to stop before killing it
+                                (default: the service's stop_grace_period, 10 seconds)
+        --reconcile-count       Scale back to the pre-deploy replica count when the final count drifts
+        --fail-fast             Abort remaining services when one fails its healthcheck and roll back
+                                the services already deployed (default)
+        --continue-on-error     Finish healthy services and report failed ones at the end
+        --scale-step STEP       Containers added per rolling batch (default: %s): double adds one
+                                per running replica, +N adds N and replaces N old ones per batch
+                                until all are replaced, N surges to N containers in total
+        --batch-size N          Same as --scale-step +N: replace N containers at a time
+        --parallel              Deploy multiple SERVICEs concurrently (rolling, with --continue-on-error)
+        --only-config           Refresh Traefik config from current labels without scaling or
+                                recreating containers
+        --verify-signature      Verify the target image signature before scaling
+        --verify-command CMD    Verification command, the image is appended (default: %s)
+        --deploy-if-changed     Skip the deploy when running containers already carry the target
+                                image's version label
+        --version-label KEY     Label compared by --deploy-if-changed (default: %s)
+        --skip-if-current       Exit without deploying when running containers already use the
+                                target image and config at the desired replica count and are healthy
+        --recreate              Recreate containers in place when the service is not routed by a
+                                proxy, instead of scaling up and swapping
+        --force-regenerate      Rebuild the whole Traefik config from the running containers once the
+                                new ones are ready, instead of swapping container IDs in place
+        --service-label KEY     Container label that maps containers to compose services
+                                (default: %s)
+        --no-proxy              Never write or reload a proxy config (alias: --no-traefik); containers
+                                are still replaced, but without a proxy swap the deploy is only
+                                zero-downtime if an external load balancer follows the containers
+        --proxy TYPE            Set proxy type (default: traefik, options: traefik, nginx-proxy, haproxy)
         --traefik-conf FILE     Specify Traefik configuration file (default: %s)
+        --dns-names             Point Traefik servers at compose DNS names (project-service-N)
+                                instead of container IDs (rolling strategy only)
+        --nginx-conf FILE       nginx config file written with --proxy nginx-proxy (default: %s)
+        --nginx-reload CMD      Command that reloads nginx after its config is rewritten
+                                (example: "docker exec proxy nginx -s reload")
+        --haproxy-conf FILE     HAProxy config file written with --proxy haproxy (default: %s)
+        --haproxy-reload CMD    Command that reloads HAProxy after its config is rewritten
+                                (example: "docker kill -s HUP haproxy")
+        --reload-cmd CMD        Command run after every proxy config write, for proxies that do not
+                                watch the file (example: "docker kill -s HUP traefik"); runs after
+                                --nginx-reload/--haproxy-reload
+        --reload-endpoint URL   URL POSTed to after every proxy config write; must answer 2xx
+        --reload-required       Fail the deploy when the reload fails instead of logging a warning
+        --traefik-api URL       Traefik API base URL; a rolling deploy waits until it reports the new
+                                servers before removing old containers, instead of a fixed wait
+        --timestamp-format FMT  Prefix log lines with timestamps (RFC3339, RFC3339Nano or a Go layout)
+        --color                 Force colored log output
+        --no-color              Disable colored log output
+        --log-format FMT        Log output format (default: %s, options: text, json)
+        --output FMT            Deploy result output (default: text, options: text, json); json prints one
+                                result object per service to stdout and moves logs to stderr
+        --log-level LEVEL       Minimum level logged (default: %s, options: debug, info, warn, error)
+    -v, --verbose               Same as --log-level debug: also logs every docker compose command line
+                                with its working directory and DOCKER_*/COMPOSE_* environment
+        --otel-endpoint URL     Export deploy traces to an OTLP/HTTP collector (example: http://localhost:4318)
+        --events-socket PATH    Stream newline-delimited JSON progress events to a Unix socket
+        --max-retries N         Retry docker commands that fail with transient daemon errors, with
+                                exponential backoff, 0 disables (default: %d)
+        --max-concurrent-deploys N
+                                Limit concurrent ztd deploys on this host, 0 disables (default: %d)
+        --deploy-slot-timeout DUR
+                                How long to wait for a free deploy slot (default: %s)
+        --lock-timeout DUR      How long to wait while another ztd run holds the lock of SERVICE,
+                                0 fails at once (default: 0)
+        --pre-deploy CMD        Run CMD through sh -c before scaling; a failure aborts the deploy
+        --post-deploy CMD       Run CMD after the old containers are removed; a failure only warns
+                                without a rollback
+        --pre-stop CMD          Run CMD against the old containers right before they are stopped
+                                (rolling only); a failure is only logged
+        --deploy-timeout DUR    Abort and roll back a deploy still running after DUR, 0 disables (default: 0)
+        --max-rollbacks N       Refuse deploys of SERVICE (exit code 3) after more than N rollbacks
+                                within --rollback-window, 0 disables (default: 0)
+        --rollback-window DUR   Window in which rollbacks are counted (default: %s)
+        --breaker-cooldown DUR  How long deploys stay refused once the breaker trips (default: %s)
+        --reset-breaker         Clear the rollback history of SERVICE and exit
 
   Blue-green:
         --host-mode VALUE       Route by host (HTTP Host / TCP HostSNI, example: green.example.com)
@@ -49,9 +182,17 @@ Options:
 
   Canary:
         --weight N              canary mode (default: %d)
+        --canary N              Shorthand for --strategy=canary --weight=N
+        --canary-duration DUR   Hold the canary weight for DUR, then promote and remove the old
+                                containers
+        --canary-rule RULE      Add a <service>-canary router with RULE that reaches only the new
+                                containers (example: Host(`+"`canary.example.com`"+`))
+        --ramp DUR              After the canary passes, raise its weight to 100%% over DUR, gating
+                                every step with --analyze and rolling back on failure
+        --ramp-steps N          Number of weight steps for --ramp (default: %d)
 
   Action-specific:
-        --auto-cleanup DURATION switch/rollback actions only (example: 10m, 1h30m)
+        --auto-cleanup DURATION switch/rollback/promote/abort actions only (example: 10m, 1h30m)
 
   Runtime analysis:
         --analyze               Enable runtime metrics analysis for blue-green/canary
@@ -63,5 +204,5 @@ Options:
         --max-4xx-ratio N       Maximum allowed 4xx ratio [0..1], -1 disables (default: %.2f)
         --max-mean-latency-ms N Maximum allowed mean latency in milliseconds, -1 disables (default: %.2f)
 
-`, DefaultHealthcheckTimeout, DefaultNoHealthcheckTimeout, DefaultStrategy, DefaultTraefikConfig, DefaultCanaryWeight, DefaultMetricsURL, DefaultAnalyzeWindow, DefaultAnalyzeInterval, DefaultAnalyzeMinRequests, DefaultAnalyzeMax5xxRatio, DefaultAnalyzeMax4xxRatio, DefaultAnalyzeMaxLatencyMS)
+`, DefaultHealthcheckTimeout, DefaultNoHealthcheckTimeout, DefaultPollInterval, DefaultTimeoutAction, DefaultResourceCheck, DefaultStrategy, DefaultStopConcurrency, DefaultScaleStep, DefaultVerifyCommand, DefaultVersionLabel, compose.DefaultServiceLabel, DefaultTraefikConfig, DefaultNginxConfig, DefaultHAProxyConfig, DefaultLogFormat, DefaultLogLevel, DefaultMaxRetries, DefaultMaxConcurrentDeploys, DefaultDeploySlotTimeout, DefaultRollbackWindow, DefaultBreakerCooldown, DefaultCanaryWeight, DefaultRampSteps, DefaultMetricsURL, DefaultAnalyzeWindow, DefaultAnalyzeInterval, DefaultAnalyzeMinRequests, DefaultAnalyzeMax5xxRatio, DefaultAnalyzeMax4xxRatio, DefaultAnalyzeMaxLatencyMS)
 }
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FormatConfig renders every Config field as "Name: value", one per line, in
+// declaration order. Slices and set keys are comma-separated and empty values print
+// as "-". URLs are printed with their credentials redacted, see redactURL.
+func FormatConfig(cfg Config) string {
+	var b strings.Builder
+	v := reflect.ValueOf(cfg)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Name == "PrintConfig" {
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s\n", t.Field(i).Name, formatValue(v.Field(i)))
+	}
+	return b.String()
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = redactURL(fmt.Sprint(v.Index(i).Interface()))
+		}
+		v = reflect.ValueOf(strings.Join(items, ","))
+	}
+	if v.Kind() == reflect.Map {
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, fmt.Sprint(key.Interface()))
+		}
+		sort.Strings(keys)
+		v = reflect.ValueOf(strings.Join(keys, ","))
+	}
+	if v.Kind() == reflect.String && v.String() == "" {
+		return "-"
+	}
+	if v.Kind() == reflect.String {
+		return redactURL(v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// redacted replaces credentials in printed URLs, like url.URL.Redacted does.
+const redacted = "xxxxx"
+
+// secretQueryKeys are the substrings that mark a query parameter as a credential.
+var secretQueryKeys = []string{"token", "secret", "password", "passwd", "key", "auth", "signature", "sig", "credential"}
+
+// redactURL hides the userinfo and the secret-looking query parameters of value when
+// it is an absolute URL, such as --metrics-url, --reload-endpoint or --otel-endpoint.
+// Any other value is returned as is.
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if isSecretQueryKey(key) {
+				query.Set(key, redacted)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+func isSecretQueryKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretQueryKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,77 @@
 package compose
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"strings"
+)
 
 type Adapter interface {
 	Up(ctx context.Context, files []string, envFiles []string, service string, detached bool, noRecreate bool) error
 	Scale(ctx context.Context, files []string, envFiles []string, service string, replicas int) error
 	PsQuiet(ctx context.Context, files []string, envFiles []string, service string) ([]string, error)
+	ServiceImage(ctx context.Context, files []string, envFiles []string, service string) (string, error)
 	LogsFollowTail(ctx context.Context, files []string, service string, tail int) error
 }
+
+// ConfigHasher is implemented by adapters that can hash the resolved config of a
+// service, so a deploy can tell whether the running containers are already current.
+type ConfigHasher interface {
+	ConfigHash(ctx context.Context, files []string, envFiles []string, service string) (string, error)
+}
+
+// ServiceConfigHash returns the config hash of service, or an empty string when the
+// adapter cannot compute one.
+func ServiceConfigHash(ctx context.Context, a Adapter, files []string, envFiles []string, service string) (string, error) {
+	hasher, ok := a.(ConfigHasher)
+	if !ok {
+		return "", nil
+	}
+	return hasher.ConfigHash(ctx, files, envFiles, service)
+}
+
+// Recreator is implemented by adapters that can recreate the containers of a service
+// in place, without scaling up first.
+type Recreator interface {
+	Recreate(ctx context.Context, files []string, envFiles []string, service string, replicas int) error
+}
+
+// RecreateService force-recreates the containers of service, keeping replicas of them
+// when replicas > 0.
+func RecreateService(ctx context.Context, a Adapter, files []string, envFiles []string, service string, replicas int) error {
+	recreator, ok := a.(Recreator)
+	if !ok {
+		return fmt.Errorf("compose adapter does not support recreating service %s", service)
+	}
+	return recreator.Recreate(ctx, files, envFiles, service, replicas)
+}
+
+// StartService brings up a service that is not running yet. With replicas > 0 it
+// starts exactly that many containers instead of the compose default.
+func StartService(ctx context.Context, a Adapter, files []string, envFiles []string, service string, replicas int) error {
+	if replicas > 0 {
+		return a.Scale(ctx, files, envFiles, service, replicas)
+	}
+	return a.Up(ctx, files, envFiles, service, true, true)
+}
+
+// SurgeFiles returns the compose files used for the scale-up step: the base files plus
+// surge-only overrides appended last so they take precedence.
+func SurgeFiles(files []string, overrides []string) []string {
+	if len(overrides) == 0 {
+		return files
+	}
+	return append(append([]string{}, files...), overrides...)
+}
+
+// NormalizeProjectName mirrors how docker compose derives a project name from a
+// directory name: lowercased, keeping only [a-z0-9_-].
+func NormalizeProjectName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimLeft(b.String(), "_-")
+}
@@ -0,0 +1,172 @@
+package compose
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSurgeFiles(t *testing.T) {
+	base := []string{"docker-compose.yml"}
+	if got := SurgeFiles(base, nil); !reflect.DeepEqual(got, base) {
+		t.Fatalf("expected base files, got %v", got)
+	}
+	got := SurgeFiles(base, []string{"surge.yml"})
+	if !reflect.DeepEqual(got, []string{"docker-compose.yml", "surge.yml"}) {
+		t.Fatalf("unexpected surge files: %v", got)
+	}
+	if len(base) != 1 {
+		t.Fatalf("base files must not be modified: %v", base)
+	}
+}
+
+func TestNormalizeProjectName(t *testing.T) {
+	if got := NormalizeProjectName("My.App_1"); got != "myapp_1" {
+		t.Fatalf("unexpected project name: %q", got)
+	}
+}
+
+func TestShellAdapter_ProjectDirectory(t *testing.T) {
+	adapter := (&ShellAdapter{commandPrefix: []string{"docker", "compose"}}).WithProjectDirectory("/srv/app")
+	got := adapter.buildComposeArgs([]string{"compose.yml"}, nil, "ps")
+	want := []string{"docker", "compose", "-f", "compose.yml", "--project-directory", "/srv/app", "ps"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected args: %v", got)
+	}
+}
+
+func TestShellAdapter_ProjectName(t *testing.T) {
+	adapter := (&ShellAdapter{commandPrefix: []string{"docker", "compose"}}).WithProjectName("shop")
+	got := adapter.buildComposeArgs([]string{"compose.yml"}, nil, "ps")
+	want := []string{"docker", "compose", "-f", "compose.yml", "--project-name", "shop", "ps"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected args: %v", got)
+	}
+}
+
+func TestShellAdapter_StopsCommandOnCancel(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not available")
+	}
+	adapter := &ShellAdapter{commandPrefix: []string{"sleep"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := adapter.output(ctx, nil, nil, "30"); err == nil {
+		t.Fatal("expected cancelled command to fail")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected command to stop on cancel, took %s", elapsed)
+	}
+}
+
+func TestShellAdapter_RemoveOrphansOnlyOnFullUp(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip("echo is not available")
+	}
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	adapter := (&ShellAdapter{commandPrefix: []string{"echo"}}).WithRemoveOrphans(true).WithLogger(log)
+
+	cases := []struct {
+		service    string
+		noRecreate bool
+		want       bool
+	}{
+		{"", false, true},
+		{"", true, false},
+		{"api", false, false},
+		{"api", true, false},
+	}
+	for _, tc := range cases {
+		buf.Reset()
+		if err := adapter.Up(context.Background(), nil, nil, tc.service, true, tc.noRecreate); err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+		if got := strings.Contains(buf.String(), "--remove-orphans"); got != tc.want {
+			t.Fatalf("service=%q noRecreate=%v: expected --remove-orphans=%v, got output %q", tc.service, tc.noRecreate, tc.want, buf.String())
+		}
+	}
+}
+
+func TestShellAdapter_LogsCommandsAtDebugLevel(t *testing.T) {
+	if _, err := exec.LookPath("echo"); err != nil {
+		t.Skip("echo is not available")
+	}
+	t.Setenv("COMPOSE_PROFILES", "web")
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	adapter := (&ShellAdapter{commandPrefix: []string{"echo"}}).WithLogger(log)
+
+	if err := adapter.Scale(context.Background(), []string{"compose.yml"}, []string{"my app.env"}, "api", 2); err != nil {
+		t.Fatalf("scale failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "==> Running") {
+		t.Fatalf("did not expect commands to be logged at info level, got %q", buf.String())
+	}
+
+	buf.Reset()
+	log.SetLevel(logrus.DebugLevel)
+	if err := adapter.Scale(context.Background(), []string{"compose.yml"}, []string{"my app.env"}, "api", 2); err != nil {
+		t.Fatalf("scale failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"==> Running echo -f compose.yml --env-file 'my app.env'", "COMPOSE_PROFILES=web", "dir="} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestShellAdapter_DryRunOnlyLogsCommandsThatChangeContainers(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	adapter := (&ShellAdapter{commandPrefix: []string{"false"}}).WithLogger(log).WithDryRun(true)
+
+	if err := adapter.Scale(context.Background(), []string{"compose.yml"}, nil, "api", 4); err != nil {
+		t.Fatalf("expected dry-run scale to succeed without running, got %v", err)
+	}
+	if err := adapter.Recreate(context.Background(), []string{"compose.yml"}, nil, "api", 0); err != nil {
+		t.Fatalf("expected dry-run recreate to succeed without running, got %v", err)
+	}
+	for _, want := range []string{
+		"==> [dry-run] Would run false -f compose.yml up --detach --scale api=4 --no-recreate api",
+		"==> [dry-run] Would run false -f compose.yml up --detach --force-recreate --no-deps api",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in %q", want, buf.String())
+		}
+	}
+	if _, err := adapter.PsQuiet(context.Background(), []string{"compose.yml"}, nil, "api"); err == nil {
+		t.Fatal("expected ps to still run in a dry run")
+	}
+}
+
+func TestQuoteCommand(t *testing.T) {
+	got := quoteCommand([]string{"docker", "compose", "--scale", "api=3", "", "it's", "$HOME"})
+	want := `docker compose --scale api=3 '' 'it'\''s' '$HOME'`
+	if got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestDockerEnv(t *testing.T) {
+	got := dockerEnv([]string{"--tls", "-H", "ssh://deploy@web1", "--context=staging", "--host"})
+	want := []string{"DOCKER_HOST=ssh://deploy@web1", "DOCKER_CONTEXT=staging"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("dockerEnv() = %v, want %v", got, want)
+	}
+	if got := dockerEnv(nil); got != nil {
+		t.Fatalf("expected no env without docker args, got %v", got)
+	}
+}
@@ -34,6 +34,10 @@ func (a *APIAdapter) PsQuiet(_ context.Context, _ []string, _ []string, _ string
 	return nil, fmt.Errorf("compose API adapter is not enabled yet")
 }
 
+func (a *APIAdapter) ServiceImage(_ context.Context, _ []string, _ []string, _ string) (string, error) {
+	return "", fmt.Errorf("compose API adapter is not enabled yet")
+}
+
 func (a *APIAdapter) LogsFollowTail(_ context.Context, _ []string, _ string, _ int) error {
 	return fmt.Errorf("compose API adapter is not enabled yet")
 }
@@ -0,0 +1,96 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+)
+
+const (
+	LabelDeployedAt   = "com.ztd.deployed-at"
+	LabelDeployReason = "com.ztd.deploy-reason"
+	LabelDeployedBy   = "com.ztd.deployed-by"
+	LabelConfigHash   = "com.ztd.config-hash"
+)
+
+// DeployMetadata is stamped as labels on surge containers for traceability.
+type DeployMetadata struct {
+	DeployedAt time.Time
+	Reason     string
+	DeployedBy string
+	ConfigHash string
+}
+
+func (m DeployMetadata) Labels() map[string]string {
+	labels := map[string]string{
+		LabelDeployedAt: m.DeployedAt.UTC().Format(time.RFC3339),
+	}
+	if m.Reason != "" {
+		labels[LabelDeployReason] = m.Reason
+	}
+	if m.DeployedBy != "" {
+		labels[LabelDeployedBy] = m.DeployedBy
+	}
+	if m.ConfigHash != "" {
+		labels[LabelConfigHash] = m.ConfigHash
+	}
+	return labels
+}
+
+// WriteDeployMetadataOverride writes a temporary compose override that adds the deploy
+// metadata labels to service. The returned cleanup removes the file.
+func WriteDeployMetadataOverride(service string, meta DeployMetadata) (string, func(), error) {
+	return writeServiceOverride("deploy metadata", service, map[string]any{"labels": meta.Labels()})
+}
+
+// WriteImageOverride writes a temporary compose override that pins service to image,
+// e.g. an image ID to roll a deploy back. The returned cleanup removes the file.
+func WriteImageOverride(service string, image string) (string, func(), error) {
+	return writeServiceOverride("image", service, map[string]any{"image": image})
+}
+
+func writeServiceOverride(kind string, service string, fields map[string]any) (string, func(), error) {
+	override := map[string]any{
+		"services": map[string]any{
+			service: fields,
+		},
+	}
+	data, err := configio.MarshalYAML(override)
+	if err != nil {
+		return "", nil, err
+	}
+
+	file, err := os.CreateTemp("", "ztd-"+strings.ReplaceAll(kind, " ", "-")+"-*.yml")
+	if err != nil {
+		return "", nil, fmt.Errorf("create %s override: %w", kind, err)
+	}
+	path := file.Name()
+	cleanup := func() { _ = os.Remove(path) }
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		cleanup()
+		return "", nil, fmt.Errorf("write %s override: %w", kind, err)
+	}
+	if err := file.Close(); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return path, cleanup, nil
+}
+
+// DefaultDeployedBy returns user@host for the current process, falling back to either part.
+func DefaultDeployedBy() string {
+	user := os.Getenv("USER")
+	host, _ := os.Hostname()
+	switch {
+	case user != "" && host != "":
+		return user + "@" + host
+	case user != "":
+		return user
+	default:
+		return host
+	}
+}
@@ -0,0 +1,62 @@
+package compose
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+)
+
+func TestWriteDeployMetadataOverride(t *testing.T) {
+	path, cleanup, err := WriteDeployMetadataOverride("api", DeployMetadata{
+		DeployedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Reason:     "release 1.2",
+		DeployedBy: "ci@runner",
+		ConfigHash: "f00d",
+	})
+	if err != nil {
+		t.Fatalf("write override: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read override: %v", err)
+	}
+	var parsed struct {
+		Services map[string]struct {
+			Labels map[string]string `yaml:"labels"`
+		} `yaml:"services"`
+	}
+	if err := configio.UnmarshalYAML(data, &parsed); err != nil {
+		t.Fatalf("parse override: %v", err)
+	}
+	labels := parsed.Services["api"].Labels
+	if labels[LabelDeployedAt] != "2024-05-01T12:00:00Z" || labels[LabelDeployReason] != "release 1.2" || labels[LabelDeployedBy] != "ci@runner" || labels[LabelConfigHash] != "f00d" {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected override to be removed, got %v", err)
+	}
+}
+
+func TestWriteImageOverride(t *testing.T) {
+	path, cleanup, err := WriteImageOverride("api", "sha256:abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read override: %v", err)
+	}
+	if !strings.Contains(string(data), "api:") || !strings.Contains(string(data), "image: sha256:abc") {
+		t.Fatalf("unexpected override:\n%s", data)
+	}
+	cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected override to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package compose
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// LabelIgnore marks containers that must be skipped by service discovery
+// (e.g. manually started debug sidecars carrying the compose service label).
+const LabelIgnore = "com.ztd.ignore"
+
+// DefaultServiceLabel is the label docker compose uses to map containers to services.
+const DefaultServiceLabel = "com.docker.compose.service"
+
+// ProjectLabel is the label docker compose uses to map containers to projects.
+const ProjectLabel = "com.docker.compose.project"
+
+type labelReader interface {
+	Labels(ctx context.Context, containerID string) (map[string]string, error)
+}
+
+// labelLister finds containers by label, so a custom service label can discover
+// containers that compose ps does not know about. An empty value matches every
+// container carrying the key.
+type labelLister interface {
+	ContainersByLabel(ctx context.Context, key string, value string) ([]string, error)
+}
+
+// nameLister finds a container by name, so a service that sets container_name is found
+// even when its container does not carry the service label.
+type nameLister interface {
+	ContainerByName(ctx context.Context, name string) (string, error)
+}
+
+// IgnoreFilter wraps an Adapter and drops containers labelled com.ztd.ignore=true
+// from PsQuiet results.
+type IgnoreFilter struct {
+	Adapter
+	docker       labelReader
+	serviceLabel string
+	project      string
+}
+
+func NewIgnoreFilter(adapter Adapter, docker labelReader) *IgnoreFilter {
+	return &IgnoreFilter{
+		Adapter: adapter,
+		docker:  docker,
+	}
+}
+
+// WithServiceLabel makes PsQuiet find containers by their key label instead of through
+// compose, for compose-compatible tools that do not set com.docker.compose.service: a
+// named service by label=key=service, the whole project by label=key.
+func (f *IgnoreFilter) WithServiceLabel(key string) *IgnoreFilter {
+	if key != DefaultServiceLabel {
+		f.serviceLabel = key
+	}
+	return f
+}
+
+// WithProject makes PsQuiet drop containers labelled with a compose project other than
+// project, so a same-named service of another project on the host is never touched.
+func (f *IgnoreFilter) WithProject(project string) *IgnoreFilter {
+	f.project = project
+	return f
+}
+
+func (f *IgnoreFilter) PsQuiet(ctx context.Context, files []string, envFiles []string, service string) ([]string, error) {
+	ids, err := f.discover(ctx, files, envFiles, service)
+	if err != nil {
+		return nil, err
+	}
+	pinned, err := f.pinnedContainer(ctx, files, envFiles, service)
+	if err != nil {
+		return nil, err
+	}
+	if pinned != "" && !slices.Contains(ids, pinned) {
+		ids = append(ids, pinned)
+	}
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		labels, err := f.docker.Labels(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if IsIgnored(labels) {
+			continue
+		}
+		if f.serviceLabel != "" && service != "" && labels[f.serviceLabel] != service && id != pinned {
+			continue
+		}
+		if f.project != "" && labels[ProjectLabel] != "" && labels[ProjectLabel] != f.project {
+			continue
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// discover lists the containers of service, or of every service when it is empty,
+// through the custom service label when one is set and the docker client can filter by
+// label, and through compose otherwise.
+func (f *IgnoreFilter) discover(ctx context.Context, files []string, envFiles []string, service string) ([]string, error) {
+	if lister, ok := f.docker.(labelLister); ok && f.serviceLabel != "" {
+		return lister.ContainersByLabel(ctx, f.serviceLabel, service)
+	}
+	return f.Adapter.PsQuiet(ctx, files, envFiles, service)
+}
+
+// pinnedContainer returns the running container named by the container_name service
+// sets in the compose files, or an empty string when it sets none or the docker client
+// cannot look containers up by name.
+func (f *IgnoreFilter) pinnedContainer(ctx context.Context, files []string, envFiles []string, service string) (string, error) {
+	lister, ok := f.docker.(nameLister)
+	if !ok || service == "" {
+		return "", nil
+	}
+	name, err := ServiceContainerName(files, envFiles, service)
+	if err != nil || name == "" {
+		return "", err
+	}
+	return lister.ContainerByName(ctx, name)
+}
+
+// ConfigHash forwards to the wrapped adapter.
+func (f *IgnoreFilter) ConfigHash(ctx context.Context, files []string, envFiles []string, service string) (string, error) {
+	return ServiceConfigHash(ctx, f.Adapter, files, envFiles, service)
+}
+
+func IsIgnored(labels map[string]string) bool {
+	ignored, err := strconv.ParseBool(strings.TrimSpace(labels[LabelIgnore]))
+	return err == nil && ignored
+}
+
+// Recreate forwards to the wrapped adapter.
+func (f *IgnoreFilter) Recreate(ctx context.Context, files []string, envFiles []string, service string, replicas int) error {
+	return RecreateService(ctx, f.Adapter, files, envFiles, service, replicas)
+}
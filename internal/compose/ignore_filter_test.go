@@ -0,0 +1,161 @@
+package compose
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type adapterMock struct {
+	APIAdapter
+	ids []string
+}
+
+func (m *adapterMock) PsQuiet(context.Context, []string, []string, string) ([]string, error) {
+	return append([]string{}, m.ids...), nil
+}
+
+type labelsMock map[string]map[string]string
+
+func (m labelsMock) Labels(_ context.Context, id string) (map[string]string, error) {
+	return m[id], nil
+}
+
+func TestIgnoreFilterPsQuiet(t *testing.T) {
+	t.Parallel()
+
+	filter := NewIgnoreFilter(&adapterMock{ids: []string{"a", "b", "c"}}, labelsMock{
+		"a": {"com.docker.compose.service": "api"},
+		"b": {"com.docker.compose.service": "api", LabelIgnore: "true"},
+		"c": {"com.docker.compose.service": "api", LabelIgnore: "false"},
+	})
+
+	ids, err := filter.PsQuiet(context.Background(), nil, nil, "api")
+	if err != nil {
+		t.Fatalf("ps quiet: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "c" {
+		t.Fatalf("unexpected ids: %#v", ids)
+	}
+}
+
+func TestIgnoreFilterPsQuiet_CustomServiceLabel(t *testing.T) {
+	t.Parallel()
+
+	filter := NewIgnoreFilter(&adapterMock{ids: []string{"a", "b"}}, labelsMock{
+		"a": {"org.example.service": "api"},
+		"b": {"org.example.service": "worker"},
+	}).WithServiceLabel("org.example.service")
+
+	ids, err := filter.PsQuiet(context.Background(), nil, nil, "api")
+	if err != nil {
+		t.Fatalf("ps quiet: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "a" {
+		t.Fatalf("unexpected ids: %#v", ids)
+	}
+
+	ids, err = filter.PsQuiet(context.Background(), nil, nil, "")
+	if err != nil {
+		t.Fatalf("ps quiet: %v", err)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("expected all containers for the project, got %#v", ids)
+	}
+}
+
+type labelListerMock struct {
+	labelsMock
+	byLabel map[string][]string
+}
+
+func (m labelListerMock) ContainersByLabel(_ context.Context, key string, value string) ([]string, error) {
+	return m.byLabel[key+"="+value], nil
+}
+
+func TestIgnoreFilterPsQuiet_CustomServiceLabelDiscoversByLabel(t *testing.T) {
+	t.Parallel()
+
+	filter := NewIgnoreFilter(&adapterMock{ids: []string{"a"}}, labelListerMock{
+		labelsMock: labelsMock{
+			"b": {"org.example.service": "api"},
+			"c": {"org.example.service": "api", LabelIgnore: "true"},
+			"d": {"org.example.service": "worker"},
+		},
+		byLabel: map[string][]string{"org.example.service=api": {"b", "c"}, "org.example.service=": {"b", "c", "d"}},
+	}).WithServiceLabel("org.example.service")
+
+	ids, err := filter.PsQuiet(context.Background(), nil, nil, "api")
+	if err != nil {
+		t.Fatalf("ps quiet: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "b" {
+		t.Fatalf("expected the labelled container outside compose ps, got %#v", ids)
+	}
+
+	ids, err = filter.PsQuiet(context.Background(), nil, nil, "")
+	if err != nil {
+		t.Fatalf("ps quiet: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "b" || ids[1] != "d" {
+		t.Fatalf("expected every labelled container for the project, got %#v", ids)
+	}
+}
+
+func TestIgnoreFilterPsQuiet_Project(t *testing.T) {
+	t.Parallel()
+
+	filter := NewIgnoreFilter(&adapterMock{ids: []string{"a", "b", "c"}}, labelsMock{
+		"a": {"com.docker.compose.service": "api", ProjectLabel: "shop"},
+		"b": {"com.docker.compose.service": "api", ProjectLabel: "blog"},
+		"c": {"com.docker.compose.service": "api"},
+	}).WithProject("shop")
+
+	ids, err := filter.PsQuiet(context.Background(), nil, nil, "api")
+	if err != nil {
+		t.Fatalf("ps quiet: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "a" || ids[1] != "c" {
+		t.Fatalf("unexpected ids: %#v", ids)
+	}
+}
+
+type nameListerMock struct {
+	labelsMock
+	byName map[string]string
+}
+
+func (m nameListerMock) ContainerByName(_ context.Context, name string) (string, error) {
+	return m.byName[name], nil
+}
+
+func TestIgnoreFilterPsQuiet_FindsContainerName(t *testing.T) {
+	dir := t.TempDir()
+	file := writeComposeFile(t, filepath.Join(dir, "compose.yml"), "services:\n  db:\n    image: postgres\n    container_name: ${STAGE:-dev}-db\n")
+	envFile := writeComposeFile(t, filepath.Join(dir, "prod.env"), "STAGE=prod\n")
+
+	filter := NewIgnoreFilter(&adapterMock{ids: []string{"a"}}, nameListerMock{
+		labelsMock: labelsMock{
+			"a": {"com.docker.compose.service": "db"},
+			"b": {},
+		},
+		byName: map[string]string{"prod-db": "b"},
+	})
+
+	ids, err := filter.PsQuiet(context.Background(), []string{file}, []string{envFile}, "db")
+	if err != nil {
+		t.Fatalf("ps quiet: %v", err)
+	}
+	if strings.Join(ids, ",") != "a,b" {
+		t.Fatalf("expected the container named by container_name, got %#v", ids)
+	}
+
+	ids, err = filter.PsQuiet(context.Background(), []string{file}, nil, "db")
+	if err != nil {
+		t.Fatalf("ps quiet: %v", err)
+	}
+	if strings.Join(ids, ",") != "a" {
+		t.Fatalf("expected no match for the default container name, got %#v", ids)
+	}
+}
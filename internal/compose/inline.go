@@ -0,0 +1,79 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+)
+
+// InlineDir holds compose files synthesized for --image deploys, relative to the
+// project directory.
+const InlineDir = ".ztd/inline"
+
+// InlineService describes a single-container service deployed from an image tag
+// without a compose file.
+type InlineService struct {
+	Name  string
+	Image string
+	Port  int
+	Rule  string
+}
+
+// inlineComposeFile is the compose file written for an InlineService.
+type inlineComposeFile struct {
+	Services map[string]inlineComposeService `yaml:"services"`
+}
+
+type inlineComposeService struct {
+	Image  string            `yaml:"image"`
+	Labels map[string]string `yaml:"labels,omitempty"`
+}
+
+// composeFile returns the minimal compose definition for s: the image plus the
+// Traefik labels the generator reads for routing.
+func (s InlineService) composeFile() inlineComposeFile {
+	labels := map[string]string{"traefik.enable": "true"}
+	if s.Rule != "" {
+		labels["traefik.http.routers."+s.Name+".rule"] = s.Rule
+	}
+	if s.Port > 0 {
+		labels["traefik.http.services."+s.Name+".loadbalancer.server.port"] = strconv.Itoa(s.Port)
+	}
+	return inlineComposeFile{Services: map[string]inlineComposeService{
+		s.Name: {Image: s.Image, Labels: labels},
+	}}
+}
+
+// serviceNamePattern is the compose-spec pattern for service names.
+var serviceNamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// ValidServiceName reports whether name is a valid compose service name, which also
+// makes it safe to use as a file name.
+func ValidServiceName(name string) bool {
+	return serviceNamePattern.MatchString(name) && name != "." && name != ".."
+}
+
+// WriteInlineComposeFile renders s under projectDir/InlineDir so that the compose
+// CLI and the config generator can read it like any other compose file.
+func WriteInlineComposeFile(projectDir string, s InlineService) (string, error) {
+	if !ValidServiceName(s.Name) {
+		return "", fmt.Errorf("invalid service name %q", s.Name)
+	}
+	data, err := configio.MarshalYAML(s.composeFile())
+	if err != nil {
+		return "", fmt.Errorf("failed to render compose file for %s: %w", s.Name, err)
+	}
+	dir := filepath.Join(projectDir, InlineDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, s.Name+".yml")
+	if err := configio.WriteAtomic(path, data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,60 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+)
+
+func TestWriteInlineComposeFile(t *testing.T) {
+	dir := t.TempDir()
+	path, err := WriteInlineComposeFile(dir, InlineService{Name: "web", Image: "nginx:1.25", Port: 80, Rule: "Host(`x`)"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := filepath.Join(dir, InlineDir, "web.yml"); path != want {
+		t.Fatalf("expected %s, got %s", want, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	var file inlineComposeFile
+	if err := configio.UnmarshalYAML(data, &file); err != nil {
+		t.Fatalf("parse file: %v", err)
+	}
+	svc := file.Services["web"]
+	if svc.Image != "nginx:1.25" {
+		t.Fatalf("unexpected image: %q", svc.Image)
+	}
+	if svc.Labels["traefik.enable"] != "true" ||
+		svc.Labels["traefik.http.routers.web.rule"] != "Host(`x`)" ||
+		svc.Labels["traefik.http.services.web.loadbalancer.server.port"] != "80" {
+		t.Fatalf("unexpected labels: %#v", svc.Labels)
+	}
+	if _, err := CheckScalable([]string{path}, nil, "web"); err != nil {
+		t.Fatalf("expected generated service to be scalable: %v", err)
+	}
+}
+
+func TestInlineServiceComposeFile_OmitsUnsetRouting(t *testing.T) {
+	file := InlineService{Name: "worker", Image: "busybox"}.composeFile()
+	labels := file.Services["worker"].Labels
+	if len(labels) != 1 || labels["traefik.enable"] != "true" {
+		t.Fatalf("unexpected labels: %#v", labels)
+	}
+}
+
+func TestWriteInlineComposeFile_RejectsInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"../x", "a/b", "..", ""} {
+		if _, err := WriteInlineComposeFile(dir, InlineService{Name: name, Image: "busybox"}); err == nil {
+			t.Fatalf("expected error for name %q", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, InlineDir)); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing written, got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package compose
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadEnv returns the variables compose files are interpolated with: the env files
+// (later files win), or the .env file next to the first compose file when none are
+// given, overridden by the process environment as docker compose does.
+func LoadEnv(files []string, envFiles []string) (map[string]string, error) {
+	env := map[string]string{}
+	paths := envFiles
+	if len(paths) == 0 && len(files) > 0 {
+		dotEnv := filepath.Join(filepath.Dir(files[0]), ".env")
+		if _, err := os.Stat(dotEnv); err == nil {
+			paths = []string{dotEnv}
+		}
+	}
+	for _, path := range paths {
+		if err := readEnvFile(path, env); err != nil {
+			return nil, err
+		}
+	}
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env, nil
+}
+
+func readEnvFile(path string, env map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("env file %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("env file %s line %d: expected KEY=VALUE", path, n)
+		}
+		env[strings.TrimSpace(key)] = unquoteEnvValue(strings.TrimSpace(value))
+	}
+	return scanner.Err()
+}
+
+func unquoteEnvValue(value string) string {
+	if len(value) >= 2 {
+		if q := value[0]; (q == '"' || q == '\'') && value[len(value)-1] == q {
+			return value[1 : len(value)-1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+// Interpolate substitutes $VAR and ${VAR} in s following the compose rules: $$ is a
+// literal $, ${VAR:-default} and ${VAR-default} fall back when VAR is empty or unset
+// (only unset without the colon), ${VAR:+alt} and ${VAR+alt} replace a set VAR, and
+// ${VAR:?msg} and ${VAR?msg} fail. Unset variables without a default are empty.
+func Interpolate(s string, env map[string]string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+		case next == '{':
+			end := matchingBrace(s, i+2)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference in %q", s)
+			}
+			value, err := expandBraced(s[i+2:end], env)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(value)
+			i = end
+		case isNameStart(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			b.WriteString(env[s[i+1:j]])
+			i = j - 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// UnmarshalInterpolated decodes a compose file into v after interpolating every scalar
+// value with env. Mapping keys and comments are left alone, as docker compose does.
+func UnmarshalInterpolated(data []byte, env map[string]string, v any) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Kind == 0 {
+		return nil
+	}
+	if err := interpolateNode(&doc, env); err != nil {
+		return err
+	}
+	return doc.Decode(v)
+}
+
+func interpolateNode(node *yaml.Node, env map[string]string) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if strings.Contains(node.Value, "$") {
+			value, err := Interpolate(node.Value, env)
+			if err != nil {
+				return err
+			}
+			node.Value = value
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolateNode(node.Content[i], env); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, child := range node.Content {
+			if err := interpolateNode(child, env); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func expandBraced(expr string, env map[string]string) (string, error) {
+	n := 0
+	for n < len(expr) && isNameChar(expr[n]) {
+		n++
+	}
+	name, rest := expr[:n], expr[n:]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("invalid variable name in ${%s}", expr)
+	}
+	value, set := env[name]
+	if rest == "" {
+		return value, nil
+	}
+
+	colon := strings.HasPrefix(rest, ":")
+	if colon {
+		rest = rest[1:]
+	}
+	if rest == "" {
+		return "", fmt.Errorf("invalid variable reference ${%s}", expr)
+	}
+	op, arg := rest[0], rest[1:]
+	present := set && (!colon || value != "")
+	switch op {
+	case '-':
+		if present {
+			return value, nil
+		}
+		return Interpolate(arg, env)
+	case '+':
+		if present {
+			return Interpolate(arg, env)
+		}
+		return "", nil
+	case '?':
+		if present {
+			return value, nil
+		}
+		msg, err := Interpolate(arg, env)
+		if err != nil {
+			return "", err
+		}
+		return "", errors.New("required variable " + name + " is missing a value: " + msg)
+	}
+	return "", fmt.Errorf("invalid variable reference ${%s}", expr)
+}
+
+// matchingBrace returns the index of the } closing a ${ whose body starts at start,
+// skipping nested ${...} references.
+func matchingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
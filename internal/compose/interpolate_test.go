@@ -0,0 +1,102 @@
+package compose
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{"DOMAIN": "example.com", "EMPTY": "", "PORT": "8080"}
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{in: "Host(`${DOMAIN}`)", want: "Host(`example.com`)"},
+		{in: "$PORT/tcp", want: "8080/tcp"},
+		{in: "$$PORT", want: "$PORT"},
+		{in: "${MISSING}", want: ""},
+		{in: "${MISSING:-fallback}", want: "fallback"},
+		{in: "${EMPTY:-fallback}", want: "fallback"},
+		{in: "${EMPTY-fallback}", want: ""},
+		{in: "${DOMAIN:+set}", want: "set"},
+		{in: "${EMPTY:+set}", want: ""},
+		{in: "${EMPTY+set}", want: "set"},
+		{in: "${MISSING:-${PORT}}", want: "8080"},
+		{in: "cost: 5$", want: "cost: 5$"},
+	}
+	for _, tc := range cases {
+		got, err := Interpolate(tc.in, env)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.in, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%q: expected %q, got %q", tc.in, tc.want, got)
+		}
+	}
+}
+
+func TestInterpolate_Errors(t *testing.T) {
+	env := map[string]string{"EMPTY": ""}
+	_, err := Interpolate("${EMPTY:?set EMPTY}", env)
+	if err == nil || !strings.Contains(err.Error(), "set EMPTY") {
+		t.Fatalf("expected required variable error, got %v", err)
+	}
+	if _, err := Interpolate("${EMPTY?set EMPTY}", env); err != nil {
+		t.Fatalf("expected empty but set variable to pass, got %v", err)
+	}
+	if _, err := Interpolate("${UNTERMINATED", env); err == nil {
+		t.Fatal("expected unterminated reference error")
+	}
+}
+
+func TestLoadEnv_EnvFilesAndProcessEnv(t *testing.T) {
+	root := t.TempDir()
+	composeFile := writeComposeFile(t, filepath.Join(root, "compose.yml"), "services: {}\n")
+	writeComposeFile(t, filepath.Join(root, ".env"), "DOMAIN=dotenv.local\n")
+	envFile := writeComposeFile(t, filepath.Join(root, "prod.env"), "# comment\nexport DOMAIN=\"prod.local\"\nTAG=v1 # pinned\nZTD_TEST_OVERRIDE=file\n")
+	t.Setenv("ZTD_TEST_OVERRIDE", "process")
+
+	env, err := LoadEnv([]string{composeFile}, nil)
+	if err != nil {
+		t.Fatalf("load env: %v", err)
+	}
+	if env["DOMAIN"] != "dotenv.local" {
+		t.Fatalf("expected .env next to the compose file, got %q", env["DOMAIN"])
+	}
+
+	env, err = LoadEnv([]string{composeFile}, []string{envFile})
+	if err != nil {
+		t.Fatalf("load env: %v", err)
+	}
+	if env["DOMAIN"] != "prod.local" || env["TAG"] != "v1" {
+		t.Fatalf("expected env file values, got DOMAIN=%q TAG=%q", env["DOMAIN"], env["TAG"])
+	}
+	if env["ZTD_TEST_OVERRIDE"] != "process" {
+		t.Fatalf("expected process env to win, got %q", env["ZTD_TEST_OVERRIDE"])
+	}
+
+	if _, err := LoadEnv([]string{composeFile}, []string{filepath.Join(root, "missing.env")}); err == nil {
+		t.Fatal("expected error for missing env file")
+	}
+}
+
+func TestUnmarshalInterpolated_ValuesOnly(t *testing.T) {
+	data := []byte("services:\n  ${NAME}:\n    labels:\n      - traefik.http.routers.api.rule=Host(`${DOMAIN:-api.local}`)\n    ports:\n      - \"${PORT}:80\"\n")
+	var cfg struct {
+		Services map[string]struct {
+			Labels []string `yaml:"labels"`
+			Ports  []string `yaml:"ports"`
+		} `yaml:"services"`
+	}
+	if err := UnmarshalInterpolated(data, map[string]string{"NAME": "api", "PORT": "8080"}, &cfg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	svc, ok := cfg.Services["${NAME}"]
+	if !ok {
+		t.Fatalf("expected mapping keys to be left alone, got %v", cfg.Services)
+	}
+	if svc.Labels[0] != "traefik.http.routers.api.rule=Host(`api.local`)" || svc.Ports[0] != "8080:80" {
+		t.Fatalf("unexpected interpolated values: %#v", svc)
+	}
+}
@@ -0,0 +1,84 @@
+package compose
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// lineLogger is an io.Writer that re-emits compose output through a logger one line
+// at a time, so progress still streams live but shares the log format and level.
+type lineLogger struct {
+	mu     sync.Mutex
+	entry  *logrus.Entry
+	stderr bool
+	buf    bytes.Buffer
+}
+
+func newLineLogger(entry *logrus.Entry, stderr bool) *lineLogger {
+	return &lineLogger{entry: entry, stderr: stderr}
+}
+
+func (w *lineLogger) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf.Next(i + 1))
+		w.emit(line[:i])
+	}
+	return len(p), nil
+}
+
+// Flush logs a trailing line that was not terminated by a newline.
+func (w *lineLogger) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() > 0 {
+		w.emit(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+func (w *lineLogger) emit(line string) {
+	line = strings.TrimRight(line, "\r")
+	// Progress redraws separate frames with carriage returns; keep the last one.
+	if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if w.stderr && isWarningLine(line) {
+		w.entry.Warn(line)
+		return
+	}
+	w.entry.Info(line)
+}
+
+func isWarningLine(line string) bool {
+	lower := strings.ToLower(strings.TrimSpace(line))
+	return strings.HasPrefix(lower, "warn") || strings.Contains(lower, "level=warning")
+}
+
+// commandFields tags a compose invocation with its subcommand and, when the last
+// argument names one, the service it targets.
+func commandFields(composeArgs []string) logrus.Fields {
+	fields := logrus.Fields{}
+	if len(composeArgs) == 0 {
+		return fields
+	}
+	fields["command"] = composeArgs[0]
+	if last := composeArgs[len(composeArgs)-1]; len(composeArgs) > 1 && !strings.HasPrefix(last, "-") {
+		if prev := composeArgs[len(composeArgs)-2]; prev != "--scale" {
+			fields["service"] = last
+		}
+	}
+	return fields
+}
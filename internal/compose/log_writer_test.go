@@ -0,0 +1,56 @@
+package compose
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLineLogger_SplitsLinesAndLevels(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true, DisableQuote: true})
+
+	w := newLineLogger(log.WithFields(logrus.Fields{"command": "up", "service": "web"}), true)
+	_, _ = w.Write([]byte(" Container web-1  Creating\r\n Container web-1  Cre"))
+	_, _ = w.Write([]byte("ated\nWARN[0000] image platform mismatch\n\n"))
+	_, _ = w.Write([]byte(" Container web-1  Started"))
+	w.Flush()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 log lines, got %d: %q", len(lines), buf.String())
+	}
+	for i, want := range []string{"level=info", "level=info", "level=warning", "level=info"} {
+		if !strings.Contains(lines[i], want) {
+			t.Fatalf("line %d: expected %s, got %q", i, want, lines[i])
+		}
+		if !strings.Contains(lines[i], "command=up") || !strings.Contains(lines[i], "service=web") {
+			t.Fatalf("line %d: missing fields: %q", i, lines[i])
+		}
+	}
+	if !strings.Contains(lines[1], "Container web-1  Created") {
+		t.Fatalf("expected a line joined across writes, got %q", lines[1])
+	}
+}
+
+func TestCommandFields(t *testing.T) {
+	cases := []struct {
+		args []string
+		want logrus.Fields
+	}{
+		{[]string{"up", "--detach", "web"}, logrus.Fields{"command": "up", "service": "web"}},
+		{[]string{"up", "--detach"}, logrus.Fields{"command": "up"}},
+		{[]string{"up", "--scale", "web=2"}, logrus.Fields{"command": "up"}},
+		{nil, logrus.Fields{}},
+	}
+	for _, tc := range cases {
+		if got := commandFields(tc.args); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("commandFields(%v) = %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
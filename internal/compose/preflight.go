@@ -0,0 +1,137 @@
+package compose
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"gopkg.in/yaml.v3"
+)
+
+type containerNameFile struct {
+	Services map[string]struct {
+		ContainerName *containerName `yaml:"container_name"`
+	} `yaml:"services"`
+}
+
+// containerName treats `!reset` and null values as clearing the name set by an
+// earlier compose file.
+type containerName string
+
+func (c *containerName) UnmarshalYAML(node *yaml.Node) error {
+	if node.Tag == "!reset" || node.Tag == "!!null" {
+		*c = ""
+		return nil
+	}
+	var name string
+	if err := node.Decode(&name); err != nil {
+		return err
+	}
+	*c = containerName(name)
+	return nil
+}
+
+type fixedContainerName struct {
+	name string
+	file string
+}
+
+type servicesFile struct {
+	Services map[string]any `yaml:"services"`
+	Include  []any          `yaml:"include"`
+}
+
+// ValidateComposeFiles checks that every compose file can be read and parsed, and that
+// service is declared in at least one of them, so a typo fails before any container is
+// touched. The service check is skipped when a file includes other compose files.
+func ValidateComposeFiles(files []string, service string) error {
+	var errs []error
+	declared := false
+	includes := false
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("compose file %s: %w", file, err))
+			continue
+		}
+		var cfg servicesFile
+		if err := configio.UnmarshalYAML(data, &cfg); err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse compose file %s: %w", file, err))
+			continue
+		}
+		if _, ok := cfg.Services[service]; ok {
+			declared = true
+		}
+		if len(cfg.Include) > 0 {
+			includes = true
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if service != "" && !declared && !includes {
+		return fmt.Errorf("service %s is not declared under services: in %s", service, strings.Join(files, ", "))
+	}
+	return nil
+}
+
+// CheckScalable fails when service sets container_name, which prevents compose from
+// running a second replica. It returns the other services that set container_name so
+// callers can warn about them. Later files override earlier ones, and an empty value
+// such as `container_name: !reset null` clears the name.
+func CheckScalable(files []string, envFiles []string, service string) ([]string, error) {
+	names, err := fixedContainerNames(files, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	if fixed, ok := names[service]; ok {
+		return nil, fmt.Errorf("service %s sets container_name %q in %s; compose cannot start a second container with a fixed name, remove it to enable zero-downtime scaling", service, fixed.name, fixed.file)
+	}
+	others := make([]string, 0, len(names))
+	for name := range names {
+		others = append(others, name)
+	}
+	sort.Strings(others)
+	return others, nil
+}
+
+// ServiceContainerName returns the container_name service sets after overrides and
+// interpolation with the env files and the environment, or an empty string.
+func ServiceContainerName(files []string, envFiles []string, service string) (string, error) {
+	names, err := fixedContainerNames(files, envFiles)
+	if err != nil {
+		return "", err
+	}
+	return names[service].name, nil
+}
+
+func fixedContainerNames(files []string, envFiles []string) (map[string]fixedContainerName, error) {
+	env, err := LoadEnv(files, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]fixedContainerName{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var cfg containerNameFile
+		if err := UnmarshalInterpolated(data, env, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse compose file %s: %w", file, err)
+		}
+		for name, svc := range cfg.Services {
+			switch {
+			case svc.ContainerName == nil:
+			case *svc.ContainerName == "":
+				delete(names, name)
+			default:
+				names[name] = fixedContainerName{name: string(*svc.ContainerName), file: file}
+			}
+		}
+	}
+	return names, nil
+}
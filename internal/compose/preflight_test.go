@@ -0,0 +1,106 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckScalable(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "compose.yml")
+	data := `services:
+  api:
+    image: api:latest
+  db:
+    image: postgres:16
+    container_name: app-db
+`
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+
+	others, err := CheckScalable([]string{file}, nil, "api")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(others) != 1 || others[0] != "db" {
+		t.Fatalf("expected db to be reported, got %v", others)
+	}
+
+	_, err = CheckScalable([]string{file}, nil, "db")
+	if err == nil || !strings.Contains(err.Error(), "service db sets container_name") {
+		t.Fatalf("expected container_name error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), file) {
+		t.Fatalf("expected the declaring file in the error, got %v", err)
+	}
+}
+
+func TestCheckScalable_LaterFilesOverride(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "compose.yml")
+	override := filepath.Join(dir, "compose.override.yml")
+	if err := os.WriteFile(base, []byte("services:\n  api:\n    container_name: api\n  db:\n    image: postgres:16\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	if err := os.WriteFile(override, []byte("services:\n  api:\n    container_name: !reset null\n  db:\n    container_name: app-db\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+
+	others, err := CheckScalable([]string{base, override}, nil, "api")
+	if err != nil {
+		t.Fatalf("expected reset container_name to allow scaling, got %v", err)
+	}
+	if len(others) != 1 || others[0] != "db" {
+		t.Fatalf("expected db from the override file to be reported, got %v", others)
+	}
+	_, err = CheckScalable([]string{base, override}, nil, "db")
+	if err == nil || !strings.Contains(err.Error(), override) {
+		t.Fatalf("expected container_name error naming the override file, got %v", err)
+	}
+}
+
+func TestValidateComposeFiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "compose.yml")
+	override := filepath.Join(dir, "compose.override.yml")
+	broken := filepath.Join(dir, "broken.yml")
+	for path, data := range map[string]string{
+		base:     "services:\n  api:\n    image: api:latest\n",
+		override: "services:\n  worker:\n    image: worker:latest\n",
+		broken:   "services: [\n",
+	} {
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("write compose file: %v", err)
+		}
+	}
+
+	if err := ValidateComposeFiles([]string{base, override}, "worker"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ValidateComposeFiles([]string{base}, ""); err != nil {
+		t.Fatalf("expected no service check without a service, got %v", err)
+	}
+
+	missing := filepath.Join(dir, "missing.yml")
+	err := ValidateComposeFiles([]string{missing, base, broken}, "api")
+	if err == nil || !strings.Contains(err.Error(), missing) || !strings.Contains(err.Error(), broken) {
+		t.Fatalf("expected every offending file to be listed, got %v", err)
+	}
+
+	err = ValidateComposeFiles([]string{base, override}, "apii")
+	if err == nil || !strings.Contains(err.Error(), "service apii is not declared") {
+		t.Fatalf("expected undeclared service error, got %v", err)
+	}
+}
+
+func TestValidateComposeFiles_SkipsServiceCheckWithInclude(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "compose.yml")
+	if err := os.WriteFile(file, []byte("include:\n  - services/api.yml\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	if err := ValidateComposeFiles([]string{file}, "api"); err != nil {
+		t.Fatalf("expected included services to be trusted, got %v", err)
+	}
+}
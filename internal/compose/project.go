@@ -0,0 +1,57 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type projectNameFile struct {
+	Name string `yaml:"name"`
+}
+
+// ProjectName derives the compose project name the way docker compose does: explicit
+// (--project or COMPOSE_PROJECT_NAME) first, then the top-level name of the last compose
+// file that sets one, then the base name of the project directory, which defaults to
+// the directory of the first compose file. The name is interpolated with the env files
+// and the process environment; it returns an empty string when that fails.
+func ProjectName(files []string, envFiles []string, projectDirectory string, explicit string) string {
+	if explicit = strings.TrimSpace(explicit); explicit != "" {
+		return NormalizeProjectName(explicit)
+	}
+
+	env, err := LoadEnv(files, envFiles)
+	if err != nil {
+		return ""
+	}
+	name := ""
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var cfg projectNameFile
+		if err := UnmarshalInterpolated(data, env, &cfg); err != nil {
+			return ""
+		}
+		if value := strings.TrimSpace(cfg.Name); value != "" {
+			name = value
+		}
+	}
+	if name != "" {
+		return NormalizeProjectName(name)
+	}
+
+	dir := projectDirectory
+	if dir == "" && len(files) > 0 {
+		dir = filepath.Dir(files[0])
+	}
+	if dir == "" {
+		dir = "."
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	return NormalizeProjectName(filepath.Base(abs))
+}
@@ -0,0 +1,56 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeComposeFile(t *testing.T, path string, data string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	return path
+}
+
+func TestProjectName_FromFirstFileDirectory(t *testing.T) {
+	root := t.TempDir()
+	base := writeComposeFile(t, filepath.Join(root, "My.Shop", "docker-compose.prod.yml"), "services:\n  api:\n    image: api\n")
+	override := writeComposeFile(t, filepath.Join(root, "overrides", "compose.override.yml"), "services:\n  api:\n    environment: {}\n")
+
+	if got := ProjectName([]string{base, override}, nil, "", ""); got != "myshop" {
+		t.Fatalf("expected directory of the first file, got %q", got)
+	}
+	if got := ProjectName([]string{base, override}, nil, filepath.Join(root, "overrides"), ""); got != "overrides" {
+		t.Fatalf("expected --project-directory to win, got %q", got)
+	}
+}
+
+func TestProjectName_CustomName(t *testing.T) {
+	root := t.TempDir()
+	base := writeComposeFile(t, filepath.Join(root, "app", "compose.yml"), "name: shop\nservices:\n  api:\n    image: api\n")
+	override := writeComposeFile(t, filepath.Join(root, "app", "compose.prod.yml"), "name: shop-prod\n")
+
+	if got := ProjectName([]string{base}, nil, "", ""); got != "shop" {
+		t.Fatalf("expected top-level name, got %q", got)
+	}
+	if got := ProjectName([]string{base, override}, nil, "", ""); got != "shop-prod" {
+		t.Fatalf("expected the last file's name to win, got %q", got)
+	}
+	if got := ProjectName([]string{base, override}, nil, "", "Billing"); got != "billing" {
+		t.Fatalf("expected the explicit name to win, got %q", got)
+	}
+
+	interpolated := writeComposeFile(t, filepath.Join(root, "app", "compose.env.yml"), "name: ${PROJECT:-shop}-${STAGE:?stage is required}\n")
+	envFile := writeComposeFile(t, filepath.Join(root, "app", "staging.env"), "STAGE=staging\n")
+	if got := ProjectName([]string{base, interpolated}, []string{envFile}, "", ""); got != "shop-staging" {
+		t.Fatalf("expected the interpolated name, got %q", got)
+	}
+	if got := ProjectName([]string{base, interpolated}, nil, "", ""); got != "" {
+		t.Fatalf("expected a name that fails to interpolate to be unknown, got %q", got)
+	}
+}
@@ -0,0 +1,104 @@
+package compose
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ResourceLimits are the per-container limits a compose service declares.
+// Zero means no limit was set.
+type ResourceLimits struct {
+	MemoryBytes int64
+	NanoCPUs    int64
+}
+
+type resourceFile struct {
+	Services map[string]struct {
+		MemLimit any `yaml:"mem_limit"`
+		CPUs     any `yaml:"cpus"`
+		Deploy   struct {
+			Resources struct {
+				Limits struct {
+					Memory any `yaml:"memory"`
+					CPUs   any `yaml:"cpus"`
+				} `yaml:"limits"`
+			} `yaml:"resources"`
+		} `yaml:"deploy"`
+	} `yaml:"services"`
+}
+
+// ServiceResourceLimits reads mem_limit/cpus and deploy.resources.limits for service.
+// deploy.resources.limits wins over the legacy keys, and later files override earlier ones.
+func ServiceResourceLimits(files []string, envFiles []string, service string) (ResourceLimits, error) {
+	env, err := LoadEnv(files, envFiles)
+	if err != nil {
+		return ResourceLimits{}, err
+	}
+	var limits ResourceLimits
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return ResourceLimits{}, err
+		}
+		var cfg resourceFile
+		if err := UnmarshalInterpolated(data, env, &cfg); err != nil {
+			return ResourceLimits{}, fmt.Errorf("failed to parse compose file %s: %w", file, err)
+		}
+		svc, ok := cfg.Services[service]
+		if !ok {
+			continue
+		}
+		for _, raw := range []any{svc.MemLimit, svc.Deploy.Resources.Limits.Memory} {
+			if raw == nil {
+				continue
+			}
+			memory, err := ParseMemory(fmt.Sprint(raw))
+			if err != nil {
+				return ResourceLimits{}, fmt.Errorf("service %s: %w", service, err)
+			}
+			limits.MemoryBytes = memory
+		}
+		for _, raw := range []any{svc.CPUs, svc.Deploy.Resources.Limits.CPUs} {
+			if raw == nil {
+				continue
+			}
+			cpus, err := strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(raw)), 64)
+			if err != nil {
+				return ResourceLimits{}, fmt.Errorf("service %s: invalid cpus %v", service, raw)
+			}
+			limits.NanoCPUs = int64(math.Round(cpus * 1e9))
+		}
+	}
+	return limits, nil
+}
+
+// ParseMemory parses compose memory values such as 512m, 1.5g, 1GB or plain bytes.
+func ParseMemory(value string) (int64, error) {
+	raw := strings.ToLower(strings.TrimSpace(value))
+	raw = strings.TrimSuffix(raw, "ib")
+	raw = strings.TrimSuffix(raw, "b")
+	multiplier := float64(1)
+	if n := len(raw); n > 0 {
+		switch raw[n-1] {
+		case 'k':
+			multiplier = 1 << 10
+		case 'm':
+			multiplier = 1 << 20
+		case 'g':
+			multiplier = 1 << 30
+		case 't':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			raw = raw[:n-1]
+		}
+	}
+	number, err := strconv.ParseFloat(raw, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid memory value %q", value)
+	}
+	return int64(number * multiplier), nil
+}
@@ -0,0 +1,82 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMemory(t *testing.T) {
+	cases := map[string]int64{
+		"1024":  1024,
+		"512b":  512,
+		"512m":  512 << 20,
+		"512M":  512 << 20,
+		"1.5g":  3 << 29,
+		"2GB":   2 << 30,
+		"1GiB":  1 << 30,
+		"64k":   64 << 10,
+		" 1g  ": 1 << 30,
+	}
+	for input, want := range cases {
+		got, err := ParseMemory(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", input, err)
+		}
+		if got != want {
+			t.Fatalf("%q: expected %d, got %d", input, want, got)
+		}
+	}
+	if _, err := ParseMemory("lots"); err == nil {
+		t.Fatal("expected invalid memory value to fail")
+	}
+}
+
+func TestServiceResourceLimits(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "compose.yml")
+	override := filepath.Join(dir, "compose.override.yml")
+	if err := os.WriteFile(base, []byte(`services:
+  api:
+    mem_limit: 256m
+    cpus: 0.25
+  worker:
+    image: worker
+`), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	if err := os.WriteFile(override, []byte(`services:
+  api:
+    deploy:
+      resources:
+        limits:
+          memory: 1G
+          cpus: "1.5"
+`), 0o644); err != nil {
+		t.Fatalf("write override file: %v", err)
+	}
+
+	limits, err := ServiceResourceLimits([]string{base}, nil, "api")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limits.MemoryBytes != 256<<20 || limits.NanoCPUs != 250000000 {
+		t.Fatalf("unexpected legacy limits: %+v", limits)
+	}
+
+	limits, err = ServiceResourceLimits([]string{base, override}, nil, "api")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limits.MemoryBytes != 1<<30 || limits.NanoCPUs != 1500000000 {
+		t.Fatalf("expected deploy limits to win, got %+v", limits)
+	}
+
+	limits, err = ServiceResourceLimits([]string{base}, nil, "worker")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if limits != (ResourceLimits{}) {
+		t.Fatalf("expected no limits for worker, got %+v", limits)
+	}
+}
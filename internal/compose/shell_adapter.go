@@ -8,26 +8,85 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/retry"
+	"github.com/sirupsen/logrus"
 )
 
+// commandWaitDelay is how long an interrupted compose command gets to exit before it
+// is killed.
+const commandWaitDelay = 10 * time.Second
+
 type ShellAdapter struct {
-	commandPrefix []string
+	commandPrefix    []string
+	projectDirectory string
+	projectName      string
+	maxRetries       int
+	removeOrphans    bool
+	dryRun           bool
+	env              []string
+	log              *logrus.Logger
 }
 
 func NewShellAdapter(dockerArgs []string) (*ShellAdapter, error) {
 	if isCommandAvailable("docker", append(append([]string{}, dockerArgs...), "compose", "version")...) {
 		prefix := append([]string{"docker"}, dockerArgs...)
 		prefix = append(prefix, "compose")
-		return &ShellAdapter{commandPrefix: prefix}, nil
+		return &ShellAdapter{commandPrefix: prefix, maxRetries: retry.DefaultMaxRetries}, nil
 	}
 
 	if isCommandAvailable("docker-compose", "version") {
-		return &ShellAdapter{commandPrefix: []string{"docker-compose"}}, nil
+		return &ShellAdapter{commandPrefix: []string{"docker-compose"}, maxRetries: retry.DefaultMaxRetries, env: dockerEnv(dockerArgs)}, nil
 	}
 
 	return nil, fmt.Errorf("docker compose or docker-compose is required")
 }
 
+// WithProjectDirectory passes --project-directory to every compose invocation so relative
+// build contexts, volumes and the project name resolve from dir instead of the CWD.
+func (s *ShellAdapter) WithProjectDirectory(dir string) *ShellAdapter {
+	s.projectDirectory = dir
+	return s
+}
+
+// WithProjectName passes --project-name to every compose invocation.
+func (s *ShellAdapter) WithProjectName(name string) *ShellAdapter {
+	s.projectName = name
+	return s
+}
+
+// WithMaxRetries sets how many times a read-only compose command (ps, config) failing
+// with a transient daemon error is retried; 0 disables retries. Commands that change
+// containers are never retried.
+func (s *ShellAdapter) WithMaxRetries(n int) *ShellAdapter {
+	s.maxRetries = n
+	return s
+}
+
+// WithLogger re-emits the output of up, scale and recreate through log line by line,
+// tagged with the compose command and service, instead of writing it to stdout.
+func (s *ShellAdapter) WithLogger(log *logrus.Logger) *ShellAdapter {
+	s.log = log
+	return s
+}
+
+// WithRemoveOrphans adds --remove-orphans to a full-project up (no service, recreate
+// allowed). Per-service and --no-recreate ups never get it, since removing orphans there
+// would stop containers the deploy does not own.
+func (s *ShellAdapter) WithRemoveOrphans(remove bool) *ShellAdapter {
+	s.removeOrphans = remove
+	return s
+}
+
+// WithDryRun makes up, scale, recreate and logs only log the command they would run.
+// Read-only commands such as ps and config still run, so a dry run sees the real
+// containers.
+func (s *ShellAdapter) WithDryRun(dryRun bool) *ShellAdapter {
+	s.dryRun = dryRun
+	return s
+}
+
 func (s *ShellAdapter) Up(ctx context.Context, files []string, envFiles []string, service string, detached bool, noRecreate bool) error {
 	args := []string{"up"}
 	if detached {
@@ -36,14 +95,26 @@ func (s *ShellAdapter) Up(ctx context.Context, files []string, envFiles []string
 	if noRecreate {
 		args = append(args, "--no-recreate")
 	}
+	if s.removeOrphans && service == "" && !noRecreate {
+		args = append(args, "--remove-orphans")
+	}
 	if service != "" {
 		args = append(args, service)
 	}
-	return s.run(ctx, files, envFiles, args...)
+	return s.runLogged(ctx, files, envFiles, args...)
 }
 
 func (s *ShellAdapter) Scale(ctx context.Context, files []string, envFiles []string, service string, replicas int) error {
-	return s.run(ctx, files, envFiles, "up", "--detach", "--scale", service+"="+strconv.Itoa(replicas), "--no-recreate", service)
+	return s.runLogged(ctx, files, envFiles, "up", "--detach", "--scale", service+"="+strconv.Itoa(replicas), "--no-recreate", service)
+}
+
+// Recreate runs up --force-recreate for service only, leaving its dependencies alone.
+func (s *ShellAdapter) Recreate(ctx context.Context, files []string, envFiles []string, service string, replicas int) error {
+	args := []string{"up", "--detach", "--force-recreate", "--no-deps"}
+	if replicas > 0 {
+		args = append(args, "--scale", service+"="+strconv.Itoa(replicas))
+	}
+	return s.runLogged(ctx, files, envFiles, append(args, service)...)
 }
 
 func (s *ShellAdapter) PsQuiet(ctx context.Context, files []string, envFiles []string, service string) ([]string, error) {
@@ -68,6 +139,34 @@ func (s *ShellAdapter) PsQuiet(ctx context.Context, files []string, envFiles []s
 	return ids, nil
 }
 
+func (s *ShellAdapter) ServiceImage(ctx context.Context, files []string, envFiles []string, service string) (string, error) {
+	out, err := s.output(ctx, files, envFiles, "config", "--images", service)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+	}
+	return "", fmt.Errorf("no image resolved for service %s", service)
+}
+
+// ConfigHash returns the hash docker compose computes for the resolved config of service.
+func (s *ShellAdapter) ConfigHash(ctx context.Context, files []string, envFiles []string, service string) (string, error) {
+	out, err := s.output(ctx, files, envFiles, "config", "--hash", service)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == service {
+			return fields[1], nil
+		}
+	}
+	return "", fmt.Errorf("no config hash resolved for service %s", service)
+}
+
 func (s *ShellAdapter) LogsFollowTail(ctx context.Context, files []string, service string, tail int) error {
 	args := []string{"logs", "--follow", "--tail=" + strconv.Itoa(tail)}
 	if service != "" {
@@ -78,27 +177,166 @@ func (s *ShellAdapter) LogsFollowTail(ctx context.Context, files []string, servi
 
 func (s *ShellAdapter) run(ctx context.Context, files []string, envFiles []string, composeArgs ...string) error {
 	allArgs := s.buildComposeArgs(files, envFiles, composeArgs...)
-	cmd := exec.CommandContext(ctx, allArgs[0], allArgs[1:]...)
+	if s.skipDryRun(allArgs) {
+		return nil
+	}
+	cmd := s.command(ctx, allArgs)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
 
+// runLogged runs a compose command that changes containers, streaming its output
+// through the logger when one is set.
+func (s *ShellAdapter) runLogged(ctx context.Context, files []string, envFiles []string, composeArgs ...string) error {
+	if s.log == nil {
+		return s.run(ctx, files, envFiles, composeArgs...)
+	}
+	allArgs := s.buildComposeArgs(files, envFiles, composeArgs...)
+	if s.skipDryRun(allArgs) {
+		return nil
+	}
+	entry := s.log.WithFields(commandFields(composeArgs))
+	stdout := newLineLogger(entry, false)
+	stderr := newLineLogger(entry, true)
+	cmd := s.command(ctx, allArgs)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+	return err
+}
+
+// skipDryRun logs allArgs and reports true when the adapter is in dry-run mode, so
+// the caller does not run it.
+func (s *ShellAdapter) skipDryRun(allArgs []string) bool {
+	if !s.dryRun {
+		return false
+	}
+	log := s.log
+	if log == nil {
+		log = logrus.StandardLogger()
+	}
+	log.Infof("==> [dry-run] Would run %s", quoteCommand(allArgs))
+	return true
+}
+
 func (s *ShellAdapter) output(ctx context.Context, files []string, envFiles []string, composeArgs ...string) (string, error) {
 	allArgs := s.buildComposeArgs(files, envFiles, composeArgs...)
-	cmd := exec.CommandContext(ctx, allArgs[0], allArgs[1:]...)
-	out, err := cmd.CombinedOutput()
+	var out []byte
+	err := retry.Do(ctx, s.maxRetries, func() error {
+		var err error
+		out, err = s.command(ctx, allArgs).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		return "", err
 	}
 	return string(out), nil
 }
 
+// commandContext builds a compose command that is interrupted when ctx is done, so
+// compose can stop what it started, and killed if it has not exited after
+// commandWaitDelay. WaitDelay also keeps Wait from blocking on output pipes held open
+// by plugin subprocesses.
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
+// dockerEnv translates the docker --host and --context arguments into DOCKER_HOST and
+// DOCKER_CONTEXT for the standalone docker-compose, which does not take docker's
+// global flags.
+func dockerEnv(dockerArgs []string) []string {
+	var env []string
+	for i := 0; i < len(dockerArgs); i++ {
+		arg := dockerArgs[i]
+		name, value, inline := strings.Cut(arg, "=")
+		var key string
+		switch name {
+		case "-H", "--host":
+			key = "DOCKER_HOST"
+		case "-c", "--context":
+			key = "DOCKER_CONTEXT"
+		default:
+			continue
+		}
+		if !inline {
+			if i+1 >= len(dockerArgs) {
+				break
+			}
+			i++
+			value = dockerArgs[i]
+		}
+		env = append(env, key+"="+value)
+	}
+	return env
+}
+
+// command builds a compose command with the adapter's extra environment.
+func (s *ShellAdapter) command(ctx context.Context, allArgs []string) *exec.Cmd {
+	cmd := commandContext(ctx, allArgs[0], allArgs[1:]...)
+	if len(s.env) > 0 {
+		cmd.Env = append(os.Environ(), s.env...)
+	}
+	if s.log != nil && s.log.IsLevelEnabled(logrus.DebugLevel) {
+		s.logCommand(allArgs)
+	}
+	return cmd
+}
+
+// logCommand logs the full command line about to run, with the working directory and
+// the DOCKER_* and COMPOSE_* variables it sees, for --verbose.
+func (s *ShellAdapter) logCommand(allArgs []string) {
+	dir, err := os.Getwd()
+	if err != nil {
+		dir = "?"
+	}
+	var env []string
+	for _, kv := range append(os.Environ(), s.env...) {
+		if strings.HasPrefix(kv, "DOCKER_") || strings.HasPrefix(kv, "COMPOSE_") {
+			env = append(env, kv)
+		}
+	}
+	entry := s.log.WithField("dir", dir)
+	if len(env) > 0 {
+		entry = entry.WithField("env", strings.Join(env, " "))
+	}
+	entry.Debugf("==> Running %s", quoteCommand(allArgs))
+}
+
+// quoteCommand joins args into a line that can be pasted into a shell.
+func quoteCommand(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`*?!;&|<>()[]{}#~") {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
 func (s *ShellAdapter) buildComposeArgs(files []string, envFiles []string, composeArgs ...string) []string {
 	cmd := append([]string{}, s.commandPrefix...)
 	for _, f := range files {
 		cmd = append(cmd, "-f", f)
 	}
+	if s.projectDirectory != "" {
+		cmd = append(cmd, "--project-directory", s.projectDirectory)
+	}
+	if s.projectName != "" {
+		cmd = append(cmd, "--project-name", s.projectName)
+	}
 	for _, env := range envFiles {
 		cmd = append(cmd, "--env-file", env)
 	}
@@ -0,0 +1,34 @@
+package compose
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// BufferStdin copies a compose file read from r (-f -) into a temporary file in dir,
+// so the compose CLI and the config generator can read it like any other compose file
+// and relative paths in it resolve against dir. The caller removes the file.
+func BufferStdin(r io.Reader, dir string) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("read compose file from stdin: %w", err)
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("read compose file from stdin: input is empty")
+	}
+	f, err := os.CreateTemp(dir, ".ztd-stdin-*.yml")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,45 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBufferStdin(t *testing.T) {
+	dir := t.TempDir()
+	content := "services:\n  api:\n    image: nginx:1.27\n"
+
+	path, err := BufferStdin(strings.NewReader(content), dir)
+	if err != nil {
+		t.Fatalf("BufferStdin() error = %v", err)
+	}
+	if filepath.Dir(path) != dir {
+		t.Fatalf("expected file in %s, got %s", dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read buffered file: %v", err)
+	}
+	if string(data) != content {
+		t.Fatalf("unexpected content:\n%s", data)
+	}
+	if err := ValidateComposeFiles([]string{path}, "api"); err != nil {
+		t.Fatalf("expected buffered file to validate: %v", err)
+	}
+}
+
+func TestBufferStdin_Empty(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := BufferStdin(strings.NewReader(""), dir); err == nil {
+		t.Fatal("expected error for empty stdin")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected no file left behind, got %d", len(entries))
+	}
+}
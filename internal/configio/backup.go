@@ -0,0 +1,108 @@
+package configio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ErrConfigChanged is returned by RestoreConfig when the config was modified after
+// the backup owner wrote it, so restoring would drop someone else's change.
+var ErrConfigChanged = errors.New("config changed since it was written")
+
+// ConfigBackup is a copy of a proxy config file taken before it is modified. The
+// copy lives next to the file with a .bak suffix, so neither Traefik's file provider
+// nor a *.cfg/*.conf include loads it, and it can be renamed back atomically.
+type ConfigBackup struct {
+	path       string
+	backupPath string
+	written    []byte
+}
+
+// BackupConfig copies the config at path. A missing file yields a backup that
+// restores nothing.
+func BackupConfig(path string) (*ConfigBackup, error) {
+	Mu.Lock()
+	defer Mu.Unlock()
+
+	src, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &ConfigBackup{path: path}, nil
+		}
+		return nil, err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.bak")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+		return nil, err
+	}
+	if err := dst.Chmod(info.Mode().Perm()); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+		return nil, err
+	}
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(dst.Name())
+		return nil, err
+	}
+	return &ConfigBackup{path: path, backupPath: dst.Name()}, nil
+}
+
+// MarkWritten records the config as the backup owner left it. RestoreConfig then
+// refuses to restore once anyone else has changed the file.
+func (b *ConfigBackup) MarkWritten() error {
+	Mu.Lock()
+	defer Mu.Unlock()
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return err
+	}
+	b.written = data
+	return nil
+}
+
+// RestoreConfig moves the backup back over the config file, consuming the backup.
+func RestoreConfig(b *ConfigBackup) error {
+	if b == nil || b.backupPath == "" {
+		return nil
+	}
+	Mu.Lock()
+	defer Mu.Unlock()
+	if b.written != nil {
+		current, err := os.ReadFile(b.path)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(current, b.written) {
+			return ErrConfigChanged
+		}
+	}
+	if err := os.Rename(b.backupPath, b.path); err != nil {
+		return err
+	}
+	b.backupPath = ""
+	return nil
+}
+
+// Discard removes the backup copy. It is a no-op once the backup was restored.
+func (b *ConfigBackup) Discard() error {
+	if b == nil || b.backupPath == "" {
+		return nil
+	}
+	err := os.Remove(b.backupPath)
+	b.backupPath = ""
+	return err
+}
@@ -0,0 +1,94 @@
+package configio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupConfig_RestoreAndDiscard(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dynamic_conf.yml")
+	if err := os.WriteFile(path, []byte("before\n"), 0o640); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	backup, err := BackupConfig(path)
+	if err != nil {
+		t.Fatalf("backup config: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("after\n"), 0o640); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := RestoreConfig(backup); err != nil {
+		t.Fatalf("restore config: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if string(data) != "before\n" {
+		t.Fatalf("expected backup content, got %q", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat config: %v", err)
+	}
+	if info.Mode().Perm() != 0o640 {
+		t.Fatalf("expected mode to be kept, got %v", info.Mode().Perm())
+	}
+	if err := backup.Discard(); err != nil {
+		t.Fatalf("discard after restore: %v", err)
+	}
+
+	backup, err = BackupConfig(path)
+	if err != nil {
+		t.Fatalf("backup config: %v", err)
+	}
+	if err := backup.Discard(); err != nil {
+		t.Fatalf("discard: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only the config to remain, got %d entries", len(entries))
+	}
+}
+
+func TestRestoreConfig_RefusesConcurrentChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	if err := os.WriteFile(path, []byte("before\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	backup, err := BackupConfig(path)
+	if err != nil {
+		t.Fatalf("backup config: %v", err)
+	}
+	defer backup.Discard()
+
+	if err := os.WriteFile(path, []byte("mine\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := backup.MarkWritten(); err != nil {
+		t.Fatalf("mark written: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("mine\nother deploy\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := RestoreConfig(backup); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("expected ErrConfigChanged, got %v", err)
+	}
+}
+
+func TestBackupConfig_MissingFile(t *testing.T) {
+	backup, err := BackupConfig(filepath.Join(t.TempDir(), "missing.yml"))
+	if err != nil {
+		t.Fatalf("backup config: %v", err)
+	}
+	if err := RestoreConfig(backup); err != nil {
+		t.Fatalf("expected restore of a missing file to be a no-op, got %v", err)
+	}
+}
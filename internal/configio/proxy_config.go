@@ -0,0 +1,21 @@
+package configio
+
+import (
+	"strings"
+	"sync"
+)
+
+// Mu serializes read-modify-write cycles on the proxy configs when services are
+// deployed in parallel. The Traefik, nginx and HAProxy writers and ConfigBackup all
+// hold it, so a backup or restore never interleaves with an update.
+var Mu sync.Mutex
+
+// ShortID returns the 12 character form of a container ID that the generated proxy
+// configs use as server name and host.
+func ShortID(id string) string {
+	id = strings.TrimSpace(id)
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
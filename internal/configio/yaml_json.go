@@ -2,8 +2,10 @@ package configio
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"syscall"
 
 	"gopkg.in/yaml.v3"
 )
@@ -20,6 +22,9 @@ func MarshalJSON(v any) ([]byte, error) {
 	return json.Marshal(v)
 }
 
+// WriteAtomic writes data to a temp file next to path and renames it into place, so a
+// watcher such as Traefik never reads a truncated file. An existing file keeps its
+// owner when the process is allowed to set it.
 func WriteAtomic(path string, data []byte, mode os.FileMode) error {
 	dir := filepath.Dir(path)
 	tmp, err := os.CreateTemp(dir, "ztd-*.tmp")
@@ -36,8 +41,31 @@ func WriteAtomic(path string, data []byte, mode os.FileMode) error {
 		_ = tmp.Close()
 		return err
 	}
+	if info, err := os.Stat(path); err == nil {
+		if err := preserveOwner(tmp, info); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
 	if err := tmp.Close(); err != nil {
 		return err
 	}
 	return os.Rename(tmp.Name(), path)
 }
+
+// preserveOwner gives f the owner and group of the file it replaces. Without the
+// privilege to do so, e.g. when not running as root, the file keeps the caller's owner.
+func preserveOwner(f *os.File, info os.FileInfo) error {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || (int(st.Uid) == os.Geteuid() && int(st.Gid) == os.Getegid()) {
+		return nil
+	}
+	if err := f.Chown(int(st.Uid), int(st.Gid)); err != nil && !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package configio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAtomic_ReplacesFileWithMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dynamic_conf.yml")
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	if err := WriteAtomic(path, []byte("new"), 0o644); err != nil {
+		t.Fatalf("write atomic: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "new" {
+		t.Fatalf("expected replaced content, got %q (%v)", data, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o644 {
+		t.Fatalf("expected mode 0644, got %v", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected no temp files left behind, got %d entries", len(entries))
+	}
+}
+
+func TestWriteAtomic_MissingDirectory(t *testing.T) {
+	if err := WriteAtomic(filepath.Join(t.TempDir(), "missing", "conf.yml"), []byte("x"), 0o644); err == nil {
+		t.Fatal("expected error for missing directory")
+	}
+}
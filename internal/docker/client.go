@@ -3,17 +3,55 @@ package docker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/retry"
 )
 
 type Client struct {
-	dockerArgs []string
+	dockerArgs      []string
+	stopConcurrency int
+	stopTimeout     int
+	maxRetries      int
+	network         string
+	dryRun          *logrus.Logger
+	runner          CommandRunner
+}
+
+type ContainerState struct {
+	Status    string    `json:"Status"`
+	Running   bool      `json:"Running"`
+	ExitCode  int       `json:"ExitCode"`
+	StartedAt time.Time `json:"StartedAt"`
 }
 
 func NewClient(dockerArgs []string) *Client {
-	return &Client{dockerArgs: append([]string{}, dockerArgs...)}
+	return &Client{dockerArgs: append([]string{}, dockerArgs...), stopConcurrency: cli.DefaultStopConcurrency, maxRetries: retry.DefaultMaxRetries, runner: execRunner{}}
+}
+
+// WithMaxRetries sets how many times a docker command failing with a transient daemon
+// error is retried; 0 disables retries.
+func (c *Client) WithMaxRetries(n int) *Client {
+	c.maxRetries = n
+	return c
+}
+
+// WithStopConcurrency sets how many containers Stop and Remove handle at once; n <= 0
+// keeps cli.DefaultStopConcurrency.
+func (c *Client) WithStopConcurrency(n int) *Client {
+	if n > 0 {
+		c.stopConcurrency = n
+	}
+	return c
 }
 
 func (c *Client) HealthStatus(ctx context.Context, containerID string) (string, error) {
@@ -27,6 +65,18 @@ func (c *Client) HealthStatus(ctx context.Context, containerID string) (string,
 	return status, nil
 }
 
+func (c *Client) State(ctx context.Context, containerID string) (ContainerState, error) {
+	out, err := c.inspect(ctx, "{{json .State}}", containerID)
+	if err != nil {
+		return ContainerState{}, err
+	}
+	var st ContainerState
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &st); err != nil {
+		return ContainerState{}, err
+	}
+	return st, nil
+}
+
 func (c *Client) HasHealthcheck(ctx context.Context, containerID string) (bool, error) {
 	out, err := c.inspect(ctx, "{{json .State.Health}}", containerID)
 	if err != nil {
@@ -41,6 +91,54 @@ func (c *Client) HasHealthcheck(ctx context.Context, containerID string) (bool,
 	return ok, nil
 }
 
+// Ping checks that the Docker engine selected by the client's docker arguments answers.
+func (c *Client) Ping(ctx context.Context) error {
+	args := append([]string{}, c.dockerArgs...)
+	args = append(args, "version", "--format", "{{.Server.Version}}")
+	if _, err := c.combinedOutput(ctx, args...); err != nil {
+		return fmt.Errorf("cannot reach the Docker engine: %w", err)
+	}
+	return nil
+}
+
+// HealthcheckStartPeriod returns the start_period of the container's healthcheck, or 0
+// when it has none or sets no start period.
+func (c *Client) HealthcheckStartPeriod(ctx context.Context, containerID string) (time.Duration, error) {
+	out, err := c.inspect(ctx, "{{if .Config.Healthcheck}}{{.Config.Healthcheck.StartPeriod}}{{end}}", containerID)
+	if err != nil {
+		return 0, err
+	}
+	raw := strings.TrimSpace(out)
+	if raw == "" || raw == "<no value>" {
+		return 0, nil
+	}
+	nanos, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse healthcheck start period of %s: %w", containerID, err)
+	}
+	return time.Duration(nanos), nil
+}
+
+// ExposedPorts returns the ports containerID exposes, e.g. "8080/tcp", sorted.
+func (c *Client) ExposedPorts(ctx context.Context, containerID string) ([]string, error) {
+	out, err := c.inspect(ctx, "{{json .Config.ExposedPorts}}", containerID)
+	if err != nil {
+		return nil, err
+	}
+	var exposed map[string]struct{}
+	if raw := strings.TrimSpace(out); raw != "" && raw != "null" {
+		if err := json.Unmarshal([]byte(raw), &exposed); err != nil {
+			return nil, fmt.Errorf("parse exposed ports of %s: %w", containerID, err)
+		}
+	}
+	ports := make([]string, 0, len(exposed))
+	for port := range exposed {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	return ports, nil
+}
+
 func (c *Client) Labels(ctx context.Context, containerID string) (map[string]string, error) {
 	out, err := c.inspect(ctx, "{{json .Config.Labels}}", containerID)
 	if err != nil {
@@ -53,53 +151,241 @@ func (c *Client) Labels(ctx context.Context, containerID string) (map[string]str
 	return labels, nil
 }
 
+// ContainersByLabel returns the full IDs of the running containers labelled key=value,
+// or of every running container carrying key when value is empty.
+func (c *Client) ContainersByLabel(ctx context.Context, key string, value string) ([]string, error) {
+	filter := "label=" + key
+	if value != "" {
+		filter += "=" + value
+	}
+	out, err := c.output(ctx, "ps", "-q", "--no-trunc", "--filter", filter)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// ContainerByName returns the full ID of the running container named name, or an empty
+// string when there is none.
+func (c *Client) ContainerByName(ctx context.Context, name string) (string, error) {
+	out, err := c.output(ctx, "ps", "-q", "--no-trunc", "--filter", "name=^/"+name+"$")
+	if err != nil {
+		return "", err
+	}
+	if ids := strings.Fields(out); len(ids) > 0 {
+		return ids[0], nil
+	}
+	return "", nil
+}
+
+func (c *Client) ImageLabels(ctx context.Context, image string) (map[string]string, error) {
+	args := append([]string{}, c.dockerArgs...)
+	args = append(args, "image", "inspect", "--format={{json .Config.Labels}}", image)
+	out, err := c.combinedOutput(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// ImageID returns the local ID of image, e.g. sha256:...
+func (c *Client) ImageID(ctx context.Context, image string) (string, error) {
+	out, err := c.output(ctx, "image", "inspect", "--format={{.Id}}", image)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// ContainerImageID returns the ID of the image the container was created from.
+func (c *Client) ContainerImageID(ctx context.Context, containerID string) (string, error) {
+	out, err := c.inspect(ctx, "{{.Image}}", containerID)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// WithNetwork makes IPAddress return the container's address on network, either the
+// full docker network name or the compose network name without the project prefix.
+func (c *Client) WithNetwork(network string) *Client {
+	c.network = network
+	return c
+}
+
+// NetworkIPs returns the IP address of the container on each network it is attached to.
+func (c *Client) NetworkIPs(ctx context.Context, containerID string) (map[string]string, error) {
+	out, err := c.inspect(ctx, "{{json .NetworkSettings.Networks}}", containerID)
+	if err != nil {
+		return nil, err
+	}
+	var networks map[string]struct {
+		IPAddress string `json:"IPAddress"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &networks); err != nil {
+		return nil, err
+	}
+	ips := make(map[string]string, len(networks))
+	for name, network := range networks {
+		ips[name] = network.IPAddress
+	}
+	return ips, nil
+}
+
+// IPAddress returns the IP address of the container on the network set with
+// WithNetwork, or else the first non-empty one in network name order.
+func (c *Client) IPAddress(ctx context.Context, containerID string) (string, error) {
+	ips, err := c.NetworkIPs(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	ip, ok := SelectNetworkIP(ips, c.network)
+	if !ok {
+		return "", fmt.Errorf("container %s has no IP address on network %s", containerID, c.network)
+	}
+	return ip, nil
+}
+
+// SelectNetworkIP picks the address on network from ips, matching the full name or a
+// compose project prefix (<project>_<network>). With no network it returns the first
+// non-empty address in network name order.
+func SelectNetworkIP(ips map[string]string, network string) (string, bool) {
+	names := make([]string, 0, len(ips))
+	for name := range ips {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if ip := ips[network]; network != "" && ip != "" {
+		return ip, true
+	}
+	for _, name := range names {
+		if ips[name] == "" {
+			continue
+		}
+		if network == "" || strings.HasSuffix(name, "_"+network) {
+			return ips[name], true
+		}
+	}
+	return "", network == ""
+}
+
+// WithStopTimeout sets the seconds Stop waits before killing a container; 0 keeps the
+// container's own stop timeout (compose stop_grace_period, 10 seconds by default).
+func (c *Client) WithStopTimeout(seconds int) *Client {
+	c.stopTimeout = seconds
+	return c
+}
+
+// WithDryRun makes Stop and Remove only log through log the containers they would act
+// on; nil turns dry-run mode off. Inspecting containers is unaffected.
+func (c *Client) WithDryRun(log *logrus.Logger) *Client {
+	c.dryRun = log
+	return c
+}
+
+// Stop stops every container, up to the stop concurrency at a time. Each container gets
+// its own stop timeout, and a failure does not keep the others from being stopped.
 func (c *Client) Stop(ctx context.Context, containerIDs []string) error {
-	if len(containerIDs) == 0 {
+	if c.dryRun != nil {
+		c.dryRun.Infof("==> [dry-run] Would stop containers %v", containerIDs)
 		return nil
 	}
-	args := append([]string{}, c.dockerArgs...)
-	args = append(args, "stop")
-	args = append(args, containerIDs...)
-	return c.run(ctx, args...)
+	return forEachContainer(containerIDs, c.stopConcurrency, func(id string) error {
+		args := append([]string{}, c.dockerArgs...)
+		args = append(args, "stop")
+		if c.stopTimeout > 0 {
+			args = append(args, "--time", strconv.Itoa(c.stopTimeout))
+		}
+		args = append(args, id)
+		return c.runOnce(ctx, args...)
+	})
 }
 
+// Remove removes every container, up to the stop concurrency at a time, and reports all
+// failures together.
 func (c *Client) Remove(ctx context.Context, containerIDs []string) error {
-	if len(containerIDs) == 0 {
+	if c.dryRun != nil {
+		c.dryRun.Infof("==> [dry-run] Would remove containers %v", containerIDs)
 		return nil
 	}
-	args := append([]string{}, c.dockerArgs...)
-	args = append(args, "rm")
-	args = append(args, containerIDs...)
-	return c.run(ctx, args...)
+	return forEachContainer(containerIDs, c.stopConcurrency, func(id string) error {
+		args := append([]string{}, c.dockerArgs...)
+		args = append(args, "rm", id)
+		return c.runOnce(ctx, args...)
+	})
+}
+
+// forEachContainer runs fn for every ID with at most workers calls in flight and joins
+// the errors, each prefixed with its container ID.
+func forEachContainer(ids []string, workers int, fn func(id string) error) error {
+	if workers <= 0 {
+		workers = cli.DefaultStopConcurrency
+	}
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(id); err != nil {
+				errs[i] = fmt.Errorf("container %s: %w", id, err)
+			}
+		}(i, id)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
 func (c *Client) LogsTail(ctx context.Context, containerID string, tail int) (string, error) {
 	args := append([]string{}, c.dockerArgs...)
 	args = append(args, "logs", "--tail", fmt.Sprintf("%d", tail), containerID)
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
-	}
-	return string(out), nil
+	return c.combinedOutput(ctx, args...)
 }
 
 func (c *Client) inspect(ctx context.Context, format string, containerID string) (string, error) {
 	args := append([]string{}, c.dockerArgs...)
 	args = append(args, "inspect", "--format="+format, containerID)
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	out, err := cmd.CombinedOutput()
+	return c.combinedOutput(ctx, args...)
+}
+
+func (c *Client) run(ctx context.Context, args ...string) error {
+	_, err := c.combinedOutput(ctx, args...)
+	return err
+}
+
+// runOnce runs docker with args without retrying, for stop and rm: a retry after the
+// daemon dropped the connection could act on a container that is already gone.
+func (c *Client) runOnce(ctx context.Context, args ...string) error {
+	_, err := c.execute(ctx, args...)
+	return err
+}
+
+// combinedOutput runs docker with args, retrying transient daemon errors, and returns
+// stdout and stderr together.
+func (c *Client) combinedOutput(ctx context.Context, args ...string) (string, error) {
+	var out string
+	err := retry.Do(ctx, c.maxRetries, func() error {
+		var err error
+		out, err = c.execute(ctx, args...)
+		return err
+	})
 	if err != nil {
-		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		return "", err
 	}
-	return string(out), nil
+	return out, nil
 }
 
-func (c *Client) run(ctx context.Context, args ...string) error {
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	out, err := cmd.CombinedOutput()
+func (c *Client) execute(ctx context.Context, args ...string) (string, error) {
+	out, err := c.runner.CombinedOutput(ctx, args...)
 	if err != nil {
-		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
 	}
-	return nil
+	return string(out), nil
 }
@@ -0,0 +1,429 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestForEachContainer_BoundsConcurrencyAndJoinsErrors(t *testing.T) {
+	var inFlight, peak int32
+	var mu sync.Mutex
+	var seen []string
+	err := forEachContainer([]string{"a", "b", "c", "d", "e", "f"}, 2, func(id string) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		seen = append(seen, id)
+		mu.Unlock()
+		if id == "b" || id == "e" {
+			return errors.New("no such container")
+		}
+		return nil
+	})
+
+	if peak > 2 {
+		t.Fatalf("expected at most 2 containers in flight, got %d", peak)
+	}
+	if len(seen) != 6 {
+		t.Fatalf("expected every container to be attempted, got %v", seen)
+	}
+	if err == nil || !strings.Contains(err.Error(), "container b: no such container") || !strings.Contains(err.Error(), "container e: no such container") {
+		t.Fatalf("expected both failures to be reported, got %v", err)
+	}
+}
+
+func TestForEachContainer_Empty(t *testing.T) {
+	if err := forEachContainer(nil, 4, func(string) error { return errors.New("unexpected call") }); err != nil {
+		t.Fatalf("expected no error for no containers, got %v", err)
+	}
+}
+
+func TestSelectNetworkIP(t *testing.T) {
+	ips := map[string]string{"shop_frontend": "172.18.0.5", "shop_backend": "172.19.0.7", "host": ""}
+
+	if ip, ok := SelectNetworkIP(ips, ""); !ok || ip != "172.19.0.7" {
+		t.Fatalf("expected the first network in name order, got %q %v", ip, ok)
+	}
+	if ip, ok := SelectNetworkIP(ips, "shop_frontend"); !ok || ip != "172.18.0.5" {
+		t.Fatalf("expected the full network name to match, got %q %v", ip, ok)
+	}
+	if ip, ok := SelectNetworkIP(ips, "frontend"); !ok || ip != "172.18.0.5" {
+		t.Fatalf("expected the compose network name to match, got %q %v", ip, ok)
+	}
+	if _, ok := SelectNetworkIP(ips, "monitoring"); ok {
+		t.Fatal("expected an unknown network not to match")
+	}
+}
+
+type fakeResult struct {
+	out string
+	err error
+}
+
+// fakeRunner answers docker commands from results, keyed by the space-joined args, and
+// records every call.
+type fakeRunner struct {
+	mu      sync.Mutex
+	results map[string][]fakeResult
+	calls   []string
+}
+
+func (f *fakeRunner) CombinedOutput(ctx context.Context, args ...string) ([]byte, error) {
+	return f.next(args)
+}
+
+func (f *fakeRunner) Output(ctx context.Context, args ...string) ([]byte, error) {
+	return f.next(args)
+}
+
+func (f *fakeRunner) next(args []string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.Join(args, " ")
+	f.calls = append(f.calls, key)
+	results := f.results[key]
+	if len(results) == 0 {
+		return nil, errors.New("unexpected command: docker " + key)
+	}
+	res := results[0]
+	if len(results) > 1 {
+		f.results[key] = results[1:]
+	}
+	return []byte(res.out), res.err
+}
+
+func TestClient_HealthStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		err     error
+		want    string
+		wantErr bool
+	}{
+		{name: "healthy", out: "\"healthy\"\n", want: "healthy"},
+		{name: "starting", out: "\"starting\"", want: "starting"},
+		{name: "no healthcheck", out: "null\n", want: "null"},
+		{name: "missing container", out: "Error: No such object: abc", err: errors.New("exit status 1"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: map[string][]fakeResult{
+				"inspect --format={{json .State.Health.Status}} abc": {{out: tt.out, err: tt.err}},
+			}}
+			got, err := NewClient(nil).WithRunner(runner).HealthStatus(context.Background(), "abc")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HealthStatus() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("HealthStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_HasHealthcheck(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want bool
+	}{
+		{name: "with healthcheck", out: `{"Status":"healthy","FailingStreak":0}`, want: true},
+		{name: "without healthcheck", out: "null", want: false},
+		{name: "unparsable", out: "<no value>", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: map[string][]fakeResult{
+				"inspect --format={{json .State.Health}} abc": {{out: tt.out}},
+			}}
+			got, err := NewClient(nil).WithRunner(runner).HasHealthcheck(context.Background(), "abc")
+			if err != nil {
+				t.Fatalf("HasHealthcheck() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("HasHealthcheck() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	runner := &fakeRunner{results: map[string][]fakeResult{
+		"--host ssh://web1 version --format {{.Server.Version}}": {{out: "27.1.1\n"}},
+	}}
+	if err := NewClient([]string{"--host", "ssh://web1"}).WithRunner(runner).Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	runner = &fakeRunner{results: map[string][]fakeResult{
+		"version --format {{.Server.Version}}": {{out: "permission denied", err: errors.New("exit status 1")}},
+	}}
+	err := NewClient(nil).WithRunner(runner).Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "cannot reach the Docker engine") {
+		t.Fatalf("expected an unreachable engine error, got %v", err)
+	}
+}
+
+func TestClient_ExposedPorts(t *testing.T) {
+	runner := &fakeRunner{results: map[string][]fakeResult{
+		"inspect --format={{json .Config.ExposedPorts}} abc": {{out: `{"8080/tcp":{},"53/udp":{}}` + "\n"}},
+		"inspect --format={{json .Config.ExposedPorts}} def": {{out: "null\n"}},
+	}}
+	client := NewClient(nil).WithRunner(runner)
+	got, err := client.ExposedPorts(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("ExposedPorts() error = %v", err)
+	}
+	if strings.Join(got, ",") != "53/udp,8080/tcp" {
+		t.Fatalf("ExposedPorts() = %v", got)
+	}
+	if got, err := client.ExposedPorts(context.Background(), "def"); err != nil || len(got) != 0 {
+		t.Fatalf("expected no exposed ports, got %v, %v", got, err)
+	}
+}
+
+func TestClient_ContainersByLabel(t *testing.T) {
+	runner := &fakeRunner{results: map[string][]fakeResult{
+		"ps -q --no-trunc --filter label=org.example.service=api": {{out: "abc123\ndef456\n"}},
+		"ps -q --no-trunc --filter label=org.example.service":     {{out: "abc123\ndef456\n789abc\n"}},
+	}}
+	got, err := NewClient(nil).WithRunner(runner).ContainersByLabel(context.Background(), "org.example.service", "api")
+	if err != nil {
+		t.Fatalf("ContainersByLabel() error = %v", err)
+	}
+	if strings.Join(got, ",") != "abc123,def456" {
+		t.Fatalf("ContainersByLabel() = %v", got)
+	}
+
+	got, err = NewClient(nil).WithRunner(runner).ContainersByLabel(context.Background(), "org.example.service", "")
+	if err != nil {
+		t.Fatalf("ContainersByLabel() error = %v", err)
+	}
+	if strings.Join(got, ",") != "abc123,def456,789abc" {
+		t.Fatalf("ContainersByLabel() without a value = %v", got)
+	}
+}
+
+func TestClient_ContainerByName(t *testing.T) {
+	runner := &fakeRunner{results: map[string][]fakeResult{
+		"ps -q --no-trunc --filter name=^/shop-db$": {{out: "abc123\n"}},
+	}}
+	got, err := NewClient(nil).WithRunner(runner).ContainerByName(context.Background(), "shop-db")
+	if err != nil {
+		t.Fatalf("ContainerByName() error = %v", err)
+	}
+	if got != "abc123" {
+		t.Fatalf("ContainerByName() = %q", got)
+	}
+}
+
+func TestClient_HealthcheckStartPeriod(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want time.Duration
+	}{
+		{name: "with start period", out: "30000000000\n", want: 30 * time.Second},
+		{name: "without healthcheck", out: "\n", want: 0},
+		{name: "unset start period", out: "0\n", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: map[string][]fakeResult{
+				"inspect --format={{if .Config.Healthcheck}}{{.Config.Healthcheck.StartPeriod}}{{end}} abc": {{out: tt.out}},
+			}}
+			got, err := NewClient(nil).WithRunner(runner).HealthcheckStartPeriod(context.Background(), "abc")
+			if err != nil {
+				t.Fatalf("HealthcheckStartPeriod() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("HealthcheckStartPeriod() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_State(t *testing.T) {
+	runner := &fakeRunner{results: map[string][]fakeResult{
+		"--context prod inspect --format={{json .State}} abc": {{out: `{"Status":"exited","Running":false,"ExitCode":137,"StartedAt":"2024-05-01T10:00:00Z"}`}},
+	}}
+	got, err := NewClient([]string{"--context", "prod"}).WithRunner(runner).State(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	want := ContainerState{Status: "exited", ExitCode: 137, StartedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	if got != want {
+		t.Fatalf("State() = %+v, want %+v", got, want)
+	}
+}
+
+func TestClient_IPAddress(t *testing.T) {
+	networks := `{"app_backend":{"IPAddress":"172.20.0.5"},"app_default":{"IPAddress":"172.19.0.3"}}`
+	tests := []struct {
+		name    string
+		network string
+		want    string
+		wantErr bool
+	}{
+		{name: "first network by name", want: "172.20.0.5"},
+		{name: "compose network name", network: "default", want: "172.19.0.3"},
+		{name: "unknown network", network: "frontend", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: map[string][]fakeResult{
+				"inspect --format={{json .NetworkSettings.Networks}} abc": {{out: networks}},
+			}}
+			got, err := NewClient(nil).WithRunner(runner).WithNetwork(tt.network).IPAddress(context.Background(), "abc")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IPAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("IPAddress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_StopAndRemove(t *testing.T) {
+	tests := []struct {
+		name        string
+		ids         []string
+		stopTimeout int
+		results     map[string][]fakeResult
+		wantCalls   []string
+		wantErrs    []string
+	}{
+		{
+			name: "default timeout",
+			ids:  []string{"a", "b"},
+			results: map[string][]fakeResult{
+				"stop a": {{}}, "stop b": {{}},
+				"rm a": {{}}, "rm b": {{}},
+			},
+			wantCalls: []string{"rm a", "rm b", "stop a", "stop b"},
+		},
+		{
+			name:        "custom timeout",
+			ids:         []string{"a"},
+			stopTimeout: 30,
+			results: map[string][]fakeResult{
+				"stop --time 30 a": {{}},
+				"rm a":             {{}},
+			},
+			wantCalls: []string{"rm a", "stop --time 30 a"},
+		},
+		{
+			name: "failures are reported per container",
+			ids:  []string{"a", "b"},
+			results: map[string][]fakeResult{
+				"stop a": {{out: "Error: No such container: a", err: errors.New("exit status 1")}},
+				"stop b": {{}},
+				"rm a":   {{out: "Error: No such container: a", err: errors.New("exit status 1")}},
+				"rm b":   {{}},
+			},
+			wantCalls: []string{"rm a", "rm b", "stop a", "stop b"},
+			wantErrs:  []string{"container a: exit status 1: Error: No such container: a"},
+		},
+		{
+			name: "transient failures are not retried",
+			ids:  []string{"a"},
+			results: map[string][]fakeResult{
+				"stop a": {{out: "connection reset by peer", err: errors.New("exit status 1")}, {}},
+				"rm a":   {{out: "connection reset by peer", err: errors.New("exit status 1")}, {}},
+			},
+			wantCalls: []string{"rm a", "stop a"},
+			wantErrs:  []string{"container a: exit status 1: connection reset by peer"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{results: tt.results}
+			client := NewClient(nil).WithRunner(runner).WithStopTimeout(tt.stopTimeout)
+			stopErr := client.Stop(context.Background(), tt.ids)
+			rmErr := client.Remove(context.Background(), tt.ids)
+			for _, err := range []error{stopErr, rmErr} {
+				if len(tt.wantErrs) == 0 && err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				for _, want := range tt.wantErrs {
+					if err == nil || !strings.Contains(err.Error(), want) {
+						t.Fatalf("error = %v, want it to contain %q", err, want)
+					}
+				}
+			}
+			calls := append([]string{}, runner.calls...)
+			sort.Strings(calls)
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Fatalf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestClient_StopAndRemoveDryRun(t *testing.T) {
+	var logs strings.Builder
+	log := logrus.New()
+	log.SetOutput(&logs)
+	runner := &fakeRunner{results: map[string][]fakeResult{}}
+	client := NewClient(nil).WithRunner(runner).WithDryRun(log)
+
+	if err := client.Stop(context.Background(), []string{"a", "b"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.Remove(context.Background(), []string{"a", "b"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runner.calls) != 0 {
+		t.Fatalf("expected no docker commands in a dry run, got %v", runner.calls)
+	}
+	for _, want := range []string{"Would stop containers [a b]", "Would remove containers [a b]"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("expected %q in %q", want, logs.String())
+		}
+	}
+}
+
+func TestClient_RetriesTransientErrors(t *testing.T) {
+	runner := &fakeRunner{results: map[string][]fakeResult{
+		"logs --tail 5 abc": {
+			{out: "Cannot connect to the Docker daemon", err: errors.New("exit status 1")},
+			{out: "ready\n"},
+		},
+	}}
+	out, err := NewClient(nil).WithRunner(runner).LogsTail(context.Background(), "abc", 5)
+	if err != nil {
+		t.Fatalf("LogsTail() error = %v", err)
+	}
+	if out != "ready\n" || len(runner.calls) != 2 {
+		t.Fatalf("LogsTail() = %q after %d calls, want ready after 2", out, len(runner.calls))
+	}
+}
+
+func TestClient_DoesNotRetryPermanentErrors(t *testing.T) {
+	runner := &fakeRunner{results: map[string][]fakeResult{
+		"rm abc": {{out: "Error: No such container: abc", err: errors.New("exit status 1")}},
+	}}
+	if err := NewClient(nil).WithRunner(runner).Remove(context.Background(), []string{"abc"}); err == nil {
+		t.Fatal("Remove() error = nil, want error")
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("calls = %v, want a single attempt", runner.calls)
+	}
+}
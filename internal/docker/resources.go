@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/retry"
+)
+
+type HostResources struct {
+	NCPU     int   `json:"NCPU"`
+	MemTotal int64 `json:"MemTotal"`
+}
+
+// HostResources reports the total CPUs and memory of the Docker host.
+func (c *Client) HostResources(ctx context.Context) (HostResources, error) {
+	out, err := c.output(ctx, "info", "--format={{json .}}")
+	if err != nil {
+		return HostResources{}, err
+	}
+	var res HostResources
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &res); err != nil {
+		return HostResources{}, err
+	}
+	return res, nil
+}
+
+// ReservedResources sums the memory (bytes) and CPU (nano CPUs) limits of all
+// running containers. Containers without limits count as zero.
+func (c *Client) ReservedResources(ctx context.Context) (int64, int64, error) {
+	out, err := c.output(ctx, "ps", "-q")
+	if err != nil {
+		return 0, 0, err
+	}
+	ids := strings.Fields(out)
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+	out, err = c.output(ctx, append([]string{"inspect", "--format={{.HostConfig.Memory}} {{.HostConfig.NanoCpus}}"}, ids...)...)
+	if err != nil {
+		return 0, 0, err
+	}
+	var memory, nanoCPUs int64
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		mem, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid container memory limit %q: %w", fields[0], err)
+		}
+		cpus, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid container cpu limit %q: %w", fields[1], err)
+		}
+		memory += mem
+		nanoCPUs += cpus
+	}
+	return memory, nanoCPUs, nil
+}
+
+func (c *Client) output(ctx context.Context, args ...string) (string, error) {
+	full := append([]string{}, c.dockerArgs...)
+	full = append(full, args...)
+	var out []byte
+	err := retry.Do(ctx, c.maxRetries, func() error {
+		var err error
+		out, err = c.runner.Output(ctx, full...)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
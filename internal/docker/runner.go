@@ -0,0 +1,34 @@
+package docker
+
+import (
+	"context"
+	"os/exec"
+)
+
+// CommandRunner runs the docker CLI. Client goes through it for every command, so tests
+// can swap in a fake instead of a docker daemon.
+type CommandRunner interface {
+	// CombinedOutput runs docker with args and returns stdout and stderr together.
+	CombinedOutput(ctx context.Context, args ...string) ([]byte, error)
+	// Output runs docker with args and returns stdout only.
+	Output(ctx context.Context, args ...string) ([]byte, error)
+}
+
+type execRunner struct{}
+
+func (execRunner) CombinedOutput(ctx context.Context, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+}
+
+func (execRunner) Output(ctx context.Context, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, "docker", args...).Output()
+}
+
+// WithRunner makes the client run docker commands through r; nil keeps the docker CLI.
+func (c *Client) WithRunner(r CommandRunner) *Client {
+	if r == nil {
+		r = execRunner{}
+	}
+	c.runner = r
+	return c
+}
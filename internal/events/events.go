@@ -0,0 +1,157 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	TypePhase           = "phase"
+	TypeContainerHealth = "container-health"
+	TypeSwapComplete    = "swap-complete"
+	TypeContainersReady = "containers-ready"
+	TypeDeployFinished  = "deploy-finished"
+)
+
+const (
+	PhaseScale        = "scale"
+	PhaseWaitHealthy  = "wait-healthy"
+	PhaseUpdateConfig = "update-config"
+	PhaseDrain        = "drain"
+	PhaseRemove       = "remove"
+	PhaseRollback     = "rollback"
+	PhaseRamp         = "ramp"
+)
+
+// Readiness methods reported as the message of a wait-healthy phase event.
+const (
+	ReadinessHealthcheck = "healthcheck"
+	ReadinessTCPProbe    = "tcp-probe"
+	ReadinessHTTPProbe   = "http-probe"
+	ReadinessFixedWait   = "fixed-wait"
+)
+
+// Event is one newline-delimited JSON record written to the events socket.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	Service   string    `json:"service,omitempty"`
+	Phase     string    `json:"phase,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	DeployID  string    `json:"deployId,omitempty"`
+	// Count is the number of containers a swap-complete or remove event covers.
+	Count int `json:"count,omitempty"`
+	// Containers lists those containers when the strategy knows them.
+	Containers []string `json:"containers,omitempty"`
+	// Replaced lists the containers that served before a swap-complete or
+	// containers-ready event's containers.
+	Replaced []string `json:"replaced,omitempty"`
+}
+
+type Sink interface {
+	Emit(event Event)
+}
+
+// Nop discards every event.
+type Nop struct{}
+
+func (Nop) Emit(Event) {}
+
+type multiSink []Sink
+
+// Multi fans every event out to all sinks.
+func Multi(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+func (m multiSink) Emit(event Event) {
+	for _, sink := range m {
+		sink.Emit(event)
+	}
+}
+
+// SocketSink streams events to a Unix socket. Write failures disable the sink so a
+// disconnected supervisor never interrupts a deploy.
+type SocketSink struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func DialSocket(path string) (*SocketSink, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("connect to events socket %s: %w", path, err)
+	}
+	return &SocketSink{conn: conn}, nil
+}
+
+func (s *SocketSink) Emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return
+	}
+	if _, err := s.conn.Write(append(data, '\n')); err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *SocketSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Phase emits a phase transition event for service.
+func Phase(sink Sink, service string, phase string) {
+	sink.Emit(Event{Type: TypePhase, Service: service, Phase: phase})
+}
+
+// WaitPhase emits the wait-healthy phase event for service with how readiness is
+// determined: ReadinessHealthcheck, ReadinessTCPProbe, ReadinessHTTPProbe or
+// ReadinessFixedWait.
+func WaitPhase(sink Sink, service string, readiness string) {
+	sink.Emit(Event{Type: TypePhase, Service: service, Phase: PhaseWaitHealthy, Message: readiness})
+}
+
+// HealthTracker emits a container-health event whenever a container's status changes.
+type HealthTracker struct {
+	sink    Sink
+	service string
+	mu      sync.Mutex
+	last    map[string]string
+}
+
+func NewHealthTracker(sink Sink, service string) *HealthTracker {
+	return &HealthTracker{sink: sink, service: service, last: map[string]string{}}
+}
+
+func (t *HealthTracker) Observe(containerID string, status string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.last[containerID] == status {
+		return
+	}
+	t.last[containerID] = status
+	t.sink.Emit(Event{Type: TypeContainerHealth, Service: t.service, Container: containerID, Status: status})
+}
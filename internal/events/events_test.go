@@ -0,0 +1,61 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestSocketSink_WritesNewlineDelimitedJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []Event, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		var got []Event
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var event Event
+			if err := json.Unmarshal(scanner.Bytes(), &event); err == nil {
+				got = append(got, event)
+			}
+		}
+		received <- got
+	}()
+
+	sink, err := DialSocket(path)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	Phase(sink, "api", PhaseScale)
+	tracker := NewHealthTracker(sink, "api")
+	tracker.Observe("c1", "starting")
+	tracker.Observe("c1", "starting")
+	tracker.Observe("c1", "healthy")
+	if err := sink.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	got := <-received
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events, got %d: %#v", len(got), got)
+	}
+	if got[0].Type != TypePhase || got[0].Phase != PhaseScale || got[0].Time.IsZero() {
+		t.Fatalf("unexpected phase event: %#v", got[0])
+	}
+	if got[2].Type != TypeContainerHealth || got[2].Status != "healthy" {
+		t.Fatalf("unexpected health event: %#v", got[2])
+	}
+}
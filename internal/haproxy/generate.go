@@ -0,0 +1,66 @@
+package haproxy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy/backends"
+)
+
+// FrontendName is the HTTP frontend that routes Host() names to the backends.
+const FrontendName = "ztd_http"
+
+// Backend is the HAProxy view of one compose service.
+type Backend struct {
+	Name    string
+	Hosts   []string
+	Servers []Server
+}
+
+// Server is one container of a backend, named by its short container ID.
+type Server struct {
+	Name    string
+	Address string
+}
+
+// NewGenerator returns a generator that renders an HAProxy config with one backend per
+// haproxy routed service.
+func NewGenerator(composeAdapter compose.Adapter, dockerClient backends.LabelReader) *backends.Generator {
+	return backends.NewGenerator(proxy.TypeHAProxy, "HAProxy", renderBackends, composeAdapter, dockerClient)
+}
+
+func renderBackends(list []backends.Backend) string {
+	out := make([]Backend, 0, len(list))
+	for _, backend := range list {
+		rendered := Backend{Name: backend.Name, Hosts: backend.Hosts}
+		for _, id := range backend.Containers {
+			rendered.Servers = append(rendered.Servers, Server{Name: id, Address: id + ":" + backend.Port})
+		}
+		out = append(out, rendered)
+	}
+	return Render(out)
+}
+
+// Render returns the HAProxy config for backends: a frontend with one Host ACL per
+// service with Host() names, and a backend section per service.
+func Render(backends []Backend) string {
+	var b strings.Builder
+	b.WriteString("# Generated by docker ztd. Do not edit.\n")
+	fmt.Fprintf(&b, "\nfrontend %s\n    bind *:80\n    mode http\n", FrontendName)
+	for _, backend := range backends {
+		if len(backend.Hosts) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "    acl host_%s hdr(host),field(1,:) -i %s\n", backend.Name, strings.Join(backend.Hosts, " "))
+		fmt.Fprintf(&b, "    use_backend %s if host_%s\n", backend.Name, backend.Name)
+	}
+	for _, backend := range backends {
+		fmt.Fprintf(&b, "\nbackend %s\n    mode http\n    balance roundrobin\n", backend.Name)
+		for _, server := range backend.Servers {
+			fmt.Fprintf(&b, "    server %s %s check\n", server.Name, server.Address)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,78 @@
+package haproxy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
+
+type composeMock struct {
+	compose.Adapter
+	ids []string
+}
+
+func (m *composeMock) PsQuiet(context.Context, []string, []string, string) ([]string, error) {
+	return m.ids, nil
+}
+
+type dockerMock struct {
+	labels map[string]map[string]string
+}
+
+func (m *dockerMock) Labels(_ context.Context, id string) (map[string]string, error) {
+	return m.labels[id], nil
+}
+
+func TestGenerate(t *testing.T) {
+	api := map[string]string{
+		"com.docker.compose.service":                         "api",
+		"traefik.enable":                                     "true",
+		"traefik.http.routers.api.rule":                      "Host(`api.example.com`) || Host(`www.example.com`)",
+		"traefik.http.services.api.loadbalancer.server.port": "8080",
+	}
+	adapter := &composeMock{ids: []string{"aaaaaaaaaaaa1111", "bbbbbbbbbbbb2222", "cccccccccccc3333", "dddddddddddd4444"}}
+	docker := &dockerMock{labels: map[string]map[string]string{
+		"aaaaaaaaaaaa1111": api,
+		"bbbbbbbbbbbb2222": api,
+		"cccccccccccc3333": {"com.docker.compose.service": "worker", "traefik.enable": "true"},
+		"dddddddddddd4444": {"com.docker.compose.service": "web", "traefik.enable": "true", "com.ztd.proxy": "traefik"},
+	}}
+
+	path := filepath.Join(t.TempDir(), "haproxy", "ztd.cfg")
+	if err := NewGenerator(adapter, docker).Generate(context.Background(), nil, nil, path); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	conf := string(data)
+	for _, want := range []string{
+		"frontend ztd_http\n    bind *:80\n    mode http\n",
+		"    acl host_api hdr(host),field(1,:) -i api.example.com www.example.com\n    use_backend api if host_api\n",
+		"backend api\n    mode http\n    balance roundrobin\n    server aaaaaaaaaaaa aaaaaaaaaaaa:8080 check\n    server bbbbbbbbbbbb bbbbbbbbbbbb:8080 check\n",
+		"backend worker\n    mode http\n    balance roundrobin\n    server cccccccccccc cccccccccccc:80 check\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Fatalf("expected %q in config:\n%s", want, conf)
+		}
+	}
+	if strings.Contains(conf, "web") || strings.Contains(conf, "host_worker") {
+		t.Fatalf("expected only api to get an ACL and web to be left to Traefik:\n%s", conf)
+	}
+}
+
+func TestGenerate_EmptyConfig(t *testing.T) {
+	adapter := &composeMock{ids: []string{"aaaaaaaaaaaa"}}
+	docker := &dockerMock{labels: map[string]map[string]string{
+		"aaaaaaaaaaaa": {"com.docker.compose.service": "api", "traefik.enable": "true"},
+	}}
+	path := filepath.Join(t.TempDir(), "ztd.cfg")
+	if err := NewGenerator(adapter, docker).WithDefaultProxy("traefik").Generate(context.Background(), nil, nil, path); err == nil {
+		t.Fatal("expected error when no service is routed by haproxy")
+	}
+}
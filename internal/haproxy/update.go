@@ -0,0 +1,68 @@
+package haproxy
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+)
+
+// UpdateBackendServers rebuilds the server set of the backend of service: server lines
+// of oldIDs are dropped and one line per new container is added in their place, copied
+// from the first dropped line. Every other section is left untouched. It returns how
+// many old servers were replaced; zero means none matched and the file is not changed.
+func UpdateBackendServers(path string, service string, oldIDs []string, newIDs []string) (int, error) {
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	old := make(map[string]bool, len(oldIDs))
+	for _, id := range oldIDs {
+		old[configio.ShortID(id)] = true
+	}
+	lines := strings.Split(string(data), "\n")
+	kept := make([]string, 0, len(lines)+len(newIDs))
+	present := map[string]bool{}
+	inBackend := false
+	replaced, insertAt := 0, -1
+	template, templateID := "", ""
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && !startsIndented(line) {
+			inBackend = len(fields) == 2 && fields[0] == "backend" && fields[1] == service
+		} else if inBackend && len(fields) >= 2 && fields[0] == "server" {
+			if old[fields[1]] {
+				if replaced == 0 {
+					template, templateID, insertAt = line, fields[1], len(kept)
+				}
+				replaced++
+				continue
+			}
+			present[fields[1]] = true
+		}
+		kept = append(kept, line)
+	}
+	if replaced == 0 {
+		return 0, nil
+	}
+
+	servers := make([]string, 0, len(newIDs))
+	for _, id := range newIDs {
+		short := configio.ShortID(id)
+		if present[short] {
+			continue
+		}
+		present[short] = true
+		servers = append(servers, strings.ReplaceAll(template, templateID, short))
+	}
+	kept = slices.Insert(kept, insertAt, servers...)
+	return replaced, configio.WriteAtomic(path, []byte(strings.Join(kept, "\n")), 0o644)
+}
+
+func startsIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
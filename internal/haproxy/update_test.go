@@ -0,0 +1,79 @@
+package haproxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateBackendServers_OnlyTouchesServiceBackend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ztd.cfg")
+	content := Render([]Backend{
+		{Name: "api", Hosts: []string{"api.example.com"}, Servers: []Server{{Name: "aaaaaaaaaaaa", Address: "aaaaaaaaaaaa:8080"}}},
+		{Name: "worker", Servers: []Server{{Name: "aaaaaaaaaaaa", Address: "aaaaaaaaaaaa:9000"}}},
+	})
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	replaced, err := UpdateBackendServers(path, "api", []string{"aaaaaaaaaaaa1111"}, []string{"bbbbbbbbbbbb2222"})
+	if err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	if replaced != 1 {
+		t.Fatalf("expected one server to be replaced, got %d", replaced)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	conf := string(data)
+	if !strings.Contains(conf, "server bbbbbbbbbbbb bbbbbbbbbbbb:8080 check") {
+		t.Fatalf("expected new container in api backend:\n%s", conf)
+	}
+	if !strings.Contains(conf, "server aaaaaaaaaaaa aaaaaaaaaaaa:9000 check") {
+		t.Fatalf("expected worker backend to be left alone:\n%s", conf)
+	}
+
+	if replaced, err := UpdateBackendServers(path, "missing", []string{"bbbbbbbbbbbb"}, []string{"cccccccccccc"}); err != nil || replaced != 0 {
+		t.Fatalf("expected no replacement for an unknown backend, got %d (%v)", replaced, err)
+	}
+}
+
+func TestUpdateBackendServers_RebuildsServerSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ztd.cfg")
+	content := Render([]Backend{{Name: "api", Servers: []Server{
+		{Name: "aaaaaaaaaaaa", Address: "aaaaaaaaaaaa:8080"},
+		{Name: "bbbbbbbbbbbb", Address: "bbbbbbbbbbbb:8080"},
+		{Name: "cccccccccccc", Address: "cccccccccccc:8080"},
+	}}})
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	replaced, err := UpdateBackendServers(path, "api",
+		[]string{"aaaaaaaaaaaa", "cccccccccccc"},
+		[]string{"dddddddddddd", "eeeeeeeeeeee", "ffffffffffff"})
+	if err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	if replaced != 2 {
+		t.Fatalf("expected two servers to be replaced, got %d", replaced)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	conf := string(data)
+	for _, id := range []string{"bbbbbbbbbbbb", "dddddddddddd", "eeeeeeeeeeee", "ffffffffffff"} {
+		if n := strings.Count(conf, "server "+id+" "+id+":8080 check"); n != 1 {
+			t.Fatalf("expected one server line for %s, got %d:\n%s", id, n, conf)
+		}
+	}
+	for _, id := range []string{"aaaaaaaaaaaa", "cccccccccccc"} {
+		if strings.Contains(conf, id) {
+			t.Fatalf("expected old server %s to be removed:\n%s", id, conf)
+		}
+	}
+}
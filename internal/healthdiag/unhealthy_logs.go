@@ -2,9 +2,13 @@ package healthdiag
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
 )
 
 type Docker interface {
@@ -12,14 +16,125 @@ type Docker interface {
 	LogsTail(ctx context.Context, containerID string, tail int) (string, error)
 }
 
-func LogUnhealthyContainerLogs(ctx context.Context, log *logrus.Logger, docker Docker, containerIDs []string, tail int) {
+// HealthState is what a health status means for a container being waited on.
+type HealthState int
+
+const (
+	// HealthPending means the container may still become ready, e.g. "starting".
+	HealthPending HealthState = iota
+	// HealthReady means the status is accepted as ready.
+	HealthReady
+	// HealthFailed means Docker reports the healthcheck as failing ("unhealthy").
+	HealthFailed
+)
+
+// ClassifyStatus tells a ready status apart from one that is still pending and from
+// "unhealthy", which Docker only reports once the healthcheck has failed its retries.
+func ClassifyStatus(status string, accepted []string) HealthState {
+	switch {
+	case IsAcceptedStatus(status, accepted):
+		return HealthReady
+	case status == "unhealthy":
+		return HealthFailed
+	default:
+		return HealthPending
+	}
+}
+
+// IsAcceptedStatus reports whether a container health status counts as healthy.
+// "healthy" is always accepted; "unhealthy" never is.
+func IsAcceptedStatus(status string, accepted []string) bool {
+	if status == "healthy" {
+		return true
+	}
+	if status == "unhealthy" {
+		return false
+	}
+	for _, candidate := range accepted {
+		if status == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// CriticalSubset splits containerIDs into the first critical containers a health wait
+// blocks on and the remainder that is only reported. A critical count of zero or one
+// covering every container keeps the full set and expected count.
+func CriticalSubset(containerIDs []string, expected int, critical int) ([]string, int, []string) {
+	if critical <= 0 || critical >= len(containerIDs) {
+		return containerIDs, expected, nil
+	}
+	return containerIDs[:critical], critical, containerIDs[critical:]
+}
+
+// LogNonCriticalStatus logs the current health of containers excluded from the health gate.
+func LogNonCriticalStatus(ctx context.Context, log *logrus.Logger, docker Docker, containerIDs []string) {
+	for _, id := range containerIDs {
+		status, err := docker.HealthStatus(ctx, id)
+		if err != nil {
+			log.Warnf("==> Unable to read health status for non-critical container %s: %v", id, err)
+			continue
+		}
+		log.Infof("==> Non-critical container %s health: %s", id, status)
+	}
+}
+
+type StateReader interface {
+	State(ctx context.Context, containerID string) (docker.ContainerState, error)
+	LogsTail(ctx context.Context, containerID string, tail int) (string, error)
+}
+
+// CheckExitedContainers returns an error with the exit code and log tail of the first
+// container that already exited or died, so health waits can fail fast instead of
+// running into the timeout. Exit code 0 counts too: a container that stops on its own
+// during the wait never becomes ready. Containers still starting are not reported.
+// The error is marked as a rollback, since a deploy removes its new containers when
+// one of them exits.
+func CheckExitedContainers(ctx context.Context, reader StateReader, containerIDs []string, tail int) error {
+	for _, id := range containerIDs {
+		st, err := reader.State(ctx, id)
+		if err != nil {
+			return err
+		}
+		if st.Running || (st.Status != "exited" && st.Status != "dead") {
+			continue
+		}
+
+		logs, err := reader.LogsTail(ctx, id, tail)
+		if err != nil {
+			return safeguard.WithRollback(safeguard.ReasonContainerExited, fmt.Errorf("container %s exited with code %d (unable to read logs: %v)", id, st.ExitCode, err))
+		}
+		logs = strings.TrimSpace(logs)
+		if logs == "" {
+			return safeguard.WithRollback(safeguard.ReasonContainerExited, fmt.Errorf("container %s exited with code %d; last %d log lines are empty", id, st.ExitCode, tail))
+		}
+		return safeguard.WithRollback(safeguard.ReasonContainerExited, fmt.Errorf("container %s exited with code %d; last %d log lines:\n%s", id, st.ExitCode, tail, logs))
+	}
+	return nil
+}
+
+// HealthFailureReason tells a health gate that ran out of time apart from one where
+// a container already reported unhealthy.
+func HealthFailureReason(ctx context.Context, docker Docker, containerIDs []string) safeguard.Reason {
+	for _, id := range containerIDs {
+		if status, err := docker.HealthStatus(ctx, id); err == nil && status == "unhealthy" {
+			return safeguard.ReasonUnhealthy
+		}
+	}
+	return safeguard.ReasonHealthcheckTimeout
+}
+
+// LogUnhealthyContainerLogs logs the last tail log lines of every container whose health
+// status is not accepted, with accepted as for IsAcceptedStatus.
+func LogUnhealthyContainerLogs(ctx context.Context, log *logrus.Logger, docker Docker, containerIDs []string, accepted []string, tail int) {
 	for _, id := range containerIDs {
 		status, err := docker.HealthStatus(ctx, id)
 		if err != nil {
 			log.Warnf("==> Unable to read health status for container %s: %v", id, err)
 			continue
 		}
-		if status == "healthy" {
+		if IsAcceptedStatus(status, accepted) {
 			continue
 		}
 
@@ -0,0 +1,120 @@
+package healthdiag
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
+)
+
+func TestIsAcceptedStatus(t *testing.T) {
+	t.Parallel()
+
+	if !IsAcceptedStatus("healthy", nil) {
+		t.Fatal("expected healthy to be accepted by default")
+	}
+	if IsAcceptedStatus("starting", nil) {
+		t.Fatal("expected starting to be rejected by default")
+	}
+	if !IsAcceptedStatus("starting", []string{"starting"}) {
+		t.Fatal("expected starting to be accepted when configured")
+	}
+	if IsAcceptedStatus("unhealthy", []string{"unhealthy"}) {
+		t.Fatal("expected unhealthy to never be accepted")
+	}
+}
+
+func TestClassifyStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		status   string
+		accepted []string
+		want     HealthState
+	}{
+		{"healthy", nil, HealthReady},
+		{"starting", nil, HealthPending},
+		{"starting", []string{"starting"}, HealthReady},
+		{"", nil, HealthPending},
+		{"unhealthy", nil, HealthFailed},
+		{"unhealthy", []string{"unhealthy"}, HealthFailed},
+	}
+	for _, tc := range cases {
+		if got := ClassifyStatus(tc.status, tc.accepted); got != tc.want {
+			t.Fatalf("ClassifyStatus(%q, %v) = %d, want %d", tc.status, tc.accepted, got, tc.want)
+		}
+	}
+}
+
+func TestCriticalSubset(t *testing.T) {
+	t.Parallel()
+
+	ids := []string{"a", "b", "c", "d"}
+	gated, expected, rest := CriticalSubset(ids, 4, 0)
+	if len(gated) != 4 || expected != 4 || len(rest) != 0 {
+		t.Fatalf("expected full set without critical count, got %v %d %v", gated, expected, rest)
+	}
+	gated, expected, rest = CriticalSubset(ids, 4, 1)
+	if len(gated) != 1 || gated[0] != "a" || expected != 1 || len(rest) != 3 {
+		t.Fatalf("unexpected critical subset: %v %d %v", gated, expected, rest)
+	}
+	gated, expected, _ = CriticalSubset(ids, 4, 10)
+	if len(gated) != 4 || expected != 4 {
+		t.Fatalf("expected full set when critical count exceeds containers, got %v %d", gated, expected)
+	}
+}
+
+type stateMock map[string]docker.ContainerState
+
+func (m stateMock) State(_ context.Context, id string) (docker.ContainerState, error) {
+	return m[id], nil
+}
+
+func (m stateMock) LogsTail(context.Context, string, int) (string, error) { return "done", nil }
+
+func TestCheckExitedContainers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	states := stateMock{
+		"running": {Status: "running", Running: true},
+		"created": {Status: "created"},
+		"clean":   {Status: "exited", ExitCode: 0},
+		"dead":    {Status: "dead", ExitCode: 137},
+	}
+	if err := CheckExitedContainers(ctx, states, []string{"running", "created"}, 20); err != nil {
+		t.Fatalf("expected running and starting containers to pass, got %v", err)
+	}
+	for id, want := range map[string]string{"clean": "exited with code 0", "dead": "exited with code 137"} {
+		err := CheckExitedContainers(ctx, states, []string{"running", id}, 20)
+		if safeguard.ReasonOf(err) != safeguard.ReasonContainerExited {
+			t.Fatalf("expected %s to fail as container-exited, got %v", id, err)
+		}
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in the error, got %v", want, err)
+		}
+	}
+}
+
+func TestLogUnhealthyContainerLogs_SkipsAcceptedStatuses(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	mock := &watchMock{status: map[string]string{"a": "healthy", "b": "starting", "c": "unhealthy"}}
+
+	LogUnhealthyContainerLogs(context.Background(), log, mock, []string{"a", "b", "c"}, []string{"starting"}, 20)
+	out := buf.String()
+	if strings.Contains(out, "Container a ") || strings.Contains(out, "Container b ") {
+		t.Fatalf("expected accepted containers to be skipped, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Container c is unhealthy") {
+		t.Fatalf("expected the unhealthy container's logs, got:\n%s", out)
+	}
+}
@@ -0,0 +1,135 @@
+package healthdiag
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultPollInterval is used when WaitHealthy is given no poll interval.
+const DefaultPollInterval = time.Second
+
+type Watcher interface {
+	HealthStatus(ctx context.Context, containerID string) (string, error)
+	StateReader
+}
+
+// StartPeriodReader is implemented by watchers that can read the start_period of a
+// container's own healthcheck, used when WaitHealthy gets no start period.
+type StartPeriodReader interface {
+	HealthcheckStartPeriod(ctx context.Context, containerID string) (time.Duration, error)
+}
+
+// StatusObserver receives every health status read by a watcher. It is called from
+// the watcher goroutines concurrently.
+type StatusObserver interface {
+	Observe(containerID string, status string)
+}
+
+type watchResult struct {
+	id      string
+	ok      bool
+	failed  bool
+	status  string
+	elapsed time.Duration
+	err     error
+}
+
+// WaitHealthy watches each container in its own goroutine with its own deadline,
+// checking every pollInterval, and reports true once expected of them reached an
+// accepted status. A container that reports unhealthy stops being watched, so it
+// counts as failed right away instead of at the deadline. WaitHealthy stops early with
+// false when the remaining watchers can no longer reach expected, and with an error
+// when a container exits, its health cannot be read or ctx is cancelled, so an
+// interrupted wait is never reported as unhealthy.
+//
+// Within startPeriod of the wait starting, unhealthy is tolerated like starting, since
+// many apps fail their first checks while booting. A zero startPeriod uses the
+// start_period of each container's own healthcheck when docker can read it.
+func WaitHealthy(ctx context.Context, log *logrus.Logger, docker Watcher, containerIDs []string, expected int, timeout time.Duration, pollInterval time.Duration, startPeriod time.Duration, accepted []string, observer StatusObserver) (bool, error) {
+	if expected <= 0 {
+		return true, nil
+	}
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	deadline := time.Now().Add(timeout)
+	results := make(chan watchResult, len(containerIDs))
+	for _, id := range containerIDs {
+		go func(id string) {
+			results <- watchContainer(ctx, log, docker, id, deadline, pollInterval, startPeriod, accepted, observer)
+		}(id)
+	}
+
+	okCount := 0
+	for pending := len(containerIDs); pending > 0; {
+		res := <-results
+		pending--
+		switch {
+		case res.err != nil:
+			return false, res.err
+		case res.ok:
+			okCount++
+			log.Infof("==> Container %s is %s after %s (%d/%d ready)", res.id, res.status, res.elapsed.Truncate(time.Second), okCount, expected)
+		case res.failed:
+			log.Errorf("==> Container %s reported unhealthy after %s", res.id, res.elapsed.Truncate(time.Second))
+		default:
+			log.Warnf("==> Container %s is still %q after %s", res.id, res.status, res.elapsed.Truncate(time.Second))
+		}
+		if okCount >= expected {
+			return true, nil
+		}
+		if okCount+pending < expected {
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+func watchContainer(ctx context.Context, log *logrus.Logger, docker Watcher, id string, deadline time.Time, pollInterval time.Duration, startPeriod time.Duration, accepted []string, observer StatusObserver) watchResult {
+	start := time.Now()
+	if startPeriod <= 0 {
+		if reader, ok := docker.(StartPeriodReader); ok {
+			period, err := reader.HealthcheckStartPeriod(ctx, id)
+			if err != nil {
+				return watchResult{id: id, err: err}
+			}
+			startPeriod = period
+		}
+	}
+	for {
+		if err := CheckExitedContainers(ctx, docker, []string{id}, 20); err != nil {
+			return watchResult{id: id, err: err}
+		}
+		status, err := docker.HealthStatus(ctx, id)
+		if err != nil {
+			return watchResult{id: id, err: err}
+		}
+		observer.Observe(id, status)
+		switch ClassifyStatus(status, accepted) {
+		case HealthReady:
+			return watchResult{id: id, ok: true, status: status, elapsed: time.Since(start)}
+		case HealthFailed:
+			if time.Since(start) >= startPeriod {
+				return watchResult{id: id, failed: true, status: status, elapsed: time.Since(start)}
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return watchResult{id: id, status: status, elapsed: time.Since(start)}
+		}
+		if elapsed := time.Since(start); elapsed < startPeriod {
+			log.Infof("==> Container %s is %q after %s, within its %s start period, waiting", id, status, elapsed.Truncate(time.Second), startPeriod)
+		} else {
+			log.Infof("==> Container %s is %q after %s, waiting", id, status, elapsed.Truncate(time.Second))
+		}
+		select {
+		case <-ctx.Done():
+			return watchResult{id: id, err: ctx.Err()}
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package healthdiag
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
+)
+
+type watchMock struct {
+	status map[string]string
+	exited map[string]bool
+}
+
+func (m *watchMock) HealthStatus(_ context.Context, id string) (string, error) {
+	return m.status[id], nil
+}
+
+func (m *watchMock) State(_ context.Context, id string) (docker.ContainerState, error) {
+	if m.exited[id] {
+		return docker.ContainerState{Status: "exited", ExitCode: 1}, nil
+	}
+	return docker.ContainerState{Status: "running", Running: true}, nil
+}
+
+func (m *watchMock) LogsTail(context.Context, string, int) (string, error) { return "boom", nil }
+
+type observerMock struct {
+	mu   sync.Mutex
+	seen map[string]string
+}
+
+func (o *observerMock) Observe(id string, status string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.seen[id] = status
+}
+
+func TestWaitHealthy_SlowContainerDoesNotMaskOthers(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mock := &watchMock{status: map[string]string{"fast": "healthy", "slow": "starting"}}
+	observer := &observerMock{seen: map[string]string{}}
+
+	start := time.Now()
+	ok, err := WaitHealthy(context.Background(), log, mock, []string{"slow", "fast"}, 1, 10*time.Second, 0, 0, nil, observer)
+	if err != nil || !ok {
+		t.Fatalf("expected one healthy container to satisfy the gate, got ok=%v err=%v", ok, err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("expected the gate to pass without waiting for the slow container")
+	}
+	if observer.seen["fast"] != "healthy" {
+		t.Fatalf("expected observer to see the fast container, got %#v", observer.seen)
+	}
+}
+
+func TestWaitHealthy_FailsWhenExpectedCannotBeReached(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mock := &watchMock{status: map[string]string{"a": "healthy", "b": "starting"}}
+
+	ok, err := WaitHealthy(context.Background(), log, mock, []string{"a", "b"}, 2, 0, 0, 0, nil, &observerMock{seen: map[string]string{}})
+	if err != nil || ok {
+		t.Fatalf("expected timeout without error, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestWaitHealthy_ExitedContainerFailsFast(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mock := &watchMock{status: map[string]string{"a": "starting", "b": "starting"}, exited: map[string]bool{"b": true}}
+
+	_, err := WaitHealthy(context.Background(), log, mock, []string{"a", "b"}, 2, 10*time.Second, 0, 0, nil, &observerMock{seen: map[string]string{}})
+	if safeguard.ReasonOf(err) != safeguard.ReasonContainerExited {
+		t.Fatalf("expected container-exited failure, got %v", err)
+	}
+}
+
+type startingMock struct {
+	watchMock
+	mu    sync.Mutex
+	polls map[string]int
+}
+
+func (m *startingMock) HealthStatus(_ context.Context, id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.polls[id]++
+	if m.polls[id] >= 3 {
+		return "healthy", nil
+	}
+	return "starting", nil
+}
+
+func TestWaitHealthy_PollInterval(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mock := &startingMock{polls: map[string]int{}}
+
+	start := time.Now()
+	ok, err := WaitHealthy(context.Background(), log, mock, []string{"a", "b"}, 2, 10*time.Second, 10*time.Millisecond, 0, nil, &observerMock{seen: map[string]string{}})
+	if err != nil || !ok {
+		t.Fatalf("expected containers to become healthy, got ok=%v err=%v", ok, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected polling every 10ms, took %s", elapsed)
+	}
+	if mock.polls["a"] != 3 || mock.polls["b"] != 3 {
+		t.Fatalf("expected every container to be polled until healthy, got %v", mock.polls)
+	}
+}
+
+func TestWaitHealthy_UnhealthyFailsFast(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mock := &watchMock{status: map[string]string{"a": "starting", "b": "unhealthy"}}
+
+	start := time.Now()
+	ok, err := WaitHealthy(context.Background(), log, mock, []string{"a", "b"}, 2, 10*time.Second, 0, 0, nil, &observerMock{seen: map[string]string{}})
+	if err != nil || ok {
+		t.Fatalf("expected failure without error, got ok=%v err=%v", ok, err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("expected an unhealthy container to fail the wait before the timeout")
+	}
+}
+
+func TestWaitHealthy_UnhealthyOutsideExpectedDoesNotFail(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mock := &watchMock{status: map[string]string{"a": "healthy", "b": "unhealthy"}}
+
+	ok, err := WaitHealthy(context.Background(), log, mock, []string{"b", "a"}, 1, 10*time.Second, 0, 0, nil, &observerMock{seen: map[string]string{}})
+	if err != nil || !ok {
+		t.Fatalf("expected the healthy container to satisfy the gate, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestWaitHealthy_CancelledIsNotUnhealthy(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mock := &watchMock{status: map[string]string{"a": "starting"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ok, err := WaitHealthy(ctx, log, mock, []string{"a"}, 1, 10*time.Second, 5*time.Millisecond, 0, nil, &observerMock{seen: map[string]string{}})
+	if ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the cancelled wait to return ctx.Err(), got ok=%v err=%v", ok, err)
+	}
+}
+
+// bootingMock reports unhealthy for its first polls, then healthy, and optionally
+// reads a start period like the docker client does.
+type bootingMock struct {
+	watchMock
+	mu          sync.Mutex
+	polls       int
+	startPeriod time.Duration
+}
+
+func (m *bootingMock) HealthStatus(context.Context, string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.polls++
+	if m.polls >= 3 {
+		return "healthy", nil
+	}
+	return "unhealthy", nil
+}
+
+type bootingStartPeriodMock struct{ *bootingMock }
+
+func (m bootingStartPeriodMock) HealthcheckStartPeriod(context.Context, string) (time.Duration, error) {
+	return m.startPeriod, nil
+}
+
+func TestWaitHealthy_StartPeriodToleratesUnhealthy(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	ok, err := WaitHealthy(context.Background(), log, &bootingMock{}, []string{"a"}, 1, 10*time.Second, 10*time.Millisecond, 5*time.Second, nil, &observerMock{seen: map[string]string{}})
+	if err != nil || !ok {
+		t.Fatalf("expected unhealthy within the start period to be waited out, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = WaitHealthy(context.Background(), log, bootingStartPeriodMock{&bootingMock{startPeriod: 5 * time.Second}}, []string{"a"}, 1, 10*time.Second, 10*time.Millisecond, 0, nil, &observerMock{seen: map[string]string{}})
+	if err != nil || !ok {
+		t.Fatalf("expected the container's own start period to be used, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = WaitHealthy(context.Background(), log, &bootingMock{}, []string{"a"}, 1, 10*time.Second, 10*time.Millisecond, 0, nil, &observerMock{seen: map[string]string{}})
+	if err != nil || ok {
+		t.Fatalf("expected unhealthy without a start period to fail, got ok=%v err=%v", ok, err)
+	}
+}
@@ -0,0 +1,95 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
+
+const (
+	PreDeploy  = "pre-deploy"
+	PostDeploy = "post-deploy"
+	PreStop    = "pre-stop"
+	OnRollback = "on-rollback"
+)
+
+// Hooks are the shell commands declared in a service's x-ztd-hooks block.
+type Hooks struct {
+	PreDeploy  string `yaml:"pre-deploy"`
+	PostDeploy string `yaml:"post-deploy"`
+	PreStop    string `yaml:"pre-stop"`
+	OnRollback string `yaml:"on-rollback"`
+}
+
+type hooksFile struct {
+	Services map[string]struct {
+		Hooks Hooks `yaml:"x-ztd-hooks"`
+	} `yaml:"services"`
+}
+
+// Load reads the x-ztd-hooks block of service, interpolating ${VAR} references like
+// docker compose does, so a literal $ in a hook is written as $$. Hooks set in later
+// compose files override the same hook from earlier ones.
+func Load(files []string, envFiles []string, service string) (Hooks, error) {
+	env, err := compose.LoadEnv(files, envFiles)
+	if err != nil {
+		return Hooks{}, err
+	}
+	var out Hooks
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return Hooks{}, err
+		}
+		var cfg hooksFile
+		if err := compose.UnmarshalInterpolated(data, env, &cfg); err != nil {
+			return Hooks{}, fmt.Errorf("failed to parse compose file %s: %w", file, err)
+		}
+		svc, ok := cfg.Services[service]
+		if !ok {
+			continue
+		}
+		if svc.Hooks.PreDeploy != "" {
+			out.PreDeploy = svc.Hooks.PreDeploy
+		}
+		if svc.Hooks.PostDeploy != "" {
+			out.PostDeploy = svc.Hooks.PostDeploy
+		}
+		if svc.Hooks.PreStop != "" {
+			out.PreStop = svc.Hooks.PreStop
+		}
+		if svc.Hooks.OnRollback != "" {
+			out.OnRollback = svc.Hooks.OnRollback
+		}
+	}
+	return out, nil
+}
+
+// Command returns the command configured for hook name, empty when unset.
+func (h Hooks) Command(name string) string {
+	switch name {
+	case PreDeploy:
+		return h.PreDeploy
+	case PostDeploy:
+		return h.PostDeploy
+	case PreStop:
+		return h.PreStop
+	case OnRollback:
+		return h.OnRollback
+	}
+	return ""
+}
+
+// Run executes command with sh -c, adding env to the current environment and
+// streaming its output to out.
+func Run(ctx context.Context, command string, env []string, out io.Writer) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
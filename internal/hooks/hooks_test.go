@@ -0,0 +1,93 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad_LaterFilesOverride(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "compose.yml")
+	override := filepath.Join(dir, "compose.override.yml")
+	writeFile(t, base, `
+services:
+  api:
+    image: api:1
+    x-ztd-hooks:
+      pre-deploy: ./migrate.sh
+      post-deploy: echo done
+      pre-stop: ./drain.sh
+  worker:
+    image: worker:1
+`)
+	writeFile(t, override, `
+services:
+  api:
+    x-ztd-hooks:
+      post-deploy: ./notify.sh
+      on-rollback: ./page.sh
+`)
+
+	got, err := Load([]string{base, override}, nil, "api")
+	if err != nil {
+		t.Fatalf("load hooks: %v", err)
+	}
+	want := Hooks{PreDeploy: "./migrate.sh", PostDeploy: "./notify.sh", PreStop: "./drain.sh", OnRollback: "./page.sh"}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+
+	got, err = Load([]string{base, override}, nil, "worker")
+	if err != nil {
+		t.Fatalf("load hooks: %v", err)
+	}
+	if got != (Hooks{}) {
+		t.Fatalf("expected no hooks for worker, got %+v", got)
+	}
+}
+
+func TestLoad_InterpolatesEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "compose.yml")
+	envFile := filepath.Join(dir, "prod.env")
+	writeFile(t, file, `
+services:
+  api:
+    x-ztd-hooks:
+      pre-deploy: ./migrate.sh --env ${STAGE}
+      post-deploy: echo $$HOSTNAME
+`)
+	writeFile(t, envFile, "STAGE=prod\n")
+
+	got, err := Load([]string{file}, []string{envFile}, "api")
+	if err != nil {
+		t.Fatalf("load hooks: %v", err)
+	}
+	if got.PreDeploy != "./migrate.sh --env prod" || got.PostDeploy != "echo $HOSTNAME" {
+		t.Fatalf("unexpected hooks: %+v", got)
+	}
+}
+
+func TestRun_PassesEnvAndReportsFailure(t *testing.T) {
+	var out bytes.Buffer
+	if err := Run(context.Background(), `echo "$ZTD_SERVICE"`, []string{"ZTD_SERVICE=api"}, &out); err != nil {
+		t.Fatalf("run hook: %v", err)
+	}
+	if strings.TrimSpace(out.String()) != "api" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if err := Run(context.Background(), "exit 3", nil, &out); err == nil {
+		t.Fatal("expected failing hook to return an error")
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
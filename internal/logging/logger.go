@@ -2,17 +2,77 @@ package logging
 
 import (
 	"os"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+type Options struct {
+	// TimestampFormat enables timestamps with the given Go layout. RFC3339 and
+	// RFC3339Nano are accepted as names. Empty keeps timestamps disabled.
+	TimestampFormat string
+	// Color overrides terminal detection: ColorAlways or ColorNever. Empty auto-detects.
+	Color string
+	// Format is cli.LogFormatText (default) or cli.LogFormatJSON.
+	Format string
+	// Level is a logrus level name such as debug, info, warn or error. Empty means info.
+	Level string
+}
+
+const (
+	ColorAuto   = ""
+	ColorAlways = "always"
+	ColorNever  = "never"
 )
 
-func NewLogger() *logrus.Logger {
+func NewLogger(opts Options) *logrus.Logger {
 	log := logrus.New()
 	log.SetOutput(os.Stdout)
 	log.SetLevel(logrus.InfoLevel)
-	log.SetFormatter(&logrus.TextFormatter{
+	if level, err := logrus.ParseLevel(opts.Level); err == nil && opts.Level != "" {
+		log.SetLevel(level)
+	}
+
+	if opts.Format == cli.LogFormatJSON {
+		// JSON lines are meant for log pipelines, so they always carry a timestamp.
+		formatter := &logrus.JSONFormatter{TimestampFormat: time.RFC3339}
+		if layout := ResolveTimestampFormat(opts.TimestampFormat); layout != "" {
+			formatter.TimestampFormat = layout
+		}
+		log.SetFormatter(formatter)
+		return log
+	}
+
+	formatter := &logrus.TextFormatter{
 		DisableTimestamp: true,
 		DisableQuote:     true,
-	})
+	}
+	if layout := ResolveTimestampFormat(opts.TimestampFormat); layout != "" {
+		formatter.DisableTimestamp = false
+		formatter.FullTimestamp = true
+		formatter.TimestampFormat = layout
+	}
+	switch opts.Color {
+	case ColorAlways:
+		formatter.ForceColors = true
+	case ColorNever:
+		formatter.DisableColors = true
+	}
+	log.SetFormatter(formatter)
 	return log
 }
+
+func ResolveTimestampFormat(format string) string {
+	format = strings.TrimSpace(format)
+	switch strings.ToLower(format) {
+	case "rfc3339":
+		return time.RFC3339
+	case "rfc3339nano":
+		return time.RFC3339Nano
+	default:
+		return format
+	}
+}
@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/cli"
+)
+
+func TestNewLogger_JSONFormatAndLevel(t *testing.T) {
+	log := NewLogger(Options{Format: cli.LogFormatJSON, Level: "warn"})
+	if _, ok := log.Formatter.(*logrus.JSONFormatter); !ok {
+		t.Fatalf("expected JSON formatter, got %T", log.Formatter)
+	}
+	if log.GetLevel() != logrus.WarnLevel {
+		t.Fatalf("expected warn level, got %s", log.GetLevel())
+	}
+	if NewLogger(Options{}).GetLevel() != logrus.InfoLevel {
+		t.Fatal("expected info level by default")
+	}
+}
+
+func TestNewLogger_ColorOverrides(t *testing.T) {
+	formatter := NewLogger(Options{Color: ColorNever}).Formatter.(*logrus.TextFormatter)
+	if !formatter.DisableColors || formatter.ForceColors {
+		t.Fatalf("expected colors disabled, got %#v", formatter)
+	}
+
+	formatter = NewLogger(Options{Color: ColorAlways}).Formatter.(*logrus.TextFormatter)
+	if !formatter.ForceColors || formatter.DisableColors {
+		t.Fatalf("expected colors forced, got %#v", formatter)
+	}
+}
@@ -0,0 +1,60 @@
+package nginx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy/backends"
+)
+
+// Upstream is the nginx view of one compose service.
+type Upstream struct {
+	Name        string
+	ServerNames []string
+	Servers     []string
+}
+
+// NewGenerator returns a generator that renders an nginx config with one upstream per
+// nginx-proxy routed service.
+func NewGenerator(composeAdapter compose.Adapter, dockerClient backends.LabelReader) *backends.Generator {
+	return backends.NewGenerator(proxy.TypeNginxProxy, "nginx", renderBackends, composeAdapter, dockerClient)
+}
+
+func renderBackends(list []backends.Backend) string {
+	upstreams := make([]Upstream, 0, len(list))
+	for _, backend := range list {
+		upstream := Upstream{Name: backend.Name, ServerNames: backend.Hosts}
+		for _, id := range backend.Containers {
+			upstream.Servers = append(upstream.Servers, id+":"+backend.Port)
+		}
+		upstreams = append(upstreams, upstream)
+	}
+	return Render(upstreams)
+}
+
+// Render returns the nginx config for upstreams: an upstream block per service and a
+// server block for every service with Host() names.
+func Render(upstreams []Upstream) string {
+	var b strings.Builder
+	b.WriteString("# Generated by docker ztd. Do not edit.\n")
+	for _, u := range upstreams {
+		fmt.Fprintf(&b, "\nupstream %s {\n", u.Name)
+		for _, server := range u.Servers {
+			fmt.Fprintf(&b, "    server %s;\n", server)
+		}
+		b.WriteString("}\n")
+		if len(u.ServerNames) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\nserver {\n    listen 80;\n    server_name %s;\n\n", strings.Join(u.ServerNames, " "))
+		fmt.Fprintf(&b, "    location / {\n        proxy_pass http://%s;\n", u.Name)
+		b.WriteString("        proxy_set_header Host $host;\n")
+		b.WriteString("        proxy_set_header X-Real-IP $remote_addr;\n")
+		b.WriteString("        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;\n")
+		b.WriteString("        proxy_set_header X-Forwarded-Proto $scheme;\n")
+		b.WriteString("    }\n}\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package nginx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
+
+type composeMock struct {
+	compose.Adapter
+	ids []string
+}
+
+func (m *composeMock) PsQuiet(context.Context, []string, []string, string) ([]string, error) {
+	return m.ids, nil
+}
+
+type dockerMock struct {
+	labels map[string]map[string]string
+}
+
+func (m *dockerMock) Labels(_ context.Context, id string) (map[string]string, error) {
+	return m.labels[id], nil
+}
+
+func TestGenerate(t *testing.T) {
+	api := map[string]string{
+		"com.docker.compose.service":                         "api",
+		"traefik.enable":                                     "true",
+		"traefik.http.routers.api.rule":                      "Host(`api.example.com`)",
+		"traefik.http.services.api.loadbalancer.server.port": "8080",
+	}
+	adapter := &composeMock{ids: []string{"aaaaaaaaaaaa1111", "bbbbbbbbbbbb2222", "cccccccccccc3333", "dddddddddddd4444"}}
+	docker := &dockerMock{labels: map[string]map[string]string{
+		"aaaaaaaaaaaa1111": api,
+		"bbbbbbbbbbbb2222": api,
+		"cccccccccccc3333": {"com.docker.compose.service": "worker", "traefik.enable": "true"},
+		"dddddddddddd4444": {"com.docker.compose.service": "web", "traefik.enable": "true", "com.ztd.proxy": "traefik"},
+	}}
+
+	path := filepath.Join(t.TempDir(), "nginx", "ztd.conf")
+	if err := NewGenerator(adapter, docker).Generate(context.Background(), nil, nil, path); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	conf := string(data)
+	for _, want := range []string{
+		"upstream api {\n    server aaaaaaaaaaaa:8080;\n    server bbbbbbbbbbbb:8080;\n}",
+		"server_name api.example.com;",
+		"proxy_pass http://api;",
+		"upstream worker {\n    server cccccccccccc:80;\n}",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Fatalf("expected %q in config:\n%s", want, conf)
+		}
+	}
+	if strings.Contains(conf, "web") || strings.Count(conf, "server {") != 1 {
+		t.Fatalf("expected only api to get a server block and web to be left to Traefik:\n%s", conf)
+	}
+}
+
+func TestGenerate_EmptyConfig(t *testing.T) {
+	adapter := &composeMock{ids: []string{"aaaaaaaaaaaa"}}
+	docker := &dockerMock{labels: map[string]map[string]string{
+		"aaaaaaaaaaaa": {"com.docker.compose.service": "api", "traefik.enable": "true"},
+	}}
+	path := filepath.Join(t.TempDir(), "ztd.conf")
+	if err := NewGenerator(adapter, docker).WithDefaultProxy("traefik").Generate(context.Background(), nil, nil, path); err == nil {
+		t.Fatal("expected error when no service is routed by nginx-proxy")
+	}
+}
+
+type exposingDockerMock struct {
+	dockerMock
+	exposed map[string][]string
+}
+
+func (m *exposingDockerMock) ExposedPorts(_ context.Context, id string) ([]string, error) {
+	return m.exposed[id], nil
+}
+
+func TestBuild_UsesSingleExposedPort(t *testing.T) {
+	adapter := &composeMock{ids: []string{"aaaaaaaaaaaa1111", "bbbbbbbbbbbb2222"}}
+	docker := &exposingDockerMock{
+		dockerMock: dockerMock{labels: map[string]map[string]string{
+			"aaaaaaaaaaaa1111": {"com.docker.compose.service": "api", "traefik.enable": "true"},
+			"bbbbbbbbbbbb2222": {"com.docker.compose.service": "admin", "traefik.enable": "true"},
+		}},
+		exposed: map[string][]string{
+			"aaaaaaaaaaaa1111": {"3000/tcp"},
+			"bbbbbbbbbbbb2222": {"3000/tcp", "9090/tcp"},
+		},
+	}
+	data, err := NewGenerator(adapter, docker).Build(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	conf := string(data)
+	for _, want := range []string{"server aaaaaaaaaaaa:3000;", "server bbbbbbbbbbbb:80;"} {
+		if !strings.Contains(conf, want) {
+			t.Fatalf("expected %q in config:\n%s", want, conf)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package nginx
+
+import (
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+)
+
+// UpdateContainerIDsInConfig rebuilds the server set of every upstream that lists one
+// of oldIDs: their server lines are dropped and one line per new container is added in
+// their place, copied from the first dropped line. It returns how many old servers
+// were replaced; zero means none matched and the file is not changed.
+func UpdateContainerIDsInConfig(path string, oldIDs []string, newIDs []string) (int, error) {
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	old := make(map[string]bool, len(oldIDs))
+	for _, id := range oldIDs {
+		old[configio.ShortID(id)] = true
+	}
+	lines := strings.Split(string(data), "\n")
+	out := make([]string, 0, len(lines)+len(newIDs))
+	replaced := 0
+	var upstream *upstreamEdit
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 2 && fields[0] == "upstream":
+			upstream = &upstreamEdit{insertAt: -1, present: map[string]bool{}}
+		case upstream != nil && strings.TrimSpace(line) == "}":
+			out = upstream.insert(out, newIDs)
+			upstream = nil
+		case upstream != nil && len(fields) >= 2 && fields[0] == "server":
+			host := serverHost(fields[1])
+			if old[host] {
+				if upstream.insertAt < 0 {
+					upstream.insertAt, upstream.template, upstream.templateID = len(out), line, host
+				}
+				replaced++
+				continue
+			}
+			upstream.present[host] = true
+		}
+		out = append(out, line)
+	}
+	if replaced == 0 {
+		return 0, nil
+	}
+
+	return replaced, configio.WriteAtomic(path, []byte(strings.Join(out, "\n")), 0o644)
+}
+
+// upstreamEdit collects, while one upstream block is read, where its dropped servers
+// stood and which servers it keeps.
+type upstreamEdit struct {
+	insertAt   int
+	template   string
+	templateID string
+	present    map[string]bool
+}
+
+// insert adds a server line per new container at the position of the first dropped
+// server. Blocks without a dropped server are left as they are.
+func (e *upstreamEdit) insert(out []string, newIDs []string) []string {
+	if e.insertAt < 0 {
+		return out
+	}
+	servers := make([]string, 0, len(newIDs))
+	for _, id := range newIDs {
+		short := configio.ShortID(id)
+		if e.present[short] {
+			continue
+		}
+		e.present[short] = true
+		servers = append(servers, strings.ReplaceAll(e.template, e.templateID, short))
+	}
+	return slices.Insert(out, e.insertAt, servers...)
+}
+
+// serverHost returns the host of an upstream server address such as "ID:8080;".
+func serverHost(address string) string {
+	address = strings.TrimSuffix(address, ";")
+	if i := strings.LastIndex(address, ":"); i >= 0 {
+		address = address[:i]
+	}
+	return address
+}
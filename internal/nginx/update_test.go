@@ -0,0 +1,68 @@
+package nginx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateContainerIDsInConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ztd.conf")
+	content := Render([]Upstream{{Name: "api", Servers: []string{"aaaaaaaaaaaa:8080"}}})
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	replaced, err := UpdateContainerIDsInConfig(path, []string{"aaaaaaaaaaaa1111"}, []string{"bbbbbbbbbbbb2222"})
+	if err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	if replaced != 1 {
+		t.Fatalf("expected 1 replacement, got %d", replaced)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), "server bbbbbbbbbbbb:8080;") {
+		t.Fatalf("expected new container in config:\n%s", data)
+	}
+}
+
+func TestUpdateContainerIDsInConfig_RebuildsServerSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ztd.conf")
+	content := Render([]Upstream{
+		{Name: "api", Servers: []string{"aaaaaaaaaaaa:8080", "bbbbbbbbbbbb:8080", "cccccccccccc:8080"}},
+		{Name: "worker", Servers: []string{"999999999999:9000"}},
+	})
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	replaced, err := UpdateContainerIDsInConfig(path,
+		[]string{"aaaaaaaaaaaa", "cccccccccccc"},
+		[]string{"dddddddddddd", "eeeeeeeeeeee", "ffffffffffff"})
+	if err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	if replaced != 2 {
+		t.Fatalf("expected two servers to be replaced, got %d", replaced)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	conf := string(data)
+	for _, id := range []string{"bbbbbbbbbbbb", "dddddddddddd", "eeeeeeeeeeee", "ffffffffffff"} {
+		if n := strings.Count(conf, "server "+id+":8080;"); n != 1 {
+			t.Fatalf("expected one server line for %s, got %d:\n%s", id, n, conf)
+		}
+	}
+	if strings.Contains(conf, "aaaaaaaaaaaa") || strings.Contains(conf, "cccccccccccc") {
+		t.Fatalf("expected old servers to be removed:\n%s", conf)
+	}
+	if !strings.Contains(conf, "upstream worker {\n    server 999999999999:9000;\n}") {
+		t.Fatalf("expected worker upstream to be left alone:\n%s", conf)
+	}
+}
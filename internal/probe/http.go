@@ -0,0 +1,101 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels that enable the HTTP readiness probe for a service.
+const (
+	LabelReadinessPath = "com.ztd.readiness.path"
+	LabelReadinessPort = "com.ztd.readiness.port"
+)
+
+var httpPollInterval = time.Second
+
+// HTTPCheck is a GET request that must answer 2xx before a container is ready.
+type HTTPCheck struct {
+	Path string
+	Port int
+}
+
+// HTTPCheckFromLabels returns the HTTP readiness probe set by the com.ztd.readiness.*
+// labels. ok is false when neither label is set; setting only one is an error.
+func HTTPCheckFromLabels(labels map[string]string) (check HTTPCheck, ok bool, err error) {
+	path := strings.TrimSpace(labels[LabelReadinessPath])
+	rawPort := strings.TrimSpace(labels[LabelReadinessPort])
+	if path == "" && rawPort == "" {
+		return HTTPCheck{}, false, nil
+	}
+	if path == "" || rawPort == "" {
+		return HTTPCheck{}, false, fmt.Errorf("%s and %s must be set together", LabelReadinessPath, LabelReadinessPort)
+	}
+	port, err := strconv.Atoi(rawPort)
+	if err != nil || port < 1 || port > 65535 {
+		return HTTPCheck{}, false, fmt.Errorf("invalid %s %q: must be a port between 1 and 65535", LabelReadinessPort, rawPort)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return HTTPCheck{Path: path, Port: port}, true, nil
+}
+
+// WaitHTTP waits until every container answers check with a 2xx status, or the timeout
+// elapses. It returns false without an error when the timeout is reached.
+func WaitHTTP(ctx context.Context, resolver IPResolver, containerIDs []string, check HTTPCheck, timeout time.Duration) (bool, error) {
+	urls := make(map[string]string, len(containerIDs))
+	for _, id := range containerIDs {
+		ip, err := resolver.IPAddress(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if ip == "" {
+			return false, fmt.Errorf("container %s has no IP address for HTTP probe", id)
+		}
+		urls[id] = "http://" + net.JoinHostPort(ip, strconv.Itoa(check.Port)) + check.Path
+	}
+
+	client := &http.Client{Timeout: httpPollInterval}
+	deadline := time.Now().Add(timeout)
+	ready := map[string]bool{}
+	for {
+		for id, url := range urls {
+			if ready[id] {
+				continue
+			}
+			if httpReady(ctx, client, url) {
+				ready[id] = true
+			}
+		}
+		if len(ready) == len(urls) {
+			return true, nil
+		}
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(httpPollInterval):
+		}
+	}
+}
+
+func httpReady(ctx context.Context, client *http.Client, url string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
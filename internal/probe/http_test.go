@@ -0,0 +1,54 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPCheckFromLabels(t *testing.T) {
+	if _, ok, err := HTTPCheckFromLabels(map[string]string{}); ok || err != nil {
+		t.Fatalf("expected no probe without labels, got ok=%v err=%v", ok, err)
+	}
+	check, ok, err := HTTPCheckFromLabels(map[string]string{LabelReadinessPath: "healthz", LabelReadinessPort: "8080"})
+	if err != nil || !ok || check.Path != "/healthz" || check.Port != 8080 {
+		t.Fatalf("unexpected probe: %+v ok=%v err=%v", check, ok, err)
+	}
+	if _, _, err := HTTPCheckFromLabels(map[string]string{LabelReadinessPath: "/healthz"}); err == nil {
+		t.Fatal("expected a path without a port to fail")
+	}
+	if _, _, err := HTTPCheckFromLabels(map[string]string{LabelReadinessPath: "/healthz", LabelReadinessPort: "http"}); err == nil {
+		t.Fatal("expected an invalid port to fail")
+	}
+}
+
+func TestWaitHTTP(t *testing.T) {
+	prev := httpPollInterval
+	httpPollInterval = 10 * time.Millisecond
+	defer func() { httpPollInterval = prev }()
+
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	_, rawPort, _ := net.SplitHostPort(server.Listener.Addr().String())
+	port, _ := strconv.Atoi(rawPort)
+
+	ok, err := WaitHTTP(context.Background(), staticResolver("127.0.0.1"), []string{"c1"}, HTTPCheck{Path: "/healthz", Port: port}, time.Second)
+	if err != nil || !ok {
+		t.Fatalf("expected probe to pass once the app is ready, got ok=%v err=%v", ok, err)
+	}
+
+	ok, err = WaitHTTP(context.Background(), staticResolver("127.0.0.1"), []string{"c1"}, HTTPCheck{Path: "/missing", Port: port}, 50*time.Millisecond)
+	if err != nil || ok {
+		t.Fatalf("expected non-2xx probe to time out, got ok=%v err=%v", ok, err)
+	}
+}
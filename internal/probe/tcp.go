@@ -0,0 +1,58 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+var tcpPollInterval = time.Second
+
+type IPResolver interface {
+	IPAddress(ctx context.Context, containerID string) (string, error)
+}
+
+// WaitTCP waits until every container accepts a TCP connection on port, or the timeout
+// elapses. It returns false without an error when the timeout is reached.
+func WaitTCP(ctx context.Context, resolver IPResolver, containerIDs []string, port int, timeout time.Duration) (bool, error) {
+	addrs := make(map[string]string, len(containerIDs))
+	for _, id := range containerIDs {
+		ip, err := resolver.IPAddress(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		if ip == "" {
+			return false, fmt.Errorf("container %s has no IP address for TCP probe", id)
+		}
+		addrs[id] = net.JoinHostPort(ip, strconv.Itoa(port))
+	}
+
+	deadline := time.Now().Add(timeout)
+	ready := map[string]bool{}
+	for {
+		for id, addr := range addrs {
+			if ready[id] {
+				continue
+			}
+			conn, err := net.DialTimeout("tcp", addr, tcpPollInterval)
+			if err == nil {
+				_ = conn.Close()
+				ready[id] = true
+			}
+		}
+		if len(ready) == len(addrs) {
+			return true, nil
+		}
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(tcpPollInterval):
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package probe
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type staticResolver string
+
+func (r staticResolver) IPAddress(context.Context, string) (string, error) {
+	return string(r), nil
+}
+
+func TestWaitTCP(t *testing.T) {
+	prev := tcpPollInterval
+	tcpPollInterval = 10 * time.Millisecond
+	defer func() { tcpPollInterval = prev }()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	ok, err := WaitTCP(context.Background(), staticResolver("127.0.0.1"), []string{"c1"}, port, time.Second)
+	if err != nil || !ok {
+		t.Fatalf("expected open port to be ready, got ok=%v err=%v", ok, err)
+	}
+
+	_ = listener.Close()
+	ok, err = WaitTCP(context.Background(), staticResolver("127.0.0.1"), []string{"c1"}, port, 50*time.Millisecond)
+	if err != nil || ok {
+		t.Fatalf("expected closed port %s to time out, got ok=%v err=%v", strconv.Itoa(port), ok, err)
+	}
+}
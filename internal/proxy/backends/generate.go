@@ -0,0 +1,183 @@
+package backends
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
+)
+
+// Backend is one compose service routed by a file-configured proxy: the Host() names
+// of its router rule and its running containers.
+type Backend struct {
+	Name  string
+	Hosts []string
+	// Containers are the short IDs of the service's containers, which double as their
+	// hostnames on the compose network.
+	Containers []string
+	Port       string
+}
+
+// Renderer turns the backends of a proxy, sorted by name, into its config file.
+type Renderer func(backends []Backend) string
+
+// Generator renders the config of a proxy that reads a generated file, nginx-proxy or
+// haproxy, with one backend per service it routes, from the same Traefik labels the
+// Traefik generator reads.
+type Generator struct {
+	compose      compose.Adapter
+	docker       LabelReader
+	proxyType    string
+	name         string
+	render       Renderer
+	defaultProxy string
+	serviceLabel string
+	dryRun       io.Writer
+	log          *logrus.Logger
+}
+
+// LabelReader reads the labels of a container.
+type LabelReader interface {
+	Labels(ctx context.Context, containerID string) (map[string]string, error)
+}
+
+// NewGenerator returns a generator for the services routed by proxyType; name is how
+// logs and errors refer to the proxy.
+func NewGenerator(proxyType string, name string, render Renderer, composeAdapter compose.Adapter, dockerClient LabelReader) *Generator {
+	return &Generator{
+		compose:      composeAdapter,
+		docker:       dockerClient,
+		proxyType:    proxyType,
+		name:         name,
+		render:       render,
+		defaultProxy: proxyType,
+		serviceLabel: compose.DefaultServiceLabel,
+		log:          discardLogger(),
+	}
+}
+
+func (g *Generator) WithLogger(log *logrus.Logger) *Generator {
+	if log != nil {
+		g.log = log
+	}
+	return g
+}
+
+// WithDefaultProxy sets the proxy type assumed for services without a com.ztd.proxy label.
+func (g *Generator) WithDefaultProxy(proxyType string) *Generator {
+	if strings.TrimSpace(proxyType) != "" {
+		g.defaultProxy = proxyType
+	}
+	return g
+}
+
+// WithServiceLabel sets the container label that names the compose service.
+func (g *Generator) WithServiceLabel(key string) *Generator {
+	if strings.TrimSpace(key) != "" {
+		g.serviceLabel = key
+	}
+	return g
+}
+
+// WithDryRun makes Generate print the config it would write to out instead of
+// writing it; nil writes as usual.
+func (g *Generator) WithDryRun(out io.Writer) *Generator {
+	g.dryRun = out
+	return g
+}
+
+func (g *Generator) Generate(ctx context.Context, composeFiles []string, envFiles []string, outputPath string) error {
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
+	data, err := g.Build(ctx, composeFiles, envFiles)
+	if err != nil {
+		return err
+	}
+	if g.dryRun != nil {
+		g.log.Infof("==> [dry-run] Would write %s config to %s:", g.name, outputPath)
+		_, err := g.dryRun.Write(data)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return err
+	}
+	return configio.WriteAtomic(outputPath, data, 0o644)
+}
+
+// Build renders the config for the running containers without writing it.
+func (g *Generator) Build(ctx context.Context, composeFiles []string, envFiles []string) ([]byte, error) {
+	backends, err := g.Collect(ctx, composeFiles, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(g.render(backends)), nil
+}
+
+// Collect returns the backends of the services routed by the generator's proxy, sorted
+// by name, from the running containers.
+func (g *Generator) Collect(ctx context.Context, composeFiles []string, envFiles []string) ([]Backend, error) {
+	allContainerIDs, err := g.compose.PsQuiet(ctx, composeFiles, envFiles, "")
+	if err != nil {
+		return nil, err
+	}
+
+	backends := map[string]*Backend{}
+	for _, id := range allContainerIDs {
+		labels, err := g.docker.Labels(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		serviceName := labels[g.serviceLabel]
+		if serviceName == "" || labels["traefik.enable"] != "true" {
+			continue
+		}
+		if proxy.Resolve(labels, g.defaultProxy) != g.proxyType {
+			continue
+		}
+
+		backend, seen := backends[serviceName]
+		if !seen {
+			backend = &Backend{Name: serviceName}
+			if rule := labels["traefik.http.routers."+serviceName+".rule"]; rule != "" {
+				backend.Hosts = proxy.HostsFromRule(rule)
+				if len(backend.Hosts) == 0 {
+					g.log.Warnf("==> Service '%s' router rule has no Host() matcher; %s only gets a backend for it", serviceName, g.name)
+				}
+			}
+			backend.Port = traefik.BackendPort(ctx, g.log, g.docker, labels, serviceName, id)
+			backends[serviceName] = backend
+		}
+		backend.Containers = append(backend.Containers, configio.ShortID(id))
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("generated %s configuration is empty", g.name)
+	}
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]Backend, 0, len(names))
+	for _, name := range names {
+		g.log.Infof("==> Service '%s' routed by %s to %d server(s)", name, g.name, len(backends[name].Containers))
+		list = append(list, *backends[name])
+	}
+	return list, nil
+}
+
+func discardLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	TypeTraefik    = "traefik"
+	TypeNginxProxy = "nginx-proxy"
+	TypeHAProxy    = "haproxy"
+
+	// LabelType selects the proxy backend for a single compose service.
+	LabelType = "com.ztd.proxy"
+)
+
+// Resolve returns the proxy type declared by the service labels, or fallback
+// (the CLI --proxy value) when the label is absent.
+func Resolve(labels map[string]string, fallback string) string {
+	if value := strings.TrimSpace(labels[LabelType]); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func IsKnown(proxyType string) bool {
+	switch proxyType {
+	case TypeTraefik, TypeNginxProxy, TypeHAProxy:
+		return true
+	default:
+		return false
+	}
+}
+
+func ValidateKnown(proxyType string) error {
+	if !IsKnown(proxyType) {
+		return fmt.Errorf("unknown proxy type: %s", proxyType)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package proxy
+
+import "testing"
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	if got := Resolve(map[string]string{LabelType: "nginx-proxy"}, TypeTraefik); got != TypeNginxProxy {
+		t.Fatalf("expected label to win, got %s", got)
+	}
+	if got := Resolve(map[string]string{}, TypeTraefik); got != TypeTraefik {
+		t.Fatalf("expected fallback, got %s", got)
+	}
+	if got := Resolve(nil, TypeNginxProxy); got != TypeNginxProxy {
+		t.Fatalf("expected fallback for nil labels, got %s", got)
+	}
+}
+
+func TestValidateKnown(t *testing.T) {
+	t.Parallel()
+
+	if err := ValidateKnown(TypeTraefik); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := ValidateKnown("envoy"); err == nil {
+		t.Fatal("expected unknown proxy type to be rejected")
+	}
+}
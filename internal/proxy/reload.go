@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var reloadHTTPTimeout = 10 * time.Second
+
+// Reloader tells a proxy that does not watch its config to apply it after ztd rewrote
+// it. For nginx and HAProxy it first runs NginxCommand or HAProxyCommand through sh;
+// then, for every proxy, it runs Command through sh and POSTs to Endpoint. A failing
+// nginx or HAProxy command always fails the reload; Command and Endpoint failures are
+// logged unless Required is set. With DryRun set it only logs what it would run.
+type Reloader struct {
+	Command        string
+	Endpoint       string
+	Required       bool
+	NginxCommand   string
+	HAProxyCommand string
+	DryRun         bool
+}
+
+// Enabled reports whether a reload command or endpoint is configured.
+func (r Reloader) Enabled() bool {
+	return strings.TrimSpace(r.Command) != "" || strings.TrimSpace(r.Endpoint) != ""
+}
+
+// Reload applies a config written for proxyType: it runs the nginx or HAProxy reload
+// command when proxyType is one of those, then the reload command and endpoint.
+func (r Reloader) Reload(ctx context.Context, log *logrus.Logger, proxyType string) error {
+	if err := r.reloadProxy(ctx, log, proxyType); err != nil {
+		return err
+	}
+	if !r.Enabled() {
+		return nil
+	}
+	if r.DryRun {
+		log.Info("==> [dry-run] Would reload proxy")
+		return nil
+	}
+	log.Info("==> Reloading proxy")
+	err := r.reload(ctx)
+	if err == nil {
+		return nil
+	}
+	if r.Required {
+		return fmt.Errorf("proxy reload failed: %w", err)
+	}
+	log.Warnf("==> WARNING: proxy reload failed: %v", err)
+	return nil
+}
+
+// reloadProxy runs the nginx or HAProxy reload command. Neither proxy watches its
+// config, so without that command or Command/Endpoint the change only applies after a
+// manual reload.
+func (r Reloader) reloadProxy(ctx context.Context, log *logrus.Logger, proxyType string) error {
+	var name, flag, command string
+	switch proxyType {
+	case TypeNginxProxy:
+		name, flag, command = "nginx", "--nginx-reload", r.NginxCommand
+	case TypeHAProxy:
+		name, flag, command = "HAProxy", "--haproxy-reload", r.HAProxyCommand
+	default:
+		return nil
+	}
+	if strings.TrimSpace(command) == "" {
+		if !r.Enabled() {
+			log.Warnf("==> WARNING: no %s command set; reload %s to apply its config", flag, name)
+		}
+		return nil
+	}
+	if r.DryRun {
+		log.Infof("==> [dry-run] Would reload %s with %s", name, command)
+		return nil
+	}
+	log.Infof("==> Reloading %s", name)
+	out, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s reload %q: %w: %s", strings.ToLower(name), command, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (r Reloader) reload(ctx context.Context) error {
+	var errs []error
+	if command := strings.TrimSpace(r.Command); command != "" {
+		if out, err := exec.CommandContext(ctx, "sh", "-c", command).CombinedOutput(); err != nil {
+			errs = append(errs, fmt.Errorf("reload command %q: %w: %s", command, err, strings.TrimSpace(string(out))))
+		}
+	}
+	if endpoint := strings.TrimSpace(r.Endpoint); endpoint != "" {
+		if err := postReload(ctx, endpoint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func postReload(ctx context.Context, endpoint string) error {
+	ctx, cancel := context.WithTimeout(ctx, reloadHTTPTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("reload endpoint %s: %w", endpoint, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("reload endpoint %s: %w", endpoint, err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("reload endpoint %s returned %s", endpoint, resp.Status)
+	}
+	return nil
+}
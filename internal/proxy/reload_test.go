@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func discardLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestReloader_RunsCommandAndEndpoint(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "reloaded")
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			calls++
+		}
+	}))
+	defer server.Close()
+
+	reloader := Reloader{Command: "touch " + marker, Endpoint: server.URL, Required: true}
+	if err := reloader.Reload(context.Background(), discardLogger(), TypeTraefik); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("expected reload command to run: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected one POST to the endpoint, got %d", calls)
+	}
+}
+
+func TestReloader_FailureIsFatalOnlyWhenRequired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	if err := (Reloader{Endpoint: server.URL}).Reload(context.Background(), discardLogger(), TypeTraefik); err != nil {
+		t.Fatalf("expected failure to be logged only, got %v", err)
+	}
+	if err := (Reloader{Endpoint: server.URL, Required: true}).Reload(context.Background(), discardLogger(), TypeTraefik); err == nil {
+		t.Fatal("expected error for a non-2xx endpoint")
+	}
+	if err := (Reloader{Command: "exit 1", Required: true}).Reload(context.Background(), discardLogger(), TypeTraefik); err == nil {
+		t.Fatal("expected error for a failing command")
+	}
+	if err := (Reloader{Required: true}).Reload(context.Background(), discardLogger(), TypeTraefik); err != nil {
+		t.Fatalf("expected no-op without command or endpoint, got %v", err)
+	}
+}
+
+func TestReloader_RunsProxyCommandFirst(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "reloads")
+	reloader := Reloader{NginxCommand: "echo nginx >> " + marker, HAProxyCommand: "echo haproxy >> " + marker, Command: "echo cmd >> " + marker}
+
+	for _, proxyType := range []string{TypeNginxProxy, TypeHAProxy, TypeTraefik} {
+		if err := reloader.Reload(context.Background(), discardLogger(), proxyType); err != nil {
+			t.Fatalf("reload %s: %v", proxyType, err)
+		}
+	}
+	data, err := os.ReadFile(marker)
+	if err != nil {
+		t.Fatalf("read marker: %v", err)
+	}
+	if string(data) != "nginx\ncmd\nhaproxy\ncmd\ncmd\n" {
+		t.Fatalf("unexpected reload order: %q", data)
+	}
+
+	if err := (Reloader{HAProxyCommand: "exit 1"}).Reload(context.Background(), discardLogger(), TypeHAProxy); err == nil {
+		t.Fatal("expected a failing HAProxy reload command to fail without Required")
+	}
+}
+
+func TestReloader_DryRunRunsNothing(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "reloaded")
+	reloader := Reloader{Command: "touch " + marker, NginxCommand: "touch " + marker, Required: true, DryRun: true}
+	if err := reloader.Reload(context.Background(), discardLogger(), TypeNginxProxy); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("expected no reload command to run in a dry run")
+	}
+}
@@ -0,0 +1,25 @@
+package proxy
+
+import "regexp"
+
+var (
+	hostMatcher = regexp.MustCompile(`\bHost\(([^)]*)\)`)
+	quotedValue = regexp.MustCompile("[`\"]([^`\"]+)[`\"]")
+)
+
+// HostsFromRule returns the domains of every Host() matcher in a Traefik router rule,
+// e.g. Host(`a.com`) || Host(`b.com`, `c.com`). Other matchers are ignored.
+func HostsFromRule(rule string) []string {
+	var hosts []string
+	seen := map[string]struct{}{}
+	for _, match := range hostMatcher.FindAllStringSubmatch(rule, -1) {
+		for _, value := range quotedValue.FindAllStringSubmatch(match[1], -1) {
+			if _, dup := seen[value[1]]; dup {
+				continue
+			}
+			seen[value[1]] = struct{}{}
+			hosts = append(hosts, value[1])
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,21 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHostsFromRule(t *testing.T) {
+	cases := map[string][]string{
+		"Host(`example.com`)":                                 {"example.com"},
+		"Host(`a.com`) || Host(`b.com`, `c.com`)":             {"a.com", "b.com", "c.com"},
+		"Host(`example.com`) && PathPrefix(`/api`)":           {"example.com"},
+		"HostRegexp(`{sub:[a-z]+}.example.com`)":              nil,
+		"Host(\"quoted.example.com\") || Host(`example.com`)": {"quoted.example.com", "example.com"},
+	}
+	for rule, want := range cases {
+		if got := HostsFromRule(rule); !reflect.DeepEqual(got, want) {
+			t.Fatalf("HostsFromRule(%q) = %v, want %v", rule, got, want)
+		}
+	}
+}
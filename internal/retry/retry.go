@@ -0,0 +1,71 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultMaxRetries is how many times a docker or compose command failing with a
+// transient daemon error is retried by default.
+const DefaultMaxRetries = 3
+
+const (
+	initialBackoff = 250 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// transientMarkers are error texts of Docker daemon hiccups that usually pass on their own.
+var transientMarkers = []string{
+	"cannot connect to the docker daemon",
+	"error during connect",
+	"connection refused",
+	"connection reset",
+	"broken pipe",
+	"i/o timeout",
+	"tls handshake timeout",
+	"unexpected eof",
+	"service unavailable",
+	"too many requests",
+}
+
+// Do calls fn until it succeeds, fails with an error IsTransient rejects, or has been
+// retried maxRetries times. The wait between attempts doubles from 250ms up to 5s.
+func Do(ctx context.Context, maxRetries int, fn func() error) error {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= maxRetries || !IsTransient(err) || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// IsTransient reports whether err looks like a temporary connection problem with the
+// Docker daemon, checking the stderr of failed commands too. Errors such as "No such
+// container" are permanent.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	text := err.Error()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		text += " " + string(exitErr.Stderr)
+	}
+	text = strings.ToLower(text)
+	for _, marker := range transientMarkers {
+		if strings.Contains(text, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDo_RetriesTransientErrors(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), 3, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("Cannot connect to the Docker daemon at unix:///var/run/docker.sock")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on the third attempt, got err=%v calls=%d", err, calls)
+	}
+}
+
+func TestDo_StopsOnPermanentErrorsAndMaxRetries(t *testing.T) {
+	calls := 0
+	err := Do(context.Background(), 3, func() error {
+		calls++
+		return errors.New("Error: No such container: abc")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected a permanent error not to be retried, got err=%v calls=%d", err, calls)
+	}
+
+	calls = 0
+	err = Do(context.Background(), 1, func() error {
+		calls++
+		return errors.New("read: connection reset by peer")
+	})
+	if err == nil || calls != 2 {
+		t.Fatalf("expected one retry, got err=%v calls=%d", err, calls)
+	}
+}
+
+func TestDo_StopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := Do(ctx, 5, func() error {
+		calls++
+		return errors.New("connection refused")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected no retry after cancellation, got err=%v calls=%d", err, calls)
+	}
+}
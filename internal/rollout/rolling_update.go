@@ -2,13 +2,21 @@ package rollout
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/haproxy"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/healthdiag"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/nginx"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/probe"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/traefik"
 )
@@ -22,6 +30,40 @@ type Options struct {
 	WaitAfterHealthy     int
 	ProxyType            string
 	TraefikConfigFile    string
+	DNSNames             bool
+	ServiceLabel         string
+	NginxConfigFile      string
+	HAProxyConfigFile    string
+	TimeoutAction        string
+	PollInterval         time.Duration
+	StartPeriod          time.Duration
+	HealthyStatuses      []string
+	SurgeOverrides       []string
+	CriticalCount        int
+	FailOnUnmatched      bool
+	TCPProbePort         int
+	ReconcileCount       bool
+	Replicas             int
+	SurgeAdd             int
+	SurgeTarget          int
+	MinOldUptime         time.Duration
+	ForceRegenerate      bool
+	// TraefikAPI is the Traefik API base URL. When set, teardown waits until the API
+	// reports the new servers instead of sleeping NoHealthcheckTimeout.
+	TraefikAPI string
+	// Drain, when set, is how long old containers keep running after the proxy stopped
+	// routing to them, replacing the NoHealthcheckTimeout pause.
+	Drain time.Duration
+	// NoProxy leaves every proxy config alone: containers are replaced, but routing
+	// to them is up to an external load balancer.
+	NoProxy bool
+	// PreStop, when set, runs against the old containers of each batch right before
+	// they are stopped.
+	PreStop func(ctx context.Context, containerIDs []string)
+	// DryRun marks a run whose compose adapter, docker client and generators only log
+	// what they would do. Scaling then starts no containers, so each batch goes from the
+	// scale-up straight to the teardown of its old containers.
+	DryRun bool
 }
 
 type Updater struct {
@@ -29,31 +71,68 @@ type Updater struct {
 	compose   compose.Adapter
 	docker    dockerOps
 	generator generatorOps
+	nginx     generatorOps
+	haproxy   generatorOps
+	events    events.Sink
+	reloader  proxy.Reloader
 }
 
 type dockerOps interface {
 	HasHealthcheck(ctx context.Context, containerID string) (bool, error)
 	HealthStatus(ctx context.Context, containerID string) (string, error)
 	LogsTail(ctx context.Context, containerID string, tail int) (string, error)
+	State(ctx context.Context, containerID string) (docker.ContainerState, error)
 	Stop(ctx context.Context, containerIDs []string) error
 	Remove(ctx context.Context, containerIDs []string) error
+	Labels(ctx context.Context, containerID string) (map[string]string, error)
+	IPAddress(ctx context.Context, containerID string) (string, error)
 }
 
 type generatorOps interface {
 	Generate(ctx context.Context, composeFiles []string, envFiles []string, outputPath string) error
 }
 
+type traefikRegenerator interface {
+	Regenerate(ctx context.Context, composeFiles []string, envFiles []string, outputPath string, exclude []string) error
+}
+
 func NewUpdater(log *logrus.Logger, composeAdapter compose.Adapter, dockerClient dockerOps, generator generatorOps) *Updater {
 	return &Updater{
 		log:       log,
 		compose:   composeAdapter,
 		docker:    dockerClient,
 		generator: generator,
+		events:    events.Nop{},
 	}
 }
 
+func (u *Updater) WithEvents(sink events.Sink) *Updater {
+	if sink != nil {
+		u.events = sink
+	}
+	return u
+}
+
+// WithNginxGenerator sets the generator used for services routed by nginx-proxy.
+func (u *Updater) WithNginxGenerator(generator generatorOps) *Updater {
+	u.nginx = generator
+	return u
+}
+
+// WithReloader sets how the proxy is told to apply a rewritten config.
+func (u *Updater) WithReloader(reloader proxy.Reloader) *Updater {
+	u.reloader = reloader
+	return u
+}
+
+// WithHAProxyGenerator sets the generator used for services routed by HAProxy.
+func (u *Updater) WithHAProxyGenerator(generator generatorOps) *Updater {
+	u.haproxy = generator
+	return u
+}
+
 func (u *Updater) Run(ctx context.Context, opt Options) (err error) {
-	if err := validateProxyType(opt.ProxyType); err != nil {
+	if err := proxy.ValidateKnown(opt.ProxyType); err != nil {
 		return err
 	}
 
@@ -62,21 +141,222 @@ func (u *Updater) Run(ctx context.Context, opt Options) (err error) {
 		return err
 	}
 	if len(oldIDs) == 0 {
-		u.log.Infof("==> Service '%s' is not running. Starting the service.", opt.Service)
-		return u.compose.Up(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service, true, true)
+		return u.coldStart(ctx, opt)
+	}
+
+	proxyType := ""
+	if !opt.NoProxy {
+		labels, err := u.docker.Labels(ctx, oldIDs[0])
+		if err != nil {
+			return err
+		}
+		proxyType = proxy.Resolve(labels, opt.ProxyType)
+		if err := validateProxyType(proxyType); err != nil {
+			return fmt.Errorf("service %s: %w", opt.Service, err)
+		}
+		if proxyType == proxy.TypeNginxProxy && u.nginx == nil {
+			return fmt.Errorf("service %s: nginx-proxy generator is not configured", opt.Service)
+		}
+		if proxyType == proxy.TypeHAProxy && u.haproxy == nil {
+			return fmt.Errorf("service %s: haproxy generator is not configured", opt.Service)
+		}
 	}
 
 	scale := len(oldIDs)
-	target := scale * 2
+	step := SurgeSize(opt, scale)
+	if step < scale {
+		u.log.Infof("==> Replacing %d containers of '%s' in batches of %d", scale, opt.Service, step)
+	}
+	runningIDs := oldIDs
+	for start := 0; start < scale; start += step {
+		batch := oldIDs[start:min(start+step, scale)]
+		if err := u.replaceBatch(ctx, opt, proxyType, runningIDs, batch); err != nil {
+			return err
+		}
+		if start+step < scale {
+			if runningIDs, err = u.compose.PsQuiet(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service); err != nil {
+				return err
+			}
+		}
+	}
+	if err := u.verifyReplicaCount(ctx, opt, scale); err != nil {
+		return err
+	}
+
+	if opt.NoProxy {
+		return nil
+	}
+	if proxyType == proxy.TypeNginxProxy {
+		if err := u.nginx.Generate(ctx, opt.ComposeFiles, opt.EnvFiles, opt.NginxConfigFile); err != nil {
+			return err
+		}
+		return u.reloader.Reload(ctx, u.log, proxyType)
+	}
+	if proxyType == proxy.TypeHAProxy {
+		if err := u.haproxy.Generate(ctx, opt.ComposeFiles, opt.EnvFiles, opt.HAProxyConfigFile); err != nil {
+			return err
+		}
+		return u.reloader.Reload(ctx, u.log, proxyType)
+	}
+	if err := u.generator.Generate(ctx, opt.ComposeFiles, opt.EnvFiles, opt.TraefikConfigFile); err != nil {
+		return err
+	}
+	return u.reloader.Reload(ctx, u.log, proxyType)
+}
+
+// updateTraefikServers points the service's Traefik servers at the new containers,
+// by container ID or, with DNSNames, by compose DNS name, weighted by their own
+// com.ztd.weight labels.
+func (u *Updater) updateTraefikServers(ctx context.Context, opt Options, oldIDs []string, newIDs []string) (int, error) {
+	weights, err := u.serverWeights(ctx, newIDs)
+	if err != nil {
+		return 0, err
+	}
+	if !opt.DNSNames {
+		return traefik.UpdateContainerIDsInConfig(opt.TraefikConfigFile, oldIDs, newIDs, weights)
+	}
+	oldHosts, err := u.serverHosts(ctx, opt, oldIDs)
+	if err != nil {
+		return 0, err
+	}
+	newHosts, err := u.serverHosts(ctx, opt, newIDs)
+	if err != nil {
+		return 0, err
+	}
+	return traefik.UpdateServerHostsInConfig(opt.TraefikConfigFile, oldHosts, newHosts, weights)
+}
+
+// regenerateTraefikConfig rebuilds the whole Traefik config from the running containers
+// and compose labels instead of swapping server IDs, so new services and label changes
+// are picked up. The old containers are left out since they stop next.
+func (u *Updater) regenerateTraefikConfig(ctx context.Context, opt Options, oldIDs []string) error {
+	regenerator, ok := u.generator.(traefikRegenerator)
+	if !ok {
+		return fmt.Errorf("the Traefik generator cannot regenerate the config")
+	}
+	u.log.Infof("==> Regenerating the whole Traefik config %s", opt.TraefikConfigFile)
+	return regenerator.Regenerate(ctx, opt.ComposeFiles, opt.EnvFiles, opt.TraefikConfigFile, oldIDs)
+}
+
+func (u *Updater) serverHosts(ctx context.Context, opt Options, ids []string) ([]string, error) {
+	hosts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		labels, err := u.docker.Labels(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, traefik.ServerHost(id, labels, opt.ServiceLabel, true))
+	}
+	return hosts, nil
+}
+
+// serverWeights returns the com.ztd.weight of each container in ids, 0 when it sets none.
+func (u *Updater) serverWeights(ctx context.Context, ids []string) ([]int, error) {
+	weights := make([]int, 0, len(ids))
+	for _, id := range ids {
+		labels, err := u.docker.Labels(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		weight, err := traefik.ServerWeight(labels)
+		if err != nil {
+			return nil, fmt.Errorf("container %s: %w", configio.ShortID(id), err)
+		}
+		weights = append(weights, weight)
+	}
+	return weights, nil
+}
+
+// waitTraefikLoaded polls the Traefik API until it serves the rewritten config, so old
+// containers are only removed once Traefik routes to the new ones.
+func (u *Updater) waitTraefikLoaded(ctx context.Context, opt Options, newIDs []string) error {
+	hosts := make([]string, 0, len(newIDs))
+	for _, id := range newIDs {
+		labels, err := u.docker.Labels(ctx, id)
+		if err != nil {
+			return err
+		}
+		hosts = append(hosts, traefik.ServerHost(id, labels, opt.ServiceLabel, opt.DNSNames))
+	}
+	u.log.Infof("==> Waiting for Traefik at %s to load the new servers of '%s' (timeout: %d seconds)", opt.TraefikAPI, opt.Service, opt.HealthcheckTimeout)
+	timeout := time.Duration(opt.HealthcheckTimeout) * time.Second
+	if err := traefik.WaitForAPI(ctx, nil, opt.TraefikAPI, opt.TraefikConfigFile, hosts, timeout, opt.PollInterval); err != nil {
+		return err
+	}
+	u.log.Infof("==> Traefik routes '%s' to the new containers; stopping and removing old containers", opt.Service)
+	return nil
+}
+
+// restoreProxyConfig puts back the config backed up before the swap, so a rolled back
+// batch never leaves the proxy routing to the new containers. When another deploy
+// changed the file since, only this batch's servers are swapped back. The proxy is then
+// reloaded like after the swap.
+func (u *Updater) restoreProxyConfig(ctx context.Context, opt Options, proxyType string, backup *configio.ConfigBackup, oldIDs []string, newIDs []string) error {
+	if backup == nil {
+		return nil
+	}
+	name, path := "Traefik", opt.TraefikConfigFile
+	swapBack := func() error {
+		_, err := u.updateTraefikServers(ctx, opt, newIDs, oldIDs)
+		return err
+	}
+	switch proxyType {
+	case proxy.TypeNginxProxy:
+		name, path = "nginx", opt.NginxConfigFile
+		swapBack = func() error {
+			_, err := nginx.UpdateContainerIDsInConfig(path, newIDs, oldIDs)
+			return err
+		}
+	case proxy.TypeHAProxy:
+		name, path = "HAProxy", opt.HAProxyConfigFile
+		swapBack = func() error {
+			_, err := haproxy.UpdateBackendServers(path, opt.Service, newIDs, oldIDs)
+			return err
+		}
+	}
+
+	err := configio.RestoreConfig(backup)
+	if errors.Is(err, configio.ErrConfigChanged) {
+		u.log.Warnf("==> %s config changed since the swap; pointing service '%s' back at its old containers", name, opt.Service)
+		err = swapBack()
+	} else if err == nil {
+		u.log.Infof("==> Restored %s config %s from the pre-swap backup", name, path)
+	}
+	if err != nil {
+		return err
+	}
+	return u.reloader.Reload(ctx, u.log, proxyType)
+}
+
+// replaceBatch surges the service by one new container per entry of oldIDs, waits for
+// them, points the proxy at them and removes oldIDs. runningIDs are all containers of
+// the service before the surge, so earlier batches' new containers are not mistaken for
+// this batch's.
+func (u *Updater) replaceBatch(ctx context.Context, opt Options, proxyType string, runningIDs []string, oldIDs []string) (err error) {
+	scale := len(oldIDs)
+	target := len(runningIDs) + scale
 	u.log.Infof("==> Scaling '%s' to '%d' instances", opt.Service, target)
-	if err := u.compose.Scale(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service, target); err != nil {
+	events.Phase(u.events, opt.Service, events.PhaseScale)
+	if err := u.compose.Scale(ctx, compose.SurgeFiles(opt.ComposeFiles, opt.SurgeOverrides), opt.EnvFiles, opt.Service, target); err != nil {
 		return err
 	}
+	if opt.DryRun {
+		return u.dryRunTeardown(ctx, opt, proxyType, oldIDs)
+	}
 	newIDs := []string{}
+	var configBackup *configio.ConfigBackup
+	defer func() {
+		if err := configBackup.Discard(); err != nil {
+			u.log.Warnf("==> Failed to remove proxy config backup: %v", err)
+		}
+	}()
 	guard := safeguard.NewRollbackGuard(u.log, "post-scale rollback", func(ctx context.Context) error {
 		if len(newIDs) == 0 {
 			return nil
 		}
+		if err := u.restoreProxyConfig(ctx, opt, proxyType, configBackup, oldIDs, newIDs); err != nil {
+			u.log.Errorf("==> Failed to restore proxy config: %v", err)
+		}
 		u.log.Warnf("==> Cleaning up new containers: %v", newIDs)
 		stopErr := u.docker.Stop(ctx, newIDs)
 		rmErr := u.docker.Remove(ctx, newIDs)
@@ -88,7 +368,7 @@ func (u *Updater) Run(ctx context.Context, opt Options) (err error) {
 	if err != nil {
 		return err
 	}
-	newIDs = diffIDs(oldIDs, allIDs)
+	newIDs = diffIDs(runningIDs, allIDs)
 	if len(newIDs) == 0 {
 		return fmt.Errorf("could not find new containers for service %s", opt.Service)
 	}
@@ -97,84 +377,344 @@ func (u *Updater) Run(ctx context.Context, opt Options) (err error) {
 	if err != nil {
 		return err
 	}
+	newLabels, err := u.docker.Labels(ctx, newIDs[0])
+	if err != nil {
+		return err
+	}
+	httpCheck, hasHTTPCheck, err := probe.HTTPCheckFromLabels(newLabels)
+	if err != nil {
+		return err
+	}
 
 	if hasHC {
 		u.log.Infof("==> Waiting for new containers to be healthy (timeout: %d seconds)", opt.HealthcheckTimeout)
-		ok, err := u.waitHealthy(ctx, newIDs, scale, opt.HealthcheckTimeout)
+		events.WaitPhase(u.events, opt.Service, events.ReadinessHealthcheck)
+		gated, gatedExpected, rest := healthdiag.CriticalSubset(newIDs, scale, opt.CriticalCount)
+		ok, err := u.waitHealthy(ctx, gated, gatedExpected, opt.HealthcheckTimeout, opt.PollInterval, opt.StartPeriod, opt.HealthyStatuses, events.NewHealthTracker(u.events, opt.Service))
 		if err != nil {
 			return err
 		}
-		if !ok {
-			u.log.Error("==> New containers are not healthy. Rolling back.")
-			healthdiag.LogUnhealthyContainerLogs(ctx, u.log, u.docker, newIDs, 20)
-			_ = u.docker.Stop(ctx, newIDs)
-			_ = u.docker.Remove(ctx, newIDs)
-			guard.Disarm()
-			return fmt.Errorf("rollback completed after healthcheck failure")
+		if ok && len(rest) > 0 {
+			healthdiag.LogNonCriticalStatus(ctx, u.log, u.docker, rest)
 		}
-
-		if opt.WaitAfterHealthy > 0 {
+		if !ok {
+			healthdiag.LogUnhealthyContainerLogs(ctx, u.log, u.docker, newIDs, opt.HealthyStatuses, 20)
+			switch opt.TimeoutAction {
+			case safeguard.TimeoutActionKeep:
+				u.log.Errorf("==> New containers are not healthy. Keeping them without switching traffic: %v", newIDs)
+				guard.Disarm()
+				return safeguard.WithReason(healthdiag.HealthFailureReason(ctx, u.docker, newIDs), fmt.Errorf("new containers are not healthy; kept without switching traffic"))
+			case safeguard.TimeoutActionForce:
+				u.log.Warn("==> New containers are not healthy. Switching traffic anyway (--timeout-action=force).")
+			default:
+				u.log.Error("==> New containers are not healthy. Rolling back.")
+				events.Phase(u.events, opt.Service, events.PhaseRollback)
+				return safeguard.WithRollback(healthdiag.HealthFailureReason(ctx, u.docker, newIDs), fmt.Errorf("new containers are not healthy"))
+			}
+		} else if opt.WaitAfterHealthy > 0 {
 			u.log.Infof("==> Waiting for healthy containers to settle down (%d seconds)", opt.WaitAfterHealthy)
-			time.Sleep(time.Duration(opt.WaitAfterHealthy) * time.Second)
+			if err := safeguard.Sleep(ctx, time.Duration(opt.WaitAfterHealthy)*time.Second); err != nil {
+				return err
+			}
 		}
-	} else {
+	} else if opt.TCPProbePort > 0 {
+		u.log.Infof("==> Waiting for new containers to accept TCP connections on port %d (timeout: %d seconds)", opt.TCPProbePort, opt.HealthcheckTimeout)
+		events.WaitPhase(u.events, opt.Service, events.ReadinessTCPProbe)
+		ok, err := probe.WaitTCP(ctx, u.docker, newIDs, opt.TCPProbePort, time.Duration(opt.HealthcheckTimeout)*time.Second)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			u.log.Error("==> New containers did not accept TCP connections in time. Rolling back.")
+			events.Phase(u.events, opt.Service, events.PhaseRollback)
+			return safeguard.WithRollback(safeguard.ReasonTCPProbeTimeout, fmt.Errorf("new containers did not accept TCP connections on port %d", opt.TCPProbePort))
+		}
+	} else if !hasHTTPCheck {
 		u.log.Infof("==> Waiting for new containers to be ready (%d seconds)", opt.NoHealthcheckTimeout)
-		time.Sleep(time.Duration(opt.NoHealthcheckTimeout) * time.Second)
+		events.WaitPhase(u.events, opt.Service, events.ReadinessFixedWait)
+		if err := safeguard.Sleep(ctx, time.Duration(opt.NoHealthcheckTimeout)*time.Second); err != nil {
+			return err
+		}
+	}
+	if hasHTTPCheck {
+		u.log.Infof("==> Waiting for new containers to answer GET %s on port %d (timeout: %d seconds)", httpCheck.Path, httpCheck.Port, opt.HealthcheckTimeout)
+		events.WaitPhase(u.events, opt.Service, events.ReadinessHTTPProbe)
+		ok, err := probe.WaitHTTP(ctx, u.docker, newIDs, httpCheck, time.Duration(opt.HealthcheckTimeout)*time.Second)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			u.log.Error("==> New containers did not pass the HTTP readiness probe in time. Rolling back.")
+			events.Phase(u.events, opt.Service, events.PhaseRollback)
+			return safeguard.WithRollback(safeguard.ReasonHTTPProbeTimeout, fmt.Errorf("new containers did not answer GET %s on port %d with 2xx", httpCheck.Path, httpCheck.Port))
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	switch opt.ProxyType {
-	case "traefik":
+	// Past this point traffic moves to the new containers, so an interrupt no longer
+	// rolls back: the swap and teardown run to completion. Only the --min-old-uptime
+	// wait, which can run for minutes, still stops on one.
+	deployCtx := ctx
+	ctx = context.WithoutCancel(ctx)
+	switch proxyType {
+	case "":
+		u.log.Infof("==> Leaving proxy config untouched for service '%s' (--no-proxy)", opt.Service)
+	case proxy.TypeTraefik:
 		u.log.Infof("==> Updating Traefik config for service: %s", opt.Service)
-		if err := traefik.UpdateContainerIDsInConfig(opt.TraefikConfigFile, oldIDs, newIDs); err != nil {
+		events.Phase(u.events, opt.Service, events.PhaseUpdateConfig)
+		if configBackup, err = configio.BackupConfig(opt.TraefikConfigFile); err != nil {
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("back up Traefik config: %w", err))
+		}
+		if opt.ForceRegenerate {
+			if err := u.regenerateTraefikConfig(ctx, opt, oldIDs); err != nil {
+				u.log.Errorf("==> Failed to regenerate Traefik config: %v. Keeping old containers serving.", err)
+				return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("regenerate Traefik config: %w", err))
+			}
+			if err := configBackup.MarkWritten(); err != nil {
+				return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("read updated Traefik config: %w", err))
+			}
+			break
+		}
+		replaced, err := u.updateTraefikServers(ctx, opt, oldIDs, newIDs)
+		if err != nil {
+			u.log.Errorf("==> Failed to write Traefik config: %v. Keeping old containers serving.", err)
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("update Traefik config: %w", err))
+		}
+		if err := configBackup.MarkWritten(); err != nil {
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("read updated Traefik config: %w", err))
+		}
+		if replaced == 0 {
+			u.log.Warnf("==> WARNING: no Traefik servers in %s matched the old containers of service '%s'; traffic may not reach the new containers", opt.TraefikConfigFile, opt.Service)
+			if opt.FailOnUnmatched {
+				return safeguard.WithRollback(safeguard.ReasonUnmatchedConfig, fmt.Errorf("no Traefik servers matched old containers of service %s; keeping old containers", opt.Service))
+			}
+		}
+	case proxy.TypeNginxProxy:
+		u.log.Infof("==> Updating nginx config for service: %s", opt.Service)
+		events.Phase(u.events, opt.Service, events.PhaseUpdateConfig)
+		if configBackup, err = configio.BackupConfig(opt.NginxConfigFile); err != nil {
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("back up nginx config: %w", err))
+		}
+		replaced, err := nginx.UpdateContainerIDsInConfig(opt.NginxConfigFile, oldIDs, newIDs)
+		if err != nil {
+			u.log.Errorf("==> Failed to write nginx config: %v. Keeping old containers serving.", err)
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("update nginx config: %w", err))
+		}
+		if err := configBackup.MarkWritten(); err != nil {
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("read updated nginx config: %w", err))
+		}
+		if replaced == 0 {
+			u.log.Warnf("==> WARNING: no nginx upstream servers in %s matched the old containers of service '%s'; traffic may not reach the new containers", opt.NginxConfigFile, opt.Service)
+			if opt.FailOnUnmatched {
+				return safeguard.WithRollback(safeguard.ReasonUnmatchedConfig, fmt.Errorf("no nginx upstream servers matched old containers of service %s; keeping old containers", opt.Service))
+			}
+		}
+	case proxy.TypeHAProxy:
+		u.log.Infof("==> Updating HAProxy backend for service: %s", opt.Service)
+		events.Phase(u.events, opt.Service, events.PhaseUpdateConfig)
+		if configBackup, err = configio.BackupConfig(opt.HAProxyConfigFile); err != nil {
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("back up HAProxy config: %w", err))
+		}
+		replaced, err := haproxy.UpdateBackendServers(opt.HAProxyConfigFile, opt.Service, oldIDs, newIDs)
+		if err != nil {
+			u.log.Errorf("==> Failed to write HAProxy config: %v. Keeping old containers serving.", err)
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("update HAProxy config: %w", err))
+		}
+		if err := configBackup.MarkWritten(); err != nil {
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, fmt.Errorf("read updated HAProxy config: %w", err))
+		}
+		if replaced == 0 {
+			u.log.Warnf("==> WARNING: no servers of HAProxy backend '%s' in %s matched the old containers; traffic may not reach the new containers", opt.Service, opt.HAProxyConfigFile)
+			if opt.FailOnUnmatched {
+				return safeguard.WithRollback(safeguard.ReasonUnmatchedConfig, fmt.Errorf("no HAProxy servers matched old containers of service %s; keeping old containers", opt.Service))
+			}
+		}
+	}
+
+	if err := u.reloader.Reload(ctx, u.log, proxyType); err != nil {
+		return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, err)
+	}
+
+	confirmLoaded := proxyType == proxy.TypeTraefik && opt.TraefikAPI != ""
+	if confirmLoaded {
+		if err := u.waitTraefikLoaded(ctx, opt, newIDs); err != nil {
+			u.log.Errorf("==> %v. Keeping old containers serving.", err)
+			return safeguard.WithReason(safeguard.ReasonConfigWriteFailed, err)
+		}
+	}
+
+	if proxyType == "" {
+		// No proxy config was written, so the swap is not reported as one.
+		u.events.Emit(events.Event{Type: events.TypeContainersReady, Service: opt.Service, Count: len(newIDs), Containers: newIDs, Replaced: oldIDs})
+	} else {
+		u.events.Emit(events.Event{Type: events.TypeSwapComplete, Service: opt.Service, Count: len(newIDs), Containers: newIDs})
+	}
+	events.Phase(u.events, opt.Service, events.PhaseDrain)
+	switch {
+	case opt.Drain > 0:
+		u.log.Infof("==> Old containers %v no longer receive traffic, draining in-flight requests for %s", oldIDs, opt.Drain)
+		if err := safeguard.Sleep(ctx, opt.Drain); err != nil {
 			return err
 		}
+	case !confirmLoaded:
+		u.log.Infof("==> Sleeping %d second, after that, stopping and removing old containers", opt.NoHealthcheckTimeout)
+		time.Sleep(time.Duration(opt.NoHealthcheckTimeout) * time.Second)
 	}
 
-	u.log.Infof("==> Sleeping %d second, after that, stopping and removing old containers", opt.NoHealthcheckTimeout)
-	time.Sleep(time.Duration(opt.NoHealthcheckTimeout) * time.Second)
+	if err := u.waitMinOldUptime(deployCtx, opt, oldIDs); err != nil {
+		return err
+	}
 
 	guard.Disarm()
 	u.log.Infof("==> These containers %v will be stopped and removed", oldIDs)
+	u.events.Emit(events.Event{Type: events.TypePhase, Service: opt.Service, Phase: events.PhaseRemove, Count: len(oldIDs), Containers: oldIDs})
+	if opt.PreStop != nil {
+		opt.PreStop(ctx, oldIDs)
+	}
 	if err := u.docker.Stop(ctx, oldIDs); err != nil {
 		return err
 	}
 	if err := u.docker.Remove(ctx, oldIDs); err != nil {
 		return err
 	}
+	return nil
+}
 
-	return u.generator.Generate(ctx, opt.ComposeFiles, opt.EnvFiles, opt.TraefikConfigFile)
+// dryRunTeardown logs the readiness wait and proxy swap of a dry-run batch, which need
+// the new containers, then runs the teardown of oldIDs, which only logs as well.
+func (u *Updater) dryRunTeardown(ctx context.Context, opt Options, proxyType string, oldIDs []string) error {
+	u.log.Infof("==> [dry-run] Would wait up to %d seconds for the new containers of '%s' to become ready", opt.HealthcheckTimeout, opt.Service)
+	switch proxyType {
+	case proxy.TypeTraefik:
+		u.log.Infof("==> [dry-run] Would point the Traefik servers of '%s' in %s at the new containers", opt.Service, opt.TraefikConfigFile)
+	case proxy.TypeNginxProxy:
+		u.log.Infof("==> [dry-run] Would point the nginx upstream of '%s' in %s at the new containers", opt.Service, opt.NginxConfigFile)
+	case proxy.TypeHAProxy:
+		u.log.Infof("==> [dry-run] Would point the HAProxy backend '%s' in %s at the new containers", opt.Service, opt.HAProxyConfigFile)
+	}
+	if opt.PreStop != nil {
+		opt.PreStop(ctx, oldIDs)
+	}
+	if err := u.docker.Stop(ctx, oldIDs); err != nil {
+		return err
+	}
+	return u.docker.Remove(ctx, oldIDs)
 }
 
-func (u *Updater) waitHealthy(ctx context.Context, containerIDs []string, expected int, timeoutSec int) (bool, error) {
-	deadline := time.Now().Add(time.Duration(timeoutSec) * time.Second)
-	for time.Now().Before(deadline) {
-		okCount := 0
-		for _, id := range containerIDs {
-			status, err := u.docker.HealthStatus(ctx, id)
-			if err != nil {
-				return false, err
-			}
-			if status == "healthy" {
-				okCount++
-			}
-		}
-		if okCount == expected {
-			return true, nil
-		}
-		time.Sleep(time.Second)
+// SurgeSize returns how many new containers each batch adds while running old ones
+// are replaced: all of them by default, SurgeAdd, or enough to reach SurgeTarget.
+func SurgeSize(opt Options, running int) int {
+	switch {
+	case opt.SurgeAdd > 0:
+		return min(opt.SurgeAdd, running)
+	case opt.SurgeTarget > 0:
+		return min(max(opt.SurgeTarget-running, 1), running)
+	default:
+		return running
+	}
+}
+
+// coldStart starts a service that is not running yet. With Replicas set, all started
+// containers must pass the healthcheck before the deploy is reported as done.
+func (u *Updater) coldStart(ctx context.Context, opt Options) error {
+	if opt.Replicas <= 0 {
+		u.log.Infof("==> Service '%s' is not running. Starting the service.", opt.Service)
+		return u.compose.Up(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service, true, true)
+	}
+
+	u.log.Infof("==> Service '%s' is not running. Starting %d instances.", opt.Service, opt.Replicas)
+	events.Phase(u.events, opt.Service, events.PhaseScale)
+	if err := compose.StartService(ctx, u.compose, opt.ComposeFiles, opt.EnvFiles, opt.Service, opt.Replicas); err != nil {
+		return err
+	}
+	if opt.DryRun {
+		return nil
+	}
+	ids, err := u.compose.PsQuiet(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service)
+	if err != nil {
+		return err
+	}
+	if len(ids) != opt.Replicas {
+		return fmt.Errorf("service %s started %d containers, expected %d", opt.Service, len(ids), opt.Replicas)
+	}
+	hasHC, err := u.docker.HasHealthcheck(ctx, ids[0])
+	if err != nil || !hasHC {
+		return err
+	}
+
+	u.log.Infof("==> Waiting for %d containers to be healthy (timeout: %d seconds)", len(ids), opt.HealthcheckTimeout)
+	events.WaitPhase(u.events, opt.Service, events.ReadinessHealthcheck)
+	ok, err := u.waitHealthy(ctx, ids, len(ids), opt.HealthcheckTimeout, opt.PollInterval, opt.StartPeriod, opt.HealthyStatuses, events.NewHealthTracker(u.events, opt.Service))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		healthdiag.LogUnhealthyContainerLogs(ctx, u.log, u.docker, ids, opt.HealthyStatuses, 20)
+		return safeguard.WithReason(healthdiag.HealthFailureReason(ctx, u.docker, ids), fmt.Errorf("service %s started but its containers are not healthy", opt.Service))
 	}
+	return nil
+}
 
-	okCount := 0
-	for _, id := range containerIDs {
-		status, err := u.docker.HealthStatus(ctx, id)
+// waitMinOldUptime delays teardown until the youngest old container has been running
+// for MinOldUptime, so back-to-back deploys do not remove containers that just started.
+func (u *Updater) waitMinOldUptime(ctx context.Context, opt Options, oldIDs []string) error {
+	if opt.MinOldUptime <= 0 {
+		return nil
+	}
+	var youngest time.Time
+	for _, id := range oldIDs {
+		st, err := u.docker.State(ctx, id)
 		if err != nil {
-			return false, err
+			return err
 		}
-		if status == "healthy" {
-			okCount++
+		if st.StartedAt.After(youngest) {
+			youngest = st.StartedAt
 		}
 	}
-	return okCount == expected, nil
+	if youngest.IsZero() {
+		return nil
+	}
+	remaining := opt.MinOldUptime - time.Since(youngest)
+	if remaining <= 0 {
+		return nil
+	}
+	u.log.Warnf("==> Old containers of '%s' started %s ago; waiting %s to reach --min-old-uptime=%s before removing them", opt.Service, time.Since(youngest).Truncate(time.Second), remaining.Truncate(time.Second), opt.MinOldUptime)
+	return safeguard.Sleep(ctx, remaining)
+}
+
+// verifyReplicaCount checks that the service is back at its pre-deploy size and,
+// with ReconcileCount, scales it to exactly that size when the old/new diffing drifted.
+func (u *Updater) verifyReplicaCount(ctx context.Context, opt Options, expected int) error {
+	ids, err := u.compose.PsQuiet(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service)
+	if err != nil {
+		return err
+	}
+	if len(ids) == expected {
+		return nil
+	}
+	if !opt.ReconcileCount {
+		u.log.Warnf("==> WARNING: service '%s' has %d containers after deploy, expected %d (use --reconcile-count to fix)", opt.Service, len(ids), expected)
+		return nil
+	}
+
+	u.log.Warnf("==> Service '%s' has %d containers after deploy, expected %d. Scaling to %d.", opt.Service, len(ids), expected, expected)
+	if err := u.compose.Scale(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service, expected); err != nil {
+		return err
+	}
+	ids, err = u.compose.PsQuiet(ctx, opt.ComposeFiles, opt.EnvFiles, opt.Service)
+	if err != nil {
+		return err
+	}
+	if len(ids) != expected {
+		return fmt.Errorf("service %s has %d containers after reconcile, expected %d", opt.Service, len(ids), expected)
+	}
+	return nil
+}
+
+func (u *Updater) waitHealthy(ctx context.Context, containerIDs []string, expected int, timeoutSec int, pollInterval time.Duration, startPeriod time.Duration, accepted []string, tracker *events.HealthTracker) (bool, error) {
+	return healthdiag.WaitHealthy(ctx, u.log, u.docker, containerIDs, expected, time.Duration(timeoutSec)*time.Second, pollInterval, startPeriod, accepted, tracker)
 }
 
 func diffIDs(oldIDs []string, allIDs []string) []string {
@@ -193,10 +733,8 @@ func diffIDs(oldIDs []string, allIDs []string) []string {
 
 func validateProxyType(proxyType string) error {
 	switch proxyType {
-	case "traefik":
+	case proxy.TypeTraefik, proxy.TypeNginxProxy, proxy.TypeHAProxy:
 		return nil
-	case "nginx-proxy":
-		return fmt.Errorf("nginx-proxy support not implemented yet")
 	default:
 		return fmt.Errorf("unknown proxy type: %s", proxyType)
 	}
@@ -3,11 +3,21 @@ package rollout
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/docker"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/safeguard"
 )
 
 func TestDiffIDs(t *testing.T) {
@@ -24,8 +34,8 @@ func TestValidateProxyType(t *testing.T) {
 		t.Fatalf("expected traefik to be valid, got error: %v", err)
 	}
 
-	if err := validateProxyType("nginx-proxy"); err == nil {
-		t.Fatal("expected nginx-proxy to be rejected")
+	if err := validateProxyType("nginx-proxy"); err != nil {
+		t.Fatalf("expected nginx-proxy to be valid, got error: %v", err)
 	}
 
 	if err := validateProxyType("unknown"); err == nil {
@@ -40,6 +50,9 @@ type composeMock struct {
 func (m *composeMock) Up(context.Context, []string, []string, string, bool, bool) error { return nil }
 func (m *composeMock) Scale(context.Context, []string, []string, string, int) error     { return nil }
 func (m *composeMock) LogsFollowTail(context.Context, []string, string, int) error      { return nil }
+func (m *composeMock) ServiceImage(context.Context, []string, []string, string) (string, error) {
+	return "", nil
+}
 func (m *composeMock) PsQuiet(_ context.Context, _ []string, _ []string, _ string) ([]string, error) {
 	m.psCalls++
 	if m.psCalls == 1 {
@@ -49,9 +62,13 @@ func (m *composeMock) PsQuiet(_ context.Context, _ []string, _ []string, _ strin
 }
 
 type dockerMock struct {
+	labels            map[string]string
+	healthStatus      string
+	exited            bool
 	hasHealthcheckErr error
 	stopCalls         [][]string
 	removeCalls       [][]string
+	startedAt         time.Time
 }
 
 func (m *dockerMock) HasHealthcheck(context.Context, string) (bool, error) {
@@ -60,8 +77,19 @@ func (m *dockerMock) HasHealthcheck(context.Context, string) (bool, error) {
 	}
 	return true, nil
 }
-func (m *dockerMock) HealthStatus(context.Context, string) (string, error) { return "healthy", nil }
+func (m *dockerMock) HealthStatus(context.Context, string) (string, error) {
+	if m.healthStatus != "" {
+		return m.healthStatus, nil
+	}
+	return "healthy", nil
+}
 func (m *dockerMock) LogsTail(context.Context, string, int) (string, error) { return "", nil }
+func (m *dockerMock) State(_ context.Context, id string) (docker.ContainerState, error) {
+	if m.exited && strings.HasPrefix(id, "new-") {
+		return docker.ContainerState{Status: "exited", ExitCode: 3}, nil
+	}
+	return docker.ContainerState{Status: "running", Running: true, StartedAt: m.startedAt}, nil
+}
 func (m *dockerMock) Stop(_ context.Context, ids []string) error {
 	cp := append([]string{}, ids...)
 	m.stopCalls = append(m.stopCalls, cp)
@@ -73,6 +101,14 @@ func (m *dockerMock) Remove(_ context.Context, ids []string) error {
 	return nil
 }
 
+func (m *dockerMock) Labels(context.Context, string) (map[string]string, error) {
+	return m.labels, nil
+}
+
+func (m *dockerMock) IPAddress(context.Context, string) (string, error) {
+	return "127.0.0.1", nil
+}
+
 type generatorMock struct{}
 
 func (m *generatorMock) Generate(context.Context, []string, []string, string) error { return nil }
@@ -111,3 +147,937 @@ func TestRun_RollbackGuardOnPostScaleError(t *testing.T) {
 		t.Fatalf("unexpected removed containers: %#v", got)
 	}
 }
+
+func TestRun_RejectsNginxProxyLabelWithoutGeneratorBeforeScaling(t *testing.T) {
+	t.Parallel()
+
+	comp := &composeMock{}
+	dock := &dockerMock{labels: map[string]string{"com.ztd.proxy": "nginx-proxy"}}
+	updater := NewUpdater(logrus.New(), comp, dock, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:      "svc",
+		ComposeFiles: []string{"docker-compose.yml"},
+		ProxyType:    "traefik",
+	})
+	if err == nil {
+		t.Fatal("expected nginx-proxy label to be rejected without an nginx generator")
+	}
+	if comp.psCalls != 1 {
+		t.Fatalf("expected no scale-up after proxy rejection, got %d ps calls", comp.psCalls)
+	}
+}
+
+func TestRun_TimeoutActionKeepLeavesNewContainers(t *testing.T) {
+	t.Parallel()
+
+	dock := &dockerMock{healthStatus: "starting"}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:       "svc",
+		ComposeFiles:  []string{"docker-compose.yml"},
+		ProxyType:     "traefik",
+		TimeoutAction: "keep",
+	})
+	if err == nil {
+		t.Fatal("expected error when new containers are not healthy")
+	}
+	if reason := safeguard.ReasonOf(err); reason != safeguard.ReasonHealthcheckTimeout {
+		t.Fatalf("expected reason %s, got %q", safeguard.ReasonHealthcheckTimeout, reason)
+	}
+	if len(dock.stopCalls) != 0 || len(dock.removeCalls) != 0 {
+		t.Fatalf("expected new containers to be kept, got stop=%#v remove=%#v", dock.stopCalls, dock.removeCalls)
+	}
+}
+
+func TestRun_TimeoutActionRollbackRemovesNewContainers(t *testing.T) {
+	t.Parallel()
+
+	dock := &dockerMock{healthStatus: "unhealthy"}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:       "svc",
+		ComposeFiles:  []string{"docker-compose.yml"},
+		ProxyType:     "traefik",
+		TimeoutAction: "rollback",
+	})
+	if err == nil {
+		t.Fatal("expected rollback error")
+	}
+	if reason := safeguard.ReasonOf(err); reason != safeguard.ReasonUnhealthy {
+		t.Fatalf("expected reason %s, got %q", safeguard.ReasonUnhealthy, reason)
+	}
+	if len(dock.stopCalls) != 1 || len(dock.stopCalls[0]) != 2 || dock.stopCalls[0][0] != "new-1" {
+		t.Fatalf("expected new containers to be stopped, got %#v", dock.stopCalls)
+	}
+}
+
+func TestRun_CancelledWaitRemovesNewContainers(t *testing.T) {
+	t.Parallel()
+
+	dock := &dockerMock{healthStatus: "starting"}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := updater.Run(ctx, Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 60,
+		PollInterval:       5 * time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the expired deadline to be returned, got %v", err)
+	}
+	if len(dock.stopCalls) != 1 || dock.stopCalls[0][0] != "new-1" {
+		t.Fatalf("expected the rollback guard to stop the new containers, got %#v", dock.stopCalls)
+	}
+	if len(dock.removeCalls) != 1 {
+		t.Fatalf("expected the rollback guard to remove the new containers, got %#v", dock.removeCalls)
+	}
+}
+
+func TestRun_FailsFastWhenSurgeContainerExits(t *testing.T) {
+	t.Parallel()
+
+	dock := &dockerMock{healthStatus: "starting", exited: true}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 60,
+		TimeoutAction:      "keep",
+	})
+	if err == nil || !strings.Contains(err.Error(), "exited with code 3") {
+		t.Fatalf("expected exit code error, got: %v", err)
+	}
+	if reason := safeguard.ReasonOf(err); reason != safeguard.ReasonContainerExited {
+		t.Fatalf("expected reason %s, got %q", safeguard.ReasonContainerExited, reason)
+	}
+	if len(dock.stopCalls) != 1 {
+		t.Fatalf("expected rollback guard to clean up exited surge containers, got %#v", dock.stopCalls)
+	}
+}
+
+func TestRun_FailOnUnmatchedConfigKeepsOldContainers(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	if err := os.WriteFile(configPath, []byte("http:\n  services: {}\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	dock := &dockerMock{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  configPath,
+		FailOnUnmatched:    true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "no Traefik servers matched") {
+		t.Fatalf("expected unmatched config error, got: %v", err)
+	}
+	if reason := safeguard.ReasonOf(err); reason != safeguard.ReasonUnmatchedConfig {
+		t.Fatalf("expected reason %s, got %q", safeguard.ReasonUnmatchedConfig, reason)
+	}
+	if len(dock.stopCalls) != 1 || dock.stopCalls[0][0] != "new-1" {
+		t.Fatalf("expected only new containers to be removed, got %#v", dock.stopCalls)
+	}
+}
+
+func TestRun_ConfigWriteFailureKeepsOldContainers(t *testing.T) {
+	t.Parallel()
+
+	dock := &dockerMock{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  filepath.Join(t.TempDir(), "missing", "dynamic_conf.yml"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "update Traefik config") {
+		t.Fatalf("expected config update error, got: %v", err)
+	}
+	if reason := safeguard.ReasonOf(err); reason != safeguard.ReasonConfigWriteFailed {
+		t.Fatalf("expected reason %s, got %q", safeguard.ReasonConfigWriteFailed, reason)
+	}
+	for _, call := range dock.stopCalls {
+		for _, id := range call {
+			if strings.HasPrefix(id, "old-") {
+				t.Fatalf("old containers must keep serving, got stop calls %#v", dock.stopCalls)
+			}
+		}
+	}
+}
+
+type oldStateErrDockerMock struct {
+	dockerMock
+}
+
+func (m *oldStateErrDockerMock) State(ctx context.Context, id string) (docker.ContainerState, error) {
+	if strings.HasPrefix(id, "old-") {
+		return docker.ContainerState{}, errors.New("inspect failed")
+	}
+	return m.dockerMock.State(ctx, id)
+}
+
+func TestRun_RestoresTraefikConfigOnPostSwapFailure(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "dynamic_conf.yml")
+	original := "http:\n  services:\n    svc:\n      loadBalancer:\n        servers:\n          - url: http://old-1:80\n          - url: http://old-2:80\n"
+	if err := os.WriteFile(configPath, []byte(original), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	dock := &oldStateErrDockerMock{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  configPath,
+		MinOldUptime:       time.Minute,
+	})
+	if err == nil || !strings.Contains(err.Error(), "inspect failed") {
+		t.Fatalf("expected post-swap failure, got: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if string(data) != original {
+		t.Fatalf("expected config to be restored, got:\n%s", data)
+	}
+	if len(dock.stopCalls) != 1 || dock.stopCalls[0][0] != "new-1" {
+		t.Fatalf("expected new containers to be removed, got %#v", dock.stopCalls)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected the backup to be consumed, got %d files", len(entries))
+	}
+}
+
+func TestRun_RestoresHAProxyConfigOnPostSwapFailure(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ztd.cfg")
+	original := "backend svc\n    mode http\n    server old-1 old-1:80 check\n    server old-2 old-2:80 check\n"
+	if err := os.WriteFile(configPath, []byte(original), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	reloadMarker := filepath.Join(dir, "reloads")
+	dock := &oldStateErrDockerMock{dockerMock: dockerMock{labels: map[string]string{"com.ztd.proxy": "haproxy"}}}
+	reloader := proxy.Reloader{HAProxyCommand: "echo x >> " + reloadMarker, Command: "echo y >> " + reloadMarker}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{}).WithHAProxyGenerator(&recordingGenerator{}).WithReloader(reloader)
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		HAProxyConfigFile:  configPath,
+		MinOldUptime:       time.Minute,
+	})
+	if err == nil || !strings.Contains(err.Error(), "inspect failed") {
+		t.Fatalf("expected post-swap failure, got: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if string(data) != original {
+		t.Fatalf("expected config to be restored, got:\n%s", data)
+	}
+	reloads, err := os.ReadFile(reloadMarker)
+	if err != nil {
+		t.Fatalf("read reload marker: %v", err)
+	}
+	if string(reloads) != "x\ny\nx\ny\n" {
+		t.Fatalf("expected HAProxy and the reload command to run after the swap and the restore, got %q", reloads)
+	}
+}
+
+func TestRun_RequiredReloadFailureRestoresConfig(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	original := "http:\n  services:\n    svc:\n      loadBalancer:\n        servers:\n          - url: http://old-1:80\n"
+	if err := os.WriteFile(configPath, []byte(original), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	dock := &dockerMock{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{}).WithReloader(proxy.Reloader{Command: "exit 3", Required: true})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  configPath,
+	})
+	if reason := safeguard.ReasonOf(err); reason != safeguard.ReasonConfigWriteFailed {
+		t.Fatalf("expected reason %s, got %q (%v)", safeguard.ReasonConfigWriteFailed, reason, err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if string(data) != original {
+		t.Fatalf("expected config to be restored, got:\n%s", data)
+	}
+	for _, call := range dock.stopCalls {
+		for _, id := range call {
+			if strings.HasPrefix(id, "old-") {
+				t.Fatalf("old containers must keep serving, got stop calls %#v", dock.stopCalls)
+			}
+		}
+	}
+}
+
+type recordingGenerator struct {
+	outputs []string
+}
+
+func (m *recordingGenerator) Generate(_ context.Context, _ []string, _ []string, outputPath string) error {
+	m.outputs = append(m.outputs, outputPath)
+	return nil
+}
+
+type regeneratingGenerator struct {
+	excluded []string
+}
+
+func (m *regeneratingGenerator) Generate(context.Context, []string, []string, string) error {
+	return nil
+}
+
+func (m *regeneratingGenerator) Regenerate(_ context.Context, _ []string, _ []string, outputPath string, exclude []string) error {
+	m.excluded = append([]string{}, exclude...)
+	return os.WriteFile(outputPath, []byte("http:\n  services:\n    svc:\n      loadBalancer:\n        servers:\n          - url: http://new-1:80\n"), 0o644)
+}
+
+func TestRun_ForceRegenerateRebuildsConfigWithoutOldContainers(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	if err := os.WriteFile(configPath, []byte("http:\n  services: {}\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	dock := &dockerMock{}
+	gen := &regeneratingGenerator{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, gen)
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  configPath,
+		FailOnUnmatched:    true,
+		ForceRegenerate:    true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(gen.excluded, ",") != "old-1,old-2" {
+		t.Fatalf("expected old containers to be left out, got %v", gen.excluded)
+	}
+	if len(dock.stopCalls) != 1 || strings.Join(dock.stopCalls[0], ",") != "old-1,old-2" {
+		t.Fatalf("expected old containers to be stopped, got %#v", dock.stopCalls)
+	}
+}
+
+func TestRun_ForceRegenerateRequiresRegenerator(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	if err := os.WriteFile(configPath, []byte("http:\n  services: {}\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	updater := NewUpdater(logrus.New(), &composeMock{}, &dockerMock{}, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  configPath,
+		ForceRegenerate:    true,
+	})
+	if reason := safeguard.ReasonOf(err); reason != safeguard.ReasonConfigWriteFailed {
+		t.Fatalf("expected reason %s, got %q (%v)", safeguard.ReasonConfigWriteFailed, reason, err)
+	}
+}
+
+func TestRun_NginxProxySwapsUpstreamsAndReloads(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ztd.conf")
+	if err := os.WriteFile(configPath, []byte("upstream svc {\n    server old-1:80;\n    server old-2:80;\n}\n"), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	reloadMarker := filepath.Join(dir, "reloaded")
+	dock := &dockerMock{labels: map[string]string{"com.ztd.proxy": "nginx-proxy"}}
+	traefikGen := &recordingGenerator{}
+	nginxGen := &recordingGenerator{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, traefikGen).WithNginxGenerator(nginxGen).WithReloader(proxy.Reloader{NginxCommand: "touch " + reloadMarker})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		NginxConfigFile:    configPath,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), "server new-1:80;") || !strings.Contains(string(data), "server new-2:80;") {
+		t.Fatalf("expected new containers in nginx upstream:\n%s", data)
+	}
+	if _, err := os.Stat(reloadMarker); err != nil {
+		t.Fatalf("expected nginx reload command to run: %v", err)
+	}
+	if len(traefikGen.outputs) != 0 || len(nginxGen.outputs) != 1 || nginxGen.outputs[0] != configPath {
+		t.Fatalf("expected only the nginx config to be regenerated, got traefik=%v nginx=%v", traefikGen.outputs, nginxGen.outputs)
+	}
+}
+
+func TestRun_HAProxySwapsBackendServersAndReloads(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "ztd.cfg")
+	config := "backend svc\n    mode http\n    server old-1 old-1:80 check\n    server old-2 old-2:80 check\n"
+	if err := os.WriteFile(configPath, []byte(config), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	reloadMarker := filepath.Join(dir, "reloaded")
+	dock := &dockerMock{labels: map[string]string{"com.ztd.proxy": "haproxy"}}
+	traefikGen := &recordingGenerator{}
+	haproxyGen := &recordingGenerator{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, traefikGen).WithHAProxyGenerator(haproxyGen).WithReloader(proxy.Reloader{HAProxyCommand: "touch " + reloadMarker})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		HAProxyConfigFile:  configPath,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), "server new-1 new-1:80 check") || !strings.Contains(string(data), "server new-2 new-2:80 check") {
+		t.Fatalf("expected new containers in HAProxy backend:\n%s", data)
+	}
+	if _, err := os.Stat(reloadMarker); err != nil {
+		t.Fatalf("expected HAProxy reload command to run: %v", err)
+	}
+	if len(traefikGen.outputs) != 0 || len(haproxyGen.outputs) != 1 || haproxyGen.outputs[0] != configPath {
+		t.Fatalf("expected only the HAProxy config to be regenerated, got traefik=%v haproxy=%v", traefikGen.outputs, haproxyGen.outputs)
+	}
+}
+
+type dnsDockerMock struct {
+	dockerMock
+}
+
+func (m *dnsDockerMock) Labels(_ context.Context, id string) (map[string]string, error) {
+	numbers := map[string]string{"old-1": "1", "old-2": "2", "new-1": "3", "new-2": "4"}
+	return map[string]string{
+		"com.docker.compose.project":          "shop",
+		"com.docker.compose.service":          "svc",
+		"com.docker.compose.container-number": numbers[id],
+	}, nil
+}
+
+func TestRun_DNSNamesSwapsServerHosts(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := "http:\n  services:\n    svc:\n      loadBalancer:\n        servers:\n          - url: http://shop-svc-1:80\n          - url: http://shop-svc-2:80\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	dock := &dnsDockerMock{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  configPath,
+		DNSNames:           true,
+		FailOnUnmatched:    true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), "http://shop-svc-3:80") || !strings.Contains(string(data), "http://shop-svc-4:80") {
+		t.Fatalf("expected new DNS names in config:\n%s", data)
+	}
+}
+
+func TestSurgeSize(t *testing.T) {
+	cases := []struct {
+		opt  Options
+		want int
+	}{
+		{Options{}, 8},
+		{Options{SurgeAdd: 2}, 2},
+		{Options{SurgeAdd: 20}, 8},
+		{Options{SurgeTarget: 11}, 3},
+		{Options{SurgeTarget: 8}, 1},
+	}
+	for _, c := range cases {
+		if got := SurgeSize(c.opt, 8); got != c.want {
+			t.Fatalf("SurgeSize(%+v, 8) = %d, want %d", c.opt, got, c.want)
+		}
+	}
+}
+
+// fleetMock keeps one container list shared by compose scale/ps and docker rm.
+type fleetMock struct {
+	composeMock
+	dockerMock
+	ids      []string
+	next     int
+	scaledTo []int
+}
+
+func (m *fleetMock) PsQuiet(context.Context, []string, []string, string) ([]string, error) {
+	return append([]string{}, m.ids...), nil
+}
+
+func (m *fleetMock) Scale(_ context.Context, _ []string, _ []string, _ string, replicas int) error {
+	m.scaledTo = append(m.scaledTo, replicas)
+	for len(m.ids) < replicas {
+		m.next++
+		m.ids = append(m.ids, fmt.Sprintf("new-%d", m.next))
+	}
+	return nil
+}
+
+func (m *fleetMock) Remove(_ context.Context, ids []string) error {
+	removed := map[string]bool{}
+	for _, id := range ids {
+		removed[id] = true
+	}
+	kept := m.ids[:0]
+	for _, id := range m.ids {
+		if !removed[id] {
+			kept = append(kept, id)
+		}
+	}
+	m.ids = kept
+	return m.dockerMock.Remove(context.Background(), ids)
+}
+
+func TestRun_ScaleStepReplacesInBatches(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := "http:\n  services:\n    svc:\n      loadBalancer:\n        servers:\n          - url: http://old-1:80\n          - url: http://old-2:80\n          - url: http://old-3:80\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	fleet := &fleetMock{ids: []string{"old-1", "old-2", "old-3"}}
+	updater := NewUpdater(logrus.New(), fleet, fleet, &generatorMock{})
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  configPath,
+		SurgeAdd:           2,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fleet.scaledTo) != 2 || fleet.scaledTo[0] != 5 || fleet.scaledTo[1] != 4 {
+		t.Fatalf("expected batches scaling to 5 then 4, got %v", fleet.scaledTo)
+	}
+	if len(fleet.removeCalls) != 2 || len(fleet.removeCalls[0]) != 2 || fleet.removeCalls[1][0] != "old-3" {
+		t.Fatalf("expected old containers removed in batches, got %v", fleet.removeCalls)
+	}
+	if len(fleet.ids) != 3 {
+		t.Fatalf("expected 3 containers after deploy, got %v", fleet.ids)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if strings.Contains(string(data), "old-") {
+		t.Fatalf("expected every old server to be replaced:\n%s", data)
+	}
+}
+
+type countComposeMock struct {
+	composeMock
+	counts     []int
+	scaledTo   []int
+	countCalls int
+}
+
+func (m *countComposeMock) PsQuiet(context.Context, []string, []string, string) ([]string, error) {
+	n := m.counts[m.countCalls]
+	if m.countCalls < len(m.counts)-1 {
+		m.countCalls++
+	}
+	return make([]string, n), nil
+}
+
+func (m *countComposeMock) Scale(_ context.Context, _ []string, _ []string, _ string, replicas int) error {
+	m.scaledTo = append(m.scaledTo, replicas)
+	return nil
+}
+
+func TestVerifyReplicaCount(t *testing.T) {
+	t.Parallel()
+
+	comp := &countComposeMock{counts: []int{3}}
+	updater := NewUpdater(logrus.New(), comp, &dockerMock{}, &generatorMock{})
+	if err := updater.verifyReplicaCount(context.Background(), Options{Service: "svc"}, 2); err != nil {
+		t.Fatalf("expected mismatch without reconcile to only warn, got %v", err)
+	}
+	if len(comp.scaledTo) != 0 {
+		t.Fatalf("did not expect scaling without reconcile, got %v", comp.scaledTo)
+	}
+
+	comp = &countComposeMock{counts: []int{3, 2}}
+	updater = NewUpdater(logrus.New(), comp, &dockerMock{}, &generatorMock{})
+	if err := updater.verifyReplicaCount(context.Background(), Options{Service: "svc", ReconcileCount: true}, 2); err != nil {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	if len(comp.scaledTo) != 1 || comp.scaledTo[0] != 2 {
+		t.Fatalf("expected scale to 2, got %v", comp.scaledTo)
+	}
+
+	comp = &countComposeMock{counts: []int{3, 3}}
+	updater = NewUpdater(logrus.New(), comp, &dockerMock{}, &generatorMock{})
+	if err := updater.verifyReplicaCount(context.Background(), Options{Service: "svc", ReconcileCount: true}, 2); err == nil {
+		t.Fatal("expected error when reconcile does not reach the target count")
+	}
+}
+
+func TestRun_ColdStartWithReplicas(t *testing.T) {
+	t.Parallel()
+
+	comp := &countComposeMock{counts: []int{0, 3}}
+	updater := NewUpdater(logrus.New(), comp, &dockerMock{}, &generatorMock{})
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		Replicas:           3,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(comp.scaledTo) != 1 || comp.scaledTo[0] != 3 {
+		t.Fatalf("expected cold start to scale to 3, got %v", comp.scaledTo)
+	}
+
+	comp = &countComposeMock{counts: []int{0, 3}}
+	updater = NewUpdater(logrus.New(), comp, &dockerMock{healthStatus: "unhealthy"}, &generatorMock{})
+	err = updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		Replicas:           3,
+	})
+	if err == nil {
+		t.Fatal("expected unhealthy cold start to fail")
+	}
+}
+
+func TestWaitMinOldUptime(t *testing.T) {
+	t.Parallel()
+
+	dock := &dockerMock{startedAt: time.Now().Add(-time.Hour)}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+	start := time.Now()
+	if err := updater.waitMinOldUptime(context.Background(), Options{Service: "svc", MinOldUptime: time.Minute}, []string{"old-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Fatal("did not expect to wait for old containers past the minimum uptime")
+	}
+
+	dock.startedAt = time.Now().Add(-800 * time.Millisecond)
+	start = time.Now()
+	if err := updater.waitMinOldUptime(context.Background(), Options{Service: "svc", MinOldUptime: time.Second}, []string{"old-1", "old-2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if waited := time.Since(start); waited < 100*time.Millisecond {
+		t.Fatalf("expected to wait for young old containers, waited %s", waited)
+	}
+
+	dock.startedAt = time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := updater.waitMinOldUptime(ctx, Options{Service: "svc", MinOldUptime: time.Hour}, []string{"old-1"}); err == nil {
+		t.Fatal("expected a cancelled deploy to stop waiting")
+	}
+}
+
+func TestReplaceBatch_CancelDuringMinOldUptimeRollsBack(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	original := "http:\n  services:\n    svc:\n      loadBalancer:\n        servers:\n          - url: http://old-1:80\n          - url: http://old-2:80\n"
+	if err := os.WriteFile(configPath, []byte(original), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	dock := &dockerMock{startedAt: time.Now()}
+	updater := NewUpdater(logrus.New(), &composeMock{psCalls: 1}, dock, &generatorMock{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	oldIDs := []string{"old-1", "old-2"}
+	err := updater.replaceBatch(ctx, Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		HealthcheckTimeout: 5,
+		TraefikConfigFile:  configPath,
+		MinOldUptime:       time.Hour,
+	}, proxy.TypeTraefik, oldIDs, oldIDs)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled wait to fail the batch, got: %v", err)
+	}
+	if waited := time.Since(start); waited > 5*time.Second {
+		t.Fatalf("expected the wait to stop on cancel, waited %s", waited)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if string(data) != original {
+		t.Fatalf("expected config to be restored, got:\n%s", data)
+	}
+	if len(dock.stopCalls) != 1 || !reflect.DeepEqual(dock.stopCalls[0], []string{"new-1", "new-2"}) {
+		t.Fatalf("expected only the new containers to be removed, got %#v", dock.stopCalls)
+	}
+}
+
+func TestRun_NoProxyLeavesConfigUntouched(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := "http:\n  services:\n    svc:\n      loadBalancer:\n        servers:\n          - url: http://old-1:80\n          - url: http://old-2:80\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	dock := &dockerMock{labels: map[string]string{"com.ztd.proxy": "envoy"}}
+	gen := &recordingGenerator{}
+	sink := &recordingSink{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, gen).WithEvents(sink)
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  configPath,
+		FailOnUnmatched:    true,
+		NoProxy:            true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gen.outputs) != 0 {
+		t.Fatalf("expected no config generation, got %v", gen.outputs)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if string(data) != content {
+		t.Fatalf("expected config untouched, got:\n%s", data)
+	}
+	if len(dock.stopCalls) != 1 || strings.Join(dock.stopCalls[0], ",") != "old-1,old-2" {
+		t.Fatalf("expected old containers to be stopped, got %#v", dock.stopCalls)
+	}
+	ready := false
+	for _, event := range sink.events {
+		if event.Type == events.TypeSwapComplete {
+			t.Fatalf("expected no swap-complete without a proxy config write, got %#v", event)
+		}
+		ready = ready || event.Type == events.TypeContainersReady
+	}
+	if !ready {
+		t.Fatalf("expected the new containers reported ready, got %#v", sink.events)
+	}
+}
+
+type recordingSink struct {
+	events []events.Event
+}
+
+func (s *recordingSink) Emit(event events.Event) {
+	s.events = append(s.events, event)
+}
+
+func TestRun_PreStopRunsBeforeOldContainersStop(t *testing.T) {
+	t.Parallel()
+
+	dock := &dockerMock{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	var hooked []string
+	stopsBeforeHook := -1
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		NoProxy:            true,
+		PreStop: func(ctx context.Context, containerIDs []string) {
+			hooked = containerIDs
+			stopsBeforeHook = len(dock.stopCalls)
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(hooked, ",") != "old-1,old-2" {
+		t.Fatalf("expected pre-stop to get the old containers, got %v", hooked)
+	}
+	if stopsBeforeHook != 0 || len(dock.stopCalls) != 1 {
+		t.Fatalf("expected pre-stop before stopping, got %d stops before and %#v", stopsBeforeHook, dock.stopCalls)
+	}
+}
+
+func TestRun_DrainWaitsAfterProxySwap(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := "http:\n  services:\n    svc:\n      loadBalancer:\n        servers:\n          - url: http://old-1:80\n          - url: http://old-2:80\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	dock := &dockerMock{}
+	updater := NewUpdater(logrus.New(), &composeMock{}, dock, &generatorMock{})
+
+	var configAtStop string
+	start := time.Now()
+	err := updater.Run(context.Background(), Options{
+		Service:              "svc",
+		ComposeFiles:         []string{"docker-compose.yml"},
+		ProxyType:            "traefik",
+		HealthcheckTimeout:   1,
+		NoHealthcheckTimeout: 30,
+		TraefikConfigFile:    configPath,
+		Drain:                200 * time.Millisecond,
+		PreStop: func(context.Context, []string) {
+			data, _ := os.ReadFile(configPath)
+			configAtStop = string(data)
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 10*time.Second {
+		t.Fatalf("expected the drain to replace the --wait pause, took %s", elapsed)
+	}
+	if strings.Contains(configAtStop, "old-1") || !strings.Contains(configAtStop, "new-1") {
+		t.Fatalf("expected old containers deregistered before draining, got config:\n%s", configAtStop)
+	}
+	if len(dock.stopCalls) != 1 || strings.Join(dock.stopCalls[0], ",") != "old-1,old-2" {
+		t.Fatalf("expected old containers to be stopped after the drain, got %#v", dock.stopCalls)
+	}
+}
+
+// dryRunComposeMock keeps reporting the old containers, as a dry-run scale starts none.
+type dryRunComposeMock struct {
+	composeMock
+	scaled []int
+}
+
+func (m *dryRunComposeMock) Scale(_ context.Context, _ []string, _ []string, _ string, replicas int) error {
+	m.scaled = append(m.scaled, replicas)
+	return nil
+}
+
+func (m *dryRunComposeMock) PsQuiet(context.Context, []string, []string, string) ([]string, error) {
+	return []string{"old-1", "old-2"}, nil
+}
+
+func TestRun_DryRunGoesFromScaleToTeardownAndRendersConfig(t *testing.T) {
+	t.Parallel()
+
+	configPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := "http:\n  services:\n    svc:\n      loadBalancer:\n        servers:\n          - url: http://old-1:80\n"
+	if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	comp := &dryRunComposeMock{}
+	dock := &dockerMock{hasHealthcheckErr: errors.New("no new containers to inspect")}
+	gen := &recordingGenerator{}
+	updater := NewUpdater(logrus.New(), comp, dock, gen)
+
+	err := updater.Run(context.Background(), Options{
+		Service:            "svc",
+		ComposeFiles:       []string{"docker-compose.yml"},
+		ProxyType:          "traefik",
+		HealthcheckTimeout: 1,
+		TraefikConfigFile:  configPath,
+		SurgeAdd:           1,
+		DryRun:             true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(comp.scaled, []int{3, 3}) {
+		t.Fatalf("expected one scale-up per batch, got %v", comp.scaled)
+	}
+	if !reflect.DeepEqual(dock.stopCalls, [][]string{{"old-1"}, {"old-2"}}) || len(dock.removeCalls) != 2 {
+		t.Fatalf("expected each batch to tear down its old container, got stop=%v remove=%v", dock.stopCalls, dock.removeCalls)
+	}
+	if !reflect.DeepEqual(gen.outputs, []string{configPath}) {
+		t.Fatalf("expected the config to be rendered once, got %v", gen.outputs)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if string(data) != content {
+		t.Fatalf("expected config untouched, got:\n%s", data)
+	}
+}
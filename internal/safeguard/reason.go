@@ -0,0 +1,60 @@
+package safeguard
+
+import "errors"
+
+// Reason classifies why a deploy rolled back or kept the old containers serving,
+// so failures can be grouped without parsing log text.
+type Reason string
+
+const (
+	ReasonHealthcheckTimeout Reason = "healthcheck-timeout"
+	ReasonUnhealthy          Reason = "unhealthy"
+	ReasonContainerExited    Reason = "container-exited"
+	ReasonTCPProbeTimeout    Reason = "tcp-probe-timeout"
+	ReasonHTTPProbeTimeout   Reason = "http-probe-timeout"
+	ReasonConfigWriteFailed  Reason = "config-write-failed"
+	ReasonUnmatchedConfig    Reason = "unmatched-config"
+	ReasonMetricsGate        Reason = "metrics-gate"
+)
+
+type reasonError struct {
+	reason     Reason
+	rolledBack bool
+	err        error
+}
+
+func (e *reasonError) Error() string { return e.err.Error() }
+func (e *reasonError) Unwrap() error { return e.err }
+
+// WithReason tags err with reason. A nil err stays nil.
+func WithReason(reason Reason, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &reasonError{reason: reason, err: err}
+}
+
+// WithRollback tags err with reason like WithReason and marks it as a deploy whose new
+// containers were rolled back, as opposed to one that kept them (--timeout-action=keep)
+// or failed before traffic could move, such as a config write. A nil err stays nil.
+func WithRollback(reason Reason, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &reasonError{reason: reason, rolledBack: true, err: err}
+}
+
+// RolledBack reports whether err was marked by WithRollback.
+func RolledBack(err error) bool {
+	var re *reasonError
+	return errors.As(err, &re) && re.rolledBack
+}
+
+// ReasonOf returns the reason attached to err, or "" when there is none.
+func ReasonOf(err error) Reason {
+	var re *reasonError
+	if errors.As(err, &re) {
+		return re.reason
+	}
+	return ""
+}
@@ -3,12 +3,16 @@ package safeguard
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type CleanupFunc func(ctx context.Context) error
 
+// cleanupTimeout bounds a cleanup that runs after the parent context was cancelled.
+const cleanupTimeout = 2 * time.Minute
+
 // RollbackGuard runs a compensating cleanup action if the parent operation fails.
 type RollbackGuard struct {
 	log     *logrus.Logger
@@ -35,12 +39,35 @@ func (g *RollbackGuard) Run(ctx context.Context, opErr *error) {
 		return
 	}
 
-	g.log.Warnf("==> %s guard activated. Running cleanup.", g.name)
+	g.armed = false
+
+	// An interrupted deploy still has to remove what it created, so the cleanup gets
+	// a context of its own instead of the cancelled one.
+	if ctx.Err() != nil {
+		g.log.Warnf("==> %s guard activated (%v). Rolling back.", g.name, context.Cause(ctx))
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+		defer cancel()
+	} else {
+		g.log.Warnf("==> %s guard activated. Running cleanup.", g.name)
+	}
 	if err := g.cleanup(ctx); err != nil {
 		g.log.Warnf("==> %s guard cleanup failed: %v", g.name, err)
 	}
 }
 
+// Sleep waits for d or until ctx is cancelled, returning ctx.Err() in that case.
+func Sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
 func WrapErrors(primary string, errs ...error) error {
 	var first error
 	for _, err := range errs {
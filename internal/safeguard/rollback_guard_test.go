@@ -3,7 +3,9 @@ package safeguard
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -40,3 +42,73 @@ func TestRollbackGuard_Disarm(t *testing.T) {
 		t.Fatal("expected cleanup not to be called when disarmed")
 	}
 }
+
+func TestRollbackGuard_RunAfterCancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	guard := NewRollbackGuard(logrus.New(), "test", func(ctx context.Context) error {
+		calls++
+		if ctx.Err() != nil {
+			t.Errorf("expected cleanup context to be live, got %v", ctx.Err())
+		}
+		return nil
+	})
+
+	opErr := ctx.Err()
+	guard.Run(ctx, &opErr)
+	guard.Run(ctx, &opErr)
+	if calls != 1 {
+		t.Fatalf("expected cleanup to run once, got %d", calls)
+	}
+}
+
+func TestSleep_Cancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if err := Sleep(context.Background(), time.Millisecond); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+}
+
+func TestReasonOf(t *testing.T) {
+	t.Parallel()
+
+	if got := ReasonOf(errors.New("plain")); got != "" {
+		t.Fatalf("expected no reason, got %q", got)
+	}
+	if WithReason(ReasonUnhealthy, nil) != nil {
+		t.Fatal("expected nil error to stay nil")
+	}
+
+	err := fmt.Errorf("deploy api: %w", WithReason(ReasonConfigWriteFailed, errors.New("disk full")))
+	if got := ReasonOf(err); got != ReasonConfigWriteFailed {
+		t.Fatalf("expected %s through wrapping, got %q", ReasonConfigWriteFailed, got)
+	}
+	if err.Error() != "deploy api: disk full" {
+		t.Fatalf("expected message to be unchanged, got %q", err.Error())
+	}
+}
+
+func TestRolledBack(t *testing.T) {
+	t.Parallel()
+
+	if RolledBack(WithReason(ReasonUnhealthy, errors.New("kept"))) {
+		t.Fatal("expected a plain reason not to count as a rollback")
+	}
+	if WithRollback(ReasonUnhealthy, nil) != nil {
+		t.Fatal("expected nil error to stay nil")
+	}
+	err := fmt.Errorf("deploy api: %w", WithRollback(ReasonTCPProbeTimeout, errors.New("no answer")))
+	if !RolledBack(err) || ReasonOf(err) != ReasonTCPProbeTimeout {
+		t.Fatalf("expected a rollback with its reason through wrapping, got %v", err)
+	}
+}
@@ -0,0 +1,12 @@
+package safeguard
+
+// What a deploy does when its new containers are still not healthy at the health
+// timeout (--timeout-action).
+const (
+	// TimeoutActionRollback removes the new containers and keeps the old ones serving.
+	TimeoutActionRollback = "rollback"
+	// TimeoutActionKeep keeps both sets running without switching traffic.
+	TimeoutActionKeep = "keep"
+	// TimeoutActionForce switches traffic to the new containers anyway.
+	TimeoutActionForce = "force"
+)
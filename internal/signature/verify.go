@@ -0,0 +1,32 @@
+package signature
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// DefaultCommand verifies an image signature when --verify-command is not set.
+const DefaultCommand = "cosign verify"
+
+// Verify runs command with image appended as the last argument and fails when the
+// command exits non-zero. The combined output is returned for logging.
+func Verify(ctx context.Context, command string, image string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		args = strings.Fields(DefaultCommand)
+	}
+	args = append(args, image)
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if output == "" {
+			return "", fmt.Errorf("signature verification failed for %s: %w", image, err)
+		}
+		return output, fmt.Errorf("signature verification failed for %s: %w: %s", image, err, output)
+	}
+	return output, nil
+}
@@ -0,0 +1,15 @@
+package signature
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	if _, err := Verify(context.Background(), "true", "app:latest"); err != nil {
+		t.Fatalf("expected successful verification, got %v", err)
+	}
+	if _, err := Verify(context.Background(), "false", "app:latest"); err == nil {
+		t.Fatal("expected failing verification command to return an error")
+	}
+}
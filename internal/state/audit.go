@@ -0,0 +1,56 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const AuditLogFileName = "audit.log"
+
+const (
+	AuditResultSkipped           = "skipped"
+	AuditResultSignatureVerified = "signature-verified"
+	AuditResultSignatureRejected = "signature-rejected"
+	AuditResultFailed            = "failed"
+)
+
+// AuditEntry is one line of the append-only deploy audit log.
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Service  string    `json:"service"`
+	Strategy string    `json:"strategy,omitempty"`
+	Result   string    `json:"result"`
+	Reason   string    `json:"reason,omitempty"`
+	DeployID string    `json:"deployId,omitempty"`
+}
+
+func (s *Store) AuditLogPath() string {
+	return filepath.Join(s.baseDir, AuditLogFileName)
+}
+
+// AppendAudit appends entry as a JSON line to the audit log next to the state files.
+func (s *Store) AppendAudit(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	path := s.AuditLogPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write audit log %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAppendAudit_AppendsJSONLines(t *testing.T) {
+	t.Parallel()
+
+	store := NewStore(t.TempDir())
+	if err := store.AppendAudit(AuditEntry{Service: "api", Result: AuditResultSkipped, Reason: "no change"}); err != nil {
+		t.Fatalf("append audit: %v", err)
+	}
+	if err := store.AppendAudit(AuditEntry{Service: "web", Result: AuditResultSkipped}); err != nil {
+		t.Fatalf("append audit: %v", err)
+	}
+
+	data, err := os.ReadFile(store.AuditLogPath())
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 audit lines, got %d", len(lines))
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("parse audit line: %v", err)
+	}
+	if entry.Service != "api" || entry.Reason != "no change" || entry.Time.IsZero() {
+		t.Fatalf("unexpected audit entry: %#v", entry)
+	}
+
+	projects, err := store.ListProjects()
+	if err != nil {
+		t.Fatalf("list projects: %v", err)
+	}
+	if len(projects) != 0 {
+		t.Fatalf("audit log must not be listed as a project: %v", projects)
+	}
+}
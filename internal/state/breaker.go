@@ -0,0 +1,98 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+)
+
+// BreakerDir keeps per-service rollback history in a subdirectory so that
+// ListProjects does not mistake it for deployment state.
+const BreakerDir = "breaker"
+
+// BreakerState records recent rollbacks of a service and when the deploy
+// circuit-breaker last tripped.
+type BreakerState struct {
+	Rollbacks []time.Time `json:"rollbacks,omitempty"`
+	TrippedAt *time.Time  `json:"trippedAt,omitempty"`
+}
+
+// Prune drops rollbacks older than window before now.
+func (b *BreakerState) Prune(now time.Time, window time.Duration) {
+	kept := b.Rollbacks[:0]
+	for _, at := range b.Rollbacks {
+		if now.Sub(at) <= window {
+			kept = append(kept, at)
+		}
+	}
+	b.Rollbacks = kept
+}
+
+// OpenUntil returns when the breaker closes again, or the zero time when it is
+// not tripped.
+func (b BreakerState) OpenUntil(cooldown time.Duration) time.Time {
+	if b.TrippedAt == nil {
+		return time.Time{}
+	}
+	return b.TrippedAt.Add(cooldown)
+}
+
+// BreakerPath returns the breaker file of service in project, so same-named services of
+// two projects sharing a directory keep separate histories.
+func (s *Store) BreakerPath(project string, service string) (string, error) {
+	key, err := ServiceStateKey(project, service)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(s.baseDir, BreakerDir, key+".json"), nil
+}
+
+// LoadBreaker returns the breaker state of service in project, empty when none was saved.
+func (s *Store) LoadBreaker(project string, service string) (BreakerState, error) {
+	path, err := s.BreakerPath(project, service)
+	if err != nil {
+		return BreakerState{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return BreakerState{}, nil
+		}
+		return BreakerState{}, err
+	}
+	var breaker BreakerState
+	if err := json.Unmarshal(data, &breaker); err != nil {
+		return BreakerState{}, fmt.Errorf("failed to parse breaker file %s: %w", path, err)
+	}
+	return breaker, nil
+}
+
+func (s *Store) SaveBreaker(project string, service string, breaker BreakerState) error {
+	path, err := s.BreakerPath(project, service)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(breaker, "", "  ")
+	if err != nil {
+		return err
+	}
+	return configio.WriteAtomic(path, data, 0o644)
+}
+
+func (s *Store) ResetBreaker(project string, service string) error {
+	path, err := s.BreakerPath(project, service)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -48,6 +48,7 @@ type DeploymentState struct {
 	QA         *QAModes         `json:"qa,omitempty"`
 	GreenStats *MetricsBaseline `json:"greenStats,omitempty"`
 	NewStats   *MetricsBaseline `json:"newStats,omitempty"`
+	CanaryRule string           `json:"canaryRule,omitempty"`
 }
 
 func (s DeploymentState) Validate() error {
@@ -0,0 +1,70 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDeploySlotsRelativeDir = ".ztd/locks"
+	EnvDeploySlotsDir             = "ZTD_DEPLOY_SLOTS_DIR"
+)
+
+var deploySlotPollInterval = 500 * time.Millisecond
+
+// ResolveDeploySlotsDir returns the directory that holds host-wide deploy slot lock files.
+func ResolveDeploySlotsDir(dir string) string {
+	if resolved := strings.TrimSpace(dir); resolved != "" {
+		return resolved
+	}
+	if fromEnv := strings.TrimSpace(os.Getenv(EnvDeploySlotsDir)); fromEnv != "" {
+		return fromEnv
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || strings.TrimSpace(home) == "" {
+		return filepath.FromSlash(DefaultDeploySlotsRelativeDir)
+	}
+	return filepath.Join(home, filepath.FromSlash(DefaultDeploySlotsRelativeDir))
+}
+
+// AcquireDeploySlot blocks until one of limit slot locks in dir is free, the timeout
+// elapses or ctx is cancelled. onWait is called once when every slot is busy.
+func AcquireDeploySlot(ctx context.Context, dir string, limit int, timeout time.Duration, onWait func()) (func() error, error) {
+	if limit <= 0 {
+		return func() error { return nil }, nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	waited := false
+	for {
+		for slot := 0; slot < limit; slot++ {
+			unlock, acquired, err := TryExclusiveFileLock(filepath.Join(dir, fmt.Sprintf("deploy-slot-%d.lock", slot)))
+			if err != nil {
+				return nil, fmt.Errorf("acquire deploy slot: %w", err)
+			}
+			if acquired {
+				return unlock, nil
+			}
+		}
+
+		if !waited {
+			waited = true
+			if onWait != nil {
+				onWait()
+			}
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("timed out after %s waiting for a free deploy slot: %d concurrent deploys are already running on this host", timeout, limit)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(deploySlotPollInterval):
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package state
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireDeploySlot_LimitsConcurrentHolders(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	unlockFirst, err := AcquireDeploySlot(context.Background(), dir, 2, time.Second, nil)
+	if err != nil {
+		t.Fatalf("acquire first slot: %v", err)
+	}
+	defer func() { _ = unlockFirst() }()
+	unlockSecond, err := AcquireDeploySlot(context.Background(), dir, 2, time.Second, nil)
+	if err != nil {
+		t.Fatalf("acquire second slot: %v", err)
+	}
+
+	waited := false
+	_, err = AcquireDeploySlot(context.Background(), dir, 2, 10*time.Millisecond, func() { waited = true })
+	if err == nil {
+		t.Fatal("expected timeout when all slots are busy")
+	}
+	if !strings.Contains(err.Error(), "waiting for a free deploy slot") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !waited {
+		t.Fatal("expected wait callback to be called")
+	}
+
+	if err := unlockSecond(); err != nil {
+		t.Fatalf("release second slot: %v", err)
+	}
+	unlockThird, err := AcquireDeploySlot(context.Background(), dir, 2, time.Second, nil)
+	if err != nil {
+		t.Fatalf("acquire freed slot: %v", err)
+	}
+	_ = unlockThird()
+}
+
+func TestAcquireDeploySlot_DisabledWhenLimitIsZero(t *testing.T) {
+	t.Parallel()
+
+	unlock, err := AcquireDeploySlot(context.Background(), t.TempDir(), 0, time.Second, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unexpected unlock error: %v", err)
+	}
+}
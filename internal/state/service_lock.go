@@ -0,0 +1,70 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ServiceLocksDir is the directory under the state dir holding one lock file per
+// project and service.
+const ServiceLocksDir = "locks"
+
+var serviceLockPollInterval = 500 * time.Millisecond
+
+// AcquireServiceLock takes the exclusive lock of key in dir so that two runs against
+// the same service do not scale it and rewrite the proxy config at the same time.
+// holder is written into the lock file and reported to runs that find it taken. With a
+// zero timeout a taken lock fails at once; otherwise it is polled until the timeout
+// elapses or ctx is cancelled, and onWait is called once with the current holder.
+func AcquireServiceLock(ctx context.Context, dir string, key string, holder string, timeout time.Duration, onWait func(holder string)) (func() error, error) {
+	path := filepath.Join(dir, key+".lock")
+	deadline := time.Now().Add(timeout)
+	waited := false
+	for {
+		unlock, acquired, err := TryExclusiveFileLock(path)
+		if err != nil {
+			return nil, fmt.Errorf("acquire lock %s: %w", path, err)
+		}
+		if acquired {
+			if err := os.WriteFile(path, []byte(holder+"\n"), 0o644); err != nil {
+				_ = unlock()
+				return nil, fmt.Errorf("write lock holder to %s: %w", path, err)
+			}
+			return unlock, nil
+		}
+
+		current := ServiceLockHolder(path)
+		if !time.Now().Before(deadline) {
+			if timeout <= 0 {
+				return nil, fmt.Errorf("another ztd run holds the lock %s (%s)", path, current)
+			}
+			return nil, fmt.Errorf("timed out after %s waiting for the lock %s held by another ztd run (%s)", timeout, path, current)
+		}
+		if !waited {
+			waited = true
+			if onWait != nil {
+				onWait(current)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(serviceLockPollInterval):
+		}
+	}
+}
+
+// ServiceLockHolder returns the holder recorded in the lock file at path, or
+// "holder unknown" when it cannot be read.
+func ServiceLockHolder(path string) string {
+	data, err := os.ReadFile(path)
+	if holder := strings.TrimSpace(string(data)); err == nil && holder != "" {
+		return holder
+	}
+	return "holder unknown"
+}
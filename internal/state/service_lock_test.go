@@ -0,0 +1,84 @@
+package state
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireServiceLock_FailsFastAndNamesHolder(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	unlock, err := AcquireServiceLock(context.Background(), dir, "shop--api", "pid 1 on ci-runner", 0, nil)
+	if err != nil {
+		t.Fatalf("acquire lock: %v", err)
+	}
+
+	_, err = AcquireServiceLock(context.Background(), dir, "shop--api", "pid 2 on ci-runner", 0, nil)
+	if err == nil {
+		t.Fatal("expected a held lock to fail at once")
+	}
+	if !strings.Contains(err.Error(), "pid 1 on ci-runner") {
+		t.Fatalf("expected the holder in the error, got %v", err)
+	}
+
+	other, err := AcquireServiceLock(context.Background(), dir, "shop--web", "pid 2 on ci-runner", 0, nil)
+	if err != nil {
+		t.Fatalf("expected other services to lock independently: %v", err)
+	}
+	_ = other()
+
+	if err := unlock(); err != nil {
+		t.Fatalf("release lock: %v", err)
+	}
+	again, err := AcquireServiceLock(context.Background(), dir, "shop--api", "pid 3 on ci-runner", 0, nil)
+	if err != nil {
+		t.Fatalf("acquire released lock: %v", err)
+	}
+	_ = again()
+}
+
+func TestAcquireServiceLock_WaitsForRelease(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	unlock, err := AcquireServiceLock(context.Background(), dir, "shop--api", "pid 1", 0, nil)
+	if err != nil {
+		t.Fatalf("acquire lock: %v", err)
+	}
+
+	var waitedOn string
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = unlock()
+	}()
+	second, err := AcquireServiceLock(context.Background(), dir, "shop--api", "pid 2", 5*time.Second, func(holder string) { waitedOn = holder })
+	if err != nil {
+		t.Fatalf("expected the lock once released: %v", err)
+	}
+	defer func() { _ = second() }()
+	if waitedOn != "pid 1" {
+		t.Fatalf("expected the wait callback to name the holder, got %q", waitedOn)
+	}
+	if got := ServiceLockHolder(dir + "/shop--api.lock"); got != "pid 2" {
+		t.Fatalf("expected the new holder in the lock file, got %q", got)
+	}
+}
+
+func TestAcquireServiceLock_TimesOut(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	unlock, err := AcquireServiceLock(context.Background(), dir, "shop--api", "pid 1", 0, nil)
+	if err != nil {
+		t.Fatalf("acquire lock: %v", err)
+	}
+	defer func() { _ = unlock() }()
+
+	_, err = AcquireServiceLock(context.Background(), dir, "shop--api", "pid 2", 20*time.Millisecond, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+}
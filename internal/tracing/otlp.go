@@ -0,0 +1,212 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+)
+
+const (
+	serviceName      = "docker-ztd"
+	rootSpanName     = "deploy"
+	spanKindInternal = 1
+	statusCodeError  = 2
+)
+
+type span struct {
+	spanID string
+	parent string
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  map[string]string
+	err    string
+}
+
+// Tracer turns deploy progress events into a root "deploy" span with one child span
+// per phase and exports them to an OTLP/HTTP collector as JSON.
+type Tracer struct {
+	mu       sync.Mutex
+	endpoint string
+	client   *http.Client
+	traceID  string
+	root     *span
+	current  *span
+	finished []*span
+}
+
+// NewTraceID returns a random W3C trace ID, also used as the deploy ID.
+func NewTraceID() string {
+	return randomHex(16)
+}
+
+func New(endpoint string, traceID string, service string) *Tracer {
+	t := &Tracer{
+		endpoint: tracesURL(endpoint),
+		client:   &http.Client{Timeout: 5 * time.Second},
+		traceID:  traceID,
+	}
+	t.root = &span{
+		spanID: randomHex(8),
+		name:   rootSpanName,
+		start:  time.Now(),
+		attrs:  map[string]string{"ztd.service": service},
+	}
+	return t
+}
+
+// TraceID identifies the deploy; it is propagated into audit log entries.
+func (t *Tracer) TraceID() string {
+	return t.traceID
+}
+
+func (t *Tracer) Emit(event events.Event) {
+	now := event.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch event.Type {
+	case events.TypePhase:
+		t.endCurrent(now)
+		t.current = &span{
+			spanID: randomHex(8),
+			parent: t.root.spanID,
+			name:   event.Phase,
+			start:  now,
+			attrs:  map[string]string{"ztd.service": event.Service},
+		}
+	case events.TypeDeployFinished:
+		t.endCurrent(now)
+		if event.Status != "" {
+			t.root.attrs["ztd.status"] = event.Status
+		}
+		if event.Status == "failed" {
+			t.root.err = event.Message
+		}
+		if t.root.end.IsZero() {
+			t.root.end = now
+		}
+	}
+}
+
+func (t *Tracer) endCurrent(now time.Time) {
+	if t.current == nil {
+		return
+	}
+	t.current.end = now
+	t.finished = append(t.finished, t.current)
+	t.current = nil
+}
+
+// Flush ends open spans and exports everything recorded so far.
+func (t *Tracer) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	now := time.Now()
+	t.endCurrent(now)
+	if t.root.end.IsZero() {
+		t.root.end = now
+	}
+	spans := append([]*span{t.root}, t.finished...)
+	t.finished = nil
+	payload := t.payload(spans)
+	t.mu.Unlock()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export traces to %s: %w", t.endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("export traces to %s: unexpected status %s", t.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (t *Tracer) payload(spans []*span) map[string]any {
+	out := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		item := map[string]any{
+			"traceId":           t.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              spanKindInternal,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        attributes(s.attrs),
+		}
+		if s.parent != "" {
+			item["parentSpanId"] = s.parent
+		}
+		if s.err != "" {
+			item["status"] = map[string]any{"code": statusCodeError, "message": s.err}
+		}
+		out = append(out, item)
+	}
+	return map[string]any{
+		"resourceSpans": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": attributes(map[string]string{"service.name": serviceName}),
+				},
+				"scopeSpans": []any{
+					map[string]any{
+						"scope": map[string]any{"name": serviceName},
+						"spans": out,
+					},
+				},
+			},
+		},
+	}
+}
+
+func attributes(values map[string]string) []any {
+	attrs := make([]any, 0, len(values))
+	for key, value := range values {
+		if value == "" {
+			continue
+		}
+		attrs = append(attrs, map[string]any{
+			"key":   key,
+			"value": map[string]any{"stringValue": value},
+		})
+	}
+	return attrs
+}
+
+func tracesURL(endpoint string) string {
+	endpoint = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	if strings.HasSuffix(endpoint, "/v1/traces") {
+		return endpoint
+	}
+	return endpoint + "/v1/traces"
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return strings.Repeat("0", n*2)
+	}
+	return hex.EncodeToString(buf)
+}
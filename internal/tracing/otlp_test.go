@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/events"
+)
+
+func TestTracer_ExportsRootAndPhaseSpans(t *testing.T) {
+	var body struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Status       *struct {
+						Code int `json:"code"`
+					} `json:"status"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tracer := New(server.URL, NewTraceID(), "api")
+	events.Phase(tracer, "api", events.PhaseScale)
+	events.Phase(tracer, "api", events.PhaseWaitHealthy)
+	tracer.Emit(events.Event{Type: events.TypeDeployFinished, Service: "api", Status: "failed", Message: "boom"})
+	if err := tracer.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	if path != "/v1/traces" {
+		t.Fatalf("unexpected export path: %s", path)
+	}
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 3 {
+		t.Fatalf("expected root and 2 phase spans, got %d", len(spans))
+	}
+	root := spans[0]
+	if root.Name != "deploy" || root.TraceID != tracer.TraceID() || root.Status == nil || root.Status.Code != statusCodeError {
+		t.Fatalf("unexpected root span: %#v", root)
+	}
+	if spans[1].Name != "scale" || spans[1].ParentSpanID != root.SpanID || spans[2].Name != "wait-healthy" {
+		t.Fatalf("unexpected phase spans: %#v", spans[1:])
+	}
+}
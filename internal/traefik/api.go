@@ -0,0 +1,197 @@
+package traefik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
+)
+
+// apiRequestTimeout bounds a single Traefik API request.
+var apiRequestTimeout = 5 * time.Second
+
+// apiService is a service of the dynamic config together with the servers of it that
+// point at hosts being confirmed.
+type apiService struct {
+	protocol string // "http", "tcp" or "udp", the Traefik API path segment
+	name     string
+	servers  []string
+}
+
+type apiServiceResponse struct {
+	LoadBalancer *struct {
+		Servers []struct {
+			URL     string `json:"url"`
+			Address string `json:"address"`
+		} `json:"servers"`
+	} `json:"loadBalancer"`
+}
+
+// WaitForAPI polls the Traefik API at baseURL until every service in the config at
+// path that routes to one of hosts reports those servers, i.e. Traefik has loaded the
+// rewritten file. It gives up with an error after timeout.
+func WaitForAPI(ctx context.Context, client *http.Client, baseURL string, path string, hosts []string, timeout time.Duration, interval time.Duration) error {
+	configio.Mu.Lock()
+	cfg, err := readDynamicConfig(path)
+	configio.Mu.Unlock()
+	if err != nil {
+		return err
+	}
+	pending := expectedAPIServices(cfg, hosts)
+	if len(pending) == 0 {
+		return nil
+	}
+	if client == nil {
+		client = &http.Client{Timeout: apiRequestTimeout}
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var missing []apiService
+		var lastErr error
+		for _, svc := range pending {
+			ok, err := apiServiceLoaded(ctx, client, baseURL, svc)
+			if err != nil {
+				lastErr = err
+			}
+			if !ok {
+				missing = append(missing, svc)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		pending = missing
+		if !time.Now().Before(deadline) {
+			names := make([]string, 0, len(missing))
+			for _, svc := range missing {
+				names = append(names, svc.name)
+			}
+			if lastErr != nil {
+				return fmt.Errorf("the Traefik API did not report the new servers of %s within %s: %w", strings.Join(names, ", "), timeout, lastErr)
+			}
+			return fmt.Errorf("the Traefik API did not report the new servers of %s within %s", strings.Join(names, ", "), timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+func expectedAPIServices(cfg types.DynamicConfig, hosts []string) []apiService {
+	want := map[string]bool{}
+	for _, host := range hosts {
+		want[host] = true
+	}
+	var out []apiService
+	if cfg.HTTP != nil {
+		for name, svc := range cfg.HTTP.Services {
+			if svc.LoadBalancer == nil {
+				continue
+			}
+			var servers []string
+			for _, server := range svc.LoadBalancer.Servers {
+				if u, err := url.Parse(server.URL); err == nil && want[u.Hostname()] {
+					servers = append(servers, server.URL)
+				}
+			}
+			if len(servers) > 0 {
+				out = append(out, apiService{protocol: "http", name: name, servers: servers})
+			}
+		}
+	}
+	if cfg.TCP != nil {
+		for name, svc := range cfg.TCP.Services {
+			if svc.LoadBalancer == nil {
+				continue
+			}
+			var servers []string
+			for _, server := range svc.LoadBalancer.Servers {
+				if want[addressHost(server.Address)] {
+					servers = append(servers, strings.TrimSpace(server.Address))
+				}
+			}
+			if len(servers) > 0 {
+				out = append(out, apiService{protocol: "tcp", name: name, servers: servers})
+			}
+		}
+	}
+	if cfg.UDP != nil {
+		for name, svc := range cfg.UDP.Services {
+			if svc.LoadBalancer == nil {
+				continue
+			}
+			var servers []string
+			for _, server := range svc.LoadBalancer.Servers {
+				if want[addressHost(server.Address)] {
+					servers = append(servers, strings.TrimSpace(server.Address))
+				}
+			}
+			if len(servers) > 0 {
+				out = append(out, apiService{protocol: "udp", name: name, servers: servers})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].protocol != out[j].protocol {
+			return out[i].protocol < out[j].protocol
+		}
+		return out[i].name < out[j].name
+	})
+	return out
+}
+
+// apiServiceLoaded reports whether Traefik serves svc with all of its expected
+// servers. Services from the dynamic config file live in the file provider. A service
+// Traefik does not know yet (404) is not loaded rather than an error.
+func apiServiceLoaded(ctx context.Context, client *http.Client, baseURL string, svc apiService) (bool, error) {
+	endpoint := strings.TrimRight(baseURL, "/") + "/api/" + svc.protocol + "/services/" + url.PathEscape(svc.name+"@file")
+	ctx, cancel := context.WithTimeout(ctx, apiRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, fmt.Errorf("Traefik API %s returned %s", endpoint, resp.Status)
+	}
+
+	var body apiServiceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return false, fmt.Errorf("decode Traefik API response from %s: %w", endpoint, err)
+	}
+	if body.LoadBalancer == nil {
+		return false, nil
+	}
+	loaded := map[string]bool{}
+	for _, server := range body.LoadBalancer.Servers {
+		loaded[server.URL] = true
+		loaded[server.Address] = true
+	}
+	for _, server := range svc.servers {
+		if !loaded[server] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
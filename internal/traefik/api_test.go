@@ -0,0 +1,91 @@
+package traefik
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const apiTestConfig = `http:
+  services:
+    api:
+      loadBalancer:
+        servers:
+          - url: http://new1:8080
+    web:
+      loadBalancer:
+        servers:
+          - url: http://other:80
+tcp:
+  services:
+    db:
+      loadBalancer:
+        servers:
+          - address: new1:5432
+`
+
+func writeAPITestConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	if err := os.WriteFile(path, []byte(apiTestConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWaitForAPI_WaitsUntilServersAreLoaded(t *testing.T) {
+	path := writeAPITestConfig(t)
+	var polls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/http/services/api@file":
+			if polls.Add(1) < 3 {
+				_, _ = w.Write([]byte(`{"loadBalancer":{"servers":[{"url":"http://old1:8080"}]}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"loadBalancer":{"servers":[{"url":"http://new1:8080"}]}}`))
+		case "/api/tcp/services/db@file":
+			_, _ = w.Write([]byte(`{"loadBalancer":{"servers":[{"address":"new1:5432"}]}}`))
+		default:
+			t.Errorf("unexpected request for %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	err := WaitForAPI(context.Background(), srv.Client(), srv.URL+"/", path, []string{"new1"}, 5*time.Second, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := polls.Load(); got != 3 {
+		t.Fatalf("expected 3 polls of the http service, got %d", got)
+	}
+}
+
+func TestWaitForAPI_TimesOut(t *testing.T) {
+	path := writeAPITestConfig(t)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	err := WaitForAPI(context.Background(), srv.Client(), srv.URL, path, []string{"new1"}, 50*time.Millisecond, 10*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if !strings.Contains(err.Error(), "api") || !strings.Contains(err.Error(), "db") {
+		t.Fatalf("expected the pending services in the error, got %v", err)
+	}
+}
+
+func TestWaitForAPI_NothingToConfirm(t *testing.T) {
+	path := writeAPITestConfig(t)
+	err := WaitForAPI(context.Background(), nil, "http://127.0.0.1:1", path, []string{"absent"}, time.Second, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("expected no requests when no service routes to the hosts, got %v", err)
+	}
+}
@@ -19,6 +19,7 @@ type BlueGreenConfigInput struct {
 	Active         string
 	ProductionRule string
 	Port           string
+	Scheme         string
 	BlueIDs        []string
 	GreenIDs       []string
 	TCPRouters     []TCPRouteInput
@@ -37,9 +38,11 @@ func ApplyBlueGreenConfig(path string, input BlueGreenConfigInput) error {
 		return fmt.Errorf("production rule is required")
 	}
 	if strings.TrimSpace(input.Port) == "" {
-		input.Port = "80"
+		input.Port = DefaultBackendPort
 	}
 
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
 	cfg, err := readDynamicConfig(path)
 	if err != nil {
 		return err
@@ -50,8 +53,8 @@ func ApplyBlueGreenConfig(path string, input BlueGreenConfigInput) error {
 	blueService := serviceColorName(input.Service, state.ColorBlue)
 	greenService := serviceColorName(input.Service, state.ColorGreen)
 
-	setOrDeleteHTTPService(cfg.HTTP.Services, blueService, input.BlueIDs, input.Port, input.HealthCheck)
-	setOrDeleteHTTPService(cfg.HTTP.Services, greenService, input.GreenIDs, input.Port, input.HealthCheck)
+	setOrDeleteHTTPService(cfg.HTTP.Services, blueService, input.BlueIDs, input.Scheme, input.Port, input.HealthCheck)
+	setOrDeleteHTTPService(cfg.HTTP.Services, greenService, input.GreenIDs, input.Scheme, input.Port, input.HealthCheck)
 	delete(cfg.HTTP.Services, input.Service)
 
 	activeService := blueService
@@ -59,8 +62,11 @@ func ApplyBlueGreenConfig(path string, input BlueGreenConfigInput) error {
 		activeService = greenService
 	}
 	cfg.HTTP.Routers[input.Service] = types.HTTPRouter{
-		Rule:    input.ProductionRule,
-		Service: activeService,
+		EntryPoints: cfg.HTTP.Routers[input.Service].EntryPoints,
+		Rule:        input.ProductionRule,
+		Service:     activeService,
+		Middlewares: cfg.HTTP.Routers[input.Service].Middlewares,
+		TLS:         cfg.HTTP.Routers[input.Service].TLS,
 	}
 
 	greenRuleSource := input.ProductionRule
@@ -162,7 +168,7 @@ func ensureTCPConfig(cfg *types.DynamicConfig) {
 	}
 }
 
-func setOrDeleteHTTPService(services map[string]types.HTTPService, name string, ids []string, port string, hc *types.HealthChecks) {
+func setOrDeleteHTTPService(services map[string]types.HTTPService, name string, ids []string, scheme string, port string, hc *types.HealthChecks) {
 	if len(ids) == 0 {
 		delete(services, name)
 		return
@@ -170,7 +176,7 @@ func setOrDeleteHTTPService(services map[string]types.HTTPService, name string,
 	servers := make([]types.HTTPServer, 0, len(ids))
 	for _, id := range ids {
 		servers = append(servers, types.HTTPServer{
-			URL: "http://" + shortID(id) + ":" + port,
+			URL: serverURL(scheme, configio.ShortID(id), port),
 		})
 	}
 	svc := types.HTTPService{
@@ -204,7 +210,7 @@ func setOrDeleteTCPService(services map[string]types.TCPService, name string, id
 	servers := make([]types.TCPServer, 0, len(ids))
 	for _, id := range ids {
 		servers = append(servers, types.TCPServer{
-			Address: shortID(id) + ":" + port,
+			Address: configio.ShortID(id) + ":" + port,
 		})
 	}
 	services[name] = types.TCPService{
@@ -166,3 +166,36 @@ func assertNotContains(t *testing.T, content string, unexpected string) {
 		t.Fatalf("expected content not to include %q\n%s", unexpected, content)
 	}
 }
+
+func TestApplyBlueGreenConfig_PreservesRouterTLS(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	existing := "http:\n  routers:\n    api:\n      entryPoints: [websecure]\n      rule: Host(`example.com`)\n      service: api\n      tls:\n        options: modern@file\n"
+	if err := os.WriteFile(path, []byte(existing), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	err := ApplyBlueGreenConfig(path, BlueGreenConfigInput{
+		Service:        "api",
+		Active:         state.ColorGreen,
+		ProductionRule: "Host(`example.com`)",
+		Port:           "8080",
+		BlueIDs:        []string{"aaaaaaaaaaaa111111111111"},
+		GreenIDs:       []string{"bbbbbbbbbbbb222222222222"},
+	})
+	if err != nil {
+		t.Fatalf("apply config: %v", err)
+	}
+
+	cfg, err := readDynamicConfig(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	router := cfg.HTTP.Routers["api"]
+	if router.TLS == nil || router.TLS.Options != "modern@file" {
+		t.Fatalf("expected tls options to be preserved, got %#v", router)
+	}
+	if len(router.EntryPoints) != 1 || router.EntryPoints[0] != "websecure" {
+		t.Fatalf("expected entrypoints to be preserved, got %#v", router)
+	}
+}
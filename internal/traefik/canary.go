@@ -14,11 +14,15 @@ type CanaryConfigInput struct {
 	Service        string
 	ProductionRule string
 	Port           string
+	Scheme         string
 	OldIDs         []string
 	NewIDs         []string
 	NewWeight      int
 	TCPRouters     []TCPRouteInput
 	HealthCheck    *types.HealthChecks
+	// CanaryRule, when set, adds a <service>-canary router that reaches only
+	// the new containers, independent of the weighted split.
+	CanaryRule string
 }
 
 func ApplyCanaryConfig(path string, input CanaryConfigInput) error {
@@ -32,7 +36,7 @@ func ApplyCanaryConfig(path string, input CanaryConfigInput) error {
 		return fmt.Errorf("new weight must be between 0 and 100")
 	}
 	if strings.TrimSpace(input.Port) == "" {
-		input.Port = "80"
+		input.Port = DefaultBackendPort
 	}
 
 	oldWeight := 100 - input.NewWeight
@@ -43,6 +47,8 @@ func ApplyCanaryConfig(path string, input CanaryConfigInput) error {
 		return fmt.Errorf("new containers are required when new weight is %d", input.NewWeight)
 	}
 
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
 	cfg, err := readDynamicConfig(path)
 	if err != nil {
 		return err
@@ -53,8 +59,8 @@ func ApplyCanaryConfig(path string, input CanaryConfigInput) error {
 	oldService := canaryServiceName(input.Service, "old")
 	newService := canaryServiceName(input.Service, "new")
 
-	setOrDeleteHTTPService(cfg.HTTP.Services, oldService, input.OldIDs, input.Port, input.HealthCheck)
-	setOrDeleteHTTPService(cfg.HTTP.Services, newService, input.NewIDs, input.Port, input.HealthCheck)
+	setOrDeleteHTTPService(cfg.HTTP.Services, oldService, input.OldIDs, input.Scheme, input.Port, input.HealthCheck)
+	setOrDeleteHTTPService(cfg.HTTP.Services, newService, input.NewIDs, input.Scheme, input.Port, input.HealthCheck)
 
 	weighted := make([]types.HTTPWeightedService, 0, 2)
 	if oldWeight > 0 {
@@ -75,8 +81,23 @@ func ApplyCanaryConfig(path string, input CanaryConfigInput) error {
 
 	setOrDeleteWeightedHTTPService(cfg.HTTP.Services, input.Service, weighted)
 	cfg.HTTP.Routers[input.Service] = types.HTTPRouter{
-		Rule:    input.ProductionRule,
-		Service: input.Service,
+		EntryPoints: cfg.HTTP.Routers[input.Service].EntryPoints,
+		Rule:        input.ProductionRule,
+		Service:     input.Service,
+		Middlewares: cfg.HTTP.Routers[input.Service].Middlewares,
+		TLS:         cfg.HTTP.Routers[input.Service].TLS,
+	}
+	canaryRouter := CanaryRouterName(input.Service)
+	if strings.TrimSpace(input.CanaryRule) != "" && len(input.NewIDs) > 0 {
+		cfg.HTTP.Routers[canaryRouter] = types.HTTPRouter{
+			EntryPoints: cfg.HTTP.Routers[input.Service].EntryPoints,
+			Rule:        input.CanaryRule,
+			Service:     newService,
+			Middlewares: cfg.HTTP.Routers[input.Service].Middlewares,
+			TLS:         cfg.HTTP.Routers[input.Service].TLS,
+		}
+	} else {
+		delete(cfg.HTTP.Routers, canaryRouter)
 	}
 
 	for _, tcp := range input.TCPRouters {
@@ -119,6 +140,11 @@ func ApplyCanaryConfig(path string, input CanaryConfigInput) error {
 	return configio.WriteAtomic(path, data, 0o644)
 }
 
+// CanaryRouterName is the router that exposes only the new canary containers.
+func CanaryRouterName(service string) string {
+	return service + "-canary"
+}
+
 func canaryServiceName(service string, side string) string {
 	return service + "_" + side
 }
@@ -115,3 +115,49 @@ func TestApplyCanaryConfig_OmitsTCPSectionWhenNoTCPRouters(t *testing.T) {
 		t.Fatalf("expected no tcp section in generated config, got:\n%s", content)
 	}
 }
+
+func TestApplyCanaryConfig_CanaryRouterTargetsNewContainersOnly(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	input := CanaryConfigInput{
+		Service:        "api",
+		ProductionRule: "Host(`example.com`)",
+		Port:           "8080",
+		OldIDs:         []string{"aaaaaaaaaaaa111111111111"},
+		NewIDs:         []string{"bbbbbbbbbbbb222222222222"},
+		NewWeight:      10,
+		CanaryRule:     "Host(`canary.example.com`)",
+	}
+	if err := ApplyCanaryConfig(path, input); err != nil {
+		t.Fatalf("apply config: %v", err)
+	}
+
+	cfg, err := readDynamicConfig(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	router, ok := cfg.HTTP.Routers["api-canary"]
+	if !ok {
+		t.Fatalf("expected api-canary router, got %#v", cfg.HTTP.Routers)
+	}
+	if router.Service != "api_new" || router.Rule != "Host(`canary.example.com`)" {
+		t.Fatalf("unexpected canary router: %#v", router)
+	}
+	if cfg.HTTP.Routers["api"].Service != "api" {
+		t.Fatalf("expected production router on weighted service, got %#v", cfg.HTTP.Routers["api"])
+	}
+
+	input.CanaryRule = ""
+	input.NewWeight = 100
+	if err := ApplyCanaryConfig(path, input); err != nil {
+		t.Fatalf("apply promoted config: %v", err)
+	}
+	cfg, err = readDynamicConfig(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if _, ok := cfg.HTTP.Routers["api-canary"]; ok {
+		t.Fatalf("expected api-canary router to be removed, got %#v", cfg.HTTP.Routers)
+	}
+}
@@ -1,48 +1,245 @@
 package traefik
 
 import (
+	"fmt"
 	"os"
 	"sort"
+	"strings"
 
-	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
+)
+
+const (
+	portSourceLabel   = "label"
+	portSourceExpose  = "compose expose"
+	portSourcePorts   = "compose ports"
+	portSourceDefault = "default"
 )
 
 type composeFile struct {
-	Services map[string]composeService `yaml:"services"`
+	Services    map[string]composeService       `yaml:"services"`
+	Middlewares map[string]types.HTTPMiddleware `yaml:"x-ztd-middlewares"`
 }
 
 type composeService struct {
-	Labels any `yaml:"labels"`
+	Labels any   `yaml:"labels"`
+	Expose []any `yaml:"expose"`
+	Ports  []any `yaml:"ports"`
+}
+
+type composePort struct {
+	Port   string
+	Source string
+}
+
+func collectTraefikEnabledServices(files []string, envFiles []string) ([]string, error) {
+	flags, err := collectTraefikEnableFlags(files, envFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]string, 0, len(flags))
+	for name, enabled := range flags {
+		if enabled {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// collectTraefikEnableFlags returns traefik.enable for every service that sets it.
+// Later compose files override earlier ones, so an override file can disable a route.
+func collectTraefikEnableFlags(files []string, envFiles []string) (map[string]bool, error) {
+	flags := map[string]bool{}
+	cfgs, err := readComposeFiles(files, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range cfgs {
+		for name, svc := range cfg.Services {
+			if enabled, set := traefikEnableLabel(svc.Labels); set {
+				flags[name] = enabled
+			}
+		}
+	}
+	return flags, nil
+}
+
+// collectComposeServicePorts returns the first container port declared by each
+// service via expose (preferred) or ports. Later compose files override earlier ones.
+func collectComposeServicePorts(files []string, envFiles []string) (map[string]composePort, error) {
+	ports := map[string]composePort{}
+	cfgs, err := readComposeFiles(files, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range cfgs {
+		for name, svc := range cfg.Services {
+			if port := firstExposePort(svc.Expose); port != "" {
+				ports[name] = composePort{Port: port, Source: portSourceExpose}
+				continue
+			}
+			if port := firstPublishedContainerPort(svc.Ports); port != "" {
+				ports[name] = composePort{Port: port, Source: portSourcePorts}
+			}
+		}
+	}
+	return ports, nil
 }
 
-func collectTraefikEnabledServices(files []string) ([]string, error) {
-	enabled := map[string]struct{}{}
+// collectComposeMiddlewares returns middleware definitions from the x-ztd-middlewares
+// extension block. Later compose files override definitions with the same name.
+func collectComposeMiddlewares(files []string, envFiles []string) (map[string]types.HTTPMiddleware, error) {
+	middlewares := map[string]types.HTTPMiddleware{}
+	cfgs, err := readComposeFiles(files, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range cfgs {
+		for name, mw := range cfg.Middlewares {
+			middlewares[name] = mw
+		}
+	}
+	return middlewares, nil
+}
+
+// routerMiddlewares parses the comma-separated traefik.http.routers.<service>.middlewares label.
+func routerMiddlewares(labels map[string]string, serviceName string) []string {
+	var names []string
+	for _, name := range strings.Split(labels["traefik.http.routers."+serviceName+".middlewares"], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// routerTLS parses traefik.http.routers.<service>.tls and its options/certresolver
+// sub-labels. Either sub-label implies TLS, so it is enabled even without tls=true.
+func routerTLS(labels map[string]string, serviceName string) *types.HTTPRouterTLS {
+	prefix := "traefik.http.routers." + serviceName + ".tls"
+	tls := &types.HTTPRouterTLS{
+		Options:      strings.TrimSpace(labels[prefix+".options"]),
+		CertResolver: strings.TrimSpace(labels[prefix+".certresolver"]),
+	}
+	if tls.Options == "" && tls.CertResolver == "" && !parseTLSLabel(labels[prefix]) {
+		return nil
+	}
+	return tls
+}
+
+// composeServiceLabels returns labels declared for service in the compose files,
+// so label edits can be applied before containers are recreated.
+func composeServiceLabels(files []string, envFiles []string, service string) (map[string]string, error) {
+	labels := map[string]string{}
+	cfgs, err := readComposeFiles(files, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range cfgs {
+		svc, ok := cfg.Services[service]
+		if !ok {
+			continue
+		}
+		switch v := svc.Labels.(type) {
+		case []any:
+			for _, item := range v {
+				parts := strings.SplitN(fmt.Sprint(item), "=", 2)
+				if len(parts) == 2 {
+					labels[strings.TrimSpace(parts[0])] = parts[1]
+				}
+			}
+		case map[string]any:
+			for key, val := range v {
+				labels[key] = fmt.Sprint(val)
+			}
+		}
+	}
+	return labels, nil
+}
+
+// readComposeFiles parses every compose file, interpolating ${VAR} references with the
+// env files and the process environment like docker compose does.
+func readComposeFiles(files []string, envFiles []string) ([]composeFile, error) {
+	env, err := compose.LoadEnv(files, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	cfgs := make([]composeFile, 0, len(files))
 	for _, file := range files {
 		data, err := os.ReadFile(file)
 		if err != nil {
 			return nil, err
 		}
-
 		var cfg composeFile
-		if err := configio.UnmarshalYAML(data, &cfg); err != nil {
-			return nil, err
+		if err := compose.UnmarshalInterpolated(data, env, &cfg); err != nil {
+			return nil, fmt.Errorf("compose file %s: %w", file, err)
 		}
-		for name, svc := range cfg.Services {
-			if hasTraefikEnableLabel(svc.Labels) {
-				enabled[name] = struct{}{}
+		cfgs = append(cfgs, cfg)
+	}
+	return cfgs, nil
+}
+
+func firstExposePort(expose []any) string {
+	for _, item := range expose {
+		if port := normalizeContainerPort(fmt.Sprint(item)); port != "" {
+			return port
+		}
+	}
+	return ""
+}
+
+func firstPublishedContainerPort(ports []any) string {
+	for _, item := range ports {
+		switch v := item.(type) {
+		case map[string]any:
+			if target, ok := v["target"]; ok {
+				if port := normalizeContainerPort(fmt.Sprint(target)); port != "" {
+					return port
+				}
+			}
+		default:
+			// Short syntax: [HOST:]CONTAINER[/PROTOCOL] or IP:HOST:CONTAINER; the container side is last.
+			raw := fmt.Sprint(v)
+			if idx := strings.LastIndex(raw, ":"); idx >= 0 {
+				raw = raw[idx+1:]
+			}
+			if port := normalizeContainerPort(raw); port != "" {
+				return port
 			}
 		}
 	}
+	return ""
+}
 
-	services := make([]string, 0, len(enabled))
-	for name := range enabled {
-		services = append(services, name)
+func normalizeContainerPort(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if idx := strings.Index(raw, "/"); idx >= 0 {
+		raw = raw[:idx]
 	}
-	sort.Strings(services)
-	return services, nil
+	if idx := strings.Index(raw, "-"); idx >= 0 {
+		raw = raw[:idx]
+	}
+	if raw == "" {
+		return ""
+	}
+	for _, r := range raw {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	return raw
 }
 
 func hasTraefikEnableLabel(labels any) bool {
+	enabled, _ := traefikEnableLabel(labels)
+	return enabled
+}
+
+// traefikEnableLabel reports the traefik.enable value and whether the label is set at all.
+func traefikEnableLabel(labels any) (bool, bool) {
 	switch v := labels.(type) {
 	case []any:
 		for _, item := range v {
@@ -50,19 +247,19 @@ func hasTraefikEnableLabel(labels any) bool {
 			if !ok {
 				continue
 			}
-			if s == "traefik.enable=true" {
-				return true
+			if value, found := strings.CutPrefix(s, "traefik.enable="); found {
+				return value == "true", true
 			}
 		}
 	case map[string]any:
 		if val, ok := v["traefik.enable"]; ok {
 			switch b := val.(type) {
 			case bool:
-				return b
+				return b, true
 			case string:
-				return b == "true"
+				return b == "true", true
 			}
 		}
 	}
-	return false
+	return false, false
 }
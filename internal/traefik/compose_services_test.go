@@ -1,6 +1,14 @@
 package traefik
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
+)
 
 func TestHasTraefikEnableLabel(t *testing.T) {
 	cases := []struct {
@@ -39,6 +47,33 @@ func TestHasTraefikEnableLabel(t *testing.T) {
 	}
 }
 
+func TestCollectTraefikEnableFlags_OverrideDisables(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "compose.yml")
+	override := filepath.Join(dir, "compose.override.yml")
+	if err := os.WriteFile(base, []byte("services:\n  api:\n    labels:\n      - traefik.enable=true\n  web:\n    labels:\n      - traefik.enable=true\n"), 0o644); err != nil {
+		t.Fatalf("write compose: %v", err)
+	}
+	if err := os.WriteFile(override, []byte("services:\n  api:\n    labels:\n      - traefik.enable=false\n"), 0o644); err != nil {
+		t.Fatalf("write override: %v", err)
+	}
+
+	flags, err := collectTraefikEnableFlags([]string{base, override}, nil)
+	if err != nil {
+		t.Fatalf("collect flags: %v", err)
+	}
+	if enabled, set := flags["api"]; !set || enabled {
+		t.Fatalf("expected api to be explicitly disabled, got enabled=%v set=%v", enabled, set)
+	}
+	services, err := collectTraefikEnabledServices([]string{base, override}, nil)
+	if err != nil {
+		t.Fatalf("collect services: %v", err)
+	}
+	if len(services) != 1 || services[0] != "web" {
+		t.Fatalf("expected only web to be enabled, got %v", services)
+	}
+}
+
 func TestSplitEntryPoints(t *testing.T) {
 	in := "xmpp, web,  metrics"
 	out := splitEntryPoints(in)
@@ -70,3 +105,148 @@ func TestCollectTCPRouterMeta_TLSLabel(t *testing.T) {
 		t.Fatalf("expected tls disabled for %q router", meta[1].RouterName)
 	}
 }
+
+func TestRouterTLS(t *testing.T) {
+	if got := routerTLS(map[string]string{}, "api"); got != nil {
+		t.Fatalf("expected no tls without labels, got %#v", got)
+	}
+	if got := routerTLS(map[string]string{"traefik.http.routers.api.tls": "false"}, "api"); got != nil {
+		t.Fatalf("expected no tls for tls=false, got %#v", got)
+	}
+
+	bare := routerTLS(map[string]string{"traefik.http.routers.api.tls": "true"}, "api")
+	if bare == nil || bare.Options != "" || bare.CertResolver != "" {
+		t.Fatalf("expected bare tls, got %#v", bare)
+	}
+	data, err := configio.MarshalYAML(types.HTTPRouter{Rule: "Host(`a`)", TLS: bare})
+	if err != nil {
+		t.Fatalf("marshal router: %v", err)
+	}
+	if !strings.Contains(string(data), "tls: {}") {
+		t.Fatalf("expected bare tls to marshal as tls: {}, got:\n%s", data)
+	}
+
+	withOptions := routerTLS(map[string]string{
+		"traefik.http.routers.api.tls.options":      "modern@file",
+		"traefik.http.routers.api.tls.certresolver": "le",
+	}, "api")
+	if withOptions == nil || withOptions.Options != "modern@file" || withOptions.CertResolver != "le" {
+		t.Fatalf("unexpected tls options: %#v", withOptions)
+	}
+}
+
+func TestCollectComposeServicePorts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "compose.yml")
+	content := `services:
+  api:
+    expose:
+      - "3000/tcp"
+    ports:
+      - "8080:9000"
+  web:
+    ports:
+      - "127.0.0.1:8081:8000"
+  worker:
+    ports:
+      - target: 7000
+        published: 17000
+  plain:
+    image: busybox
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+
+	ports, err := collectComposeServicePorts([]string{path}, nil)
+	if err != nil {
+		t.Fatalf("collect ports: %v", err)
+	}
+	if got := ports["api"]; got.Port != "3000" || got.Source != portSourceExpose {
+		t.Fatalf("unexpected api port: %+v", got)
+	}
+	if got := ports["web"]; got.Port != "8000" || got.Source != portSourcePorts {
+		t.Fatalf("unexpected web port: %+v", got)
+	}
+	if got := ports["worker"]; got.Port != "7000" || got.Source != portSourcePorts {
+		t.Fatalf("unexpected worker port: %+v", got)
+	}
+	if _, ok := ports["plain"]; ok {
+		t.Fatalf("expected no port for service without expose/ports, got %+v", ports["plain"])
+	}
+}
+
+func TestResolveHTTPPort(t *testing.T) {
+	composePorts := map[string]composePort{"api": {Port: "3000", Source: portSourceExpose}}
+
+	if port, source := resolveHTTPPort(map[string]string{
+		"traefik.http.services.api.loadbalancer.server.port": "9001",
+	}, "api", composePorts); port != "9001" || source != portSourceLabel {
+		t.Fatalf("expected label port, got %s (%s)", port, source)
+	}
+	if port, source := resolveHTTPPort(nil, "api", composePorts); port != "3000" || source != portSourceExpose {
+		t.Fatalf("expected compose port, got %s (%s)", port, source)
+	}
+	if port, source := resolveHTTPPort(nil, "web", composePorts); port != "80" || source != portSourceDefault {
+		t.Fatalf("expected default port, got %s (%s)", port, source)
+	}
+}
+
+func TestComposeParsing_AnchorsAndMergeKeys(t *testing.T) {
+	file := filepath.Join("testdata", "compose_anchors.yml")
+
+	services, err := collectTraefikEnabledServices([]string{file}, nil)
+	if err != nil {
+		t.Fatalf("collect services: %v", err)
+	}
+	if len(services) != 2 || services[0] != "api" || services[1] != "web" {
+		t.Fatalf("unexpected traefik services: %v", services)
+	}
+
+	ports, err := collectComposeServicePorts([]string{file}, nil)
+	if err != nil {
+		t.Fatalf("collect ports: %v", err)
+	}
+	for _, name := range []string{"api", "web", "worker"} {
+		if ports[name].Port != "8080" {
+			t.Fatalf("expected merged expose port for %s, got %#v", name, ports[name])
+		}
+	}
+
+	labels, err := composeServiceLabels([]string{file}, nil, "web")
+	if err != nil {
+		t.Fatalf("collect labels: %v", err)
+	}
+	if labels["traefik.enable"] != "true" || labels["traefik.http.routers.web.rule"] != "Host(`web.local`)" {
+		t.Fatalf("unexpected merged labels: %v", labels)
+	}
+}
+
+func TestComposeServiceLabels_InterpolatesEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "compose.yml")
+	envFile := filepath.Join(dir, "prod.env")
+	if err := os.WriteFile(file, []byte("services:\n  web:\n    labels:\n      traefik.enable: ${WEB_ROUTED:-false}\n      traefik.http.routers.web.rule: Host(`${DOMAIN}`)\n    ports:\n      - \"${WEB_PORT}:80\"\n"), 0o644); err != nil {
+		t.Fatalf("write compose: %v", err)
+	}
+	if err := os.WriteFile(envFile, []byte("WEB_ROUTED=true\nDOMAIN=web.example.com\nWEB_PORT=8080\n"), 0o644); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+
+	labels, err := composeServiceLabels([]string{file}, []string{envFile}, "web")
+	if err != nil {
+		t.Fatalf("collect labels: %v", err)
+	}
+	if labels["traefik.http.routers.web.rule"] != "Host(`web.example.com`)" {
+		t.Fatalf("expected interpolated rule, got %v", labels)
+	}
+	services, err := collectTraefikEnabledServices([]string{file}, []string{envFile})
+	if err != nil {
+		t.Fatalf("collect services: %v", err)
+	}
+	if len(services) != 1 || services[0] != "web" {
+		t.Fatalf("expected web to be enabled through the env file, got %v", services)
+	}
+	if services, err = collectTraefikEnabledServices([]string{file}, nil); err != nil || len(services) != 0 {
+		t.Fatalf("expected the default to disable web, got %v (%v)", services, err)
+	}
+}
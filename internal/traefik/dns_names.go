@@ -0,0 +1,53 @@
+package traefik
+
+import (
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+)
+
+const (
+	composeProjectLabel         = "com.docker.compose.project"
+	composeContainerNumberLabel = "com.docker.compose.container-number"
+)
+
+// ComposeDNSName returns the container name compose resolves on the project network,
+// e.g. shop-api-3, or an empty string when one of the labels is missing. serviceLabel
+// is the --service-label key naming the service; empty means the compose default.
+func ComposeDNSName(labels map[string]string, serviceLabel string) string {
+	if serviceLabel == "" {
+		serviceLabel = compose.DefaultServiceLabel
+	}
+	project := strings.TrimSpace(labels[composeProjectLabel])
+	service := strings.TrimSpace(labels[serviceLabel])
+	number := strings.TrimSpace(labels[composeContainerNumberLabel])
+	if project == "" || service == "" || number == "" {
+		return ""
+	}
+	return project + "-" + service + "-" + number
+}
+
+// ServerHost returns the host Traefik reaches a container at: its compose DNS name
+// with dnsNames, falling back to the short container ID.
+func ServerHost(id string, labels map[string]string, serviceLabel string, dnsNames bool) string {
+	if dnsNames {
+		if name := ComposeDNSName(labels, serviceLabel); name != "" {
+			return name
+		}
+	}
+	return configio.ShortID(id)
+}
+
+// WithDNSNames makes generated servers use compose DNS names instead of container IDs.
+func (g *Generator) WithDNSNames(enabled bool) *Generator {
+	g.dnsNames = enabled
+	return g
+}
+
+// UpdateServerHostsInConfig replaces the servers on the old hosts with servers on the
+// new ones in the dynamic config, like UpdateContainerIDsInConfig, and returns how many
+// servers were replaced. Hosts match whole, so api-1 never matches api-10.
+func UpdateServerHostsInConfig(path string, oldHosts []string, newHosts []string, weights []int) (int, error) {
+	return replaceServerHosts(path, oldHosts, newHosts, weights)
+}
@@ -0,0 +1,115 @@
+package traefik
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServerHost(t *testing.T) {
+	labels := map[string]string{
+		"com.docker.compose.project":          "shop",
+		"com.docker.compose.service":          "api",
+		"com.docker.compose.container-number": "3",
+	}
+	if got := ServerHost("abcdef1234567890", labels, "", true); got != "shop-api-3" {
+		t.Fatalf("expected DNS name, got %q", got)
+	}
+	if got := ServerHost("abcdef1234567890", labels, "", false); got != "abcdef123456" {
+		t.Fatalf("expected short ID without DNS names, got %q", got)
+	}
+	custom := map[string]string{
+		"com.docker.compose.project":          "shop",
+		"org.example.service":                 "web",
+		"com.docker.compose.container-number": "3",
+	}
+	if got := ServerHost("abcdef1234567890", custom, "org.example.service", true); got != "shop-web-3" {
+		t.Fatalf("expected DNS name from the custom service label, got %q", got)
+	}
+	delete(labels, "com.docker.compose.container-number")
+	if got := ServerHost("abcdef1234567890", labels, "", true); got != "abcdef123456" {
+		t.Fatalf("expected short ID fallback without container number, got %q", got)
+	}
+}
+
+type dnsDockerMock struct{}
+
+func (m *dnsDockerMock) Labels(_ context.Context, containerID string) (map[string]string, error) {
+	labels := map[string]string{
+		"com.docker.compose.project":                             "shop",
+		"com.docker.compose.service":                             "example",
+		"traefik.http.routers.example.rule":                      "Host(`example.com`)",
+		"traefik.http.services.example.loadbalancer.server.port": "9001",
+	}
+	if containerID == "abcdef1234567890" {
+		labels["com.docker.compose.container-number"] = "1"
+	}
+	return labels, nil
+}
+
+func TestGenerate_DNSNames(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(composePath, []byte("services:\n  example:\n    labels:\n      - traefik.enable=true\n"), 0o644); err != nil {
+		t.Fatalf("write compose: %v", err)
+	}
+	outputPath := filepath.Join(dir, "dynamic_conf.yml")
+
+	g := NewGenerator(&composeMock{}, &dnsDockerMock{}).WithDNSNames(true)
+	if err := g.Generate(context.Background(), []string{composePath}, nil, outputPath); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), "http://shop-example-1:9001") {
+		t.Fatalf("expected DNS name server:\n%s", data)
+	}
+	if !strings.Contains(string(data), "http://fedcba654321:9001") {
+		t.Fatalf("expected short ID fallback for container without number:\n%s", data)
+	}
+}
+
+func TestUpdateServerHostsInConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := `http:
+  services:
+    api:
+      loadBalancer:
+        servers:
+          - url: http://shop-api-1:80
+          - url: http://shop-api-10:80
+tcp:
+  services:
+    api-xmpp:
+      loadBalancer:
+        servers:
+          - address: shop-api-1:5222
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	replaced, err := UpdateServerHostsInConfig(path, []string{"shop-api-1"}, []string{"shop-api-3"}, nil)
+	if err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	if replaced != 2 {
+		t.Fatalf("expected 2 replacements, got %d", replaced)
+	}
+	cfg, err := readDynamicConfig(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	http := cfg.HTTP.Services["api"].LoadBalancer.Servers
+	if len(http) != 2 || http[0].URL != "http://shop-api-3:80" || http[1].URL != "http://shop-api-10:80" {
+		t.Fatalf("unexpected http servers: %#v", http)
+	}
+	tcp := cfg.TCP.Services["api-xmpp"].LoadBalancer.Servers
+	if len(tcp) != 1 || tcp[0].Address != "shop-api-3:5222" {
+		t.Fatalf("unexpected tcp servers: %#v", tcp)
+	}
+}
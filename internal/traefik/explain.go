@@ -0,0 +1,152 @@
+package traefik
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
+)
+
+// ExplainEntry describes how a single label feeds the generated config.
+// Target is empty when the label is ignored, and Note then says why.
+type ExplainEntry struct {
+	Label  string
+	Value  string
+	Target string
+	Note   string
+}
+
+// provenance records, while a service is built, which generated config field each
+// label feeds, so explain reports what the build actually did. A nil provenance records
+// nothing.
+type provenance struct {
+	labels  map[string]ExplainEntry
+	derived []ExplainEntry
+}
+
+func newProvenance() *provenance {
+	return &provenance{labels: map[string]ExplainEntry{}}
+}
+
+// set records that label, when present in labels, feeds target.
+func (p *provenance) set(labels map[string]string, label string, target string, note string) {
+	if p == nil {
+		return
+	}
+	if value, ok := labels[label]; ok {
+		p.labels[label] = ExplainEntry{Label: label, Value: value, Target: target, Note: note}
+	}
+}
+
+// note records that label, when present in labels, was read without feeding a field.
+func (p *provenance) note(labels map[string]string, label string, note string) {
+	p.set(labels, label, "", note)
+}
+
+// derive records a value that feeds target without coming from a label.
+func (p *provenance) derive(value string, target string, note string) {
+	if p == nil {
+		return
+	}
+	p.derived = append(p.derived, ExplainEntry{Value: value, Target: target, Note: note})
+}
+
+// Explain walks the labels of the first running container of service the same
+// way Generate does, without writing anything.
+func (g *Generator) Explain(ctx context.Context, composeFiles []string, envFiles []string, service string) ([]ExplainEntry, error) {
+	ids, err := g.compose.PsQuiet(ctx, composeFiles, envFiles, service)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("service %s has no running containers", service)
+	}
+	labels, err := g.docker.Labels(ctx, ids[0])
+	if err != nil {
+		return nil, err
+	}
+	enabledServices, err := collectTraefikEnabledServices(composeFiles, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	composePorts, err := collectComposeServicePorts(composeFiles, envFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := false
+	for _, name := range enabledServices {
+		if name == service {
+			enabled = true
+		}
+	}
+	skipReason := ""
+	if !enabled {
+		skipReason = "service is not traefik.enable=true in the compose files"
+	} else if proxyType := proxy.Resolve(labels, g.defaultProxy); proxyType != proxy.TypeTraefik {
+		skipReason = "service is routed by " + proxyType
+	}
+
+	if skipReason == "" && servesHTTP(labels) {
+		g.detectExposedPort(ctx, composePorts, labels, service, ids[0])
+	}
+	return g.explainLabels(labels, service, composePorts, skipReason), nil
+}
+
+// explainLabels builds service from labels the same way build does and reports the
+// provenance it recorded; labels the build did not read are listed as ignored.
+func (g *Generator) explainLabels(labels map[string]string, service string, composePorts map[string]composePort, skipReason string) []ExplainEntry {
+	prov := newProvenance()
+	prov.note(labels, "traefik.enable", "enables service discovery")
+	prov.note(labels, proxy.LabelType, "selects the proxy for this service")
+	if skipReason == "" {
+		quiet := *g
+		quiet.log = discardLogger()
+		quiet.addService(newDynamicConfig(), labels, service, []serverEndpoint{{host: service}}, composePorts, prov)
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		if strings.HasPrefix(key, "traefik.") || strings.HasPrefix(key, "com.ztd.") || key == LabelServerWeightAlias {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	entries := make([]ExplainEntry, 0, len(keys)+len(prov.derived))
+	for _, key := range keys {
+		entry, ok := prov.labels[key]
+		if !ok {
+			entry = ExplainEntry{Label: key, Value: labels[key], Note: skipReason}
+			if skipReason == "" {
+				entry.Note = ignoredLabelNote(key, service)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return append(entries, prov.derived...)
+}
+
+// ignoredLabelNote says why the build of service did not read key.
+func ignoredLabelNote(key string, service string) string {
+	switch {
+	case key == compose.LabelIgnore:
+		return "container is skipped by service discovery"
+	case strings.HasPrefix(key, "com.ztd."):
+		return "ztd metadata, not used in the Traefik config"
+	case strings.HasPrefix(key, "traefik.http.routers.") && !strings.HasPrefix(key, "traefik.http.routers."+service+"."):
+		return "only the router named after the compose service (" + service + ") is generated"
+	case strings.HasPrefix(key, "traefik.http.services.") && !strings.HasPrefix(key, "traefik.http.services."+service+"."):
+		return "only the service named after the compose service (" + service + ") is generated"
+	case strings.HasPrefix(key, "traefik.tcp.routers."):
+		return "tcp router needs both a rule and a service port label"
+	case strings.HasPrefix(key, "traefik.udp.routers."):
+		return "udp router needs a service port label"
+	case strings.HasPrefix(key, "traefik.tcp.services."), strings.HasPrefix(key, "traefik.udp.services."):
+		return "no router uses this service"
+	}
+	return "not supported by ztd"
+}
@@ -0,0 +1,89 @@
+package traefik
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestExplain_MapsLabelsToConfigFields(t *testing.T) {
+	t.Parallel()
+
+	gen := NewGenerator(&composeMock{}, &dockerMock{})
+	entries, err := gen.Explain(context.Background(), []string{filepath.Join("testdata", "compose.yml")}, nil, "example")
+	if err != nil {
+		t.Fatalf("explain failed: %v", err)
+	}
+
+	targets := map[string]string{}
+	for _, entry := range entries {
+		targets[entry.Label] = entry.Target
+	}
+	want := map[string]string{
+		"traefik.http.routers.example.rule":                               "http.routers.example.rule",
+		"traefik.http.services.example.loadbalancer.server.port":          "http.services.example.loadBalancer.servers[].url",
+		"traefik.http.services.example.loadbalancer.healthCheck.interval": "http.services.example.loadBalancer.healthCheck.interval",
+		"traefik.tcp.routers.example-xmpp.entrypoints":                    "tcp.routers.example-xmpp.entryPoints",
+		"traefik.tcp.services.example-xmpp.loadbalancer.server.port":      "tcp.services.example-xmpp.loadBalancer.servers[].address",
+	}
+	for label, target := range want {
+		if got := targets[label]; got != target {
+			t.Fatalf("label %s: expected target %q, got %q", label, target, got)
+		}
+	}
+	if _, ok := targets["com.docker.compose.service"]; ok {
+		t.Fatal("did not expect non-traefik labels in explain output")
+	}
+}
+
+func TestExplainLabels_IgnoredLabels(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{
+		"traefik.http.routers.other.rule":                               "Host(`other`)",
+		"traefik.http.services.api.loadbalancer.healthCheck.unknown":    "x",
+		"traefik.http.services.api.loadbalancer.passhostheader":         "false",
+		"traefik.tcp.routers.db.rule":                                   "HostSNI(`*`)",
+		"traefik.http.services.api.loadbalancer.healthCheck.headers.X1": "y",
+	}
+	entries := NewGenerator(nil, nil).explainLabels(labels, "api", nil, "")
+	for _, entry := range entries {
+		if entry.Label == "traefik.http.services.api.loadbalancer.healthCheck.headers.X1" {
+			if entry.Target != "http.services.api.loadBalancer.healthCheck.headers.X1" {
+				t.Fatalf("unexpected header target: %#v", entry)
+			}
+			continue
+		}
+		if entry.Label != "" && entry.Target != "" {
+			t.Fatalf("expected %s to be ignored, got %#v", entry.Label, entry)
+		}
+		if entry.Label != "" && entry.Note == "" {
+			t.Fatalf("expected a reason for ignored label %s", entry.Label)
+		}
+	}
+
+	sticky := NewGenerator(nil, nil).explainLabels(map[string]string{"traefik.http.services.api.loadbalancer.sticky.cookie.httpOnly": "true"}, "api", nil, "")
+	if len(sticky) != 2 || sticky[0].Target != "http.services.api.loadBalancer.sticky.cookie.httpOnly" {
+		t.Fatalf("expected sticky cookie label to be mapped, got %#v", sticky)
+	}
+
+	skipped := NewGenerator(nil, nil).explainLabels(map[string]string{"traefik.http.routers.api.rule": "Host(`a`)"}, "api", nil, "service is routed by nginx-proxy")
+	if len(skipped) != 1 || skipped[0].Target != "" || skipped[0].Note != "service is routed by nginx-proxy" {
+		t.Fatalf("expected skipped service labels to be ignored, got %#v", skipped)
+	}
+}
+
+func TestExplainLabels_FollowsTheBuild(t *testing.T) {
+	t.Parallel()
+
+	labels := map[string]string{
+		"traefik.http.routers.api.entrypoints":                 "websecure",
+		"traefik.http.services.api.loadbalancer.sticky.cookie": "false",
+		"traefik.tcp.routers.db.entrypoints":                   "pg",
+	}
+	for _, entry := range NewGenerator(nil, nil).explainLabels(labels, "api", nil, "") {
+		if entry.Label != "" && entry.Target != "" {
+			t.Fatalf("expected %s, which the build does not use, to be ignored, got %#v", entry.Label, entry)
+		}
+	}
+}
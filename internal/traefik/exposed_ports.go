@@ -0,0 +1,76 @@
+package traefik
+
+import (
+	"context"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const portSourceImage = "container exposed port"
+
+// DefaultBackendPort is the backend port used when neither a label, the compose file
+// nor the container's exposed ports name one.
+const DefaultBackendPort = "80"
+
+// exposedPortsReader is implemented by docker clients that can list the ports a
+// container exposes (image EXPOSE plus --expose), used when no port is configured.
+type exposedPortsReader interface {
+	ExposedPorts(ctx context.Context, containerID string) ([]string, error)
+}
+
+// detectExposedPort records the single TCP port exposed by containerID as the backend
+// port of service in composePorts, when neither the port label nor the compose file
+// sets one. With several exposed ports it warns and leaves the default of 80.
+func (g *Generator) detectExposedPort(ctx context.Context, composePorts map[string]composePort, labels map[string]string, service string, containerID string) {
+	if _, source := resolveHTTPPort(labels, service, composePorts); source != portSourceDefault {
+		return
+	}
+	if port := exposedPort(ctx, g.log, g.docker, service, containerID); port != "" {
+		composePorts[service] = composePort{Port: port, Source: portSourceImage}
+	}
+}
+
+// BackendPort returns the backend port of service for the proxies that do not read the
+// compose file: the traefik.http.services.<service>.loadbalancer.server.port label, else
+// the single TCP port containerID exposes, else DefaultBackendPort. The exposed ports are
+// only read when docker can list them.
+func BackendPort(ctx context.Context, log *logrus.Logger, docker any, labels map[string]string, service string, containerID string) string {
+	if port := strings.TrimSpace(labels["traefik.http.services."+service+".loadbalancer.server.port"]); port != "" {
+		return port
+	}
+	if port := exposedPort(ctx, log, docker, service, containerID); port != "" {
+		return port
+	}
+	return DefaultBackendPort
+}
+
+// exposedPort returns the single TCP port exposed by containerID, or an empty string
+// when docker cannot list exposed ports or the container exposes none or several; the
+// last case and read errors are logged as warnings.
+func exposedPort(ctx context.Context, log *logrus.Logger, docker any, service string, containerID string) string {
+	reader, ok := docker.(exposedPortsReader)
+	if !ok || containerID == "" {
+		return ""
+	}
+	ports, err := reader.ExposedPorts(ctx, containerID)
+	if err != nil {
+		log.WithError(err).Warnf("==> Cannot read the exposed ports of service '%s'; using port %s", service, DefaultBackendPort)
+		return ""
+	}
+	var tcp []string
+	for _, port := range ports {
+		if number, proto, _ := strings.Cut(port, "/"); proto == "" || proto == "tcp" {
+			tcp = append(tcp, number)
+		}
+	}
+	switch len(tcp) {
+	case 0:
+		return ""
+	case 1:
+		return tcp[0]
+	default:
+		log.Warnf("==> Service '%s' exposes several ports %v; using port %s, set traefik.http.services.%s.loadbalancer.server.port to choose one", service, tcp, DefaultBackendPort, service)
+		return ""
+	}
+}
@@ -0,0 +1,64 @@
+package traefik
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+type exposedPortsDockerMock struct {
+	ports []string
+}
+
+func (m *exposedPortsDockerMock) Labels(context.Context, string) (map[string]string, error) {
+	return map[string]string{
+		"com.docker.compose.service":        "example",
+		"traefik.http.routers.example.rule": "Host(`example.com`)",
+	}, nil
+}
+
+func (m *exposedPortsDockerMock) ExposedPorts(context.Context, string) ([]string, error) {
+	return m.ports, nil
+}
+
+func TestGenerate_BackendPortFromExposedPorts(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(composePath, []byte("services:\n  example:\n    labels:\n      - traefik.enable=true\n"), 0o644); err != nil {
+		t.Fatalf("write compose: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		ports    []string
+		wantURL  string
+		wantWarn bool
+	}{
+		{name: "single tcp port", ports: []string{"3000/tcp", "5353/udp"}, wantURL: "http://abcdef123456:3000"},
+		{name: "several ports", ports: []string{"3000/tcp", "9090/tcp"}, wantURL: "http://abcdef123456:80", wantWarn: true},
+		{name: "nothing exposed", wantURL: "http://abcdef123456:80"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log := logrus.New()
+			log.SetOutput(&logs)
+			g := NewGenerator(&composeMock{}, &exposedPortsDockerMock{ports: tt.ports}).WithLogger(log)
+			data, err := g.Build(context.Background(), []string{composePath}, nil, filepath.Join(dir, "dynamic_conf.yml"))
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+			if !strings.Contains(string(data), tt.wantURL) {
+				t.Fatalf("expected %s in config:\n%s", tt.wantURL, data)
+			}
+			if got := strings.Contains(logs.String(), "exposes several ports"); got != tt.wantWarn {
+				t.Fatalf("expected warning=%v, got logs %q", tt.wantWarn, logs.String())
+			}
+		})
+	}
+}
@@ -3,18 +3,29 @@ package traefik
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/sirupsen/logrus"
+
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/proxy"
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
 )
 
 type Generator struct {
-	compose compose.Adapter
-	docker  labelReader
+	compose      compose.Adapter
+	docker       labelReader
+	defaultProxy string
+	serviceLabel string
+	dnsNames     bool
+	dryRun       io.Writer
+	log          *logrus.Logger
 }
 
 type labelReader interface {
@@ -23,55 +34,152 @@ type labelReader interface {
 
 func NewGenerator(composeAdapter compose.Adapter, dockerClient labelReader) *Generator {
 	return &Generator{
-		compose: composeAdapter,
-		docker:  dockerClient,
+		compose:      composeAdapter,
+		docker:       dockerClient,
+		defaultProxy: proxy.TypeTraefik,
+		serviceLabel: compose.DefaultServiceLabel,
+		log:          discardLogger(),
+	}
+}
+
+func (g *Generator) WithLogger(log *logrus.Logger) *Generator {
+	if log != nil {
+		g.log = log
+	}
+	return g
+}
+
+// WithDefaultProxy sets the proxy type assumed for services without a com.ztd.proxy label.
+func (g *Generator) WithDefaultProxy(proxyType string) *Generator {
+	if strings.TrimSpace(proxyType) != "" {
+		g.defaultProxy = proxyType
+	}
+	return g
+}
+
+// WithServiceLabel sets the container label that names the compose service.
+func (g *Generator) WithServiceLabel(key string) *Generator {
+	if strings.TrimSpace(key) != "" {
+		g.serviceLabel = key
 	}
+	return g
+}
+
+// WithDryRun makes the generator print the config it would write to out instead of
+// writing it; nil writes as usual.
+func (g *Generator) WithDryRun(out io.Writer) *Generator {
+	g.dryRun = out
+	return g
 }
 
 func (g *Generator) Generate(ctx context.Context, composeFiles []string, envFiles []string, outputPath string) error {
-	enabledServices, err := collectTraefikEnabledServices(composeFiles)
+	return g.Regenerate(ctx, composeFiles, envFiles, outputPath, nil)
+}
+
+// Regenerate rebuilds the whole dynamic config like Generate, leaving out the
+// containers in exclude, e.g. the old containers of a rollout that are about to stop.
+func (g *Generator) Regenerate(ctx context.Context, composeFiles []string, envFiles []string, outputPath string, exclude []string) error {
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
+	data, err := g.buildMerged(ctx, composeFiles, envFiles, outputPath, exclude)
 	if err != nil {
 		return err
 	}
+	return g.write(outputPath, data)
+}
+
+// write stores data at outputPath or, in a dry run, prints it.
+func (g *Generator) write(outputPath string, data []byte) error {
+	if g.dryRun != nil {
+		g.log.Infof("==> [dry-run] Would write Traefik config to %s:", outputPath)
+		_, err := g.dryRun.Write(data)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return err
+	}
+	return configio.WriteAtomic(outputPath, data, 0o644)
+}
+
+// Build renders the dynamic config for the running containers, merged into the
+// config at outputPath, without writing it. Routers, services and middlewares of the
+// services in composeFiles are replaced; entries written for other projects or by hand
+// are kept.
+func (g *Generator) Build(ctx context.Context, composeFiles []string, envFiles []string, outputPath string) ([]byte, error) {
+	return g.buildMerged(ctx, composeFiles, envFiles, outputPath, nil)
+}
+
+func (g *Generator) buildMerged(ctx context.Context, composeFiles []string, envFiles []string, outputPath string, exclude []string) ([]byte, error) {
+	generated, err := g.build(ctx, composeFiles, envFiles, exclude)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := readDynamicConfig(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("read existing Traefik config %s: %w", outputPath, err)
+	}
+	cfg, err := mergeDynamicConfig(existing, generated, composeFiles, envFiles)
+	if err != nil {
+		return nil, err
+	}
+	return configio.MarshalYAML(cfg)
+}
+
+func (g *Generator) build(ctx context.Context, composeFiles []string, envFiles []string, exclude []string) (types.DynamicConfig, error) {
+	enableFlags, err := collectTraefikEnableFlags(composeFiles, envFiles)
+	if err != nil {
+		return types.DynamicConfig{}, err
+	}
+	var enabledServices []string
+	for name, enabled := range enableFlags {
+		if enabled {
+			enabledServices = append(enabledServices, name)
+		} else {
+			g.log.Infof("==> Service '%s' sets traefik.enable=false; leaving it out of the Traefik config", name)
+		}
+	}
+	sort.Strings(enabledServices)
 	if len(enabledServices) == 0 {
-		return fmt.Errorf("no services with label traefik.enable=true were found")
+		return types.DynamicConfig{}, fmt.Errorf("no services with label traefik.enable=true were found")
+	}
+	composePorts, err := collectComposeServicePorts(composeFiles, envFiles)
+	if err != nil {
+		return types.DynamicConfig{}, err
+	}
+	middlewares, err := collectComposeMiddlewares(composeFiles, envFiles)
+	if err != nil {
+		return types.DynamicConfig{}, err
 	}
 
-	serviceEndpoints := map[string][]string{}
+	serviceEndpoints := map[string][]serverEndpoint{}
 	for _, svc := range enabledServices {
 		ids, err := g.compose.PsQuiet(ctx, composeFiles, envFiles, svc)
 		if err != nil {
-			return err
+			return types.DynamicConfig{}, err
 		}
-		for _, id := range ids {
-			serviceEndpoints[svc] = append(serviceEndpoints[svc], shortID(id))
+		endpoints, err := g.serverEndpoints(ctx, withoutContainers(ids, exclude))
+		if err != nil {
+			return types.DynamicConfig{}, err
 		}
+		serviceEndpoints[svc] = append(serviceEndpoints[svc], endpoints...)
 	}
 
 	allContainerIDs, err := g.compose.PsQuiet(ctx, composeFiles, envFiles, "")
 	if err != nil {
-		return err
+		return types.DynamicConfig{}, err
 	}
+	allContainerIDs = withoutContainers(allContainerIDs, exclude)
 
-	cfg := types.DynamicConfig{
-		HTTP: &types.HTTPConfig{
-			Routers:  map[string]types.HTTPRouter{},
-			Services: map[string]types.HTTPService{},
-		},
-		TCP: &types.TCPConfig{
-			Routers:  map[string]types.TCPRouter{},
-			Services: map[string]types.TCPService{},
-		},
-	}
+	cfg := newDynamicConfig()
 	processedServices := map[string]struct{}{}
 
 	for _, id := range allContainerIDs {
 		labels, err := g.docker.Labels(ctx, id)
 		if err != nil {
-			return err
+			return types.DynamicConfig{}, err
 		}
 
-		serviceName := labels["com.docker.compose.service"]
+		serviceName := labels[g.serviceLabel]
 		if serviceName == "" {
 			continue
 		}
@@ -84,76 +192,203 @@ func (g *Generator) Generate(ctx context.Context, composeFiles []string, envFile
 			continue
 		}
 		processedServices[serviceName] = struct{}{}
-
-		routerRule := labels["traefik.http.routers."+serviceName+".rule"]
-		if routerRule != "" {
-			cfg.HTTP.Routers[serviceName] = types.HTTPRouter{
-				Rule:    routerRule,
-				Service: serviceName,
-			}
+		if proxy.Resolve(labels, g.defaultProxy) != proxy.TypeTraefik {
+			continue
 		}
 
-		httpPort := labels["traefik.http.services."+serviceName+".loadbalancer.server.port"]
-		if httpPort == "" {
-			httpPort = "80"
+		if servesHTTP(labels) {
+			g.detectExposedPort(ctx, composePorts, labels, serviceName, id)
 		}
+		g.addService(cfg, labels, serviceName, endpoints, composePorts, nil)
+	}
+
+	if countDynamicConfigEntries(cfg) == 0 {
+		return types.DynamicConfig{}, fmt.Errorf("generated Traefik configuration is empty")
+	}
+	if len(middlewares) > 0 {
+		cfg.HTTP.Middlewares = middlewares
+	}
+	return cfg, nil
+}
 
-		httpServers := make([]types.HTTPServer, 0, len(endpoints))
+func newDynamicConfig() types.DynamicConfig {
+	return types.DynamicConfig{
+		HTTP: &types.HTTPConfig{
+			Routers:  map[string]types.HTTPRouter{},
+			Services: map[string]types.HTTPService{},
+		},
+		TCP: &types.TCPConfig{
+			Routers:  map[string]types.TCPRouter{},
+			Services: map[string]types.TCPService{},
+		},
+		UDP: &types.UDPConfig{
+			Routers:  map[string]types.UDPRouter{},
+			Services: map[string]types.UDPService{},
+		},
+	}
+}
+
+// addService adds the HTTP, TCP and UDP routers and services of serviceName to cfg,
+// recording in prov which config field each label feeds.
+func (g *Generator) addService(cfg types.DynamicConfig, labels map[string]string, serviceName string, endpoints []serverEndpoint, composePorts map[string]composePort, prov *provenance) {
+	if servesHTTP(labels) {
+		prov.note(labels, LabelProtocol, "http router and service are generated")
+		g.addHTTPService(cfg.HTTP, labels, serviceName, endpoints, composePorts, prov)
+	} else {
+		prov.note(labels, LabelProtocol, "no http router or service is generated, only tcp/udp routers")
+	}
+
+	for _, tcp := range collectTCPRouterMeta(labels) {
+		cfg.TCP.Routers[tcp.RouterName] = newTCPRouter(tcp.Rule, tcp.RouterService, tcp.EntryPoints, tcp.TLSEnabled)
+
+		tcpServers := make([]types.TCPServer, 0, len(endpoints))
 		for _, endpoint := range endpoints {
-			httpServers = append(httpServers, types.HTTPServer{
-				URL: "http://" + endpoint + ":" + httpPort,
+			tcpServers = append(tcpServers, types.TCPServer{
+				Address: endpoint.host + ":" + tcp.BackendPort,
 			})
 		}
-
-		httpService := types.HTTPService{
-			LoadBalancer: &types.HTTPLoadBalancer{
-				Servers: httpServers,
+		cfg.TCP.Services[tcp.RouterService] = types.TCPService{
+			LoadBalancer: &types.TCPLoadBalancer{
+				Servers: tcpServers,
 			},
 		}
 
-		if hc := extractHealthCheck(labels, serviceName); hc != nil {
-			httpService.LoadBalancer.HealthCheck = hc
-		}
-		cfg.HTTP.Services[serviceName] = httpService
+		router := "traefik.tcp.routers." + tcp.RouterName + "."
+		prov.set(labels, router+"rule", "tcp.routers."+tcp.RouterName+".rule", "")
+		prov.set(labels, router+"service", "tcp.routers."+tcp.RouterName+".service", "")
+		prov.set(labels, router+"entrypoints", "tcp.routers."+tcp.RouterName+".entryPoints", "")
+		prov.set(labels, router+"tls", "tcp.routers."+tcp.RouterName+".tls", "")
+		prov.set(labels, "traefik.tcp.services."+tcp.RouterService+".loadbalancer.server.port", "tcp.services."+tcp.RouterService+".loadBalancer.servers[].address", "")
+	}
+	for _, udp := range collectUDPRouterMeta(labels) {
+		cfg.UDP.Routers[udp.RouterName] = newUDPRouter(udp.RouterService, udp.EntryPoints)
+		cfg.UDP.Services[udp.RouterService] = udpService(endpoints, udp.BackendPort)
 
-		for _, tcp := range collectTCPRouterMeta(labels) {
-			cfg.TCP.Routers[tcp.RouterName] = newTCPRouter(tcp.Rule, tcp.RouterService, tcp.EntryPoints, tcp.TLSEnabled)
+		router := "traefik.udp.routers." + udp.RouterName + "."
+		prov.set(labels, router+"service", "udp.routers."+udp.RouterName+".service", "")
+		prov.set(labels, router+"entrypoints", "udp.routers."+udp.RouterName+".entryPoints", "")
+		prov.set(labels, "traefik.udp.services."+udp.RouterService+".loadbalancer.server.port", "udp.services."+udp.RouterService+".loadBalancer.servers[].address", "")
+	}
+}
 
-			tcpServers := make([]types.TCPServer, 0, len(endpoints))
-			for _, endpoint := range endpoints {
-				tcpServers = append(tcpServers, types.TCPServer{
-					Address: endpoint + ":" + tcp.BackendPort,
-				})
-			}
-			cfg.TCP.Services[tcp.RouterService] = types.TCPService{
-				LoadBalancer: &types.TCPLoadBalancer{
-					Servers: tcpServers,
-				},
-			}
+// addHTTPService adds the HTTP router, when a rule label is set, and the load balanced
+// service of serviceName to cfg.
+func (g *Generator) addHTTPService(cfg *types.HTTPConfig, labels map[string]string, serviceName string, endpoints []serverEndpoint, composePorts map[string]composePort, prov *provenance) {
+	routerLabel := "traefik.http.routers." + serviceName + "."
+	routerRule := labels[routerLabel+"rule"]
+	if routerRule != "" {
+		cfg.Routers[serviceName] = types.HTTPRouter{
+			EntryPoints: splitEntryPoints(labels[routerLabel+"entrypoints"]),
+			Rule:        routerRule,
+			Service:     serviceName,
+			Middlewares: routerMiddlewares(labels, serviceName),
+			TLS:         routerTLS(labels, serviceName),
+		}
+
+		router := "http.routers." + serviceName + "."
+		prov.set(labels, routerLabel+"rule", router+"rule", "")
+		prov.set(labels, routerLabel+"entrypoints", router+"entryPoints", "")
+		prov.set(labels, routerLabel+"middlewares", router+"middlewares", "")
+		if parseTLSLabel(labels[routerLabel+"tls"]) {
+			prov.set(labels, routerLabel+"tls", router+"tls", "")
+		} else {
+			prov.note(labels, routerLabel+"tls", "tls is not true")
 		}
+		prov.set(labels, routerLabel+"tls.options", router+"tls.options", "")
+		prov.set(labels, routerLabel+"tls.certresolver", router+"tls.certResolver", "")
+	}
+
+	httpPort, portSource := resolveHTTPPort(labels, serviceName, composePorts)
+	g.log.Infof("==> Service '%s' backend port %s (source: %s)", serviceName, httpPort, portSource)
+
+	serviceLabel := "traefik.http.services." + serviceName + ".loadbalancer."
+	servers := "http.services." + serviceName + ".loadBalancer.servers[]."
+	if portSource == portSourceLabel {
+		prov.set(labels, serviceLabel+"server.port", servers+"url", "backend port")
+	} else {
+		prov.derive(httpPort, servers+"url", "backend port from "+portSource)
+	}
+	prov.set(labels, serviceLabel+"server.scheme", servers+"url", "backend scheme")
+	prov.set(labels, LabelServerWeight, servers+"weight", "")
+	if _, ok := labels[LabelServerWeight]; ok {
+		prov.note(labels, LabelServerWeightAlias, LabelServerWeight+" is set and wins")
+	} else {
+		prov.set(labels, LabelServerWeightAlias, servers+"weight", "")
 	}
 
-	if len(cfg.HTTP.Routers) == 0 && len(cfg.HTTP.Services) == 0 && len(cfg.TCP.Routers) == 0 && len(cfg.TCP.Services) == 0 {
-		return fmt.Errorf("generated Traefik configuration is empty")
+	scheme := ServerScheme(labels, serviceName)
+	httpServers := make([]types.HTTPServer, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		httpServers = append(httpServers, types.HTTPServer{
+			URL:    serverURL(scheme, endpoint.host, httpPort),
+			Weight: endpoint.weight,
+		})
 	}
-	if len(cfg.HTTP.Routers) == 0 && len(cfg.HTTP.Services) == 0 {
-		cfg.HTTP = nil
+
+	httpService := types.HTTPService{
+		LoadBalancer: &types.HTTPLoadBalancer{
+			Servers: httpServers,
+		},
 	}
-	if len(cfg.TCP.Routers) == 0 && len(cfg.TCP.Services) == 0 {
-		cfg.TCP = nil
+
+	if hc := healthCheck(labels, serviceName, httpPort, prov); hc != nil {
+		httpService.LoadBalancer.HealthCheck = hc
 	}
+	httpService.LoadBalancer.Sticky = extractSticky(labels, serviceName, prov)
+	cfg.Services[serviceName] = httpService
+}
 
-	data, err := configio.MarshalYAML(cfg)
-	if err != nil {
-		return err
+func resolveHTTPPort(labels map[string]string, serviceName string, composePorts map[string]composePort) (string, string) {
+	if port := strings.TrimSpace(labels["traefik.http.services."+serviceName+".loadbalancer.server.port"]); port != "" {
+		return port, portSourceLabel
 	}
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
-		return err
+	if port, ok := composePorts[serviceName]; ok {
+		return port.Port, port.Source
 	}
-	return configio.WriteAtomic(outputPath, data, 0o644)
+	return DefaultBackendPort, portSourceDefault
+}
+
+// ServerScheme returns the backend scheme from the
+// traefik.http.services.<service>.loadbalancer.server.scheme label, http by default.
+func ServerScheme(labels map[string]string, serviceName string) string {
+	if scheme := strings.TrimSpace(labels["traefik.http.services."+serviceName+".loadbalancer.server.scheme"]); scheme != "" {
+		return strings.ToLower(scheme)
+	}
+	return "http"
 }
 
-func extractHealthCheck(labels map[string]string, serviceName string) *types.HealthChecks {
+func serverURL(scheme string, host string, port string) string {
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + host + ":" + port
+}
+
+func discardLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+// LabelHealthCheckPath is shorthand for a Traefik healthCheck on the service: the path
+// plus defaults for interval, timeout, scheme and the backend port.
+const LabelHealthCheckPath = "com.ztd.healthcheck.path"
+
+const (
+	defaultHealthCheckInterval = "10s"
+	defaultHealthCheckTimeout  = "3s"
+)
+
+// healthCheckFields are the healthCheck label fields HealthCheck reads.
+var healthCheckFields = []string{"path", "interval", "timeout", "scheme", "mode", "hostname", "port", "followRedirects", "method", "status"}
+
+// HealthCheck reads the healthCheck labels of serviceName, nil when none are set. With
+// LabelHealthCheckPath set, unset fields are derived from it and port; explicit labels win.
+func HealthCheck(labels map[string]string, serviceName string, port string) *types.HealthChecks {
+	return healthCheck(labels, serviceName, port, nil)
+}
+
+func healthCheck(labels map[string]string, serviceName string, port string, prov *provenance) *types.HealthChecks {
 	prefix := "traefik.http.services." + serviceName + ".loadbalancer.healthCheck."
 	hc := &types.HealthChecks{
 		Path:            labels[prefix+"path"],
@@ -167,11 +402,20 @@ func extractHealthCheck(labels map[string]string, serviceName string) *types.Hea
 		Method:          labels[prefix+"method"],
 		Status:          labels[prefix+"status"],
 	}
+	if path := strings.TrimSpace(labels[LabelHealthCheckPath]); path != "" {
+		fillDefault(&hc.Path, path)
+		fillDefault(&hc.Interval, defaultHealthCheckInterval)
+		fillDefault(&hc.Timeout, defaultHealthCheckTimeout)
+		fillDefault(&hc.Scheme, ServerScheme(labels, serviceName))
+		fillDefault(&hc.Port, port)
+	}
 
+	target := "http.services." + serviceName + ".loadBalancer.healthCheck"
 	headers := map[string]string{}
 	for k, v := range labels {
 		if strings.HasPrefix(k, prefix+"headers.") {
 			headers[strings.TrimPrefix(k, prefix+"headers.")] = v
+			prov.set(labels, k, target+".headers."+strings.TrimPrefix(k, prefix+"headers."), "")
 		}
 	}
 	if len(headers) > 0 {
@@ -182,14 +426,94 @@ func extractHealthCheck(labels map[string]string, serviceName string) *types.Hea
 		hc.Hostname == "" && hc.Port == "" && hc.FollowRedirects == "" && hc.Method == "" && hc.Status == "" && len(hc.Headers) == 0 {
 		return nil
 	}
+	for _, field := range healthCheckFields {
+		prov.set(labels, prefix+field, target+"."+field, "")
+	}
+	prov.set(labels, LabelHealthCheckPath, target, "path with interval "+defaultHealthCheckInterval+", timeout "+defaultHealthCheckTimeout+" and the backend scheme and port, unless set by healthCheck labels")
 	return hc
 }
 
+func fillDefault(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// extractSticky reads the traefik.http.services.<service>.loadbalancer.sticky.cookie
+// labels. Label keys are matched case-insensitively, as Traefik does.
+func extractSticky(labels map[string]string, serviceName string, prov *provenance) *types.Sticky {
+	prefix := strings.ToLower("traefik.http.services." + serviceName + ".loadbalancer.sticky.cookie")
+	target := "http.services." + serviceName + ".loadBalancer.sticky.cookie"
+	var cookie *types.StickyCookie
+	read := map[string]string{}
+	for label, value := range labels {
+		key := strings.ToLower(label)
+		if key != prefix && !strings.HasPrefix(key, prefix+".") {
+			continue
+		}
+		if cookie == nil {
+			cookie = &types.StickyCookie{}
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimPrefix(key, prefix) {
+		case "":
+			if enabled, err := strconv.ParseBool(value); err == nil && !enabled {
+				return nil
+			}
+			read[label] = target
+		case ".name":
+			cookie.Name = value
+			read[label] = target + ".name"
+		case ".secure":
+			cookie.Secure, _ = strconv.ParseBool(value)
+			read[label] = target + ".secure"
+		case ".httponly":
+			cookie.HTTPOnly, _ = strconv.ParseBool(value)
+			read[label] = target + ".httpOnly"
+		case ".samesite":
+			cookie.SameSite = value
+			read[label] = target + ".sameSite"
+		case ".maxage":
+			cookie.MaxAge, _ = strconv.Atoi(value)
+			read[label] = target + ".maxAge"
+		}
+	}
+	if cookie == nil {
+		return nil
+	}
+	for label, field := range read {
+		prov.set(labels, label, field, "")
+	}
+	return &types.Sticky{Cookie: cookie}
+}
+
 func pruneEmptyDynamicConfigSections(cfg *types.DynamicConfig) {
-	if cfg.HTTP != nil && len(cfg.HTTP.Routers) == 0 && len(cfg.HTTP.Services) == 0 {
+	if cfg.HTTP != nil && len(cfg.HTTP.Routers) == 0 && len(cfg.HTTP.Services) == 0 && len(cfg.HTTP.Middlewares) == 0 && len(cfg.HTTP.Extra) == 0 {
 		cfg.HTTP = nil
 	}
-	if cfg.TCP != nil && len(cfg.TCP.Routers) == 0 && len(cfg.TCP.Services) == 0 {
+	if cfg.TCP != nil && len(cfg.TCP.Routers) == 0 && len(cfg.TCP.Services) == 0 && len(cfg.TCP.Extra) == 0 {
 		cfg.TCP = nil
 	}
+	if cfg.UDP != nil && len(cfg.UDP.Routers) == 0 && len(cfg.UDP.Services) == 0 && len(cfg.UDP.Extra) == 0 {
+		cfg.UDP = nil
+	}
+}
+
+// withoutContainers drops the IDs in exclude from ids, comparing short IDs so full and
+// abbreviated IDs match.
+func withoutContainers(ids []string, exclude []string) []string {
+	if len(exclude) == 0 {
+		return ids
+	}
+	skip := make(map[string]struct{}, len(exclude))
+	for _, id := range exclude {
+		skip[configio.ShortID(id)] = struct{}{}
+	}
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := skip[configio.ShortID(id)]; !ok {
+			kept = append(kept, id)
+		}
+	}
+	return kept
 }
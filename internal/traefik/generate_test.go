@@ -1,14 +1,19 @@
 package traefik
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
 )
 
 type composeMock struct{}
@@ -16,6 +21,9 @@ type composeMock struct{}
 func (m *composeMock) Up(context.Context, []string, []string, string, bool, bool) error { return nil }
 func (m *composeMock) Scale(context.Context, []string, []string, string, int) error     { return nil }
 func (m *composeMock) LogsFollowTail(context.Context, []string, string, int) error      { return nil }
+func (m *composeMock) ServiceImage(context.Context, []string, []string, string) (string, error) {
+	return "", nil
+}
 
 func (m *composeMock) PsQuiet(_ context.Context, _ []string, _ []string, service string) ([]string, error) {
 	switch service {
@@ -36,6 +44,8 @@ func (m *dockerMock) Labels(_ context.Context, containerID string) (map[string]s
 	}
 	if containerID == "abcdef1234567890" {
 		base["traefik.http.routers.example.rule"] = "Host(`example.com`) && PathPrefix(`/`)"
+		base["traefik.http.routers.example.entrypoints"] = "web, websecure"
+		base["traefik.http.routers.example.tls.certresolver"] = "letsencrypt"
 		base["traefik.http.services.example.loadbalancer.server.port"] = "9001"
 		base["traefik.http.services.example.loadbalancer.healthCheck.path"] = "/health"
 		base["traefik.http.services.example.loadbalancer.healthCheck.interval"] = "10s"
@@ -122,6 +132,37 @@ func TestGenerate_OmitsEmptyTCPSection(t *testing.T) {
 	}
 }
 
+func TestGenerate_DryRunPrintsInsteadOfWriting(t *testing.T) {
+	t.Parallel()
+
+	composePath := filepath.Join("testdata", "compose.yml")
+	outputPath := filepath.Join(t.TempDir(), "traefik", "dynamic_conf.yml")
+
+	var out bytes.Buffer
+	gen := NewGenerator(&composeMock{}, &dockerMock{}).WithDryRun(&out)
+	if err := gen.Generate(context.Background(), []string{composePath}, nil, outputPath); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no config file in a dry run, stat err = %v", err)
+	}
+	wantRaw, err := os.ReadFile(filepath.Join("testdata", "dynamic_conf.golden.yml"))
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	gotCanonical, err := canonicalYAML(out.Bytes())
+	if err != nil {
+		t.Fatalf("canonicalize printed yaml: %v", err)
+	}
+	wantCanonical, err := canonicalYAML(wantRaw)
+	if err != nil {
+		t.Fatalf("canonicalize golden yaml: %v", err)
+	}
+	if gotCanonical != wantCanonical {
+		t.Fatalf("printed yaml differs from golden\nwant=%s\ngot=%s", wantCanonical, gotCanonical)
+	}
+}
+
 func canonicalYAML(data []byte) (string, error) {
 	var v any
 	if err := configio.UnmarshalYAML(data, &v); err != nil {
@@ -133,3 +174,747 @@ func canonicalYAML(data []byte) (string, error) {
 	}
 	return string(b), nil
 }
+
+func TestRegenerate_LeavesOutExcludedContainers(t *testing.T) {
+	t.Parallel()
+
+	composePath := filepath.Join("testdata", "compose.yml")
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+
+	gen := NewGenerator(&composeMock{}, &dockerNoTCPMock{})
+	if err := gen.Regenerate(context.Background(), []string{composePath}, nil, outputPath, []string{"fedcba654321"}); err != nil {
+		t.Fatalf("regenerate failed: %v", err)
+	}
+
+	gotRaw, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read generated file: %v", err)
+	}
+	content := string(gotRaw)
+	if !strings.Contains(content, "abcdef123456") || strings.Contains(content, "fedcba654321") {
+		t.Fatalf("expected only the kept container as server, got:\n%s", content)
+	}
+}
+
+type dockerProxyLabelMock struct {
+	proxyType string
+}
+
+func (m *dockerProxyLabelMock) Labels(_ context.Context, _ string) (map[string]string, error) {
+	labels := map[string]string{
+		"com.docker.compose.service":                             "example",
+		"traefik.http.routers.example.rule":                      "Host(`example.com`)",
+		"traefik.http.services.example.loadbalancer.server.port": "9001",
+	}
+	if m.proxyType != "" {
+		labels["com.ztd.proxy"] = m.proxyType
+	}
+	return labels, nil
+}
+
+func TestGenerate_SkipsServicesRoutedByOtherProxy(t *testing.T) {
+	t.Parallel()
+
+	composePath := filepath.Join("testdata", "compose.yml")
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+
+	gen := NewGenerator(&composeMock{}, &dockerProxyLabelMock{proxyType: "nginx-proxy"})
+	if err := gen.Generate(context.Background(), []string{composePath}, nil, outputPath); err == nil {
+		t.Fatal("expected empty configuration error when the only service uses nginx-proxy")
+	}
+
+	gen = NewGenerator(&composeMock{}, &dockerProxyLabelMock{proxyType: "traefik"}).WithDefaultProxy("nginx-proxy")
+	if err := gen.Generate(context.Background(), []string{composePath}, nil, outputPath); err != nil {
+		t.Fatalf("expected traefik label to override default proxy, got: %v", err)
+	}
+}
+
+func TestRefreshService_UpdatesRulePreservingServers(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "compose.yml")
+	if err := os.WriteFile(composePath, []byte(`services:
+  example:
+    labels:
+      - "traefik.enable=true"
+      - "traefik.http.routers.example.rule=Host(`+"`new.example.com`"+`)"
+`), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	outputPath := filepath.Join(dir, "dynamic_conf.yml")
+	if err := os.WriteFile(outputPath, []byte(`http:
+  routers:
+    example:
+      rule: Host(`+"`old.example.com`"+`)
+      service: example-blue
+  services:
+    example-blue:
+      loadBalancer:
+        servers:
+          - url: http://aaaaaaaaaaaa:9001
+`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	gen := NewGenerator(&composeMock{}, &dockerMock{})
+	if err := gen.RefreshService(context.Background(), []string{composePath}, nil, outputPath, "example"); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+
+	cfg, err := readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	router := cfg.HTTP.Routers["example"]
+	if router.Rule != "Host(`new.example.com`)" || router.Service != "example-blue" {
+		t.Fatalf("unexpected router after refresh: %+v", router)
+	}
+	if _, ok := cfg.HTTP.Services["example"]; ok {
+		t.Fatal("expected no plain service to be created while router targets blue")
+	}
+	servers := cfg.HTTP.Services["example-blue"].LoadBalancer.Servers
+	if len(servers) != 1 || servers[0].URL != "http://aaaaaaaaaaaa:9001" {
+		t.Fatalf("expected existing servers to be preserved, got %+v", servers)
+	}
+}
+
+type dockerMiddlewareMock struct{}
+
+func (m *dockerMiddlewareMock) Labels(_ context.Context, _ string) (map[string]string, error) {
+	return map[string]string{
+		"com.docker.compose.service":                             "example",
+		"traefik.http.routers.example.rule":                      "Host(`example.com`)",
+		"traefik.http.routers.example.middlewares":               "api-chain, secure-headers",
+		"traefik.http.services.example.loadbalancer.server.port": "9001",
+	}, nil
+}
+
+func TestGenerate_EmitsComposeMiddlewares(t *testing.T) {
+	t.Parallel()
+
+	composePath := filepath.Join("testdata", "compose_middlewares.yml")
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+
+	gen := NewGenerator(&composeMock{}, &dockerMiddlewareMock{})
+	if err := gen.Generate(context.Background(), []string{composePath}, nil, outputPath); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	cfg, err := readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read generated config: %v", err)
+	}
+	router := cfg.HTTP.Routers["example"]
+	if len(router.Middlewares) != 2 || router.Middlewares[0] != "api-chain" || router.Middlewares[1] != "secure-headers" {
+		t.Fatalf("unexpected router middlewares: %#v", router.Middlewares)
+	}
+	if _, ok := cfg.HTTP.Middlewares["secure-headers"]["headers"]; !ok {
+		t.Fatalf("expected secure-headers middleware definition, got %#v", cfg.HTTP.Middlewares)
+	}
+	if _, ok := cfg.HTTP.Middlewares["api-chain"]["chain"]; !ok {
+		t.Fatalf("expected api-chain middleware definition, got %#v", cfg.HTTP.Middlewares)
+	}
+}
+
+func TestRefreshService_RemovesDisabledService(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "compose.yml")
+	overridePath := filepath.Join(dir, "compose.override.yml")
+	if err := os.WriteFile(basePath, []byte("services:\n  example:\n    labels:\n      - \"traefik.enable=true\"\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	if err := os.WriteFile(overridePath, []byte("services:\n  example:\n    labels:\n      traefik.enable: \"false\"\n"), 0o644); err != nil {
+		t.Fatalf("write override file: %v", err)
+	}
+	outputPath := filepath.Join(dir, "dynamic_conf.yml")
+	if err := os.WriteFile(outputPath, []byte(`http:
+  routers:
+    example:
+      rule: Host(`+"`example.com`"+`)
+      service: example
+    other:
+      rule: Host(`+"`other.example.com`"+`)
+      service: other
+  services:
+    example:
+      loadBalancer:
+        servers:
+          - url: http://aaaaaaaaaaaa:9001
+    other:
+      loadBalancer:
+        servers:
+          - url: http://bbbbbbbbbbbb:9001
+tcp:
+  routers:
+    example-xmpp:
+      rule: HostSNI(`+"`*`"+`)
+      service: example-xmpp
+  services:
+    example-xmpp:
+      loadBalancer:
+        servers:
+          - address: aaaaaaaaaaaa:5222
+`), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	gen := NewGenerator(&composeMock{}, &dockerMock{})
+	if err := gen.RefreshService(context.Background(), []string{basePath, overridePath}, nil, outputPath, "example"); err != nil {
+		t.Fatalf("refresh failed: %v", err)
+	}
+
+	cfg, err := readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if _, ok := cfg.HTTP.Routers["example"]; ok {
+		t.Fatalf("expected disabled router to be removed, got %#v", cfg.HTTP.Routers)
+	}
+	if _, ok := cfg.HTTP.Services["example"]; ok {
+		t.Fatalf("expected disabled service to be removed, got %#v", cfg.HTTP.Services)
+	}
+	if _, ok := cfg.HTTP.Routers["other"]; !ok {
+		t.Fatal("expected unrelated router to be kept")
+	}
+	if cfg.TCP != nil {
+		t.Fatalf("expected tcp section to be pruned, got %#v", cfg.TCP)
+	}
+}
+
+type customServiceLabelMock struct{}
+
+func (m *customServiceLabelMock) Labels(ctx context.Context, containerID string) (map[string]string, error) {
+	labels, err := (&dockerNoTCPMock{}).Labels(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	labels["org.example.service"] = labels["com.docker.compose.service"]
+	delete(labels, "com.docker.compose.service")
+	return labels, nil
+}
+
+func TestGenerate_CustomServiceLabel(t *testing.T) {
+	t.Parallel()
+
+	composePath := filepath.Join("testdata", "compose.yml")
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+
+	gen := NewGenerator(&composeMock{}, &customServiceLabelMock{})
+	if err := gen.Generate(context.Background(), []string{composePath}, nil, outputPath); err == nil {
+		t.Fatal("expected no routes without the default service label")
+	}
+
+	gen = NewGenerator(&composeMock{}, &customServiceLabelMock{}).WithServiceLabel("org.example.service")
+	if err := gen.Generate(context.Background(), []string{composePath}, nil, outputPath); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	gotRaw, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read generated file: %v", err)
+	}
+	if !strings.Contains(string(gotRaw), "example.com") {
+		t.Fatalf("expected router for service from custom label, got:\n%s", gotRaw)
+	}
+}
+
+type dockerHTTPSMock struct{}
+
+func (m *dockerHTTPSMock) Labels(_ context.Context, _ string) (map[string]string, error) {
+	return map[string]string{
+		"com.docker.compose.service":                               "example",
+		"traefik.http.routers.example.rule":                        "Host(`example.com`)",
+		"traefik.http.services.example.loadbalancer.server.port":   "8443",
+		"traefik.http.services.example.loadbalancer.server.scheme": "HTTPS",
+	}, nil
+}
+
+func TestGenerate_ServerScheme(t *testing.T) {
+	t.Parallel()
+
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	gen := NewGenerator(&composeMock{}, &dockerHTTPSMock{})
+	if err := gen.Generate(context.Background(), []string{filepath.Join("testdata", "compose.yml")}, nil, outputPath); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	cfg, err := readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	servers := cfg.HTTP.Services["example"].LoadBalancer.Servers
+	if len(servers) != 2 || servers[0].URL != "https://abcdef123456:8443" || servers[1].URL != "https://fedcba654321:8443" {
+		t.Fatalf("expected https server urls, got %#v", servers)
+	}
+
+	if _, err := UpdateContainerIDsInConfig(outputPath, []string{"abcdef1234567890"}, []string{"0123456789abcdef"}, nil); err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	cfg, err = readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if got := cfg.HTTP.Services["example"].LoadBalancer.Servers[0].URL; got != "https://0123456789ab:8443" {
+		t.Fatalf("expected scheme to survive the container swap, got %q", got)
+	}
+}
+
+type dockerStickyMock struct{}
+
+func (m *dockerStickyMock) Labels(_ context.Context, _ string) (map[string]string, error) {
+	return map[string]string{
+		"com.docker.compose.service":                                        "example",
+		"traefik.http.routers.example.rule":                                 "Host(`example.com`)",
+		"traefik.http.services.example.loadbalancer.sticky.cookie.name":     "ztd_session",
+		"traefik.http.services.example.loadbalancer.sticky.cookie.secure":   "true",
+		"traefik.http.services.example.loadbalancer.sticky.cookie.httpOnly": "true",
+	}, nil
+}
+
+func TestGenerate_StickyCookie(t *testing.T) {
+	t.Parallel()
+
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	gen := NewGenerator(&composeMock{}, &dockerStickyMock{})
+	if err := gen.Generate(context.Background(), []string{filepath.Join("testdata", "compose.yml")}, nil, outputPath); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	want := types.StickyCookie{Name: "ztd_session", Secure: true, HTTPOnly: true}
+
+	if _, err := UpdateContainerIDsInConfig(outputPath, []string{"abcdef1234567890"}, []string{"0123456789abcdef"}, nil); err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	cfg, err := readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	lb := cfg.HTTP.Services["example"].LoadBalancer
+	if lb.Sticky == nil || lb.Sticky.Cookie == nil || !reflect.DeepEqual(*lb.Sticky.Cookie, want) {
+		t.Fatalf("expected sticky cookie to survive the container swap, got %#v", lb.Sticky)
+	}
+	if lb.Servers[0].URL != "http://0123456789ab:80" {
+		t.Fatalf("expected swapped server, got %q", lb.Servers[0].URL)
+	}
+}
+
+func TestExtractSticky(t *testing.T) {
+	prefix := "traefik.http.services.api.loadbalancer.sticky.cookie"
+	if got := extractSticky(map[string]string{}, "api", nil); got != nil {
+		t.Fatalf("expected no sticky block without labels, got %#v", got)
+	}
+	got := extractSticky(map[string]string{prefix: "true"}, "api", nil)
+	if got == nil || got.Cookie == nil || !reflect.DeepEqual(*got.Cookie, types.StickyCookie{}) {
+		t.Fatalf("expected default sticky cookie, got %#v", got)
+	}
+	if got := extractSticky(map[string]string{prefix: "false", prefix + ".name": "sid"}, "api", nil); got != nil {
+		t.Fatalf("expected sticky=false to disable the cookie, got %#v", got)
+	}
+	data, err := yaml.Marshal(types.HTTPLoadBalancer{Servers: []types.HTTPServer{{URL: "http://a:80"}}})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(data), "sticky") {
+		t.Fatalf("expected sticky to be omitted, got %s", data)
+	}
+}
+
+func TestRemoveService_KeepsOtherServices(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "compose.yml")
+	if err := os.WriteFile(composePath, []byte("services:\n  api:\n    labels:\n      traefik.enable: \"true\"\n      traefik.tcp.routers.api-grpc.rule: HostSNI(`*`)\n      traefik.tcp.services.api-grpc.loadbalancer.server.port: \"9000\"\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	path := filepath.Join(dir, "dynamic_conf.yml")
+	content := "http:\n  routers:\n    api:\n      service: api\n    api-canary:\n      service: api\n    web:\n      service: web\n" +
+		"  services:\n    api-blue:\n      loadBalancer:\n        servers:\n          - url: http://aaaaaaaaaaaa:80\n    web:\n      loadBalancer:\n        servers:\n          - url: http://bbbbbbbbbbbb:80\n" +
+		"tcp:\n  routers:\n    api-grpc:\n      service: api-grpc\n  services:\n    api-grpc:\n      loadBalancer:\n        servers:\n          - address: aaaaaaaaaaaa:9000\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	removed, err := RemoveService(path, []string{composePath}, nil, "api", nil)
+	if err != nil {
+		t.Fatalf("remove service: %v", err)
+	}
+	if !removed {
+		t.Fatal("expected entries to be removed")
+	}
+	cfg, err := readDynamicConfig(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if len(cfg.HTTP.Routers) != 1 || cfg.HTTP.Routers["web"].Service != "web" || len(cfg.HTTP.Services) != 1 {
+		t.Fatalf("expected only web to remain, got %#v", cfg.HTTP)
+	}
+	if cfg.TCP != nil {
+		t.Fatalf("expected tcp entries of api to be removed, got %#v", cfg.TCP)
+	}
+
+	removed, err = RemoveService(path, []string{composePath}, nil, "api", nil)
+	if err != nil || removed {
+		t.Fatalf("expected second removal to be a no-op, got removed=%v err=%v", removed, err)
+	}
+}
+
+// servicesComposeMock reports the containers of each service from a fixed map; the
+// empty service name lists the "" entry or, without one, those of api, web and worker.
+type servicesComposeMock struct {
+	composeMock
+	containers map[string][]string
+}
+
+func (m *servicesComposeMock) PsQuiet(_ context.Context, _ []string, _ []string, service string) ([]string, error) {
+	if ids, ok := m.containers[service]; service != "" || ok {
+		return ids, nil
+	}
+	var all []string
+	for _, name := range []string{"api", "web", "worker"} {
+		all = append(all, m.containers[name]...)
+	}
+	return all, nil
+}
+
+type containerLabelsMock map[string]map[string]string
+
+func (m containerLabelsMock) Labels(_ context.Context, containerID string) (map[string]string, error) {
+	return m[containerID], nil
+}
+
+func TestBuild_LabelParsing(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		compose    string
+		containers map[string][]string
+		labels     containerLabelsMock
+		want       types.DynamicConfig
+		wantErr    string
+	}{
+		{
+			name: "service without traefik.enable is left out",
+			compose: `services:
+  api:
+    labels: ["traefik.enable=true"]
+  worker:
+    image: busybox
+`,
+			containers: map[string][]string{"api": {"aaaaaaaaaaaa1111"}, "worker": {"bbbbbbbbbbbb2222"}},
+			labels: containerLabelsMock{
+				"aaaaaaaaaaaa1111": {"com.docker.compose.service": "api", "traefik.http.routers.api.rule": "Host(`api.example.com`)"},
+				"bbbbbbbbbbbb2222": {"com.docker.compose.service": "worker", "traefik.http.routers.worker.rule": "Host(`worker.example.com`)"},
+			},
+			want: types.DynamicConfig{HTTP: &types.HTTPConfig{
+				Routers: map[string]types.HTTPRouter{"api": {Rule: "Host(`api.example.com`)", Service: "api"}},
+				Services: map[string]types.HTTPService{"api": {LoadBalancer: &types.HTTPLoadBalancer{
+					Servers: []types.HTTPServer{{URL: "http://aaaaaaaaaaaa:80"}},
+				}}},
+			}},
+		},
+		{
+			name: "traefik.enable=false is left out",
+			compose: `services:
+  api:
+    labels:
+      traefik.enable: "false"
+`,
+			containers: map[string][]string{"api": {"aaaaaaaaaaaa1111"}},
+			labels: containerLabelsMock{
+				"aaaaaaaaaaaa1111": {"com.docker.compose.service": "api", "traefik.http.routers.api.rule": "Host(`api.example.com`)"},
+			},
+			wantErr: "no services with label traefik.enable=true",
+		},
+		{
+			name: "rule without port defaults to 80 on every container",
+			compose: `services:
+  web:
+    labels: ["traefik.enable=true"]
+`,
+			containers: map[string][]string{"web": {"aaaaaaaaaaaa1111", "bbbbbbbbbbbb2222", "cccccccccccc3333"}},
+			labels: containerLabelsMock{
+				"aaaaaaaaaaaa1111": {"com.docker.compose.service": "web", "traefik.http.routers.web.rule": "Host(`example.com`)", "traefik.http.routers.web.entrypoints": "websecure"},
+				"bbbbbbbbbbbb2222": {"com.docker.compose.service": "web"},
+				"cccccccccccc3333": {"com.docker.compose.service": "web"},
+			},
+			want: types.DynamicConfig{HTTP: &types.HTTPConfig{
+				Routers: map[string]types.HTTPRouter{"web": {EntryPoints: []string{"websecure"}, Rule: "Host(`example.com`)", Service: "web"}},
+				Services: map[string]types.HTTPService{"web": {LoadBalancer: &types.HTTPLoadBalancer{
+					Servers: []types.HTTPServer{{URL: "http://aaaaaaaaaaaa:80"}, {URL: "http://bbbbbbbbbbbb:80"}, {URL: "http://cccccccccccc:80"}},
+				}}},
+			}},
+		},
+		{
+			name: "service without rule gets a service but no router",
+			compose: `services:
+  web:
+    labels: ["traefik.enable=true"]
+`,
+			containers: map[string][]string{"web": {"aaaaaaaaaaaa1111"}},
+			labels: containerLabelsMock{
+				"aaaaaaaaaaaa1111": {"com.docker.compose.service": "web", "traefik.http.services.web.loadbalancer.server.port": "8080"},
+			},
+			want: types.DynamicConfig{HTTP: &types.HTTPConfig{
+				Routers: map[string]types.HTTPRouter{},
+				Services: map[string]types.HTTPService{"web": {LoadBalancer: &types.HTTPLoadBalancer{
+					Servers: []types.HTTPServer{{URL: "http://aaaaaaaaaaaa:8080"}},
+				}}},
+			}},
+		},
+		{
+			name: "enabled service without running containers",
+			compose: `services:
+  web:
+    labels: ["traefik.enable=true"]
+`,
+			containers: map[string][]string{},
+			labels:     containerLabelsMock{},
+			wantErr:    "generated Traefik configuration is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			composePath := filepath.Join(t.TempDir(), "compose.yml")
+			if err := os.WriteFile(composePath, []byte(tt.compose), 0o644); err != nil {
+				t.Fatalf("write compose file: %v", err)
+			}
+			gen := NewGenerator(&servicesComposeMock{containers: tt.containers}, tt.labels)
+			got, err := gen.build(context.Background(), []string{composePath}, nil, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("build() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("build() error = %v", err)
+			}
+			pruneEmptyDynamicConfigSections(&got)
+			pruneEmptyDynamicConfigSections(&tt.want)
+			if !reflect.DeepEqual(got, tt.want) {
+				gotYAML, _ := configio.MarshalYAML(got)
+				wantYAML, _ := configio.MarshalYAML(tt.want)
+				t.Fatalf("build() mismatch\nwant=%s\ngot=%s", wantYAML, gotYAML)
+			}
+		})
+	}
+}
+
+func TestExtractHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	prefix := "traefik.http.services.web.loadbalancer.healthCheck."
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   *types.HealthChecks
+	}{
+		{
+			name:   "no health check labels",
+			labels: map[string]string{"traefik.http.services.web.loadbalancer.server.port": "8080"},
+		},
+		{
+			name:   "other service labels are ignored",
+			labels: map[string]string{"traefik.http.services.api.loadbalancer.healthCheck.path": "/health"},
+		},
+		{
+			name: "all fields",
+			labels: map[string]string{
+				prefix + "path":            "/health",
+				prefix + "interval":        "10s",
+				prefix + "timeout":         "2s",
+				prefix + "scheme":          "https",
+				prefix + "mode":            "http",
+				prefix + "hostname":        "example.com",
+				prefix + "port":            "8081",
+				prefix + "followRedirects": "false",
+				prefix + "method":          "HEAD",
+				prefix + "status":          "204",
+			},
+			want: &types.HealthChecks{
+				Path: "/health", Interval: "10s", Timeout: "2s", Scheme: "https", Mode: "http",
+				Hostname: "example.com", Port: "8081", FollowRedirects: "false", Method: "HEAD", Status: "204",
+			},
+		},
+		{
+			name:   "derived from the shorthand label",
+			labels: map[string]string{LabelHealthCheckPath: "/healthz"},
+			want:   &types.HealthChecks{Path: "/healthz", Interval: "10s", Timeout: "3s", Scheme: "http", Port: "8080"},
+		},
+		{
+			name: "explicit labels override derived fields",
+			labels: map[string]string{
+				LabelHealthCheckPath: "/healthz",
+				prefix + "interval":  "30s",
+				prefix + "path":      "/ready",
+				"traefik.http.services.web.loadbalancer.server.scheme": "https",
+			},
+			want: &types.HealthChecks{Path: "/ready", Interval: "30s", Timeout: "3s", Scheme: "https", Port: "8080"},
+		},
+		{
+			name: "headers only",
+			labels: map[string]string{
+				prefix + "headers.X-Probe":           "ztd",
+				prefix + "headers.X-Forwarded-Proto": "https",
+			},
+			want: &types.HealthChecks{Headers: map[string]string{"X-Probe": "ztd", "X-Forwarded-Proto": "https"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := HealthCheck(tt.labels, "web", "8080"); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("HealthCheck() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate_ServerWeights(t *testing.T) {
+	t.Parallel()
+
+	composePath := filepath.Join(t.TempDir(), "compose.yml")
+	if err := os.WriteFile(composePath, []byte("services:\n  web:\n    labels: [\"traefik.enable=true\"]\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	labels := containerLabelsMock{
+		"aaaaaaaaaaaa1111": {"com.docker.compose.service": "web", "traefik.http.routers.web.rule": "Host(`example.com`)", LabelServerWeight: "3"},
+		"bbbbbbbbbbbb2222": {"com.docker.compose.service": "web"},
+		"cccccccccccc3333": {"com.docker.compose.service": "web", LabelServerWeight: "2"},
+	}
+	gen := NewGenerator(&servicesComposeMock{containers: map[string][]string{"web": {"aaaaaaaaaaaa1111", "bbbbbbbbbbbb2222", "cccccccccccc3333"}}}, labels)
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	if err := gen.Generate(context.Background(), []string{composePath}, nil, outputPath); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if n := strings.Count(string(data), "weight:"); n != 2 {
+		t.Fatalf("expected weight only on labelled servers, got:\n%s", data)
+	}
+	cfg, err := readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	want := []types.HTTPServer{
+		{URL: "http://aaaaaaaaaaaa:80", Weight: 3},
+		{URL: "http://bbbbbbbbbbbb:80"},
+		{URL: "http://cccccccccccc:80", Weight: 2},
+	}
+	if got := cfg.HTTP.Services["web"].LoadBalancer.Servers; !reflect.DeepEqual(got, want) {
+		t.Fatalf("servers = %#v, want %#v", got, want)
+	}
+
+	labels["bbbbbbbbbbbb2222"][LabelServerWeight] = "heavy"
+	if err := gen.Generate(context.Background(), []string{composePath}, nil, outputPath); err == nil {
+		t.Fatal("expected error for an invalid weight label")
+	}
+}
+
+func TestServerWeight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "1", want: 1},
+		{value: " 5 ", want: 5},
+		{value: "0", wantErr: true},
+		{value: "-2", wantErr: true},
+		{value: "1.5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ServerWeight(map[string]string{LabelServerWeight: tt.value})
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ServerWeight(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Fatalf("ServerWeight(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+
+	if got, err := ServerWeight(map[string]string{LabelServerWeightAlias: "4"}); err != nil || got != 4 {
+		t.Fatalf("expected the ztd.weight alias to be read, got %d %v", got, err)
+	}
+	if got, err := ServerWeight(map[string]string{LabelServerWeight: "2", LabelServerWeightAlias: "4"}); err != nil || got != 2 {
+		t.Fatalf("expected com.ztd.weight to win over the alias, got %d %v", got, err)
+	}
+}
+
+func TestGenerate_UDPRoutersAndProtocolLabel(t *testing.T) {
+	t.Parallel()
+
+	composePath := filepath.Join(t.TempDir(), "compose.yml")
+	if err := os.WriteFile(composePath, []byte("services:\n  game:\n    labels: [\"traefik.enable=true\"]\n  db:\n    labels: [\"traefik.enable=true\"]\n"), 0o644); err != nil {
+		t.Fatalf("write compose file: %v", err)
+	}
+	labels := containerLabelsMock{
+		"aaaaaaaaaaaa1111": {
+			"com.docker.compose.service":                         "game",
+			LabelProtocol:                                        "udp",
+			"traefik.udp.routers.game.entrypoints":               "game-udp",
+			"traefik.udp.services.game.loadbalancer.server.port": "27015",
+		},
+		"bbbbbbbbbbbb2222": {
+			"com.docker.compose.service":                       "db",
+			LabelProtocol:                                      "tcp",
+			"traefik.tcp.routers.db.rule":                      "HostSNI(`*`)",
+			"traefik.tcp.routers.db.entrypoints":               "postgres",
+			"traefik.tcp.services.db.loadbalancer.server.port": "5432",
+		},
+	}
+	gen := NewGenerator(&servicesComposeMock{containers: map[string][]string{
+		"":     {"aaaaaaaaaaaa1111", "bbbbbbbbbbbb2222"},
+		"game": {"aaaaaaaaaaaa1111"},
+		"db":   {"bbbbbbbbbbbb2222"},
+	}}, labels)
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	if err := gen.Generate(context.Background(), []string{composePath}, nil, outputPath); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	cfg, err := readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if cfg.HTTP != nil {
+		t.Fatalf("expected no http section for tcp/udp services, got %#v", cfg.HTTP)
+	}
+	if cfg.UDP == nil {
+		t.Fatal("expected a udp section")
+	}
+	wantRouter := types.UDPRouter{EntryPoints: []string{"game-udp"}, Service: "game"}
+	if got := cfg.UDP.Routers["game"]; !reflect.DeepEqual(got, wantRouter) {
+		t.Fatalf("udp router = %#v, want %#v", got, wantRouter)
+	}
+	wantServers := []types.UDPServer{{Address: "aaaaaaaaaaaa:27015"}}
+	if got := cfg.UDP.Services["game"].LoadBalancer.Servers; !reflect.DeepEqual(got, wantServers) {
+		t.Fatalf("udp servers = %#v, want %#v", got, wantServers)
+	}
+	if got := cfg.TCP.Routers["db"].Rule; got != "HostSNI(`*`)" {
+		t.Fatalf("unexpected tcp rule %q", got)
+	}
+
+	replaced, err := UpdateContainerIDsInConfig(outputPath, []string{"aaaaaaaaaaaa1111"}, []string{"dddddddddddd4444"}, nil)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if replaced != 1 {
+		t.Fatalf("expected the udp server to be swapped, replaced %d", replaced)
+	}
+	cfg, err = readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if got := cfg.UDP.Services["game"].LoadBalancer.Servers; !reflect.DeepEqual(got, []types.UDPServer{{Address: "dddddddddddd:27015"}}) {
+		t.Fatalf("udp servers after swap = %#v", got)
+	}
+}
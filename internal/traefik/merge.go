@@ -0,0 +1,58 @@
+package traefik
+
+import (
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
+)
+
+// mergeDynamicConfig replaces the entries existing holds for the services declared in
+// composeFiles, including blue-green and canary variants, with generated. Entries of
+// services the compose files do not declare are left as they are.
+func mergeDynamicConfig(existing types.DynamicConfig, generated types.DynamicConfig, composeFiles []string, envFiles []string) (types.DynamicConfig, error) {
+	enableFlags, err := collectTraefikEnableFlags(composeFiles, envFiles)
+	if err != nil {
+		return types.DynamicConfig{}, err
+	}
+	ensureHTTPConfig(&existing)
+	ensureTCPConfig(&existing)
+	ensureUDPConfig(&existing)
+	for service := range enableFlags {
+		labels, err := composeServiceLabels(composeFiles, envFiles, service)
+		if err != nil {
+			return types.DynamicConfig{}, err
+		}
+		removeServiceEntries(&existing, service, labels)
+	}
+
+	if generated.HTTP != nil {
+		for name, router := range generated.HTTP.Routers {
+			existing.HTTP.Routers[name] = router
+		}
+		for name, service := range generated.HTTP.Services {
+			existing.HTTP.Services[name] = service
+		}
+		if len(generated.HTTP.Middlewares) > 0 && existing.HTTP.Middlewares == nil {
+			existing.HTTP.Middlewares = map[string]types.HTTPMiddleware{}
+		}
+		for name, middleware := range generated.HTTP.Middlewares {
+			existing.HTTP.Middlewares[name] = middleware
+		}
+	}
+	if generated.TCP != nil {
+		for name, router := range generated.TCP.Routers {
+			existing.TCP.Routers[name] = router
+		}
+		for name, service := range generated.TCP.Services {
+			existing.TCP.Services[name] = service
+		}
+	}
+	if generated.UDP != nil {
+		for name, router := range generated.UDP.Routers {
+			existing.UDP.Routers[name] = router
+		}
+		for name, service := range generated.UDP.Services {
+			existing.UDP.Services[name] = service
+		}
+	}
+	pruneEmptyDynamicConfigSections(&existing)
+	return existing, nil
+}
@@ -0,0 +1,154 @@
+package traefik
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestGenerate_MergesIntoExistingConfig(t *testing.T) {
+	t.Parallel()
+
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	existing := `http:
+  routers:
+    other:
+      rule: Host(` + "`other.com`" + `)
+      service: other
+    example:
+      rule: Host(` + "`stale.com`" + `)
+      service: example-blue
+    example-qa-host:
+      rule: Host(` + "`qa.example.com`" + `)
+      service: example-green
+  services:
+    other:
+      loadBalancer:
+        servers:
+          - url: http://other:80
+    example-blue:
+      loadBalancer:
+        servers:
+          - url: http://old:9001
+  middlewares:
+    other-auth:
+      basicAuth:
+        users: [admin]
+`
+	if err := os.WriteFile(outputPath, []byte(existing), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	gen := NewGenerator(&composeMock{}, &dockerNoTCPMock{})
+	if err := gen.Generate(context.Background(), []string{filepath.Join("testdata", "compose.yml")}, nil, outputPath); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	cfg, err := readDynamicConfig(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if router, ok := cfg.HTTP.Routers["other"]; !ok || router.Service != "other" {
+		t.Fatalf("expected router of another project to be kept, got %#v", cfg.HTTP.Routers)
+	}
+	if _, ok := cfg.HTTP.Services["other"]; !ok {
+		t.Fatalf("expected service of another project to be kept, got %#v", cfg.HTTP.Services)
+	}
+	if _, ok := cfg.HTTP.Middlewares["other-auth"]; !ok {
+		t.Fatalf("expected middleware of another project to be kept, got %#v", cfg.HTTP.Middlewares)
+	}
+	if router := cfg.HTTP.Routers["example"]; router.Rule != "Host(`example.com`) && PathPrefix(`/`)" || router.Service != "example" {
+		t.Fatalf("expected router of managed service to be regenerated, got %#v", router)
+	}
+	if _, ok := cfg.HTTP.Routers["example-qa-host"]; ok {
+		t.Fatal("expected stale blue-green router of managed service to be removed")
+	}
+	if _, ok := cfg.HTTP.Services["example-blue"]; ok {
+		t.Fatal("expected stale blue-green service of managed service to be removed")
+	}
+	if servers := cfg.HTTP.Services["example"].LoadBalancer.Servers; len(servers) != 2 {
+		t.Fatalf("expected regenerated servers, got %#v", servers)
+	}
+}
+
+func TestGenerate_MergeKeepsUnmodelledKeys(t *testing.T) {
+	t.Parallel()
+
+	outputPath := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	existing := `tls:
+  certificates:
+    - certFile: /certs/site.crt
+      keyFile: /certs/site.key
+  options:
+    modern:
+      minVersion: VersionTLS13
+http:
+  serversTransports:
+    insecure:
+      insecureSkipVerify: true
+  routers:
+    other:
+      rule: Host(` + "`other.com`" + `)
+      ruleSyntax: v2
+      service: other
+  services:
+    other:
+      loadBalancer:
+        passHostHeader: false
+        serversTransport: insecure
+        servers:
+          - url: https://other:443
+tcp:
+  middlewares:
+    allow-lan:
+      ipAllowList:
+        sourceRange: [10.0.0.0/8]
+`
+	if err := os.WriteFile(outputPath, []byte(existing), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	gen := NewGenerator(&composeMock{}, &dockerNoTCPMock{})
+	if err := gen.Generate(context.Background(), []string{filepath.Join("testdata", "compose.yml")}, nil, outputPath); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	var before, after map[string]any
+	if err := yaml.Unmarshal([]byte(existing), &before); err != nil {
+		t.Fatalf("parse existing config: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &after); err != nil {
+		t.Fatalf("parse merged config: %v", err)
+	}
+	for _, path := range [][]string{
+		{"tls"},
+		{"http", "serversTransports"},
+		{"http", "routers", "other"},
+		{"http", "services", "other"},
+		{"tcp", "middlewares"},
+	} {
+		if want, got := lookupKey(before, path), lookupKey(after, path); want == nil || !reflect.DeepEqual(want, got) {
+			t.Fatalf("expected %v to survive the merge unchanged:\n%s", path, data)
+		}
+	}
+}
+
+func lookupKey(doc map[string]any, path []string) any {
+	var cur any = doc
+	for _, key := range path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[key]
+	}
+	return cur
+}
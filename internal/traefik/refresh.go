@@ -0,0 +1,259 @@
+package traefik
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/state"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
+)
+
+// RefreshService recomputes router and service definitions for a single service from
+// the running container labels (overlaid with compose file labels) and updates the
+// Traefik config in place. Existing servers and router targets are preserved, so no
+// containers need to be scaled or recreated.
+func (g *Generator) RefreshService(ctx context.Context, composeFiles []string, envFiles []string, outputPath string, service string) error {
+	ids, err := g.compose.PsQuiet(ctx, composeFiles, envFiles, service)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("service %s has no running containers", service)
+	}
+
+	labels, err := g.docker.Labels(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	endpoints, err := g.serverEndpoints(ctx, ids)
+	if err != nil {
+		return err
+	}
+	composeLabels, err := composeServiceLabels(composeFiles, envFiles, service)
+	if err != nil {
+		return err
+	}
+	merged := make(map[string]string, len(labels)+len(composeLabels))
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range composeLabels {
+		merged[k] = v
+	}
+	enableFlags, err := collectTraefikEnableFlags(composeFiles, envFiles)
+	if err != nil {
+		return err
+	}
+	if enabled, set := enableFlags[service]; set && !enabled {
+		return g.removeService(outputPath, service, merged)
+	}
+
+	composePorts, err := collectComposeServicePorts(composeFiles, envFiles)
+	if err != nil {
+		return err
+	}
+
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
+	cfg, err := readDynamicConfig(outputPath)
+	if err != nil {
+		return err
+	}
+	ensureHTTPConfig(&cfg)
+	ensureTCPConfig(&cfg)
+	ensureUDPConfig(&cfg)
+
+	if rule := merged["traefik.http.routers."+service+".rule"]; rule != "" {
+		router := cfg.HTTP.Routers[service]
+		if router.Service == "" {
+			router.Service = service
+		}
+		router.Rule = rule
+		router.EntryPoints = splitEntryPoints(merged["traefik.http.routers."+service+".entrypoints"])
+		router.Middlewares = routerMiddlewares(merged, service)
+		router.TLS = routerTLS(merged, service)
+		cfg.HTTP.Routers[service] = router
+	}
+
+	middlewares, err := collectComposeMiddlewares(composeFiles, envFiles)
+	if err != nil {
+		return err
+	}
+	for name, mw := range middlewares {
+		if cfg.HTTP.Middlewares == nil {
+			cfg.HTTP.Middlewares = map[string]types.HTTPMiddleware{}
+		}
+		cfg.HTTP.Middlewares[name] = mw
+	}
+
+	if servesHTTP(merged) {
+		g.detectExposedPort(ctx, composePorts, merged, service, ids[0])
+	}
+	port, portSource := resolveHTTPPort(merged, service, composePorts)
+	hc := HealthCheck(merged, service, port)
+	existing, exists := cfg.HTTP.Services[service]
+	router, routed := cfg.HTTP.Routers[service]
+	switch {
+	case !servesHTTP(merged):
+		// Routed only through its TCP/UDP routers.
+	case exists && existing.LoadBalancer != nil:
+		existing.LoadBalancer.HealthCheck = hc
+		existing.LoadBalancer.Sticky = extractSticky(merged, service, nil)
+		cfg.HTTP.Services[service] = existing
+	case !exists && (!routed || router.Service == service):
+		g.log.Infof("==> Service '%s' backend port %s (source: %s)", service, port, portSource)
+		servers := make([]types.HTTPServer, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			servers = append(servers, types.HTTPServer{URL: serverURL(ServerScheme(merged, service), endpoint.host, port), Weight: endpoint.weight})
+		}
+		cfg.HTTP.Services[service] = types.HTTPService{
+			LoadBalancer: &types.HTTPLoadBalancer{
+				Servers:     servers,
+				Sticky:      extractSticky(merged, service, nil),
+				HealthCheck: hc,
+			},
+		}
+	}
+
+	for _, tcp := range collectTCPRouterMeta(merged) {
+		routerService := tcp.RouterService
+		if existing, ok := cfg.TCP.Routers[tcp.RouterName]; ok && existing.Service != "" {
+			routerService = existing.Service
+		}
+		cfg.TCP.Routers[tcp.RouterName] = newTCPRouter(tcp.Rule, routerService, tcp.EntryPoints, tcp.TLSEnabled)
+
+		if _, ok := cfg.TCP.Services[routerService]; ok {
+			continue
+		}
+		servers := make([]types.TCPServer, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			servers = append(servers, types.TCPServer{Address: endpoint.host + ":" + tcp.BackendPort})
+		}
+		cfg.TCP.Services[routerService] = types.TCPService{LoadBalancer: &types.TCPLoadBalancer{Servers: servers}}
+	}
+	for _, udp := range collectUDPRouterMeta(merged) {
+		routerService := udp.RouterService
+		if existing, ok := cfg.UDP.Routers[udp.RouterName]; ok && existing.Service != "" {
+			routerService = existing.Service
+		}
+		cfg.UDP.Routers[udp.RouterName] = newUDPRouter(routerService, udp.EntryPoints)
+		if _, ok := cfg.UDP.Services[routerService]; !ok {
+			cfg.UDP.Services[routerService] = udpService(endpoints, udp.BackendPort)
+		}
+	}
+
+	pruneEmptyDynamicConfigSections(&cfg)
+
+	data, err := configio.MarshalYAML(cfg)
+	if err != nil {
+		return err
+	}
+	return g.write(outputPath, data)
+}
+
+// removeService drops every router and service ztd may have written for service,
+// including blue-green and canary variants, once it sets traefik.enable=false.
+func (g *Generator) removeService(outputPath string, service string, labels map[string]string) error {
+	if g.dryRun != nil {
+		g.log.Infof("==> [dry-run] Service '%s' sets traefik.enable=false; would remove its routers and services from %s", service, outputPath)
+		return nil
+	}
+	g.log.Infof("==> Service '%s' sets traefik.enable=false; removing its routers and services from the Traefik config", service)
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
+	_, err := removeServiceFromConfig(outputPath, service, labels)
+	return err
+}
+
+// RemoveService drops every router and service ztd may have written for service from
+// the dynamic config at path, leaving other services intact. TCP and UDP routers are taken from
+// the container labels overlaid with the compose file labels. It reports whether
+// anything was removed; the file is not written otherwise.
+func RemoveService(path string, composeFiles []string, envFiles []string, service string, labels map[string]string) (bool, error) {
+	composeLabels, err := composeServiceLabels(composeFiles, envFiles, service)
+	if err != nil {
+		return false, err
+	}
+	merged := make(map[string]string, len(labels)+len(composeLabels))
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range composeLabels {
+		merged[k] = v
+	}
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
+	return removeServiceFromConfig(path, service, merged)
+}
+
+func removeServiceFromConfig(path string, service string, labels map[string]string) (bool, error) {
+	cfg, err := readDynamicConfig(path)
+	if err != nil {
+		return false, err
+	}
+	before := countDynamicConfigEntries(cfg)
+	removeServiceEntries(&cfg, service, labels)
+	if countDynamicConfigEntries(cfg) == before {
+		return false, nil
+	}
+
+	pruneEmptyDynamicConfigSections(&cfg)
+	data, err := configio.MarshalYAML(cfg)
+	if err != nil {
+		return false, err
+	}
+	return true, configio.WriteAtomic(path, data, 0o644)
+}
+
+func countDynamicConfigEntries(cfg types.DynamicConfig) int {
+	n := 0
+	if cfg.HTTP != nil {
+		n += len(cfg.HTTP.Routers) + len(cfg.HTTP.Services)
+	}
+	if cfg.TCP != nil {
+		n += len(cfg.TCP.Routers) + len(cfg.TCP.Services)
+	}
+	if cfg.UDP != nil {
+		n += len(cfg.UDP.Routers) + len(cfg.UDP.Services)
+	}
+	return n
+}
+
+// removeServiceEntries deletes the routers and services ztd writes for service, with
+// the TCP and UDP routers taken from labels.
+func removeServiceEntries(cfg *types.DynamicConfig, service string, labels map[string]string) {
+	ensureHTTPConfig(cfg)
+	ensureTCPConfig(cfg)
+	ensureUDPConfig(cfg)
+
+	for _, name := range []string{service, CanaryRouterName(service), qaRouterName(service, "host"), qaRouterName(service, "headers"), qaRouterName(service, "cookies"), qaRouterName(service, "ip")} {
+		delete(cfg.HTTP.Routers, name)
+	}
+	for _, name := range serviceVariants(service) {
+		delete(cfg.HTTP.Services, name)
+	}
+	for _, tcp := range collectTCPRouterMeta(labels) {
+		delete(cfg.TCP.Routers, tcp.RouterName)
+		delete(cfg.TCP.Routers, tcpQARouterName(service, tcp.RouterName, "host"))
+		delete(cfg.TCP.Routers, tcpQARouterName(service, tcp.RouterName, "ip"))
+		delete(cfg.TCP.Services, tcp.RouterService)
+		for _, name := range serviceVariants(tcp.BackendBaseName) {
+			delete(cfg.TCP.Services, name)
+		}
+	}
+	for _, udp := range collectUDPRouterMeta(labels) {
+		delete(cfg.UDP.Routers, udp.RouterName)
+		delete(cfg.UDP.Services, udp.RouterService)
+	}
+}
+
+func serviceVariants(name string) []string {
+	return []string{
+		name,
+		serviceColorName(name, state.ColorBlue),
+		serviceColorName(name, state.ColorGreen),
+		canaryServiceName(name, "old"),
+		canaryServiceName(name, "new"),
+	}
+}
@@ -0,0 +1,94 @@
+package traefik
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
+)
+
+// ReferencedHosts returns every server host in the dynamic config at path, from both
+// HTTP server URLs and TCP server addresses. A missing file has no hosts.
+func ReferencedHosts(path string) (map[string]bool, error) {
+	configio.Mu.Lock()
+	cfg, err := readDynamicConfig(path)
+	configio.Mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return referencedHosts(cfg), nil
+}
+
+func referencedHosts(cfg types.DynamicConfig) map[string]bool {
+	hosts := map[string]bool{}
+	if cfg.HTTP != nil {
+		for _, svc := range cfg.HTTP.Services {
+			if svc.LoadBalancer == nil {
+				continue
+			}
+			for _, server := range svc.LoadBalancer.Servers {
+				if u, err := url.Parse(server.URL); err == nil && u.Hostname() != "" {
+					hosts[u.Hostname()] = true
+				}
+			}
+		}
+	}
+	if cfg.TCP != nil {
+		for _, svc := range cfg.TCP.Services {
+			if svc.LoadBalancer == nil {
+				continue
+			}
+			for _, server := range svc.LoadBalancer.Servers {
+				if host := addressHost(server.Address); host != "" {
+					hosts[host] = true
+				}
+			}
+		}
+	}
+	if cfg.UDP != nil {
+		for _, svc := range cfg.UDP.Services {
+			if svc.LoadBalancer == nil {
+				continue
+			}
+			for _, server := range svc.LoadBalancer.Servers {
+				if host := addressHost(server.Address); host != "" {
+					hosts[host] = true
+				}
+			}
+		}
+	}
+	return hosts
+}
+
+// addressHost returns the host of a TCP/UDP server address, or the address itself
+// when it has no port.
+func addressHost(address string) string {
+	address = strings.TrimSpace(address)
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+// IsReferenced reports whether a container appears in hosts, either by short ID or by
+// its compose DNS name.
+func IsReferenced(hosts map[string]bool, id string, labels map[string]string, serviceLabel string) bool {
+	if hosts[configio.ShortID(id)] {
+		return true
+	}
+	name := ComposeDNSName(labels, serviceLabel)
+	return name != "" && hosts[name]
+}
+
+// RoutesService reports whether service sets traefik.enable=true in the compose files,
+// so its containers are expected in the dynamic config.
+func RoutesService(files []string, envFiles []string, service string) (bool, error) {
+	flags, err := collectTraefikEnableFlags(files, envFiles)
+	if err != nil {
+		return false, err
+	}
+	return flags[service], nil
+}
@@ -0,0 +1,49 @@
+package traefik
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/compose"
+)
+
+func TestReferencedHosts_CollectsHTTPAndTCPServers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := "http:\n  services:\n    api:\n      loadBalancer:\n        servers:\n          - url: https://aaaaaaaaaaaa:8443\n          - url: http://shop-api-2:80\n" +
+		"tcp:\n  services:\n    db:\n      loadBalancer:\n        servers:\n          - address: bbbbbbbbbbbb:5432\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	hosts, err := ReferencedHosts(path)
+	if err != nil {
+		t.Fatalf("read hosts: %v", err)
+	}
+	for _, host := range []string{"aaaaaaaaaaaa", "shop-api-2", "bbbbbbbbbbbb"} {
+		if !hosts[host] {
+			t.Fatalf("expected %s in hosts, got %v", host, hosts)
+		}
+	}
+
+	if !IsReferenced(hosts, "aaaaaaaaaaaa1111", nil, "") {
+		t.Fatalf("expected container to be referenced by short ID")
+	}
+	labels := map[string]string{composeProjectLabel: "shop", compose.DefaultServiceLabel: "api", composeContainerNumberLabel: "2"}
+	if !IsReferenced(hosts, "cccccccccccc", labels, "") {
+		t.Fatalf("expected container to be referenced by DNS name")
+	}
+	if IsReferenced(hosts, "dddddddddddd", nil, "") {
+		t.Fatalf("expected unknown container not to be referenced")
+	}
+}
+
+func TestReferencedHosts_MissingFile(t *testing.T) {
+	hosts, err := ReferencedHosts(filepath.Join(t.TempDir(), "missing.yml"))
+	if err != nil {
+		t.Fatalf("expected missing config to be empty, got %v", err)
+	}
+	if len(hosts) != 0 {
+		t.Fatalf("expected no hosts, got %v", hosts)
+	}
+}
@@ -11,7 +11,7 @@ func newTCPRouter(rule string, service string, entryPoints []string, tlsEnabled
 		router.EntryPoints = append([]string{}, entryPoints...)
 	}
 	if tlsEnabled {
-		router.TLS = &struct{}{}
+		router.TLS = &types.TCPRouterTLS{}
 	}
 	return router
 }
@@ -0,0 +1,100 @@
+package traefik
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/types"
+)
+
+// LabelProtocol set to tcp or udp on a service routed only through its
+// traefik.tcp.*/traefik.udp.* routers leaves out the HTTP router and service that are
+// otherwise generated for every Traefik service.
+const LabelProtocol = "com.ztd.protocol"
+
+type udpRouterMeta struct {
+	RouterName    string
+	RouterService string
+	BackendPort   string
+	EntryPoints   []string
+}
+
+func collectUDPRouterMeta(labels map[string]string) []udpRouterMeta {
+	names := udpRouterNames(labels)
+	routers := make([]udpRouterMeta, 0, len(names))
+	for _, name := range names {
+		routerService := labels["traefik.udp.routers."+name+".service"]
+		if routerService == "" {
+			routerService = name
+		}
+		port := labels["traefik.udp.services."+routerService+".loadbalancer.server.port"]
+		if port == "" {
+			continue
+		}
+		routers = append(routers, udpRouterMeta{
+			RouterName:    name,
+			RouterService: routerService,
+			BackendPort:   port,
+			EntryPoints:   splitEntryPoints(labels["traefik.udp.routers."+name+".entrypoints"]),
+		})
+	}
+	return routers
+}
+
+func udpRouterNames(labels map[string]string) []string {
+	set := map[string]struct{}{}
+	for key := range labels {
+		if strings.HasPrefix(key, "traefik.udp.routers.") {
+			parts := strings.Split(key, ".")
+			if len(parts) >= 4 {
+				set[parts[3]] = struct{}{}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(set))
+	for n := range set {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newUDPRouter(service string, entryPoints []string) types.UDPRouter {
+	router := types.UDPRouter{Service: service}
+	if len(entryPoints) > 0 {
+		router.EntryPoints = append([]string{}, entryPoints...)
+	}
+	return router
+}
+
+func udpService(endpoints []serverEndpoint, port string) types.UDPService {
+	servers := make([]types.UDPServer, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		servers = append(servers, types.UDPServer{Address: endpoint.host + ":" + port})
+	}
+	return types.UDPService{LoadBalancer: &types.UDPLoadBalancer{Servers: servers}}
+}
+
+// servesHTTP reports whether the HTTP router and service are generated for a service:
+// always, unless LabelProtocol restricts it to tcp or udp.
+func servesHTTP(labels map[string]string) bool {
+	switch strings.ToLower(strings.TrimSpace(labels[LabelProtocol])) {
+	case "tcp", "udp":
+		return false
+	default:
+		return true
+	}
+}
+
+func ensureUDPConfig(cfg *types.DynamicConfig) {
+	if cfg.UDP == nil {
+		cfg.UDP = &types.UDPConfig{}
+	}
+	if cfg.UDP.Routers == nil {
+		cfg.UDP.Routers = map[string]types.UDPRouter{}
+	}
+	if cfg.UDP.Services == nil {
+		cfg.UDP.Services = map[string]types.UDPService{}
+	}
+}
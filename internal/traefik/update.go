@@ -2,36 +2,199 @@ package traefik
 
 import (
 	"os"
+	"strconv"
 	"strings"
 
+	"gopkg.in/yaml.v3"
+
 	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
 )
 
-func UpdateContainerIDsInConfig(path string, oldIDs []string, newIDs []string) error {
+// UpdateContainerIDsInConfig replaces the servers of the old containers with servers
+// for the new ones in the dynamic config and returns how many old servers were
+// replaced. Zero means no server matched. weights holds the com.ztd.weight of each new
+// container, 0 when it sets none.
+func UpdateContainerIDsInConfig(path string, oldIDs []string, newIDs []string, weights []int) (int, error) {
+	return replaceServerHosts(path, shortIDs(oldIDs), shortIDs(newIDs), weights)
+}
+
+// replaceServerHosts swaps server sets rather than pairing hosts by index: every load
+// balancer with a server on one of oldHosts drops all of those servers and gets one
+// server per new host instead, copied from the first old server with only its host
+// changed, so its scheme, port, path and other keys carry over. The weight of an HTTP
+// server is not copied but taken from weights, by new host index. The old and new
+// containers may differ in number, e.g. after a scale-down.
+//
+// Only the servers lists are edited in the parsed YAML tree, so the rest of the file,
+// including keys ztd does not model, comments and key order, is written back as it was.
+func replaceServerHosts(path string, oldHosts []string, newHosts []string, weights []int) (int, error) {
+	configio.Mu.Lock()
+	defer configio.Mu.Unlock()
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return 0, err
+	}
+	if len(doc.Content) == 0 {
+		return 0, nil
+	}
+
+	old := make(map[string]struct{}, len(oldHosts))
+	for _, host := range oldHosts {
+		old[host] = struct{}{}
+	}
+	replaced := 0
+	for _, section := range []struct{ name, key string }{{"http", "url"}, {"tcp", "address"}, {"udp", "address"}} {
+		services := mappingValue(mappingValue(doc.Content[0], section.name), "services")
+		if services == nil || services.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 1; i < len(services.Content); i += 2 {
+			servers := mappingValue(mappingValue(services.Content[i], "loadBalancer"), "servers")
+			if servers == nil || servers.Kind != yaml.SequenceNode {
+				continue
+			}
+			replaced += replaceServers(servers, section.key, old, newHosts, weights)
+		}
+	}
+	if replaced == 0 {
+		return 0, nil
+	}
+
+	out, err := configio.MarshalYAML(&doc)
+	if err != nil {
+		return 0, err
+	}
+	return replaced, configio.WriteAtomic(path, out, 0o644)
+}
+
+// replaceServers removes every server of the servers sequence whose key (url or
+// address) is on an old host and puts copies of the first of them, one per new host,
+// in its place. HTTP servers (keyed by url) get the weight of their new host. It
+// returns how many servers were removed.
+func replaceServers(servers *yaml.Node, key string, old map[string]struct{}, newHosts []string, weights []int) int {
+	kept := map[string]struct{}{}
+	for _, s := range servers.Content {
+		if addr := mappingValue(s, key); addr != nil {
+			if _, host, _ := splitServer(addr.Value); !isOld(old, host) {
+				kept[host] = struct{}{}
+			}
+		}
 	}
-	content := string(data)
 
-	max := len(oldIDs)
-	if len(newIDs) < max {
-		max = len(newIDs)
+	out := make([]*yaml.Node, 0, len(servers.Content)+len(newHosts))
+	replaced := 0
+	for _, s := range servers.Content {
+		addr := mappingValue(s, key)
+		if addr == nil {
+			out = append(out, s)
+			continue
+		}
+		prefix, host, suffix := splitServer(addr.Value)
+		if !isOld(old, host) {
+			out = append(out, s)
+			continue
+		}
+		replaced++
+		if replaced > 1 {
+			continue
+		}
+		for i, newHost := range newHosts {
+			if _, dup := kept[newHost]; dup {
+				continue
+			}
+			kept[newHost] = struct{}{}
+			c := copyNode(s)
+			mappingValue(c, key).Value = prefix + newHost + suffix
+			if key == "url" {
+				weight := 0
+				if i < len(weights) {
+					weight = weights[i]
+				}
+				setWeight(c, weight)
+			}
+			out = append(out, c)
+		}
 	}
+	if replaced > 0 {
+		servers.Content = out
+	}
+	return replaced
+}
+
+// mappingValue returns the value of key in the mapping node n, or nil when n is not a
+// mapping or has no such key.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
 
-	for i := 0; i < max; i++ {
-		oldShort := shortID(oldIDs[i])
-		newShort := shortID(newIDs[i])
-		content = strings.ReplaceAll(content, oldShort, newShort)
+// copyNode deep-copies n, so a copied server can be edited without changing the one
+// it was copied from.
+// setWeight sets the weight key of the server mapping n, or removes it when weight is 0
+// so Traefik applies its default.
+func setWeight(n *yaml.Node, weight int) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value != "weight" {
+			continue
+		}
+		if weight == 0 {
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+		} else {
+			n.Content[i+1].Value = strconv.Itoa(weight)
+		}
+		return
 	}
+	if weight > 0 {
+		n.Content = append(n.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "weight"},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(weight)})
+	}
+}
 
-	return configio.WriteAtomic(path, []byte(content), 0o644)
+func copyNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = copyNode(child)
+	}
+	return &c
+}
+
+func isOld(old map[string]struct{}, host string) bool {
+	_, ok := old[host]
+	return ok
+}
+
+// splitServer splits a server URL (http://host:80/path) or TCP/UDP address (host:5222)
+// around its host.
+func splitServer(server string) (string, string, string) {
+	prefix := ""
+	rest := server
+	if i := strings.Index(rest, "://"); i >= 0 {
+		prefix, rest = rest[:i+3], rest[i+3:]
+	}
+	end := strings.IndexAny(rest, ":/")
+	if end < 0 {
+		end = len(rest)
+	}
+	return prefix, rest[:end], rest[end:]
 }
 
-func shortID(id string) string {
-	id = strings.TrimSpace(id)
-	if len(id) > 12 {
-		return id[:12]
+func shortIDs(ids []string) []string {
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, configio.ShortID(id))
 	}
-	return id
+	return out
 }
@@ -0,0 +1,195 @@
+package traefik
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateContainerIDsInConfig_ReportsReplacements(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := "http:\n  services:\n    api:\n      loadBalancer:\n        servers:\n          - url: http://aaaaaaaaaaaa:80\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	replaced, err := UpdateContainerIDsInConfig(path, []string{"aaaaaaaaaaaa1111"}, []string{"bbbbbbbbbbbb2222"}, nil)
+	if err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	if replaced != 1 {
+		t.Fatalf("expected 1 replacement, got %d", replaced)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), "bbbbbbbbbbbb:80") {
+		t.Fatalf("expected new container in config:\n%s", data)
+	}
+
+	replaced, err = UpdateContainerIDsInConfig(path, []string{"cccccccccccc"}, []string{"dddddddddddd"}, nil)
+	if err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	if replaced != 0 {
+		t.Fatalf("expected no replacements, got %d", replaced)
+	}
+}
+
+func TestUpdateContainerIDsInConfig_KeepsScheme(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := "http:\n  services:\n    api:\n      loadBalancer:\n        servers:\n          - url: https://aaaaaaaaaaaa:8443\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if _, err := UpdateContainerIDsInConfig(path, []string{"aaaaaaaaaaaa1111"}, []string{"bbbbbbbbbbbb2222"}, nil); err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	cfg, err := readDynamicConfig(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if got := cfg.HTTP.Services["api"].LoadBalancer.Servers[0].URL; got != "https://bbbbbbbbbbbb:8443" {
+		t.Fatalf("expected https server url, got %q", got)
+	}
+}
+
+func TestUpdateContainerIDsInConfig_UnequalCounts(t *testing.T) {
+	content := `http:
+  services:
+    api:
+      loadBalancer:
+        servers:
+          - url: http://aaaaaaaaaaaa:8080
+          - url: http://bbbbbbbbbbbb:8080
+          - url: http://cccccccccccc:8080
+    web:
+      loadBalancer:
+        servers:
+          - url: http://ffffffffffff:80
+`
+	tests := []struct {
+		name   string
+		oldIDs []string
+		newIDs []string
+		want   []string
+	}{
+		{
+			name:   "fewer new containers",
+			oldIDs: []string{"aaaaaaaaaaaa1111", "bbbbbbbbbbbb2222", "cccccccccccc3333"},
+			newIDs: []string{"dddddddddddd4444"},
+			want:   []string{"http://dddddddddddd:8080"},
+		},
+		{
+			name:   "more new containers",
+			oldIDs: []string{"aaaaaaaaaaaa1111", "bbbbbbbbbbbb2222", "cccccccccccc3333"},
+			newIDs: []string{"dddddddddddd4444", "eeeeeeeeeeee5555", "111111111111", "222222222222"},
+			want:   []string{"http://dddddddddddd:8080", "http://eeeeeeeeeeee:8080", "http://111111111111:8080", "http://222222222222:8080"},
+		},
+		{
+			name:   "partial batch keeps the other servers",
+			oldIDs: []string{"bbbbbbbbbbbb2222"},
+			newIDs: []string{"dddddddddddd4444", "eeeeeeeeeeee5555"},
+			want:   []string{"http://aaaaaaaaaaaa:8080", "http://dddddddddddd:8080", "http://eeeeeeeeeeee:8080", "http://cccccccccccc:8080"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			replaced, err := UpdateContainerIDsInConfig(path, tt.oldIDs, tt.newIDs, nil)
+			if err != nil {
+				t.Fatalf("update config: %v", err)
+			}
+			if replaced != len(tt.oldIDs) {
+				t.Fatalf("expected %d replacements, got %d", len(tt.oldIDs), replaced)
+			}
+			cfg, err := readDynamicConfig(path)
+			if err != nil {
+				t.Fatalf("read config: %v", err)
+			}
+			var got []string
+			for _, server := range cfg.HTTP.Services["api"].LoadBalancer.Servers {
+				got = append(got, server.URL)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("api servers = %v, want %v", got, tt.want)
+			}
+			if web := cfg.HTTP.Services["web"].LoadBalancer.Servers; len(web) != 1 || web[0].URL != "http://ffffffffffff:80" {
+				t.Fatalf("expected other services untouched, got %#v", web)
+			}
+		})
+	}
+}
+
+func TestUpdateContainerIDsInConfig_WeightsFromNewContainers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := "http:\n  services:\n    api:\n      loadBalancer:\n        servers:\n          - url: http://aaaaaaaaaaaa:80\n            weight: 3\n          - url: http://bbbbbbbbbbbb:80\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if _, err := UpdateContainerIDsInConfig(path, []string{"aaaaaaaaaaaa1111"}, []string{"cccccccccccc3333", "dddddddddddd4444"}, []int{5, 0}); err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	cfg, err := readDynamicConfig(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	servers := cfg.HTTP.Services["api"].LoadBalancer.Servers
+	if len(servers) != 3 || servers[0].Weight != 5 || servers[1].Weight != 0 || servers[2].URL != "http://bbbbbbbbbbbb:80" || servers[2].Weight != 0 {
+		t.Fatalf("expected new servers to take the weight of their own container, got %#v", servers)
+	}
+
+	if _, err := UpdateContainerIDsInConfig(path, []string{"bbbbbbbbbbbb"}, []string{"eeeeeeeeeeee5555"}, []int{2}); err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	if cfg, err = readDynamicConfig(path); err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	servers = cfg.HTTP.Services["api"].LoadBalancer.Servers
+	if len(servers) != 3 || servers[2].URL != "http://eeeeeeeeeeee:80" || servers[2].Weight != 2 {
+		t.Fatalf("expected a weight added to a server that had none, got %#v", servers)
+	}
+}
+
+func TestUpdateContainerIDsInConfig_KeepsRestOfFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dynamic_conf.yml")
+	content := `# managed by hand
+tls:
+    options:
+        modern:
+            minVersion: VersionTLS13
+http:
+    serversTransports:
+        insecure:
+            insecureSkipVerify: true
+    services:
+        api:
+            loadBalancer:
+                passHostHeader: false # keep the upstream host
+                servers:
+                    - url: http://aaaaaaaaaaaa:80/v1
+                      preservePath: true
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if _, err := UpdateContainerIDsInConfig(path, []string{"aaaaaaaaaaaa1111"}, []string{"bbbbbbbbbbbb2222"}, nil); err != nil {
+		t.Fatalf("update config: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	want := strings.Replace(content, "aaaaaaaaaaaa", "bbbbbbbbbbbb", 1)
+	if string(data) != want {
+		t.Fatalf("expected only the server host to change, got:\n%s", data)
+	}
+}
@@ -0,0 +1,60 @@
+package traefik
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ku9nov/docker-compose-ztd-plugin/internal/configio"
+)
+
+// LabelServerWeight sets the Traefik load balancer weight of a container's server, for
+// replicas on unequal hardware. Containers without it get Traefik's default of 1.
+const LabelServerWeight = "com.ztd.weight"
+
+// LabelServerWeightAlias is the short name of LabelServerWeight, like the ztd.* names of
+// the option labels; com.ztd.weight wins when a container sets both.
+const LabelServerWeightAlias = "ztd.weight"
+
+// serverEndpoint is where Traefik reaches one container, with its server weight; a
+// zero weight is left out of the config.
+type serverEndpoint struct {
+	host   string
+	weight int
+}
+
+func (g *Generator) serverEndpoints(ctx context.Context, ids []string) ([]serverEndpoint, error) {
+	endpoints := make([]serverEndpoint, 0, len(ids))
+	for _, id := range ids {
+		labels, err := g.docker.Labels(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		weight, err := ServerWeight(labels)
+		if err != nil {
+			return nil, fmt.Errorf("container %s: %w", configio.ShortID(id), err)
+		}
+		endpoints = append(endpoints, serverEndpoint{host: ServerHost(id, labels, g.serviceLabel, g.dnsNames), weight: weight})
+	}
+	return endpoints, nil
+}
+
+// ServerWeight returns the com.ztd.weight (or ztd.weight) label as a positive integer,
+// or 0 when neither label is set.
+func ServerWeight(labels map[string]string) (int, error) {
+	label := LabelServerWeight
+	raw := strings.TrimSpace(labels[label])
+	if raw == "" {
+		label = LabelServerWeightAlias
+		raw = strings.TrimSpace(labels[label])
+	}
+	if raw == "" {
+		return 0, nil
+	}
+	weight, err := strconv.Atoi(raw)
+	if err != nil || weight < 1 {
+		return 0, fmt.Errorf("invalid %s label %q: must be a positive integer", label, raw)
+	}
+	return weight, nil
+}
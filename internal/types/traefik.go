@@ -1,42 +1,90 @@
 package types
 
+// Extra holds the keys of a Traefik dynamic config level that these types do not
+// model, such as the top-level tls block or http.serversTransports, so a config read
+// and written back keeps them.
+type Extra map[string]any
+
 type DynamicConfig struct {
-	HTTP *HTTPConfig `yaml:"http,omitempty"`
-	TCP  *TCPConfig  `yaml:"tcp,omitempty"`
+	HTTP  *HTTPConfig `yaml:"http,omitempty"`
+	TCP   *TCPConfig  `yaml:"tcp,omitempty"`
+	UDP   *UDPConfig  `yaml:"udp,omitempty"`
+	Extra Extra       `yaml:",inline"`
 }
 
 type HTTPConfig struct {
-	Routers  map[string]HTTPRouter  `yaml:"routers,omitempty"`
-	Services map[string]HTTPService `yaml:"services,omitempty"`
+	Routers     map[string]HTTPRouter     `yaml:"routers,omitempty"`
+	Services    map[string]HTTPService    `yaml:"services,omitempty"`
+	Middlewares map[string]HTTPMiddleware `yaml:"middlewares,omitempty"`
+	Extra       Extra                     `yaml:",inline"`
 }
 
 type HTTPRouter struct {
-	Rule     string `yaml:"rule,omitempty"`
-	Service  string `yaml:"service,omitempty"`
-	Priority int    `yaml:"priority,omitempty"`
+	EntryPoints []string       `yaml:"entryPoints,omitempty"`
+	Rule        string         `yaml:"rule,omitempty"`
+	Service     string         `yaml:"service,omitempty"`
+	Priority    int            `yaml:"priority,omitempty"`
+	Middlewares []string       `yaml:"middlewares,omitempty"`
+	TLS         *HTTPRouterTLS `yaml:"tls,omitempty"`
+	Extra       Extra          `yaml:",inline"`
+}
+
+// HTTPRouterTLS is emitted as `tls: {}` when TLS is enabled without sub-options.
+type HTTPRouterTLS struct {
+	Options      string `yaml:"options,omitempty"`
+	CertResolver string `yaml:"certResolver,omitempty"`
+	Extra        Extra  `yaml:",inline"`
 }
 
+// HTTPMiddleware is passed through verbatim, keyed by middleware type (headers, chain, ...).
+type HTTPMiddleware map[string]any
+
 type HTTPService struct {
 	LoadBalancer *HTTPLoadBalancer  `yaml:"loadBalancer,omitempty"`
 	Weighted     *HTTPWeightedRoute `yaml:"weighted,omitempty"`
+	Extra        Extra              `yaml:",inline"`
 }
 
 type HTTPLoadBalancer struct {
 	Servers     []HTTPServer  `yaml:"servers,omitempty"`
+	Sticky      *Sticky       `yaml:"sticky,omitempty"`
 	HealthCheck *HealthChecks `yaml:"healthCheck,omitempty"`
+	Extra       Extra         `yaml:",inline"`
+}
+
+// Sticky pins clients to one server with a cookie. A nil Cookie is not sticky.
+type Sticky struct {
+	Cookie *StickyCookie `yaml:"cookie,omitempty"`
+	Extra  Extra         `yaml:",inline"`
+}
+
+// StickyCookie is emitted as `cookie: {}` when Traefik's default cookie is used.
+type StickyCookie struct {
+	Name     string `yaml:"name,omitempty"`
+	Secure   bool   `yaml:"secure,omitempty"`
+	HTTPOnly bool   `yaml:"httpOnly,omitempty"`
+	SameSite string `yaml:"sameSite,omitempty"`
+	MaxAge   int    `yaml:"maxAge,omitempty"`
+	Extra    Extra  `yaml:",inline"`
 }
 
 type HTTPWeightedRoute struct {
 	Services []HTTPWeightedService `yaml:"services,omitempty"`
+	Extra    Extra                 `yaml:",inline"`
 }
 
 type HTTPWeightedService struct {
 	Name   string `yaml:"name,omitempty"`
 	Weight int    `yaml:"weight,omitempty"`
+	Extra  Extra  `yaml:",inline"`
 }
 
+// HTTPServer is one backend of a load balancer. A zero Weight is omitted, which
+// Traefik treats as 1.
 type HTTPServer struct {
-	URL string `yaml:"url,omitempty"`
+	URL    string `yaml:"url,omitempty"`
+	Weight int    `yaml:"weight,omitempty"`
+	Extra  Extra  `yaml:",inline"`
 }
 
 type HealthChecks struct {
@@ -51,38 +99,81 @@ type HealthChecks struct {
 	Method          string            `yaml:"method,omitempty"`
 	Status          string            `yaml:"status,omitempty"`
 	Headers         map[string]string `yaml:"headers,omitempty"`
+	Extra           Extra             `yaml:",inline"`
 }
 
 type TCPConfig struct {
 	Routers  map[string]TCPRouter  `yaml:"routers,omitempty"`
 	Services map[string]TCPService `yaml:"services,omitempty"`
+	Extra    Extra                 `yaml:",inline"`
 }
 
 type TCPRouter struct {
-	Rule        string    `yaml:"rule,omitempty"`
-	Service     string    `yaml:"service,omitempty"`
-	EntryPoints []string  `yaml:"entryPoints,omitempty"`
-	TLS         *struct{} `yaml:"tls,omitempty"`
+	Rule        string        `yaml:"rule,omitempty"`
+	Service     string        `yaml:"service,omitempty"`
+	EntryPoints []string      `yaml:"entryPoints,omitempty"`
+	TLS         *TCPRouterTLS `yaml:"tls,omitempty"`
+	Extra       Extra         `yaml:",inline"`
+}
+
+// TCPRouterTLS is emitted as `tls: {}` when TLS is enabled without sub-options, such
+// as passthrough, which are kept as they were read.
+type TCPRouterTLS struct {
+	Extra Extra `yaml:",inline"`
 }
 
 type TCPService struct {
 	LoadBalancer *TCPLoadBalancer  `yaml:"loadBalancer,omitempty"`
 	Weighted     *TCPWeightedRoute `yaml:"weighted,omitempty"`
+	Extra        Extra             `yaml:",inline"`
 }
 
 type TCPLoadBalancer struct {
 	Servers []TCPServer `yaml:"servers,omitempty"`
+	Extra   Extra       `yaml:",inline"`
 }
 
 type TCPWeightedRoute struct {
 	Services []TCPWeightedService `yaml:"services,omitempty"`
+	Extra    Extra                `yaml:",inline"`
 }
 
 type TCPWeightedService struct {
 	Name   string `yaml:"name,omitempty"`
 	Weight int    `yaml:"weight,omitempty"`
+	Extra  Extra  `yaml:",inline"`
 }
 
 type TCPServer struct {
 	Address string `yaml:"address,omitempty"`
+	Extra   Extra  `yaml:",inline"`
+}
+
+// UDPConfig holds UDP routers, which have no rule: a UDP entry point forwards all of
+// its datagrams to the router's service.
+type UDPConfig struct {
+	Routers  map[string]UDPRouter  `yaml:"routers,omitempty"`
+	Services map[string]UDPService `yaml:"services,omitempty"`
+	Extra    Extra                 `yaml:",inline"`
+}
+
+type UDPRouter struct {
+	EntryPoints []string `yaml:"entryPoints,omitempty"`
+	Service     string   `yaml:"service,omitempty"`
+	Extra       Extra    `yaml:",inline"`
+}
+
+type UDPService struct {
+	LoadBalancer *UDPLoadBalancer `yaml:"loadBalancer,omitempty"`
+	Extra        Extra            `yaml:",inline"`
+}
+
+type UDPLoadBalancer struct {
+	Servers []UDPServer `yaml:"servers,omitempty"`
+	Extra   Extra       `yaml:",inline"`
+}
+
+type UDPServer struct {
+	Address string `yaml:"address,omitempty"`
+	Extra   Extra  `yaml:",inline"`
 }